@@ -12,6 +12,7 @@ import (
 	jokadb "github.com/apsdsm/joka/db"
 	entityapp "github.com/apsdsm/joka/internal/domains/entity/app"
 	lockinfra "github.com/apsdsm/joka/internal/domains/lock/infra"
+	migrationdomain "github.com/apsdsm/joka/internal/domains/migration/domain"
 	templateinfra "github.com/apsdsm/joka/internal/domains/template/infra"
 	"github.com/fatih/color"
 )
@@ -21,8 +22,8 @@ import (
 // confirms once for the whole flow.
 type RunResetCommand struct {
 	DB *sql.DB
-	// Secrets resolves {{ asm.<source>.<key> }} entity template references
-	// against the `secrets:` sources in .jokarc.yaml.
+	// Secrets resolves {{ asm.<source>.<key> }} references in entity and data
+	// sync files against the `secrets:` sources in .jokarc.yaml.
 	Secrets           entityapp.SecretResolver
 	Driver            jokadb.Driver
 	MigrationsDir     string
@@ -30,8 +31,18 @@ type RunResetCommand struct {
 	EntitiesDir       string
 	Tables            []templateinfra.TableConfig
 	IgnoreForeignKeys bool
+	BatchSize         int
 	AutoConfirm       bool
 	OutputFormat      string
+	// Timeouts are the session timeouts passed to migrate up, data sync and
+	// entity sync.
+	Timeouts jokadb.Timeouts
+	// Profile is the selected .jokarc.yaml profile, used by migrate up to
+	// evaluate `-- joka:if profile=...` blocks.
+	Profile string
+	// Snapshots and SnapshotRetention are passed to migrate up.
+	Snapshots         migrationdomain.SnapshotOptions
+	SnapshotRetention int
 }
 
 func (r RunResetCommand) Execute(ctx context.Context) error {
@@ -102,12 +113,16 @@ func (r RunResetCommand) Execute(ctx context.Context) error {
 		color.Cyan("\n[3/5] Applying migrations...")
 	}
 	if err := (migration.RunMigrateUpCommand{
-		DB:            r.DB,
-		Driver:        r.Driver,
-		MigrationsDir: r.MigrationsDir,
-		AutoConfirm:   true,
-		OutputFormat:  "text",
-		SkipLock:      true,
+		DB:                r.DB,
+		Driver:            r.Driver,
+		MigrationsDir:     r.MigrationsDir,
+		AutoConfirm:       true,
+		OutputFormat:      "text",
+		SkipLock:          true,
+		Timeouts:          r.Timeouts,
+		Profile:           r.Profile,
+		Snapshots:         r.Snapshots,
+		SnapshotRetention: r.SnapshotRetention,
 	}).Execute(ctx); err != nil {
 		if jsonOut {
 			return shared.PrintErrorJSON(fmt.Errorf("migrate up: %w", err))
@@ -126,8 +141,11 @@ func (r RunResetCommand) Execute(ctx context.Context) error {
 		Tables:            r.Tables,
 		AutoConfirm:       true,
 		IgnoreForeignKeys: r.IgnoreForeignKeys,
+		BatchSize:         r.BatchSize,
 		OutputFormat:      "text",
 		SkipLock:          true,
+		Timeouts:          r.Timeouts,
+		Secrets:           r.Secrets,
 	}).Execute(ctx); err != nil {
 		if jsonOut {
 			return shared.PrintErrorJSON(fmt.Errorf("data sync: %w", err))
@@ -147,6 +165,7 @@ func (r RunResetCommand) Execute(ctx context.Context) error {
 		AutoConfirm:  true,
 		OutputFormat: "text",
 		SkipLock:     true,
+		Timeouts:     r.Timeouts,
 	}).Execute(ctx); err != nil {
 		if jsonOut {
 			return shared.PrintErrorJSON(fmt.Errorf("entity sync: %w", err))
@@ -0,0 +1,367 @@
+package dbtools
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apsdsm/joka/cmd/shared"
+	jokadb "github.com/apsdsm/joka/db"
+	entityapp "github.com/apsdsm/joka/internal/domains/entity/app"
+	entitydomain "github.com/apsdsm/joka/internal/domains/entity/domain"
+	entityinfra "github.com/apsdsm/joka/internal/domains/entity/infra"
+	migrationapp "github.com/apsdsm/joka/internal/domains/migration/app"
+	migrationdomain "github.com/apsdsm/joka/internal/domains/migration/domain"
+	migrationinfra "github.com/apsdsm/joka/internal/domains/migration/infra"
+	templateapp "github.com/apsdsm/joka/internal/domains/template/app"
+	templatedomain "github.com/apsdsm/joka/internal/domains/template/domain"
+	templateinfra "github.com/apsdsm/joka/internal/domains/template/infra"
+	"github.com/fatih/color"
+)
+
+// StatusEnvironment is one environment "joka status" reports on, usually a
+// .jokarc.yaml profile.
+type StatusEnvironment struct {
+	Name string
+	// ResolveDSN resolves the environment's connection. It runs under the
+	// environment's timeout, since it may call a secret provider.
+	ResolveDSN    func(ctx context.Context) (string, error)
+	MigrationsDir string
+	TemplatesDir  string
+	EntitiesDir   string
+	Tables        []templateinfra.TableConfig
+}
+
+// RunStatusCommand handles "status". It checks every environment
+// concurrently, each within Timeout, and prints a matrix of migration index
+// by environment followed by an entity and data sync summary. An environment
+// that can't be reached or read is reported as such; the others are still
+// shown.
+type RunStatusCommand struct {
+	Environments []StatusEnvironment
+	Timeout      time.Duration
+	OutputFormat string
+}
+
+// environmentStatus is what was found in one environment.
+type environmentStatus struct {
+	Name        string            `json:"name"`
+	Error       string            `json:"error,omitempty"`
+	LastApplied string            `json:"last_applied"`
+	Pending     int               `json:"pending"`
+	Migrations  map[string]string `json:"migrations"`         // index -> status
+	Entities    map[string]int    `json:"entities,omitempty"` // file status -> count
+	Data        map[string]int    `json:"data,omitempty"`     // table status -> count
+}
+
+func (r RunStatusCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+
+	statuses := make([]environmentStatus, len(r.Environments))
+	var wg sync.WaitGroup
+	for i, env := range r.Environments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = r.check(ctx, env)
+		}()
+	}
+	wg.Wait()
+
+	indexes := migrationIndexes(statuses)
+
+	if jsonOut {
+		shared.PrintJSON(map[string]any{"status": "ok", "migrations": indexes, "environments": statuses})
+		return nil
+	}
+
+	printMigrationMatrix(statuses, indexes)
+	printSyncSummary(statuses)
+
+	for _, s := range statuses {
+		if s.Error != "" {
+			color.Red("%s is unreachable: %s", s.Name, s.Error)
+		}
+	}
+	return nil
+}
+
+// check reads one environment, giving up after r.Timeout. The work runs in
+// its own goroutine because opening a connection doesn't honour ctx; a
+// connection that outlives the timeout is closed when it finishes.
+func (r RunStatusCommand) check(ctx context.Context, env StatusEnvironment) environmentStatus {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	done := make(chan environmentStatus, 1)
+	go func() {
+		status := environmentStatus{Name: env.Name}
+		if err := readEnvironment(ctx, env, &status); err != nil {
+			status = environmentStatus{Name: env.Name, Error: err.Error()}
+		}
+		done <- status
+	}()
+
+	select {
+	case status := <-done:
+		return status
+	case <-ctx.Done():
+		return environmentStatus{Name: env.Name, Error: fmt.Sprintf("timed out after %s", r.Timeout)}
+	}
+}
+
+func readEnvironment(ctx context.Context, env StatusEnvironment, status *environmentStatus) error {
+	dsn, err := env.ResolveDSN(ctx)
+	if err != nil {
+		return err
+	}
+	conn, driver, err := jokadb.Open(dsn)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer conn.Close()
+
+	if err := readMigrations(ctx, env, conn, driver, status); err != nil {
+		return err
+	}
+	if err := readEntities(ctx, env, conn, driver, status); err != nil {
+		return fmt.Errorf("reading entity status: %w", err)
+	}
+	if err := readData(ctx, env, conn, driver, status); err != nil {
+		return fmt.Errorf("reading data status: %w", err)
+	}
+	return nil
+}
+
+func readMigrations(ctx context.Context, env StatusEnvironment, conn *sql.DB, driver jokadb.Driver, status *environmentStatus) error {
+	var adapter migrationapp.DBAdapter = migrationinfra.NewMySQLDBAdapter(conn)
+	if driver == jokadb.Postgres {
+		adapter = migrationinfra.NewPostgresDBAdapter(conn)
+	}
+
+	exists, err := adapter.HasMigrationsTable(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("migrations table does not exist")
+	}
+
+	chain, err := migrationapp.GetMigrationChainAction{DB: adapter, MigrationsDir: env.MigrationsDir}.Execute(ctx)
+	if err != nil {
+		return err
+	}
+	status.Migrations = make(map[string]string, len(chain))
+	for _, m := range chain {
+		status.Migrations[m.MigrationIndex] = m.Status
+		switch m.Status {
+		case migrationdomain.StatusApplied:
+			status.LastApplied = m.MigrationIndex
+		case migrationdomain.StatusPending:
+			status.Pending++
+		}
+	}
+	return nil
+}
+
+// readEntities counts entity files by sync status. It doesn't create the
+// tracking table: without one, every file is new. Environments without an
+// entities directory are skipped.
+func readEntities(ctx context.Context, env StatusEnvironment, conn *sql.DB, driver jokadb.Driver, status *environmentStatus) error {
+	if info, err := os.Stat(env.EntitiesDir); err != nil || !info.IsDir() {
+		return nil
+	}
+	files, err := entityinfra.DiscoverEntityFiles(env.EntitiesDir)
+	if err != nil {
+		return err
+	}
+
+	status.Entities = make(map[string]int)
+	tracked, err := jokadb.TableExists(ctx, conn, driver, "joka_entities")
+	if err != nil {
+		return err
+	}
+	if !tracked {
+		status.Entities[string(entitydomain.StatusNew)] = len(files)
+		return nil
+	}
+
+	var adapter entityapp.DBAdapter = entityinfra.NewMySQLDBAdapter(conn)
+	if driver == jokadb.Postgres {
+		adapter = entityinfra.NewPostgresDBAdapter(conn)
+	}
+	results, err := entityapp.EntityStatusAction{DB: adapter, EntitiesDir: env.EntitiesDir, Files: files}.Execute(ctx)
+	if err != nil {
+		return err
+	}
+	for _, info := range results {
+		status.Entities[string(info.Status)]++
+	}
+	return nil
+}
+
+// readData counts the configured template tables by sync status, from the
+// hashes in joka_templates. Like readEntities it doesn't create the tracking
+// table: without one, every table is new.
+func readData(ctx context.Context, env StatusEnvironment, conn *sql.DB, driver jokadb.Driver, status *environmentStatus) error {
+	if len(env.Tables) == 0 {
+		return nil
+	}
+	tables, err := templateinfra.GetTables(env.TemplatesDir, env.Tables)
+	if err != nil {
+		return err
+	}
+
+	status.Data = make(map[string]int)
+	tracked, err := jokadb.TableExists(ctx, conn, driver, "joka_templates")
+	if err != nil {
+		return err
+	}
+	if !tracked {
+		status.Data[string(templatedomain.StatusNew)] = len(tables)
+		return nil
+	}
+
+	var adapter templateapp.DBAdapter = templateinfra.NewMySQLDBAdapter(conn)
+	if driver == jokadb.Postgres {
+		adapter = templateinfra.NewPostgresDBAdapter(conn)
+	}
+	results, err := templateapp.TableStatusAction{DB: adapter, Tables: tables}.Execute(ctx)
+	if err != nil {
+		return err
+	}
+	for _, info := range results {
+		status.Data[string(info.Status)]++
+	}
+	return nil
+}
+
+// migrationIndexes returns every migration index seen in any environment, in
+// order.
+func migrationIndexes(statuses []environmentStatus) []string {
+	seen := make(map[string]bool)
+	indexes := []string{}
+	for _, s := range statuses {
+		for index := range s.Migrations {
+			if !seen[index] {
+				seen[index] = true
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	sort.Strings(indexes)
+	return indexes
+}
+
+func printMigrationMatrix(statuses []environmentStatus, indexes []string) {
+	widths := make([]int, len(statuses))
+	for i, s := range statuses {
+		widths[i] = max(len(s.Name), len("unreachable"), len(migrationdomain.StatusFileMissing))
+	}
+	indexWidth := len("Migration")
+	for _, index := range indexes {
+		indexWidth = max(indexWidth, len(index))
+	}
+
+	color.Set(color.Bold)
+	fmt.Printf("%-*s", indexWidth, "Migration")
+	for i, s := range statuses {
+		fmt.Printf("  %-*s", widths[i], s.Name)
+	}
+	fmt.Println()
+	color.Unset()
+
+	for _, index := range indexes {
+		fmt.Printf("%-*s", indexWidth, index)
+		for i, s := range statuses {
+			cell := s.Migrations[index]
+			switch {
+			case s.Error != "":
+				cell = "unreachable"
+			case cell == "":
+				cell = "-"
+			}
+			printCell(cell, widths[i])
+		}
+		fmt.Println()
+	}
+	if len(indexes) == 0 {
+		fmt.Println("No migrations found.")
+	}
+	fmt.Println()
+}
+
+func printCell(cell string, width int) {
+	padded := fmt.Sprintf("  %-*s", width, cell)
+	switch cell {
+	case migrationdomain.StatusApplied:
+		color.New(color.FgGreen).Print(padded)
+	case migrationdomain.StatusPending:
+		color.New(color.FgYellow).Print(padded)
+	case migrationdomain.StatusFileMissing, "unreachable":
+		color.New(color.FgRed).Print(padded)
+	default:
+		fmt.Print(padded)
+	}
+}
+
+func printSyncSummary(statuses []environmentStatus) {
+	color.Set(color.Bold)
+	fmt.Println("Sync summary:")
+	color.Unset()
+	for _, s := range statuses {
+		if s.Error != "" {
+			continue
+		}
+		fmt.Printf("  %s: last applied %s, %d pending; entities %s; data %s\n",
+			s.Name, orNone(s.LastApplied), s.Pending, entitySummary(s.Entities), dataSummary(s.Data))
+	}
+	fmt.Println()
+}
+
+// entitySummary is e.g. "3 synced, 1 modified".
+func entitySummary(counts map[string]int) string {
+	if counts == nil {
+		return "not configured"
+	}
+	var parts []string
+	for _, st := range []entitydomain.FileStatus{entitydomain.StatusSynced, entitydomain.StatusModified, entitydomain.StatusNew, entitydomain.StatusOrphaned} {
+		if n := counts[string(st)]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, st))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// dataSummary is e.g. "2 synced, 1 modified".
+func dataSummary(counts map[string]int) string {
+	if counts == nil {
+		return "not configured"
+	}
+	var parts []string
+	for _, st := range []templatedomain.TableStatus{templatedomain.StatusSynced, templatedomain.StatusModified, templatedomain.StatusNew, templatedomain.StatusOrphaned} {
+		if n := counts[string(st)]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, st))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orNone(index string) string {
+	if index == "" {
+		return "none"
+	}
+	return index
+}
@@ -36,6 +36,8 @@ type RunEntitySyncCommand struct {
 	// new files are still inserted as usual. The escape hatch for when change
 	// detection is in doubt.
 	Force bool
+	// Timeouts are the session timeouts applied to the sync transaction.
+	Timeouts jokadb.Timeouts
 }
 
 func (r RunEntitySyncCommand) Execute(ctx context.Context) error {
@@ -201,6 +203,10 @@ func (r RunEntitySyncCommand) Execute(ctx context.Context) error {
 
 		fmt.Println()
 
+		if settings := r.Timeouts.Settings(r.Driver); len(settings) > 0 {
+			fmt.Printf("Session timeouts: %s\n\n", shared.FormatTimeouts(settings))
+		}
+
 		if !r.AutoConfirm {
 			if !shared.Confirm("Proceed with entity sync? (only 'yes' will confirm): ") {
 				color.Yellow("Entity sync cancelled.")
@@ -209,13 +215,22 @@ func (r RunEntitySyncCommand) Execute(ctx context.Context) error {
 		}
 	}
 
-	tx, err := r.DB.BeginTx(ctx, nil)
+	tx, endTx, err := jokadb.BeginTx(ctx, r.DB, r.Driver, r.Timeouts)
 	if err != nil {
 		if jsonOut {
 			return shared.PrintErrorJSON(fmt.Errorf("starting transaction: %w", err))
 		}
 		return fmt.Errorf("starting transaction: %w", err)
 	}
+	defer endTx()
+
+	if err := jokadb.ApplyTimeouts(ctx, tx, r.Driver, r.Timeouts); err != nil {
+		tx.Rollback() //nolint:errcheck
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
 
 	txAdapter := newEntityTxAdapter(r.Driver, tx, r.DB)
 
@@ -250,7 +265,7 @@ func (r RunEntitySyncCommand) Execute(ctx context.Context) error {
 	}
 
 	if jsonOut {
-		shared.PrintJSON(map[string]any{"status": "ok", "synced": syncedPaths, "updated": updatedPaths, "forced": r.Force, "plan": planJSON(plan)})
+		shared.PrintJSON(map[string]any{"status": "ok", "synced": syncedPaths, "updated": updatedPaths, "forced": r.Force, "plan": planJSON(plan), "timeouts": r.Timeouts.Settings(r.Driver)})
 		return nil
 	}
 
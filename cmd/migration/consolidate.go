@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,13 +18,22 @@ import (
 	"github.com/apsdsm/joka/internal/domains/migration/infra"
 )
 
+// ErrConsolidationMismatch is returned by consolidate --validate when the
+// consolidated file, applied to a shadow database, does not reproduce the
+// snapshot it was generated from.
+var ErrConsolidationMismatch = errors.New("consolidated schema does not match snapshot")
+
 // RunConsolidateCommand handles "migrate consolidate --up-to <index>". It
 // replaces all migration files up to and including the target with a single
 // file containing the schema snapshot at that point, with tables ordered to
-// respect foreign key dependencies.
+// respect foreign key dependencies. With ShadowDSN set, the consolidated SQL
+// is first applied to that (recreated) shadow database and the originals are
+// only replaced if the result matches the snapshot.
 type RunConsolidateCommand struct {
 	DB            *sql.DB
 	Driver        jokadb.Driver
+	TargetDSN     string
+	ShadowDSN     string
 	MigrationsDir string
 	UpToIndex     string
 	AutoConfirm   bool
@@ -129,6 +139,10 @@ func (r RunConsolidateCommand) Execute(ctx context.Context) error {
 		fmt.Printf("  Target: %s\n", r.UpToIndex)
 		fmt.Printf("  Migrations to consolidate: %d\n", len(filesToDelete))
 		for _, m := range filesToDelete {
+			if _, err := os.Stat(infra.DownFilePath(r.MigrationsDir, filepath.Base(m.FileFullPath))); err == nil {
+				fmt.Printf("    - %s_%s.sql (and its down file)\n", m.MigrationIndex, m.FileName)
+				continue
+			}
 			fmt.Printf("    - %s_%s.sql\n", m.MigrationIndex, m.FileName)
 		}
 		fmt.Printf("  Tables in snapshot: %d\n", len(schema))
@@ -136,6 +150,11 @@ func (r RunConsolidateCommand) Execute(ctx context.Context) error {
 			fmt.Printf("    - %s\n", name)
 		}
 		fmt.Printf("  New file: %s\n", newFileName)
+		if r.ShadowDSN != "" {
+			if shadow, err := jokadb.ParseTarget(r.ShadowDSN); err == nil {
+				fmt.Printf("  Validate on shadow: %s (dropped and recreated)\n", shadow)
+			}
+		}
 		fmt.Println()
 	}
 
@@ -146,7 +165,39 @@ func (r RunConsolidateCommand) Execute(ctx context.Context) error {
 		}
 	}
 
-	// 6. Write the consolidated file.
+	// 6. Optionally prove the consolidated file reproduces the snapshot.
+	if r.ShadowDSN != "" {
+		diff, err := r.validate(ctx, consolidatedSQL, schema)
+		if err != nil {
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error: %v", err)
+			return err
+		}
+		if diff.HasDrift() {
+			if jsonOut {
+				shared.PrintJSON(map[string]any{
+					"status":          "error",
+					"error":           ErrConsolidationMismatch.Error(),
+					"migration_index": r.UpToIndex,
+					"added":           diff.Added,
+					"removed":         diff.Removed,
+					"modified":        diff.Modified,
+				})
+				return ErrConsolidationMismatch
+			}
+			color.Red("The consolidated file does not reproduce the snapshot at %s; no files were changed.", r.UpToIndex)
+			fmt.Println()
+			printSchemaDiff(diff, "snapshot", "shadow")
+			return ErrConsolidationMismatch
+		}
+		if !jsonOut {
+			color.Green("Validated: the consolidated file reproduces the snapshot at %s.", r.UpToIndex)
+		}
+	}
+
+	// 7. Write the consolidated file.
 	newFilePath := filepath.Join(r.MigrationsDir, newFileName)
 	if err := os.WriteFile(newFilePath, []byte(consolidatedSQL), 0644); err != nil {
 		err = fmt.Errorf("writing consolidated file: %w", err)
@@ -157,23 +208,61 @@ func (r RunConsolidateCommand) Execute(ctx context.Context) error {
 		return err
 	}
 
-	// 7. Delete old migration files.
+	// 8. Delete old migration files and their down files. Their contents are
+	// kept until the end so a failure can put everything back.
+	type removedFile struct {
+		path string
+		data []byte
+		mode os.FileMode
+	}
+	var removed []removedFile
+	rollback := func() {
+		for _, f := range removed {
+			os.MkdirAll(filepath.Dir(f.path), 0755)
+			os.WriteFile(f.path, f.data, f.mode)
+		}
+		os.Remove(newFilePath)
+	}
+	remove := func(path string) error {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed = append(removed, removedFile{path: path, data: data, mode: info.Mode().Perm()})
+		return nil
+	}
+
 	var deleted []string
 	for _, m := range filesToDelete {
-		if err := os.Remove(m.FileFullPath); err != nil {
-			// Rollback: remove the consolidated file we just wrote.
-			os.Remove(newFilePath)
-			err = fmt.Errorf("deleting %s: %w", m.FileFullPath, err)
-			if jsonOut {
-				return shared.PrintErrorJSON(err)
+		paths := []string{m.FileFullPath}
+		downPath := infra.DownFilePath(r.MigrationsDir, filepath.Base(m.FileFullPath))
+		if _, err := os.Stat(downPath); err == nil {
+			paths = append(paths, downPath)
+		}
+		for _, path := range paths {
+			if err := remove(path); err != nil {
+				rollback()
+				err = fmt.Errorf("deleting %s: %w", path, err)
+				if jsonOut {
+					return shared.PrintErrorJSON(err)
+				}
+				color.Red("Error: %v", err)
+				return err
 			}
-			color.Red("Error: %v", err)
-			return err
 		}
 		deleted = append(deleted, m.MigrationIndex)
 	}
+	// Drop the down directory if that emptied it; Remove fails otherwise.
+	os.Remove(filepath.Join(r.MigrationsDir, "down"))
 
-	// 8. Verify the resulting migration directory looks correct.
+	// 9. Verify the resulting migration directory looks correct.
 	remaining, err := infra.ListMigrationFiles(r.MigrationsDir)
 	if err != nil {
 		if jsonOut {
@@ -199,3 +288,23 @@ func (r RunConsolidateCommand) Execute(ctx context.Context) error {
 	fmt.Printf("  Remaining migration files: %d\n", len(remaining))
 	return nil
 }
+
+// validate applies the consolidated SQL to a freshly recreated shadow database
+// and diffs the result against the snapshot it was generated from. The user
+// has already confirmed the plan, which names the shadow, so dropping it is
+// not confirmed again.
+func (r RunConsolidateCommand) validate(ctx context.Context, consolidatedSQL string, schema map[string]string) (app.VerifyResult, error) {
+	shadowConn, _, err := openShadow(ctx, r.ShadowDSN, r.TargetDSN, r.Driver, true, true)
+	if err != nil {
+		return app.VerifyResult{}, err
+	}
+	defer shadowConn.Close()
+
+	diff, err := app.ValidateConsolidationAction{
+		Shadow:   newMigrationAdapter(r.Driver, shadowConn),
+		SQL:      consolidatedSQL,
+		Snapshot: schema,
+	}.Execute(ctx)
+	diff.MigrationIndex = r.UpToIndex
+	return diff, err
+}
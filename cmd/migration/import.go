@@ -0,0 +1,200 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/fatih/color"
+
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/apsdsm/joka/cmd/shared"
+	"github.com/apsdsm/joka/internal/domains/migration/app"
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+	lockinfra "github.com/apsdsm/joka/internal/domains/lock/infra"
+)
+
+// RunImportCommand handles "migrate import --from <tool>". It translates
+// another tool's migration files into joka's naming convention and seeds
+// joka_migrations from that tool's tracking table, so an existing project can
+// switch to joka without re-running anything.
+type RunImportCommand struct {
+	DB            *sql.DB
+	Driver        jokadb.Driver
+	MigrationsDir string
+	Source        domain.ImportSource
+	SourceDir     string
+	DryRun        bool
+	AutoConfirm   bool
+	OutputFormat  string
+	// Snapshots controls how the snapshot at the last applied index is stored.
+	Snapshots domain.SnapshotOptions
+}
+
+// Execute prints the import plan, then (unless DryRun) writes the files and
+// seeds the history inside a transaction. Unless DryRun, it holds the
+// advisory lock throughout, so it can't race a concurrent "migrate up".
+func (r RunImportCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+
+	if !r.DryRun {
+		lockAdapter := lockinfra.NewLockAdapter(r.Driver, r.DB)
+		if err := lockAdapter.Acquire(ctx, "migrate import"); err != nil {
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			return err
+		}
+		defer lockAdapter.Release(ctx)
+	}
+
+	adapter := newMigrationAdapter(r.Driver, r.DB)
+	plan, err := app.PlanImportAction{
+		DB:            adapter,
+		Source:        r.Source,
+		SourceDir:     r.SourceDir,
+		MigrationsDir: r.MigrationsDir,
+	}.Execute(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if !jsonOut {
+		printImportPlan(plan)
+	}
+
+	if r.DryRun {
+		if jsonOut {
+			shared.PrintJSON(importPlanJSON(plan, "dry_run", nil))
+			return nil
+		}
+		fmt.Println("Dry run: nothing written.")
+		return nil
+	}
+
+	if !r.AutoConfirm && !jsonOut {
+		if !shared.Confirm(fmt.Sprintf("Import %d migrations (%d applied)? (only 'yes' will proceed): ", len(plan.Entries), len(plan.Applied()))) {
+			fmt.Println("Import aborted by user.")
+			return nil
+		}
+	}
+
+	// joka_migrations is created outside the transaction, since DDL would
+	// commit it on MySQL, and dropped again if the import fails.
+	if plan.CreateTable {
+		if err := adapter.CreateMigrationsTable(ctx); err != nil {
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error: %v", err)
+			return err
+		}
+	}
+	fail := func(err error) error {
+		if plan.CreateTable {
+			adapter.DropMigrationsTable(ctx)
+		}
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(fmt.Errorf("starting transaction: %w", err))
+	}
+
+	written, err := app.ApplyImportAction{
+		DB:            newMigrationTxAdapter(r.Driver, tx, r.DB),
+		Plan:          plan,
+		MigrationsDir: r.MigrationsDir,
+		Snapshot:      r.Snapshots,
+	}.Execute(ctx)
+	if err != nil {
+		tx.Rollback()
+		return fail(fmt.Errorf("importing migrations: %w", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail(fmt.Errorf("committing transaction: %w", err))
+	}
+
+	if jsonOut {
+		shared.PrintJSON(importPlanJSON(plan, "ok", written))
+		return nil
+	}
+
+	color.Green("Import complete.")
+	fmt.Printf("  Files written: %d\n", len(written))
+	fmt.Printf("  Migrations recorded as applied: %d\n", len(plan.Applied()))
+	fmt.Printf("  The %s tracking table was left in place; drop it once you've switched over.\n", r.Source)
+	return nil
+}
+
+// printImportPlan prints the dry-run report: each foreign migration, the joka
+// file it becomes and whether it will be recorded as applied.
+func printImportPlan(plan app.ImportPlan) {
+	color.Green("Import plan (%s):", plan.Source)
+	if plan.CreateTable {
+		fmt.Println("  joka_migrations will be created")
+	}
+	for _, e := range plan.Entries {
+		status := "pending"
+		if e.Applied {
+			status = "applied"
+		}
+		down := ""
+		if e.Down != "" {
+			down = " (+ down)"
+		}
+		fmt.Printf("  %-20s -> %s [%s]%s\n", e.Version, e.FileName, status, down)
+		for _, w := range e.Warnings {
+			color.Yellow("    warning: %s", w)
+		}
+	}
+	for _, w := range plan.Warnings {
+		color.Yellow("  warning: %s", w)
+	}
+	fmt.Printf("  %d migrations, %d applied\n", len(plan.Entries), len(plan.Applied()))
+	fmt.Println()
+}
+
+// importPlanJSON builds the JSON form of a plan. written is nil for dry runs.
+func importPlanJSON(plan app.ImportPlan, status string, written []string) map[string]any {
+	entries := make([]map[string]any, len(plan.Entries))
+	for i, e := range plan.Entries {
+		entry := map[string]any{
+			"version":      e.Version,
+			"index":        e.Index,
+			"file":         e.FileName,
+			"source_files": e.SourceFiles,
+			"applied":      e.Applied,
+			"has_down":     e.Down != "",
+		}
+		if !e.AppliedAt.IsZero() {
+			entry["applied_at"] = e.AppliedAt.UTC()
+		}
+		if len(e.Warnings) > 0 {
+			entry["warnings"] = e.Warnings
+		}
+		entries[i] = entry
+	}
+
+	out := map[string]any{
+		"status":     status,
+		"source":     plan.Source,
+		"migrations": entries,
+		"applied":    len(plan.Applied()),
+		"warnings":   plan.Warnings,
+	}
+	if written != nil {
+		out["written"] = written
+	}
+	return out
+}
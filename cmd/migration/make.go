@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/fatih/color"
+	jokadb "github.com/apsdsm/joka/db"
 	"github.com/apsdsm/joka/cmd/shared"
 	"github.com/apsdsm/joka/internal/domains/migration/infra"
 )
@@ -13,6 +14,18 @@ type RunMakeCommand struct {
 	MigrationsDir string
 	Name          string
 	OutputFormat  string
+	// Template selects a scaffold (built-in or from ScaffoldsDir). Empty
+	// writes the blank scaffold.
+	Template     string
+	ScaffoldsDir string
+	// Driver picks the dialect for driver-aware scaffolds. `make` doesn't
+	// connect, so it comes from --driver, the configured connection, or
+	// DATABASE_URL.
+	Driver jokadb.Driver
+	Table  string
+	Column string
+	Down   bool
+	Meta   bool
 }
 
 // Execute creates a new migration file with the specified name in the migrations directory.
@@ -23,7 +36,15 @@ func (r RunMakeCommand) Execute(c context.Context) error {
 		color.Green("Creating new migration file '%s' in '%s'...", r.Name, r.MigrationsDir)
 	}
 
-	filename, err := infra.CreateMigrationFile(r.MigrationsDir, r.Name)
+	created, err := infra.CreateMigrationFile(r.MigrationsDir, r.Name, infra.ScaffoldOptions{
+		Template:    r.Template,
+		TemplateDir: r.ScaffoldsDir,
+		Driver:      r.Driver,
+		Table:       r.Table,
+		Column:      r.Column,
+		Down:        r.Down,
+		Meta:        r.Meta,
+	})
 	if err != nil {
 		if jsonOut {
 			return shared.PrintErrorJSON(err)
@@ -33,10 +54,17 @@ func (r RunMakeCommand) Execute(c context.Context) error {
 	}
 
 	if jsonOut {
-		shared.PrintJSON(map[string]string{"status": "ok", "file": filename})
+		out := map[string]string{"status": "ok", "file": created.File}
+		if created.DownFile != "" {
+			out["down_file"] = created.DownFile
+		}
+		shared.PrintJSON(out)
 		return nil
 	}
 
-	color.Green("Created migration file: %s", filename)
+	color.Green("Created migration file: %s", created.File)
+	if created.DownFile != "" {
+		color.Green("Created down file: %s", created.DownFile)
+	}
 	return nil
 }
@@ -0,0 +1,50 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/apsdsm/joka/cmd/shared"
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/fatih/color"
+)
+
+// openShadow drops and recreates the shadow database named by shadowDSN and
+// returns a connection to it. It refuses a shadow on the same server and
+// database as the target (loopback hosts and default ports compare equal), or
+// on a different driver, since the replay would
+// then be meaningless. Dropping is confirmed unless autoConfirm or JSON output
+// is set; ok is false when the user declines.
+func openShadow(ctx context.Context, shadowDSN, targetDSN string, driver jokadb.Driver, autoConfirm, jsonOut bool) (conn *sql.DB, ok bool, err error) {
+	shadow, err := jokadb.ParseTarget(shadowDSN)
+	if err != nil {
+		return nil, false, fmt.Errorf("shadow database: %w", err)
+	}
+	if shadow.Driver != driver {
+		return nil, false, fmt.Errorf("shadow database uses %s but the target uses %s", shadow.Driver, driver)
+	}
+	if targetDSN != "" {
+		if shadowDSN == targetDSN {
+			return nil, false, fmt.Errorf("shadow database %s is the target database", shadow)
+		}
+		target, err := jokadb.ParseTarget(targetDSN)
+		if err == nil && target.SameDatabase(shadow) {
+			return nil, false, fmt.Errorf("shadow database %s is the target database", shadow)
+		}
+	}
+
+	if !jsonOut && !autoConfirm {
+		color.Yellow("Shadow database %s will be dropped and recreated.", shadow)
+		if !shared.Confirm("Type 'yes' to proceed: ") {
+			color.Yellow("Cancelled.")
+			return nil, false, nil
+		}
+	}
+
+	conn, _, err = jokadb.RecreateDatabase(ctx, shadowDSN)
+	if err != nil {
+		return nil, false, err
+	}
+	return conn, true, nil
+}
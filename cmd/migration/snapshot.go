@@ -5,20 +5,24 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
 	"sort"
 
 	"github.com/fatih/color"
 	jokadb "github.com/apsdsm/joka/db"
 	"github.com/apsdsm/joka/cmd/shared"
+	"github.com/apsdsm/joka/internal/domains/migration/app"
 )
 
 // RunSnapshotCommand handles "migrate snapshot [migration_index]". It retrieves
 // and pretty-prints a stored schema snapshot. If no migration index is given,
-// it shows the most recent snapshot.
+// it shows the most recent snapshot. With WritePath set, the snapshot is
+// written to that file in foreign key order instead of printed.
 type RunSnapshotCommand struct {
 	DB             *sql.DB
 	Driver         jokadb.Driver
 	MigrationIndex string // empty = latest
+	WritePath      string
 	OutputFormat   string
 }
 
@@ -55,6 +59,14 @@ func (r RunSnapshotCommand) Execute(ctx context.Context) error {
 	// Parse the JSON map of {table_name: "CREATE TABLE ..."}.
 	var schema map[string]string
 	if err := json.Unmarshal([]byte(snapshot), &schema); err != nil {
+		if r.WritePath != "" {
+			err = fmt.Errorf("parsing snapshot: %w", err)
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error: %v", err)
+			return err
+		}
 		if jsonOut {
 			// Return raw snapshot as a string if parsing fails.
 			shared.PrintJSON(map[string]any{"status": "ok", "migration_index": index, "schema_raw": snapshot})
@@ -67,6 +79,10 @@ func (r RunSnapshotCommand) Execute(ctx context.Context) error {
 		return nil
 	}
 
+	if r.WritePath != "" {
+		return r.write(index, schema, jsonOut)
+	}
+
 	if jsonOut {
 		shared.PrintJSON(map[string]any{"status": "ok", "migration_index": index, "schema": schema})
 		return nil
@@ -89,3 +105,27 @@ func (r RunSnapshotCommand) Execute(ctx context.Context) error {
 
 	return nil
 }
+
+// write renders the snapshot as a schema file at r.WritePath, tables ordered
+// so that referenced tables come before the tables referencing them.
+func (r RunSnapshotCommand) write(index string, schema map[string]string, jsonOut bool) error {
+	order, err := app.TopologicalSort(app.ParseFKDependencies(schema))
+	if err == nil {
+		err = os.WriteFile(r.WritePath, []byte(app.GenerateSchemaFile(index, schema, order)), 0644)
+	}
+	if err != nil {
+		err = fmt.Errorf("writing schema file: %w", err)
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if jsonOut {
+		shared.PrintJSON(map[string]any{"status": "ok", "migration_index": index, "path": r.WritePath, "tables": order})
+		return nil
+	}
+	color.Green("Wrote %d table(s) from the snapshot for migration %s to %s.", len(order), index, r.WritePath)
+	return nil
+}
@@ -0,0 +1,65 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/apsdsm/joka/cmd/shared"
+	jokadb "github.com/apsdsm/joka/db"
+	lockinfra "github.com/apsdsm/joka/internal/domains/lock/infra"
+	"github.com/apsdsm/joka/internal/domains/migration/app"
+	"github.com/fatih/color"
+)
+
+// RunSnapshotPruneCommand handles "migrate snapshot prune --keep N". It deletes
+// all but the newest N snapshots and the stored table DDL only they used. The
+// migration lock is held so a concurrent migrate up can't write a snapshot
+// that refers to DDL being deleted.
+type RunSnapshotPruneCommand struct {
+	DB           *sql.DB
+	Driver       jokadb.Driver
+	Keep         int
+	AutoConfirm  bool
+	OutputFormat string
+}
+
+func (r RunSnapshotPruneCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+
+	if !r.AutoConfirm && !jsonOut {
+		if !shared.Confirm(fmt.Sprintf("Delete all but the newest %d snapshot(s)? (only 'yes' will proceed): ", r.Keep)) {
+			fmt.Println("Prune aborted by user.")
+			return nil
+		}
+	}
+
+	lockAdapter := lockinfra.NewLockAdapter(r.Driver, r.DB)
+	if err := lockAdapter.Acquire(ctx, "snapshot prune"); err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+	defer lockAdapter.Release(ctx)
+
+	result, err := app.PruneSnapshotsAction{
+		DB:   newMigrationAdapter(r.Driver, r.DB),
+		Keep: r.Keep,
+	}.Execute(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if jsonOut {
+		shared.PrintJSON(map[string]any{"status": "ok", "keep": r.Keep, "pruned": result})
+		return nil
+	}
+
+	color.Green("Pruned %d snapshot(s) and %d unused table definition(s).", result.Snapshots, result.Tables)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/fatih/color"
 	jokadb "github.com/apsdsm/joka/db"
@@ -12,8 +13,15 @@ import (
 	lockinfra "github.com/apsdsm/joka/internal/domains/lock/infra"
 	"github.com/apsdsm/joka/internal/domains/migration/app"
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
+	"github.com/apsdsm/joka/internal/domains/migration/infra"
 )
 
+// defaultPostgresLockTimeout is the lock_timeout applied to the migration
+// transaction on Postgres when none is configured. It makes a DDL migration
+// that can't acquire its lock (e.g. an app still holding the table) error out
+// in seconds instead of wedging.
+const defaultPostgresLockTimeout = 15 * time.Second
+
 // RunMigrateUpCommand handles the "migrate up" command. It builds the migration
 // chain, identifies pending migrations, and applies them inside a transaction.
 type RunMigrateUpCommand struct {
@@ -25,14 +33,39 @@ type RunMigrateUpCommand struct {
 	// SkipLock skips advisory lock acquisition. Used when an outer command
 	// (e.g. `joka reset`) already holds the lock.
 	SkipLock bool
+	// Timeouts are the session timeouts applied to the migration transaction
+	// (from .jokarc.yaml, the profile, and command flags). Individual
+	// migrations can override them with header directives.
+	Timeouts jokadb.Timeouts
+	// Retry is the number of extra attempts made when the batch fails with a
+	// lock timeout or deadlock. Zero disables retrying. Any other failure is
+	// returned immediately.
+	Retry int
+	// RetryBackoff is the wait before the first retry; it doubles on each
+	// subsequent attempt, up to jokadb.MaxRetryBackoff.
+	RetryBackoff time.Duration
+	// Profile is the selected .jokarc.yaml profile, used to evaluate
+	// `-- joka:if profile=...` blocks.
+	Profile string
+	// DryRun prints the evaluated SQL of each pending migration instead of
+	// applying it. No lock is taken and nothing is written.
+	DryRun bool
+	// Snapshots controls when snapshots are captured (after every migration,
+	// or once at the end of the batch) and how they are stored.
+	Snapshots domain.SnapshotOptions
+	// SnapshotRetention, when positive, prunes all but the newest
+	// SnapshotRetention snapshots once the batch is applied.
+	SnapshotRetention int
 }
 
 // Execute acquires an advisory lock, applies all pending migrations in a
 // single transaction, and releases the lock when done (including on error).
+// With Retry set, a batch that fails on lock contention is rolled back and
+// re-attempted after an exponential backoff while the lock is still held.
 func (r RunMigrateUpCommand) Execute(ctx context.Context) error {
 	jsonOut := r.OutputFormat == shared.OutputJSON
 
-	if !r.SkipLock {
+	if !r.SkipLock && !r.DryRun {
 		// Acquire advisory lock to prevent concurrent migration runs.
 		lockAdapter := lockinfra.NewLockAdapter(r.Driver, r.DB)
 		if err := lockAdapter.Acquire(ctx, "migrate up"); err != nil {
@@ -88,6 +121,38 @@ func (r RunMigrateUpCommand) Execute(ctx context.Context) error {
 		return nil
 	}
 
+	timeouts := r.Timeouts
+	if r.Driver == jokadb.Postgres && timeouts.LockTimeout == 0 {
+		timeouts.LockTimeout = defaultPostgresLockTimeout
+	}
+
+	// Read header overrides up front so a malformed directive (or conditional
+	// block) fails before anything is applied.
+	conditions := infra.Conditions{Driver: r.Driver, Profile: r.Profile}
+	overrides := make([]jokadb.Timeouts, len(pending))
+	for i, m := range pending {
+		overrides[i], err = infra.ReadMigrationTimeouts(m.FileFullPath, conditions)
+		if err != nil {
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error reading migration %s: %v", m.MigrationIndex, err)
+			return err
+		}
+	}
+
+	if !jsonOut {
+		if settings := timeouts.Settings(r.Driver); len(settings) > 0 {
+			fmt.Printf("Session timeouts: %s\n", shared.FormatTimeouts(settings))
+		}
+	}
+
+	if r.DryRun {
+		return r.printDryRun(pending, overrides, timeouts, conditions, jsonOut)
+	}
+
+	initial := pending
+
 	if !r.AutoConfirm && !jsonOut {
 		if !shared.Confirm(fmt.Sprintf("%d pending migrations found. Apply now? (only 'yes' will apply): ", len(pending))) {
 			fmt.Println("Migration aborted by user.")
@@ -95,62 +160,220 @@ func (r RunMigrateUpCommand) Execute(ctx context.Context) error {
 		}
 	}
 
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		if jsonOut {
-			return shared.PrintErrorJSON(fmt.Errorf("starting transaction: %w", err))
+	var retries []map[string]any
+	for attempt := 1; ; attempt++ {
+		err = r.applyBatch(ctx, pending, overrides, timeouts, jsonOut)
+		if err == nil {
+			break
 		}
-		return fmt.Errorf("starting transaction: %w", err)
+
+		if attempt > r.Retry || !jokadb.IsLockError(err) {
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error applying migrations: %v", err)
+			return err
+		}
+
+		// Exponential backoff: RetryBackoff, 2x, 4x, ... up to a cap.
+		wait := jokadb.RetryBackoff(r.RetryBackoff, attempt)
+		retries = append(retries, map[string]any{"attempt": attempt, "error": err.Error(), "wait": wait.String()})
+		if !jsonOut {
+			color.Yellow("Lock contention on attempt %d/%d: %v", attempt, r.Retry+1, err)
+			color.Yellow("Rolled back. Retrying in %s...", wait)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			if jsonOut {
+				return shared.PrintErrorJSON(ctx.Err())
+			}
+			return ctx.Err()
+		}
+
+		// Re-read the chain before retrying. MySQL commits DDL implicitly, so
+		// migrations before the failing one may already be recorded; they
+		// must not be applied twice.
+		chain, err := app.GetMigrationChainAction{
+			DB:            adapter,
+			MigrationsDir: r.MigrationsDir,
+		}.Execute(ctx)
+		if err != nil {
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error applying migrations: %v", err)
+			return err
+		}
+		pending, overrides = pendingWithOverrides(chain, pending, overrides)
 	}
 
-	// Fail fast on lock contention rather than hanging indefinitely: a DDL
-	// migration that can't acquire its lock (e.g. an app still holding the
-	// table) errors out in seconds instead of wedging. Postgres-only syntax.
-	if r.Driver == jokadb.Postgres {
-		if _, err := tx.ExecContext(ctx, "SET LOCAL lock_timeout = '15s'"); err != nil {
-			tx.Rollback()
+	// Every migration that was pending at the start is now applied, whether
+	// in the final attempt or implicitly committed by an earlier one.
+	applied := make([]string, len(initial))
+	for i, m := range initial {
+		applied[i] = m.MigrationIndex
+	}
+
+	// Retention runs after commit, still under the lock, so it never races a
+	// snapshot being written.
+	var pruned *app.PruneResult
+	if r.SnapshotRetention > 0 {
+		result, err := app.PruneSnapshotsAction{DB: adapter, Keep: r.SnapshotRetention}.Execute(ctx)
+		if err != nil {
 			if jsonOut {
-				return shared.PrintErrorJSON(fmt.Errorf("setting lock_timeout: %w", err))
+				return shared.PrintErrorJSON(fmt.Errorf("pruning snapshots: %w", err))
 			}
-			return fmt.Errorf("setting lock_timeout: %w", err)
+			color.Red("Migrations applied, but pruning snapshots failed: %v", err)
+			return err
 		}
+		pruned = &result
 	}
 
+	if jsonOut {
+		out := map[string]any{"status": "ok", "applied": applied, "timeouts": timeouts.Settings(r.Driver)}
+		if len(retries) > 0 {
+			out["retries"] = retries
+		}
+		if pruned != nil {
+			out["pruned"] = pruned
+		}
+		shared.PrintJSON(out)
+		return nil
+	}
+
+	color.Green("All migrations applied successfully.")
+	if pruned != nil && pruned.Snapshots > 0 {
+		fmt.Printf("Pruned %d old snapshot(s) (retention %d).\n", pruned.Snapshots, r.SnapshotRetention)
+	}
+	return nil
+}
+
+// applyBatch applies pending migrations inside a single transaction with the
+// session timeouts set, committing on success and rolling back on any error.
+// Once the transaction ends, every timeout it set, per-migration overrides
+// included, is reset.
+func (r RunMigrateUpCommand) applyBatch(ctx context.Context, pending []domain.Migration, overrides []jokadb.Timeouts, timeouts jokadb.Timeouts, jsonOut bool) error {
+	set := timeouts
+	for _, o := range overrides {
+		set = set.Merge(o)
+	}
+	tx, endTx, err := jokadb.BeginTx(ctx, r.DB, r.Driver, set)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer endTx()
+
 	txAdapter := newMigrationTxAdapter(r.Driver, tx, r.DB)
 
-	var applied []string
-	for _, m := range pending {
+	// Fail fast on lock contention rather than hanging indefinitely.
+	if err := txAdapter.SetSessionTimeouts(ctx, timeouts); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// One tracker per attempt: a rolled-back batch must not leave its schema
+	// behind for the retry.
+	schema := &app.SchemaTracker{}
+	endOfBatch := r.Snapshots.Mode == domain.SnapshotEndOfBatch
+
+	for i, m := range pending {
 		if !jsonOut {
-			fmt.Printf("Applying migration %s...\n", m.MigrationIndex)
+			if settings := overrides[i].Settings(r.Driver); len(settings) > 0 {
+				fmt.Printf("Applying migration %s (%s)...\n", m.MigrationIndex, shared.FormatTimeouts(settings))
+			} else {
+				fmt.Printf("Applying migration %s...\n", m.MigrationIndex)
+			}
 		}
-		err = app.ApplyAction{
+		err := app.ApplyAction{
 			DB:        txAdapter,
 			Migration: m,
+			Timeouts:  timeouts,
+			Overrides: overrides[i],
+			Profile:   r.Profile,
+			Snapshot:  r.Snapshots,
+			Schema:    schema,
+			// In end-of-batch mode only the last migration is snapshotted.
+			SkipSnapshot: endOfBatch && i < len(pending)-1,
 		}.Execute(ctx)
-
 		if err != nil {
 			tx.Rollback()
-			if jsonOut {
-				return shared.PrintErrorJSON(err)
-			}
-			color.Red("Error applying migrations: %v", err)
 			return err
 		}
-		applied = append(applied, m.MigrationIndex)
 	}
 
 	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// printDryRun prints each pending migration's SQL as it would be executed:
+// conditional blocks evaluated for this driver and profile, then split into
+// statements. The checksum covers the raw file.
+func (r RunMigrateUpCommand) printDryRun(pending []domain.Migration, overrides []jokadb.Timeouts, timeouts jokadb.Timeouts, conditions infra.Conditions, jsonOut bool) error {
+	fail := func(m domain.Migration, err error) error {
 		if jsonOut {
-			return shared.PrintErrorJSON(fmt.Errorf("committing transaction: %w", err))
+			return shared.PrintErrorJSON(err)
 		}
-		return fmt.Errorf("committing transaction: %w", err)
+		color.Red("Error reading migration %s: %v", m.MigrationIndex, err)
+		return err
+	}
+
+	out := make([]map[string]any, 0, len(pending))
+	for i, m := range pending {
+		sqlContent, err := infra.ReadMigrationSQL(m.FileFullPath, conditions)
+		if err != nil {
+			return fail(m, err)
+		}
+		checksum, err := infra.MigrationChecksum(m.FileFullPath)
+		if err != nil {
+			return fail(m, err)
+		}
+		out = append(out, map[string]any{
+			"index":      m.MigrationIndex,
+			"file":       fmt.Sprintf("%s_%s.sql", m.MigrationIndex, m.FileName),
+			"checksum":   checksum,
+			"statements": jokadb.SplitSQLStatements(sqlContent),
+			"timeouts":   timeouts.Merge(overrides[i]).Settings(r.Driver),
+		})
 	}
 
 	if jsonOut {
-		shared.PrintJSON(map[string]any{"status": "ok", "applied": applied})
+		shared.PrintJSON(map[string]any{"status": "dry_run", "pending": out, "timeouts": timeouts.Settings(r.Driver)})
 		return nil
 	}
 
-	color.Green("All migrations applied successfully.")
+	for _, m := range out {
+		color.Green("-- Migration %s (sha256 %s)", m["index"], m["checksum"])
+		for _, stmt := range m["statements"].([]string) {
+			fmt.Printf("%s;\n", stmt)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Dry run: %d pending migrations, nothing applied.\n", len(out))
 	return nil
 }
+
+// pendingWithOverrides narrows a previous pending list (and its parallel
+// header overrides) to the migrations the refreshed chain still reports as
+// pending.
+func pendingWithOverrides(chain []domain.Migration, pending []domain.Migration, overrides []jokadb.Timeouts) ([]domain.Migration, []jokadb.Timeouts) {
+	stillPending := make(map[string]bool)
+	for _, m := range chain {
+		if m.Status == domain.StatusPending {
+			stillPending[m.MigrationIndex] = true
+		}
+	}
+
+	var p []domain.Migration
+	var o []jokadb.Timeouts
+	for i, m := range pending {
+		if stillPending[m.MigrationIndex] {
+			p = append(p, m)
+			o = append(o, overrides[i])
+		}
+	}
+	return p, o
+}
@@ -19,17 +19,35 @@ var ErrSchemaDrift = errors.New("schema drift detected")
 
 // RunVerifyCommand handles "migrate verify". It compares the live database
 // schema against the latest snapshot and reports added/removed/modified
-// tables. Exits non-zero when drift is found.
+// tables. With ShadowDSN set it instead replays the migration files on a
+// throwaway shadow database and compares the replay against every stored
+// snapshot and the live schema. With AgainstFile set, the comparison is against
+// a schema file written by "migrate snapshot --write" instead (the shadow
+// replay, if any, is compared against the file). Exits non-zero when drift is
+// found.
 type RunVerifyCommand struct {
-	DB           *sql.DB
-	Driver       jokadb.Driver
-	OutputFormat string
+	DB            *sql.DB
+	Driver        jokadb.Driver
+	TargetDSN     string
+	ShadowDSN     string
+	AgainstFile   string
+	MigrationsDir string
+	Profile       string
+	AutoConfirm   bool
+	OutputFormat  string
 }
 
 func (r RunVerifyCommand) Execute(ctx context.Context) error {
 	jsonOut := r.OutputFormat == shared.OutputJSON
 	adapter := newMigrationAdapter(r.Driver, r.DB)
 
+	if r.AgainstFile != "" {
+		return r.executeAgainstFile(ctx, adapter, jsonOut)
+	}
+	if r.ShadowDSN != "" {
+		return r.executeShadow(ctx, adapter, jsonOut)
+	}
+
 	result, err := app.VerifySchemaAction{DB: adapter}.Execute(ctx)
 	if err != nil {
 		if jsonOut {
@@ -61,10 +79,144 @@ func (r RunVerifyCommand) Execute(ctx context.Context) error {
 
 	color.Red("Schema drift detected against migration %s.", result.MigrationIndex)
 	fmt.Println()
+	printSchemaDiff(result, "snapshot", "live")
+
+	return ErrSchemaDrift
+}
+
+// executeAgainstFile runs verify --against-file, against the live database or,
+// with --shadow, against a replay of the migration files.
+func (r RunVerifyCommand) executeAgainstFile(ctx context.Context, adapter app.DBAdapter, jsonOut bool) error {
+	action := app.VerifyFileAction{DB: adapter, Path: r.AgainstFile}
+	actual := "live"
+
+	if r.ShadowDSN != "" {
+		shadowConn, ok, err := openShadow(ctx, r.ShadowDSN, r.TargetDSN, r.Driver, r.AutoConfirm, jsonOut)
+		if err != nil {
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error: %v", err)
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		defer shadowConn.Close()
+
+		action.DB = newMigrationAdapter(r.Driver, shadowConn)
+		action.ReplayDir = r.MigrationsDir
+		action.Profile = r.Profile
+		actual = "replay"
+	}
+
+	result, err := action.Execute(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if jsonOut {
+		shared.PrintJSON(map[string]any{
+			"status":          "ok",
+			"file":            r.AgainstFile,
+			"against":         actual,
+			"migration_index": result.MigrationIndex,
+			"drift":           result.HasDrift(),
+			"added":           result.Added,
+			"removed":         result.Removed,
+			"modified":        result.Modified,
+		})
+		if result.HasDrift() {
+			return ErrSchemaDrift
+		}
+		return nil
+	}
+
+	if !result.HasDrift() {
+		color.Green("No drift: the %s schema matches %s.", actual, r.AgainstFile)
+		return nil
+	}
+
+	color.Red("Schema drift detected: the %s schema differs from %s (migration %s).", actual, r.AgainstFile, result.MigrationIndex)
+	fmt.Println()
+	printSchemaDiff(result, "file", actual)
+
+	return ErrSchemaDrift
+}
+
+// executeShadow runs verify --shadow.
+func (r RunVerifyCommand) executeShadow(ctx context.Context, adapter app.DBAdapter, jsonOut bool) error {
+	shadowConn, ok, err := openShadow(ctx, r.ShadowDSN, r.TargetDSN, r.Driver, r.AutoConfirm, jsonOut)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	defer shadowConn.Close()
+
+	result, err := app.ShadowVerifyAction{
+		Target:        adapter,
+		Shadow:        newMigrationAdapter(r.Driver, shadowConn),
+		MigrationsDir: r.MigrationsDir,
+		Profile:       r.Profile,
+	}.Execute(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if jsonOut {
+		shared.PrintJSON(map[string]any{
+			"status":            "ok",
+			"drift":             result.HasDrift(),
+			"replayed":          result.Replayed,
+			"snapshots_checked": result.SnapshotsChecked,
+			"last_applied":      result.LastApplied,
+			"divergence":        result.Divergence,
+		})
+		if result.HasDrift() {
+			return ErrSchemaDrift
+		}
+		return nil
+	}
+
+	fmt.Printf("Replayed %d migration(s) on the shadow database; checked %d snapshot(s).\n", len(result.Replayed), result.SnapshotsChecked)
+
+	if !result.HasDrift() {
+		color.Green("No drift: the replay matches every snapshot and the live schema.")
+		return nil
+	}
+
+	d := result.Divergence
+	if d.Against == "live" {
+		color.Red("Replay diverges from the live schema at migration %s.", d.MigrationIndex)
+	} else {
+		color.Red("Replay diverges from the snapshot at migration %s.", d.MigrationIndex)
+	}
+	fmt.Println()
+	printSchemaDiff(d.Diff, "replay", d.Against)
 
+	return ErrSchemaDrift
+}
+
+// printSchemaDiff prints the added/removed/modified tables of a diff, naming
+// the expected and actual sides with the given labels.
+func printSchemaDiff(result app.VerifyResult, expected, actual string) {
 	if len(result.Added) > 0 {
 		color.Set(color.Bold)
-		fmt.Println("Added tables (in live, not in snapshot):")
+		fmt.Printf("Added tables (in %s, not in %s):\n", actual, expected)
 		color.Unset()
 		for _, t := range result.Added {
 			color.Green("  + %s", t)
@@ -74,7 +226,7 @@ func (r RunVerifyCommand) Execute(ctx context.Context) error {
 
 	if len(result.Removed) > 0 {
 		color.Set(color.Bold)
-		fmt.Println("Removed tables (in snapshot, missing from live):")
+		fmt.Printf("Removed tables (in %s, missing from %s):\n", expected, actual)
 		color.Unset()
 		for _, t := range result.Removed {
 			color.Red("  - %s", t)
@@ -89,16 +241,14 @@ func (r RunVerifyCommand) Execute(ctx context.Context) error {
 		for _, m := range result.Modified {
 			color.Yellow("  ~ %s", m.Table)
 			fmt.Println()
-			color.Cyan("    -- snapshot")
+			color.Cyan("    -- %s", expected)
 			fmt.Println("   ", indent(m.Snapshot))
 			fmt.Println()
-			color.Cyan("    -- live")
+			color.Cyan("    -- %s", actual)
 			fmt.Println("   ", indent(m.Live))
 			fmt.Println()
 		}
 	}
-
-	return ErrSchemaDrift
 }
 
 // indent prefixes every line after the first with four spaces, so multi-line
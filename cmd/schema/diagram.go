@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/apsdsm/joka/cmd/shared"
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/apsdsm/joka/internal/domains/schema/app"
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+	"github.com/fatih/color"
+)
+
+// RunDiagramCommand handles "schema diagram". It renders the tables of the
+// live database, or of a stored snapshot, as a Mermaid, DOT or PlantUML
+// entity-relationship diagram. The diagram goes to stdout so it can be piped,
+// or to WritePath.
+type RunDiagramCommand struct {
+	DB           *sql.DB
+	Driver       jokadb.Driver
+	Index        string // empty = live database, "latest" = newest snapshot
+	Format       domain.DiagramFormat
+	Filter       app.TableFilter
+	WritePath    string
+	OutputFormat string
+}
+
+func (r RunDiagramCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+
+	tables, source, err := loadTables(ctx, newMigrationAdapter(r.Driver, r.DB), r.Index)
+	if err == nil {
+		tables, err = app.SelectTables(tables, r.Filter)
+	}
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	diagram := app.RenderDiagram(tables, r.Format)
+
+	if r.WritePath != "" {
+		if err := os.WriteFile(r.WritePath, []byte(diagram), 0644); err != nil {
+			err = fmt.Errorf("writing diagram: %w", err)
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
+			}
+			color.Red("Error: %v", err)
+			return err
+		}
+	}
+
+	if jsonOut {
+		out := map[string]any{"status": "ok", "format": r.Format, "source": source.String(), "tables": len(tables)}
+		if r.WritePath != "" {
+			out["path"] = r.WritePath
+		} else {
+			out["diagram"] = diagram
+		}
+		shared.PrintJSON(out)
+		return nil
+	}
+
+	if r.WritePath != "" {
+		color.Green("Wrote a %s diagram of %d table(s) from the %s to %s.", r.Format, len(tables), source, r.WritePath)
+		return nil
+	}
+	fmt.Print(diagram)
+	return nil
+}
@@ -0,0 +1,188 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/apsdsm/joka/cmd/shared"
+	jokadb "github.com/apsdsm/joka/db"
+	migrationapp "github.com/apsdsm/joka/internal/domains/migration/app"
+	"github.com/fatih/color"
+)
+
+// ErrDatabasesDiffer is returned when the two databases compared by
+// "schema diff" differ, so scripts can detect it via exit code.
+var ErrDatabasesDiffer = errors.New("databases differ")
+
+// DiffSide is one of the databases "schema diff" compares.
+type DiffSide struct {
+	Profile string // the .jokarc.yaml profile the DSN came from, if any
+	DSN     string
+}
+
+// RunDiffCommand handles "schema diff". It computes the live schema of both
+// databases, reports tables added, removed or modified going from From to To
+// (normalized as in migrate verify), and compares their joka_migrations
+// histories. Exits non-zero when they differ.
+type RunDiffCommand struct {
+	From         DiffSide
+	To           DiffSide
+	OutputFormat string
+}
+
+func (r RunDiffCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+
+	result, from, to, err := r.diff(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if jsonOut {
+		modified := make([]map[string]string, len(result.Schema.Modified))
+		for i, m := range result.Schema.Modified {
+			modified[i] = map[string]string{"table": m.Table, "from": m.Snapshot, "to": m.Live}
+		}
+		shared.PrintJSON(map[string]any{
+			"status":   "ok",
+			"from":     from,
+			"to":       to,
+			"differ":   result.HasDifferences(),
+			"added":    result.Schema.Added,
+			"removed":  result.Schema.Removed,
+			"modified": modified,
+			"history":  result.History,
+		})
+		if result.HasDifferences() {
+			return ErrDatabasesDiffer
+		}
+		return nil
+	}
+
+	fmt.Printf("Comparing %s with %s.\n\n", from, to)
+	printHistoryDiff(result.History, from, to)
+
+	if !result.Schema.HasDrift() {
+		color.Green("The schemas match.")
+	} else {
+		color.Red("The schemas differ.")
+		fmt.Println()
+		printTableDiff(result.Schema, from, to)
+	}
+
+	if result.HasDifferences() {
+		return ErrDatabasesDiffer
+	}
+	return nil
+}
+
+// diff opens both databases and compares them. from and to are the labels
+// the output names them by.
+func (r RunDiffCommand) diff(ctx context.Context) (result migrationapp.DatabaseDiffResult, from, to string, err error) {
+	fromTarget, err := jokadb.ParseTarget(r.From.DSN)
+	if err != nil {
+		return result, "", "", fmt.Errorf("from database: %w", err)
+	}
+	toTarget, err := jokadb.ParseTarget(r.To.DSN)
+	if err != nil {
+		return result, "", "", fmt.Errorf("to database: %w", err)
+	}
+	if fromTarget.Driver != toTarget.Driver {
+		return result, "", "", fmt.Errorf("cannot compare a %s database with a %s database", fromTarget.Driver, toTarget.Driver)
+	}
+	from, to = r.From.label(fromTarget), r.To.label(toTarget)
+
+	fromConn, driver, err := jokadb.Open(r.From.DSN)
+	if err != nil {
+		return result, from, to, fmt.Errorf("connecting to %s: %w", from, err)
+	}
+	defer fromConn.Close()
+	toConn, _, err := jokadb.Open(r.To.DSN)
+	if err != nil {
+		return result, from, to, fmt.Errorf("connecting to %s: %w", to, err)
+	}
+	defer toConn.Close()
+
+	result, err = migrationapp.DiffDatabasesAction{
+		From: newMigrationAdapter(driver, fromConn),
+		To:   newMigrationAdapter(driver, toConn),
+	}.Execute(ctx)
+	return result, from, to, err
+}
+
+// label names a side by its profile, if any, and its database. The DSN
+// itself is never shown, since it may hold a password.
+func (s DiffSide) label(t jokadb.Target) string {
+	if s.Profile != "" {
+		return fmt.Sprintf("%s (%s)", s.Profile, t)
+	}
+	return t.String()
+}
+
+func printHistoryDiff(h migrationapp.HistoryDiff, from, to string) {
+	color.Set(color.Bold)
+	fmt.Println("Migrations:")
+	color.Unset()
+	fmt.Printf("  %s: last applied %s\n", from, orNone(h.FromLastApplied))
+	fmt.Printf("  %s: last applied %s\n", to, orNone(h.ToLastApplied))
+	if len(h.OnlyFrom) > 0 {
+		fmt.Printf("  Applied only on %s: %s\n", from, strings.Join(h.OnlyFrom, ", "))
+	}
+	if len(h.OnlyTo) > 0 {
+		fmt.Printf("  Applied only on %s: %s\n", to, strings.Join(h.OnlyTo, ", "))
+	}
+	fmt.Println()
+}
+
+// printTableDiff prints the added/removed/modified tables going from one
+// database to the other.
+func printTableDiff(result migrationapp.VerifyResult, from, to string) {
+	if len(result.Added) > 0 {
+		color.Set(color.Bold)
+		fmt.Printf("Added tables (in %s, not in %s):\n", to, from)
+		color.Unset()
+		for _, t := range result.Added {
+			color.Green("  + %s", t)
+		}
+		fmt.Println()
+	}
+
+	if len(result.Removed) > 0 {
+		color.Set(color.Bold)
+		fmt.Printf("Removed tables (in %s, not in %s):\n", from, to)
+		color.Unset()
+		for _, t := range result.Removed {
+			color.Red("  - %s", t)
+		}
+		fmt.Println()
+	}
+
+	if len(result.Modified) > 0 {
+		color.Set(color.Bold)
+		fmt.Println("Modified tables:")
+		color.Unset()
+		for _, m := range result.Modified {
+			color.Yellow("  ~ %s", m.Table)
+			fmt.Println()
+			color.Cyan("    -- %s", from)
+			fmt.Println("   ", strings.ReplaceAll(strings.TrimRight(m.Snapshot, "\n"), "\n", "\n    "))
+			fmt.Println()
+			color.Cyan("    -- %s", to)
+			fmt.Println("   ", strings.ReplaceAll(strings.TrimRight(m.Live, "\n"), "\n", "\n    "))
+			fmt.Println()
+		}
+	}
+}
+
+func orNone(index string) string {
+	if index == "" {
+		return "none"
+	}
+	return index
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apsdsm/joka/cmd/shared"
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/apsdsm/joka/internal/domains/schema/app"
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+	"github.com/apsdsm/joka/internal/domains/schema/infra"
+	"github.com/fatih/color"
+)
+
+// RunDocsCommand handles "schema docs". It writes a Markdown page per table
+// (and HTML pages with HTML set) describing columns, indexes and foreign keys,
+// with comments read from the live catalog, plus an index page and a history
+// page built from the stored snapshots. Pages of tables that no longer exist
+// are removed, so the output directory can be regenerated in place (e.g. in
+// CI) and committed.
+type RunDocsCommand struct {
+	DB           *sql.DB
+	Driver       jokadb.Driver
+	Index        string // empty = live database, "latest" = newest snapshot
+	OutDir       string
+	HTML         bool
+	OutputFormat string
+}
+
+func (r RunDocsCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+
+	files, source, tables, err := r.render(ctx)
+	if err == nil {
+		err = writeDocs(r.OutDir, files)
+	}
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if jsonOut {
+		paths := make([]string, len(files))
+		for i, f := range files {
+			paths[i] = f.Path
+		}
+		shared.PrintJSON(map[string]any{"status": "ok", "out": r.OutDir, "source": source.String(), "tables": tables, "files": paths})
+		return nil
+	}
+
+	color.Green("Wrote %d page(s) documenting %d table(s) from the %s to %s.", len(files), tables, source, r.OutDir)
+	return nil
+}
+
+func (r RunDocsCommand) render(ctx context.Context) ([]app.DocFile, schemaSource, int, error) {
+	adapter := newMigrationAdapter(r.Driver, r.DB)
+
+	tables, source, err := loadTables(ctx, adapter, r.Index)
+	if err != nil {
+		return nil, schemaSource{}, 0, err
+	}
+
+	comments, err := infra.NewCatalogAdapter(r.Driver, r.DB).GetComments(ctx)
+	if err != nil {
+		return nil, schemaSource{}, 0, fmt.Errorf("reading comments: %w", err)
+	}
+	app.ApplyComments(tables, comments)
+
+	history, err := app.TableHistoryAction{Snapshots: adapter}.Execute(ctx)
+	if err != nil {
+		return nil, schemaSource{}, 0, err
+	}
+
+	in := app.DocsInput{Tables: tables, History: history, Source: source.String()}
+	files := app.RenderDocs(in, domain.DocsMarkdown)
+	if r.HTML {
+		files = append(files, app.RenderDocs(in, domain.DocsHTML)...)
+	}
+	return files, source, len(tables), nil
+}
+
+// writeDocs writes files below dir and removes table pages, in the formats
+// just written, left from tables that have since been dropped.
+func writeDocs(dir string, files []app.DocFile) error {
+	tablesDir := filepath.Join(dir, app.TablesDir)
+	if err := os.MkdirAll(tablesDir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", tablesDir, err)
+	}
+
+	written := make(map[string]bool, len(files))
+	formats := make(map[string]bool)
+	for _, f := range files {
+		formats[filepath.Ext(f.Path)] = true
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("writing docs: %w", err)
+		}
+		written[path] = true
+	}
+
+	entries, err := os.ReadDir(tablesDir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", tablesDir, err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(tablesDir, e.Name())
+		if e.IsDir() || written[path] || !formats[filepath.Ext(e.Name())] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing stale page: %w", err)
+		}
+	}
+	return nil
+}
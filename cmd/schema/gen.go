@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+
+	"github.com/apsdsm/joka/cmd/shared"
+	jokadb "github.com/apsdsm/joka/db"
+	migrationapp "github.com/apsdsm/joka/internal/domains/migration/app"
+	"github.com/apsdsm/joka/internal/domains/schema/app"
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+	"github.com/apsdsm/joka/internal/domains/schema/infra"
+	"github.com/fatih/color"
+)
+
+// RunGenGoCommand handles "schema gen go". It writes a Go struct per table,
+// and a type per enum, to schema_gen.go in OutDir. With Check set it writes
+// nothing and fails if the file on disk differs from what would be generated,
+// for CI.
+type RunGenGoCommand struct {
+	DB           *sql.DB
+	Driver       jokadb.Driver
+	Index        string // empty = live database, "latest" = newest snapshot
+	OutDir       string
+	Package      string
+	Nullable     domain.NullableStyle
+	Check        bool
+	OutputFormat string
+}
+
+func (r RunGenGoCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+	path := filepath.Join(r.OutDir, app.GoFileName)
+
+	src, opts, err := r.generate(ctx)
+	if err == nil {
+		if r.Check {
+			err = checkGenerated(path, src)
+		} else {
+			err = writeGenerated(r.OutDir, path, src)
+		}
+	}
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		color.Red("Error: %v", err)
+		return err
+	}
+
+	if jsonOut {
+		shared.PrintJSON(map[string]any{"status": "ok", "path": path, "package": opts.Package, "migration_index": opts.MigrationIndex, "checked": r.Check})
+		return nil
+	}
+
+	if r.Check {
+		color.Green("%s is up to date.", path)
+	} else {
+		color.Green("Wrote %s (package %s).", path, opts.Package)
+	}
+	return nil
+}
+
+func (r RunGenGoCommand) generate(ctx context.Context) ([]byte, domain.GoGenOptions, error) {
+	opts := domain.GoGenOptions{Package: r.Package, Nullable: r.Nullable}
+	if !token.IsIdentifier(r.Package) {
+		return nil, opts, fmt.Errorf("%q is not a valid Go package name; pass --package", r.Package)
+	}
+	adapter := newMigrationAdapter(r.Driver, r.DB)
+
+	tables, source, err := loadTables(ctx, adapter, r.Index)
+	if err != nil {
+		return nil, opts, err
+	}
+	opts.MigrationIndex = source.index
+	if source.live {
+		if opts.MigrationIndex, err = lastAppliedMigration(ctx, adapter); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	catalog := infra.NewCatalogAdapter(r.Driver, r.DB)
+	comments, err := catalog.GetComments(ctx)
+	if err != nil {
+		return nil, opts, fmt.Errorf("reading comments: %w", err)
+	}
+	app.ApplyComments(tables, comments)
+	enums, err := catalog.GetEnums(ctx)
+	if err != nil {
+		return nil, opts, fmt.Errorf("reading enum types: %w", err)
+	}
+
+	src, err := app.GenerateGo(tables, enums, opts)
+	return src, opts, err
+}
+
+// lastAppliedMigration returns the index of the newest applied migration, or
+// "" when none has been applied.
+func lastAppliedMigration(ctx context.Context, adapter migrationapp.DBAdapter) (string, error) {
+	exists, err := adapter.HasMigrationsTable(ctx)
+	if err != nil || !exists {
+		return "", err
+	}
+	rows, err := adapter.GetAppliedMigrations(ctx)
+	if err != nil || len(rows) == 0 {
+		return "", err
+	}
+	return rows[len(rows)-1].MigrationIndex, nil
+}
+
+func writeGenerated(dir, path string, src []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func checkGenerated(path string, src []byte) error {
+	current, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s does not exist; run joka schema gen go", path)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	if !bytes.Equal(current, src) {
+		return fmt.Errorf("%s is out of date; run joka schema gen go", path)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	jokadb "github.com/apsdsm/joka/db"
+	migrationapp "github.com/apsdsm/joka/internal/domains/migration/app"
+	migrationinfra "github.com/apsdsm/joka/internal/domains/migration/infra"
+	"github.com/apsdsm/joka/internal/domains/schema/app"
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+func newMigrationAdapter(driver jokadb.Driver, conn *sql.DB) migrationapp.DBAdapter {
+	if driver == jokadb.Postgres {
+		return migrationinfra.NewPostgresDBAdapter(conn)
+	}
+	return migrationinfra.NewMySQLDBAdapter(conn)
+}
+
+// schemaSource is where loadTables read the schema from.
+type schemaSource struct {
+	live  bool
+	index string // the snapshot's migration index; empty when live
+}
+
+func (s schemaSource) String() string {
+	if s.live {
+		return "live database"
+	}
+	return "snapshot " + s.index
+}
+
+// loadTables reads the schema the schema commands describe: the live database
+// when index is empty, the newest snapshot for "latest", and otherwise the
+// snapshot taken after the given migration.
+func loadTables(ctx context.Context, adapter migrationapp.DBAdapter, index string) ([]domain.Table, schemaSource, error) {
+	if index == "" {
+		schema, err := adapter.ComputeSchema(ctx)
+		if err != nil {
+			return nil, schemaSource{}, fmt.Errorf("reading schema: %w", err)
+		}
+		return app.ParseSchema(schema), schemaSource{live: true}, nil
+	}
+
+	if index == "latest" {
+		var err error
+		if index, err = adapter.GetLatestSnapshotIndex(ctx); err != nil {
+			return nil, schemaSource{}, err
+		}
+	}
+
+	snapshot, err := adapter.GetSchemaSnapshot(ctx, index)
+	if err != nil {
+		return nil, schemaSource{}, err
+	}
+	var schema map[string]string
+	if err := json.Unmarshal([]byte(snapshot), &schema); err != nil {
+		return nil, schemaSource{}, fmt.Errorf("parsing snapshot for migration %s: %w", index, err)
+	}
+	return app.ParseSchema(schema), schemaSource{index: index}, nil
+}
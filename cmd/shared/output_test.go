@@ -118,3 +118,18 @@ func TestPrintErrorJSON(t *testing.T) {
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && bytes.Contains([]byte(s), []byte(substr))
 }
+
+func TestFormatTimeouts(t *testing.T) {
+	t.Run("it renders settings sorted by name", func(t *testing.T) {
+		got := FormatTimeouts(map[string]string{"statement_timeout": "5m0s", "lock_timeout": "15s"})
+		if want := "lock_timeout=15s, statement_timeout=5m0s"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("it renders nothing for no settings", func(t *testing.T) {
+		if got := FormatTimeouts(map[string]string{}); got != "" {
+			t.Errorf("expected empty string, got %q", got)
+		}
+	})
+}
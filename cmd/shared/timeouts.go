@@ -0,0 +1,23 @@
+package shared
+
+import (
+	"sort"
+	"strings"
+)
+
+// FormatTimeouts renders session timeout settings (as returned by
+// db.Timeouts.Settings) as "name=value" pairs in a stable order, e.g.
+// "lock_timeout=15s, statement_timeout=5m0s".
+func FormatTimeouts(settings map[string]string) string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + settings[name]
+	}
+	return strings.Join(parts, ", ")
+}
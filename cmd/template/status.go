@@ -0,0 +1,86 @@
+package template
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/fatih/color"
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/apsdsm/joka/cmd/shared"
+	"github.com/apsdsm/joka/internal/domains/template/app"
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/domains/template/infra"
+)
+
+// RunDataStatusCommand handles the "data status" command.
+type RunDataStatusCommand struct {
+	DB           *sql.DB
+	Driver       jokadb.Driver
+	TemplatesDir string
+	Tables       []infra.TableConfig
+	OutputFormat string
+}
+
+func (r RunDataStatusCommand) Execute(ctx context.Context) error {
+	jsonOut := r.OutputFormat == shared.OutputJSON
+
+	dbAdapter := newTemplateAdapter(r.Driver, r.DB)
+
+	if err := dbAdapter.EnsureTrackingTable(ctx); err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(fmt.Errorf("ensuring tracking table: %w", err))
+		}
+		return fmt.Errorf("ensuring tracking table: %w", err)
+	}
+
+	tables, err := infra.GetTables(r.TemplatesDir, r.Tables)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+
+	results, err := app.TableStatusAction{DB: dbAdapter, Tables: tables}.Execute(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+
+	if jsonOut {
+		if results == nil {
+			results = []domain.TableSyncInfo{}
+		}
+		shared.PrintJSON(map[string]any{"status": "ok", "tables": results})
+		return nil
+	}
+
+	if len(results) == 0 {
+		color.Yellow("No tables configured for sync.")
+		return nil
+	}
+
+	fmt.Println()
+	color.Set(color.Bold)
+	fmt.Println("Template table status:")
+	color.Unset()
+
+	for _, info := range results {
+		switch info.Status {
+		case domain.StatusSynced:
+			color.Green("  [synced]    %s", info.Table)
+		case domain.StatusModified:
+			color.Yellow("  [modified]  %s", info.Table)
+		case domain.StatusNew:
+			color.Cyan("  [new]       %s", info.Table)
+		case domain.StatusOrphaned:
+			color.Red("  [orphaned]  %s", info.Table)
+		}
+	}
+
+	fmt.Println()
+	return nil
+}
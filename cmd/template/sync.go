@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 	jokadb "github.com/apsdsm/joka/db"
@@ -12,10 +13,13 @@ import (
 	"github.com/apsdsm/joka/internal/domains/template/app"
 	"github.com/apsdsm/joka/internal/domains/template/domain"
 	"github.com/apsdsm/joka/internal/domains/template/infra"
+	"github.com/apsdsm/joka/internal/expr"
 )
 
 // RunDataSyncCommand handles the "data sync" command. It reads table configs
 // and data files from the templates directory, then syncs them to the database.
+// Tables whose files are unchanged since their last sync are skipped, and the
+// rest are synced parents first, by their foreign keys.
 type RunDataSyncCommand struct {
 	DB                *sql.DB
 	Driver            jokadb.Driver
@@ -24,9 +28,23 @@ type RunDataSyncCommand struct {
 	AutoConfirm       bool
 	IgnoreForeignKeys bool
 	OutputFormat      string
+	// DryRun prints the rows each table would insert, update or delete,
+	// column by column, and exits without applying (or locking).
+	DryRun bool
+	// BatchSize is how many rows each insert of a truncated table carries.
+	// Zero uses app.DefaultBatchSize.
+	BatchSize int
+	// Force syncs every table, even those whose content hash matches the
+	// one recorded in joka_templates at their last sync.
+	Force bool
 	// SkipLock skips advisory lock acquisition. Used when an outer command
 	// (e.g. `joka reset`) already holds the lock.
 	SkipLock bool
+	// Timeouts are the session timeouts applied to the sync transaction.
+	Timeouts jokadb.Timeouts
+	// Secrets resolves {{ asm.<source>.<key> }} references in record files
+	// against the `secrets:` sources in .jokarc.yaml.
+	Secrets expr.SecretResolver
 }
 
 // Execute acquires an advisory lock, syncs all configured tables inside a
@@ -34,7 +52,11 @@ type RunDataSyncCommand struct {
 func (r RunDataSyncCommand) Execute(ctx context.Context) error {
 	jsonOut := r.OutputFormat == shared.OutputJSON
 
-	if !r.SkipLock {
+	if r.Force && !jsonOut {
+		color.Yellow("Forced re-sync: every table will be synced regardless of its stored hash.")
+	}
+
+	if !r.SkipLock && !r.DryRun {
 		// Acquire advisory lock to prevent concurrent sync/migration runs.
 		lockAdapter := lockinfra.NewLockAdapter(r.Driver, r.DB)
 		if err := lockAdapter.Acquire(ctx, "data sync"); err != nil {
@@ -64,22 +86,70 @@ func (r RunDataSyncCommand) Execute(ctx context.Context) error {
 		return nil
 	}
 
-	// Pre-load row counts for display/confirmation.
-	type tablePreview struct {
-		Table domain.Table
-		Rows  int
-		Files int
+	dbAdapter := newTemplateAdapter(r.Driver, r.DB)
+
+	synced, err := r.syncedTables(ctx, dbAdapter)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
 	}
-	var previews []tablePreview
-	for _, table := range tables {
-		rows, err := app.LoadTableDataAction{Table: table}.Execute(ctx)
+
+	all := tables
+	tables, hashes, skipped, err := r.changedTables(ctx, synced, all)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+
+	if len(tables) == 0 {
+		if jsonOut {
+			shared.PrintJSON(map[string]any{"status": "ok", "tables": []string{}, "skipped": skipped, "message": "all tables already synced"})
+			return nil
+		}
+		color.Green("All template tables already synced.")
+		return nil
+	}
+
+	// A skipped table that references a truncated one has to be synced too:
+	// a cascade would empty it, and otherwise it blocks the TRUNCATE.
+	tables, err = app.ReferencingTablesAction{DB: dbAdapter, Tables: tables, All: all}.Execute(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+	skipped = skippedTables(all, tables)
+
+	tables, err = app.OrderTablesAction{DB: dbAdapter, Tables: tables}.Execute(ctx)
+	if err != nil {
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+
+	if r.DryRun {
+		plan, err := app.PlanSyncAction{DB: dbAdapter, Tables: tables}.Execute(ctx)
 		if err != nil {
 			if jsonOut {
 				return shared.PrintErrorJSON(err)
 			}
+			color.Red("Error: %v", err)
 			return err
 		}
-		previews = append(previews, tablePreview{Table: table, Rows: len(rows), Files: len(table.Records)})
+		if jsonOut {
+			shared.PrintJSON(map[string]any{"status": "ok", "dry_run": true, "plan": planJSON(plan), "skipped": skipped})
+			return nil
+		}
+		printSkipped(skipped)
+		printPlan(plan)
+		color.Yellow("\nDry run — no changes applied.")
+		return nil
 	}
 
 	if !jsonOut {
@@ -87,10 +157,24 @@ func (r RunDataSyncCommand) Execute(ctx context.Context) error {
 		color.Set(color.Bold)
 		fmt.Println("Tables to sync:")
 		color.Unset()
-		for _, p := range previews {
-			color.Cyan("  %s (%s): %d rows from %d files", p.Table.Name, p.Table.Strategy, p.Rows, p.Files)
+		for _, table := range tables {
+			// Count the rows as they stream by rather than loading the table.
+			rows := 0
+			err := app.LoadTableDataAction{Table: table}.Stream(ctx, func(map[string]any) error {
+				rows++
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			color.Cyan("  %s (%s): %d rows from %d files", table.Name, table.Strategy, rows, len(table.Records))
 		}
 		fmt.Println()
+		printSkipped(skipped)
+
+		if settings := r.Timeouts.Settings(r.Driver); len(settings) > 0 {
+			fmt.Printf("Session timeouts: %s\n\n", shared.FormatTimeouts(settings))
+		}
 
 		if !r.AutoConfirm {
 			if !shared.Confirm("Proceed with sync? (only 'yes' will confirm): ") {
@@ -100,13 +184,22 @@ func (r RunDataSyncCommand) Execute(ctx context.Context) error {
 		}
 	}
 
-	tx, err := r.DB.BeginTx(ctx, nil)
+	tx, endTx, err := jokadb.BeginTx(ctx, r.DB, r.Driver, r.Timeouts)
 	if err != nil {
 		if jsonOut {
 			return shared.PrintErrorJSON(fmt.Errorf("starting transaction: %w", err))
 		}
 		return fmt.Errorf("starting transaction: %w", err)
 	}
+	defer endTx()
+
+	if err := jokadb.ApplyTimeouts(ctx, tx, r.Driver, r.Timeouts); err != nil {
+		tx.Rollback()
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
 
 	txAdapter := newTemplateTxAdapter(r.Driver, tx, r.DB)
 
@@ -120,37 +213,88 @@ func (r RunDataSyncCommand) Execute(ctx context.Context) error {
 		}
 	}
 
+	// Truncated tables are emptied up front, children before parents, so a
+	// parent isn't truncated while a child still references it.
+	if err := (app.TruncateTablesAction{DB: txAdapter, Tables: tables}).Execute(ctx); err != nil {
+		tx.Rollback()
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+
+	// Stale rows of delete tables go next, also children first, so deleting a
+	// parent row and the child rows referencing it in the same change works.
+	deleted, err := app.PruneTablesAction{DB: txAdapter, Tables: tables, Secrets: r.Secrets}.Execute(ctx)
+	if err != nil {
+		tx.Rollback()
+		if jsonOut {
+			return shared.PrintErrorJSON(err)
+		}
+		return err
+	}
+
 	type tableResult struct {
 		Name       string `json:"name"`
 		Strategy   string `json:"strategy"`
 		RowsSynced int    `json:"rows_synced"`
+		domain.SyncResult
 	}
 	var results []tableResult
 
 	for _, table := range tables {
-		if table.Strategy == domain.StrategyTruncate {
+		if !jsonOut {
+			color.Cyan("Syncing %s...", table.Name)
+		}
+
+		var result domain.SyncResult
+		switch table.Strategy {
+		case domain.StrategyTruncate:
+			action := app.SyncTableAction{DB: txAdapter, Table: table, BatchSize: r.BatchSize, Secrets: r.Secrets}
 			if !jsonOut {
-				color.Cyan("Syncing %s...", table.Name)
+				action.Progress = func(inserted int) { fmt.Printf("\r  %d rows...", inserted) }
 			}
-
-			count, err := app.SyncTableAction{DB: txAdapter, Table: table}.Execute(ctx)
-			if err != nil {
-				tx.Rollback()
-				if jsonOut {
-					return shared.PrintErrorJSON(err)
-				}
-				return err
+			result.Inserted, err = action.Execute(ctx)
+		case domain.StrategyUpdate:
+			result, err = app.UpsertTableAction{DB: txAdapter, Table: table, Secrets: r.Secrets}.Execute(ctx)
+		case domain.StrategyDelete:
+			result, err = app.UpsertTableAction{DB: txAdapter, Table: table, Secrets: r.Secrets}.Execute(ctx)
+			result.Deleted = deleted[table.Name]
+		default:
+			err = fmt.Errorf("unknown strategy %q for %s (expected truncate, update or delete)", table.Strategy, table.Name)
+		}
+		if err != nil {
+			tx.Rollback()
+			if jsonOut {
+				return shared.PrintErrorJSON(err)
 			}
-			if !jsonOut {
-				fmt.Printf("  Synced %d rows\n", count)
+			return err
+		}
+
+		if err := txAdapter.RecordTableSynced(ctx, table.Name, hashes[table.Name]); err != nil {
+			tx.Rollback()
+			if jsonOut {
+				return shared.PrintErrorJSON(fmt.Errorf("recording %s as synced: %w", table.Name, err))
 			}
-			results = append(results, tableResult{Name: table.Name, Strategy: string(table.Strategy), RowsSynced: count})
-		} else {
-			if !jsonOut {
-				color.Yellow("Strategy '%s' not yet implemented for %s, skipping.", table.Strategy, table.Name)
+			return fmt.Errorf("recording %s as synced: %w", table.Name, err)
+		}
+
+		if !jsonOut {
+			switch table.Strategy {
+			case domain.StrategyTruncate:
+				fmt.Printf("\r  Synced %d rows\n", result.Inserted)
+			case domain.StrategyUpdate:
+				fmt.Printf("  %d inserted, %d updated, %d unchanged\n", result.Inserted, result.Updated, result.Unchanged)
+			default:
+				fmt.Printf("  %d inserted, %d updated, %d unchanged, %d deleted\n", result.Inserted, result.Updated, result.Unchanged, result.Deleted)
 			}
-			results = append(results, tableResult{Name: table.Name, Strategy: string(table.Strategy), RowsSynced: 0})
 		}
+		results = append(results, tableResult{
+			Name:       table.Name,
+			Strategy:   string(table.Strategy),
+			RowsSynced: result.Inserted + result.Updated + result.Deleted,
+			SyncResult: result,
+		})
 	}
 
 	if r.IgnoreForeignKeys {
@@ -171,7 +315,7 @@ func (r RunDataSyncCommand) Execute(ctx context.Context) error {
 	}
 
 	if jsonOut {
-		shared.PrintJSON(map[string]any{"status": "ok", "tables": results})
+		shared.PrintJSON(map[string]any{"status": "ok", "tables": results, "skipped": skipped, "timeouts": r.Timeouts.Settings(r.Driver)})
 		return nil
 	}
 
@@ -180,6 +324,198 @@ func (r RunDataSyncCommand) Execute(ctx context.Context) error {
 	return nil
 }
 
+// syncedTables returns the hash recorded for each synced table, creating
+// joka_templates if needed. A dry run doesn't write: it reads joka_templates
+// only if it exists, and otherwise treats every table as never synced.
+func (r RunDataSyncCommand) syncedTables(ctx context.Context, db app.DBAdapter) (map[string]string, error) {
+	if r.DryRun {
+		tracked, err := jokadb.TableExists(ctx, r.DB, r.Driver, "joka_templates")
+		if err != nil {
+			return nil, err
+		}
+		if !tracked {
+			return map[string]string{}, nil
+		}
+	} else if err := db.EnsureTrackingTable(ctx); err != nil {
+		return nil, fmt.Errorf("ensuring tracking table: %w", err)
+	}
+	return db.GetAllSyncedTables(ctx)
+}
+
+// changedTables hashes each table and returns those that changed since their
+// last sync (all of them with --force), the hashes of every table, and the
+// names of the unchanged tables. Tables with lookups or secret references
+// always count as changed, since their values can change while their files
+// don't.
+func (r RunDataSyncCommand) changedTables(ctx context.Context, synced map[string]string, tables []domain.Table) ([]domain.Table, map[string]string, []string, error) {
+	var changed []domain.Table
+	hashes := make(map[string]string, len(tables))
+	skipped := []string{}
+	for _, table := range tables {
+		hash, err := app.HashTable(table)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hashes[table.Name] = hash
+		if dbHash, ok := synced[table.Name]; ok && dbHash == hash && !r.Force {
+			external, err := app.HasExternalValues(ctx, table)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if !external {
+				skipped = append(skipped, table.Name)
+				continue
+			}
+		}
+		changed = append(changed, table)
+	}
+	return changed, hashes, skipped, nil
+}
+
+// skippedTables returns the names of the tables of all that aren't in tables.
+func skippedTables(all, tables []domain.Table) []string {
+	syncing := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		syncing[t.Name] = true
+	}
+	skipped := []string{}
+	for _, t := range all {
+		if !syncing[t.Name] {
+			skipped = append(skipped, t.Name)
+		}
+	}
+	return skipped
+}
+
+func printSkipped(skipped []string) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Printf("Unchanged since the last sync (skipped): %s\n\n", strings.Join(skipped, ", "))
+}
+
+// printPlan renders a SyncPlan as a human-readable preview: per table, the
+// rows to insert and delete and per-column before/after diffs of the rows to
+// update.
+func printPlan(plan *app.SyncPlan) {
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+
+	for _, t := range plan.Tables {
+		fmt.Println()
+		color.Set(color.Bold)
+		fmt.Printf("%s (%s):", t.Table, t.Strategy)
+		color.Unset()
+		if !t.HasChanges() {
+			fmt.Println(" no changes")
+			continue
+		}
+		fmt.Printf(" %d to insert, %d to update, %d to delete\n", len(t.Inserts), len(t.Updates), len(t.Deletes))
+
+		for _, row := range t.Inserts {
+			green.Printf("  + %s\n", rowLabel(t.Table, row.Key))
+			printValues(row.Values)
+		}
+		for _, row := range t.Updates {
+			color.Set(color.Bold)
+			fmt.Printf("  ~ %s\n", rowLabel(t.Table, row.Key))
+			color.Unset()
+			for _, c := range row.Changes {
+				if c.Regenerated {
+					fmt.Printf("      %s: (regenerated)\n", c.Column)
+					continue
+				}
+				fmt.Printf("      %s:\n", c.Column)
+				red.Printf("        - %s\n", c.Before)
+				if c.Deferred {
+					green.Printf("        + (lookup, resolved at apply time)\n")
+					continue
+				}
+				green.Printf("        + %s\n", c.After)
+			}
+		}
+		for _, row := range t.Deletes {
+			red.Printf("  - %s\n", rowLabel(t.Table, row.Key))
+			printValues(row.Values)
+		}
+	}
+}
+
+// rowLabel is e.g. "currencies (code=USD)".
+func rowLabel(table string, key []app.ColumnValue) string {
+	if len(key) == 0 {
+		return table
+	}
+	parts := make([]string, len(key))
+	for i, k := range key {
+		parts[i] = k.Column + "=" + k.Value
+	}
+	return fmt.Sprintf("%s (%s)", table, strings.Join(parts, ", "))
+}
+
+func printValues(values []app.ColumnValue) {
+	for _, v := range values {
+		if v.Note != "" {
+			fmt.Printf("      %s: (%s)\n", v.Column, v.Note)
+			continue
+		}
+		fmt.Printf("      %s: %s\n", v.Column, v.Value)
+	}
+}
+
+// planJSON converts a SyncPlan into plain maps/slices for JSON output.
+func planJSON(plan *app.SyncPlan) map[string]any {
+	values := func(cvs []app.ColumnValue) []map[string]any {
+		out := make([]map[string]any, 0, len(cvs))
+		for _, v := range cvs {
+			out = append(out, map[string]any{"column": v.Column, "value": v.Value, "note": v.Note})
+		}
+		return out
+	}
+	rows := func(plans []app.RowPlan) []map[string]any {
+		out := make([]map[string]any, 0, len(plans))
+		for _, row := range plans {
+			out = append(out, map[string]any{"key": values(row.Key), "values": values(row.Values)})
+		}
+		return out
+	}
+
+	tables := make([]map[string]any, 0, len(plan.Tables))
+	for _, t := range plan.Tables {
+		updates := make([]map[string]any, 0, len(t.Updates))
+		for _, row := range t.Updates {
+			changes := make([]map[string]any, 0, len(row.Changes))
+			for _, c := range row.Changes {
+				changes = append(changes, map[string]any{
+					"column": c.Column, "before": c.Before, "after": c.After, "regenerated": c.Regenerated, "deferred": c.Deferred,
+				})
+			}
+			updates = append(updates, map[string]any{"key": values(row.Key), "changes": changes})
+		}
+		key := t.Key
+		if key == nil {
+			key = []string{}
+		}
+		tables = append(tables, map[string]any{
+			"table":    t.Table,
+			"strategy": string(t.Strategy),
+			"key":      key,
+			"inserts":  rows(t.Inserts),
+			"updates":  updates,
+			"deletes":  rows(t.Deletes),
+		})
+	}
+
+	return map[string]any{"tables": tables}
+}
+
+func newTemplateAdapter(driver jokadb.Driver, conn *sql.DB) app.DBAdapter {
+	if driver == jokadb.Postgres {
+		return infra.NewPostgresDBAdapter(conn)
+	}
+	return infra.NewMySQLDBAdapter(conn)
+}
+
 func newTemplateTxAdapter(driver jokadb.Driver, tx *sql.Tx, conn *sql.DB) app.DBAdapter {
 	if driver == jokadb.Postgres {
 		return infra.NewPostgresTxDBAdapter(tx, conn)
@@ -3,14 +3,54 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/apsdsm/joka/internal/domains/template/domain"
 	"gopkg.in/yaml.v3"
 )
 
+// TableConfig configures how data sync writes one table. Key names the
+// columns the update strategy matches rows on; empty means the primary key.
+// NullToken is the CSV/TSV cell text that means NULL (e.g. \N); empty
+// means none. Columns maps the column names used in the record files to how
+// they are written. Truncate tunes the truncate strategy's TRUNCATE.
 type TableConfig struct {
-	Name     string              `yaml:"name"`
-	Strategy domain.StrategyType `yaml:"strategy"`
+	Name      string                  `yaml:"name"`
+	Strategy  domain.StrategyType     `yaml:"strategy"`
+	Key       []string                `yaml:"key"`
+	NullToken string                  `yaml:"null_token"`
+	Columns   map[string]ColumnConfig `yaml:"columns"`
+	Truncate  TruncateConfig          `yaml:"truncate"`
+}
+
+// TruncateConfig is a table's `truncate:` options. RestartIdentity, true
+// when unset, resets the table's identity sequences (RESTART IDENTITY);
+// Cascade truncates the tables referencing it too (CASCADE). Both are
+// PostgreSQL options.
+type TruncateConfig struct {
+	RestartIdentity *bool `yaml:"restart_identity"`
+	Cascade         bool  `yaml:"cascade"`
+}
+
+// ColumnConfig is one entry of a table's `columns:` map. A plain string
+// renames the column (`csv_header: db_column`); a mapping can also set a
+// default for rows without the column, ignore it, or compute it from the
+// row's other columns (`value: "${first} ${last}"`).
+type ColumnConfig struct {
+	Column  string `yaml:"column"`
+	Default any    `yaml:"default"`
+	Ignore  bool   `yaml:"ignore"`
+	Value   string `yaml:"value"`
+}
+
+// UnmarshalYAML accepts either a column name or a mapping.
+func (c *ColumnConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Column = node.Value
+		return nil
+	}
+	type plain ColumnConfig
+	return node.Decode((*plain)(c))
 }
 
 // Secret describes where to pull secrets from: either connection secrets when
@@ -52,26 +92,105 @@ type Connection struct {
 	Secret   *Secret           `yaml:"secret"`
 }
 
+// Timeouts configures the lock and statement timeouts applied to the
+// transactions opened by migrate up, data sync and entity sync. Values are Go
+// durations ("15s", "2m"); unset values leave the server setting alone.
+// lock_timeout/statement_timeout apply to PostgreSQL, lock_wait_timeout/
+// max_execution_time to MySQL.
+type Timeouts struct {
+	LockTimeout      time.Duration `yaml:"lock_timeout"`
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+	LockWaitTimeout  time.Duration `yaml:"lock_wait_timeout"`
+	MaxExecutionTime time.Duration `yaml:"max_execution_time"`
+}
+
+// UnmarshalYAML rejects a timeout that is set but not positive: 0 would
+// disable the server's timeout rather than leave it alone.
+func (t *Timeouts) UnmarshalYAML(node *yaml.Node) error {
+	var raw struct {
+		LockTimeout      *time.Duration `yaml:"lock_timeout"`
+		StatementTimeout *time.Duration `yaml:"statement_timeout"`
+		LockWaitTimeout  *time.Duration `yaml:"lock_wait_timeout"`
+		MaxExecutionTime *time.Duration `yaml:"max_execution_time"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name  string
+		value *time.Duration
+		dst   *time.Duration
+	}{
+		{"lock_timeout", raw.LockTimeout, &t.LockTimeout},
+		{"statement_timeout", raw.StatementTimeout, &t.StatementTimeout},
+		{"lock_wait_timeout", raw.LockWaitTimeout, &t.LockWaitTimeout},
+		{"max_execution_time", raw.MaxExecutionTime, &t.MaxExecutionTime},
+	} {
+		if f.value == nil {
+			continue
+		}
+		if *f.value <= 0 {
+			return fmt.Errorf("timeouts.%s must be positive, got %s", f.name, *f.value)
+		}
+		*f.dst = *f.value
+	}
+	return nil
+}
+
+// Snapshots configures how migrate up captures and stores schema snapshots.
+// Compress gzips each table's DDL; Retention, when positive, prunes all but
+// the newest Retention snapshots after each migrate up; Mode is
+// "per-migration" (the default) or "end-of-batch".
+type Snapshots struct {
+	Compress  bool   `yaml:"compress"`
+	Retention int    `yaml:"retention"`
+	Mode      string `yaml:"mode"`
+}
+
+// Gen configures code generated from the schema by `joka schema gen`. Flags
+// override each field.
+type Gen struct {
+	Go GoGen `yaml:"go"`
+}
+
+// GoGen configures `joka schema gen go`: the output directory, the package
+// name (default: the directory's name) and how nullable columns are typed
+// ("sql", "pointer" or "generic").
+type GoGen struct {
+	Out      string `yaml:"out"`
+	Package  string `yaml:"package"`
+	Nullable string `yaml:"nullable"`
+}
+
 // Profile overlays the base config. Set (non-nil) fields override the base;
 // unset fields inherit it.
 type Profile struct {
 	Migrations        *string           `yaml:"migrations"`
 	Templates         *string           `yaml:"templates"`
 	Entities          *string           `yaml:"entities"`
+	Scaffolds         *string           `yaml:"scaffolds"`
 	Tables            []TableConfig     `yaml:"tables"`
 	IgnoreForeignKeys *bool             `yaml:"ignore_foreign_keys"`
+	BatchSize         *int              `yaml:"batch_size"`
 	Connection        *Connection       `yaml:"connection"`
 	Secrets           map[string]Secret `yaml:"secrets"`
+	Timeouts          *Timeouts         `yaml:"timeouts"`
+	Snapshots         *Snapshots        `yaml:"snapshots"`
 }
 
 type Config struct {
 	Migrations        string             `yaml:"migrations"`
 	Templates         string             `yaml:"templates"`
 	Entities          string             `yaml:"entities"`
+	Scaffolds         string             `yaml:"scaffolds"`
 	Tables            []TableConfig      `yaml:"tables"`
 	IgnoreForeignKeys bool               `yaml:"ignore_foreign_keys"`
+	BatchSize         int                `yaml:"batch_size"`
 	Connection        *Connection        `yaml:"connection"`
 	Secrets           map[string]Secret  `yaml:"secrets"`
+	Timeouts          Timeouts           `yaml:"timeouts"`
+	Snapshots         Snapshots          `yaml:"snapshots"`
+	Gen               Gen                `yaml:"gen"`
 	Profiles          map[string]Profile `yaml:"profiles"`
 }
 
@@ -123,12 +242,18 @@ func applyProfile(base *Config, p Profile) *Config {
 	if p.Entities != nil {
 		merged.Entities = *p.Entities
 	}
+	if p.Scaffolds != nil {
+		merged.Scaffolds = *p.Scaffolds
+	}
 	if p.Tables != nil {
 		merged.Tables = p.Tables
 	}
 	if p.IgnoreForeignKeys != nil {
 		merged.IgnoreForeignKeys = *p.IgnoreForeignKeys
 	}
+	if p.BatchSize != nil {
+		merged.BatchSize = *p.BatchSize
+	}
 	if p.Connection != nil {
 		merged.Connection = p.Connection
 	}
@@ -142,6 +267,25 @@ func applyProfile(base *Config, p Profile) *Config {
 		}
 		merged.Secrets = sources
 	}
+	if p.Timeouts != nil {
+		// Overlay per field so a profile can tighten one timeout without
+		// restating the rest.
+		if p.Timeouts.LockTimeout != 0 {
+			merged.Timeouts.LockTimeout = p.Timeouts.LockTimeout
+		}
+		if p.Timeouts.StatementTimeout != 0 {
+			merged.Timeouts.StatementTimeout = p.Timeouts.StatementTimeout
+		}
+		if p.Timeouts.LockWaitTimeout != 0 {
+			merged.Timeouts.LockWaitTimeout = p.Timeouts.LockWaitTimeout
+		}
+		if p.Timeouts.MaxExecutionTime != 0 {
+			merged.Timeouts.MaxExecutionTime = p.Timeouts.MaxExecutionTime
+		}
+	}
+	if p.Snapshots != nil {
+		merged.Snapshots = *p.Snapshots
+	}
 
 	return &merged
 }
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/apsdsm/joka/internal/domains/template/domain"
 )
@@ -21,6 +23,8 @@ tables:
     strategy: truncate
   - name: settings
     strategy: update
+    key: [code]
+    null_token: '\N'
 `
 		os.WriteFile(".jokarc.yaml", []byte(yaml), 0644)
 
@@ -40,7 +44,7 @@ tables:
 		if cfg.Tables[0].Name != "emails" || cfg.Tables[0].Strategy != domain.StrategyTruncate {
 			t.Errorf("unexpected first table: %+v", cfg.Tables[0])
 		}
-		if cfg.Tables[1].Name != "settings" || cfg.Tables[1].Strategy != domain.StrategyUpdate {
+		if cfg.Tables[1].Name != "settings" || cfg.Tables[1].Strategy != domain.StrategyUpdate || len(cfg.Tables[1].Key) != 1 || cfg.Tables[1].Key[0] != "code" || cfg.Tables[1].NullToken != `\N` {
 			t.Errorf("unexpected second table: %+v", cfg.Tables[1])
 		}
 	})
@@ -78,6 +82,7 @@ tables:
 func TestLoadProfile(t *testing.T) {
 	const cfgYAML = `migrations: db/migrations
 entities: db/entities
+batch_size: 500
 connection:
   source: env
 profiles:
@@ -86,6 +91,7 @@ profiles:
       source: env
   dev-remote:
     entities: db/entities-dev
+    batch_size: 5000
     connection:
       source: aws_secrets_manager
       driver: mysql
@@ -119,6 +125,9 @@ profiles:
 		if cfg.Entities != "db/entities" {
 			t.Errorf("expected base entities, got %q", cfg.Entities)
 		}
+		if cfg.BatchSize != 500 {
+			t.Errorf("expected base batch size 500, got %d", cfg.BatchSize)
+		}
 		if cfg.Connection == nil || cfg.Connection.Source != "env" {
 			t.Errorf("expected base env connection, got %+v", cfg.Connection)
 		}
@@ -141,6 +150,9 @@ profiles:
 		if cfg.Entities != "db/entities-dev" {
 			t.Errorf("expected overridden entities, got %q", cfg.Entities)
 		}
+		if cfg.BatchSize != 5000 {
+			t.Errorf("expected overridden batch size 5000, got %d", cfg.BatchSize)
+		}
 		if cfg.Connection == nil || cfg.Connection.Source != "aws_secrets_manager" {
 			t.Fatalf("expected aws connection, got %+v", cfg.Connection)
 		}
@@ -225,3 +237,191 @@ profiles:
 		}
 	})
 }
+
+func TestLoadTimeouts(t *testing.T) {
+	const cfgYAML = `timeouts:
+  lock_timeout: 15s
+  statement_timeout: 5m
+  lock_wait_timeout: 30s
+profiles:
+  prod:
+    timeouts:
+      lock_timeout: 3s
+`
+
+	writeCfg := func(t *testing.T) {
+		t.Helper()
+		dir := t.TempDir()
+		orig, _ := os.Getwd()
+		os.Chdir(dir)
+		t.Cleanup(func() { os.Chdir(orig) })
+		if err := os.WriteFile(".jokarc.yaml", []byte(cfgYAML), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("base timeouts are parsed as durations", func(t *testing.T) {
+		writeCfg(t)
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Timeouts.LockTimeout != 15*time.Second || cfg.Timeouts.StatementTimeout != 5*time.Minute {
+			t.Errorf("unexpected timeouts: %+v", cfg.Timeouts)
+		}
+	})
+
+	t.Run("profile overlays timeouts per field", func(t *testing.T) {
+		writeCfg(t)
+		cfg, err := Load("prod")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Timeouts.LockTimeout != 3*time.Second {
+			t.Errorf("expected profile lock_timeout 3s, got %v", cfg.Timeouts.LockTimeout)
+		}
+		if cfg.Timeouts.StatementTimeout != 5*time.Minute || cfg.Timeouts.LockWaitTimeout != 30*time.Second {
+			t.Errorf("expected inherited timeouts, got %+v", cfg.Timeouts)
+		}
+	})
+
+	t.Run("it rejects timeouts that aren't positive", func(t *testing.T) {
+		for _, yml := range []string{"timeouts:\n  lock_timeout: -5s\n", "profiles:\n  prod:\n    timeouts:\n      statement_timeout: 0s\n"} {
+			dir := t.TempDir()
+			orig, _ := os.Getwd()
+			os.Chdir(dir)
+			if err := os.WriteFile(".jokarc.yaml", []byte(yml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(""); err == nil {
+				t.Errorf("expected an error for %q", yml)
+			}
+			os.Chdir(orig)
+		}
+	})
+}
+
+func TestLoadSnapshots(t *testing.T) {
+	const cfgYAML = `snapshots:
+  compress: true
+  retention: 50
+  mode: end-of-batch
+profiles:
+  dev:
+    snapshots:
+      retention: 5
+`
+
+	dir := t.TempDir()
+	orig, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(orig) })
+	if err := os.WriteFile(".jokarc.yaml", []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("base snapshot settings are parsed", func(t *testing.T) {
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cfg.Snapshots.Compress || cfg.Snapshots.Retention != 50 || cfg.Snapshots.Mode != "end-of-batch" {
+			t.Errorf("unexpected snapshots: %+v", cfg.Snapshots)
+		}
+	})
+
+	t.Run("profile replaces the snapshot settings as a whole", func(t *testing.T) {
+		cfg, err := Load("dev")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Snapshots.Compress || cfg.Snapshots.Retention != 5 || cfg.Snapshots.Mode != "" {
+			t.Errorf("unexpected snapshots: %+v", cfg.Snapshots)
+		}
+	})
+}
+
+func TestLoadGen(t *testing.T) {
+	const cfgYAML = `gen:
+  go:
+    out: internal/models
+    package: models
+    nullable: pointer
+`
+
+	dir := t.TempDir()
+	orig, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(orig) })
+	if err := os.WriteFile(".jokarc.yaml", []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("go generation settings are parsed", func(t *testing.T) {
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := GoGen{Out: "internal/models", Package: "models", Nullable: "pointer"}
+		if cfg.Gen.Go != want {
+			t.Errorf("expected %+v, got %+v", want, cfg.Gen.Go)
+		}
+	})
+}
+
+func TestLoadTableColumns(t *testing.T) {
+	const cfgYAML = `tables:
+  - name: users
+    columns:
+      E-mail: email
+      status:
+        default: active
+      legacy_id:
+        ignore: true
+      name:
+        value: "${first} ${last}"
+  - name: events
+    strategy: truncate
+    truncate:
+      restart_identity: false
+      cascade: true
+`
+
+	dir := t.TempDir()
+	orig, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(orig) })
+	if err := os.WriteFile(".jokarc.yaml", []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("a string renames and a mapping sets default, ignore or value", func(t *testing.T) {
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := map[string]ColumnConfig{
+			"E-mail":    {Column: "email"},
+			"status":    {Default: "active"},
+			"legacy_id": {Ignore: true},
+			"name":      {Value: "${first} ${last}"},
+		}
+		if !reflect.DeepEqual(cfg.Tables[0].Columns, want) {
+			t.Errorf("expected %+v, got %+v", want, cfg.Tables[0].Columns)
+		}
+	})
+
+	t.Run("it loads truncate options", func(t *testing.T) {
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tc := cfg.Tables[1].Truncate
+		if tc.RestartIdentity == nil || *tc.RestartIdentity || !tc.Cascade {
+			t.Errorf("expected restart_identity false and cascade, got %+v", tc)
+		}
+		if cfg.Tables[0].Truncate.RestartIdentity != nil {
+			t.Error("expected restart_identity to be unset when not configured")
+		}
+	})
+}
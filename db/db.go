@@ -119,3 +119,37 @@ func TableExists(ctx context.Context, db *sql.DB, driver Driver, tableName strin
 	}
 	return exists == 1, nil
 }
+
+// ColumnExists checks whether the given table in the current database schema
+// has a column with the given name.
+func ColumnExists(ctx context.Context, db *sql.DB, driver Driver, tableName, columnName string) (bool, error) {
+	var query string
+	switch driver {
+	case Postgres:
+		query = `
+			SELECT 1
+			FROM information_schema.columns
+			WHERE table_name = $1
+			AND column_name = $2
+			AND table_schema = current_schema()
+		`
+	default:
+		query = `
+			SELECT 1
+			FROM information_schema.columns
+			WHERE table_name = ?
+			AND column_name = ?
+			AND table_schema = DATABASE()
+		`
+	}
+
+	var exists int
+	err := db.QueryRowContext(ctx, query, tableName, columnName).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("checking column existence: %w", err)
+	}
+	return exists == 1, nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// IsLockError reports whether err (or anything it wraps) is a lock-wait
+// timeout or deadlock reported by either driver. These failures are
+// transient: the same statements may succeed once the competing lock holder
+// finishes, so they are safe to retry after rolling back.
+//
+//   - PostgreSQL: 55P03 lock_not_available (lock_timeout), 40P01 deadlock_detected
+//   - MySQL: 1205 ER_LOCK_WAIT_TIMEOUT (row and metadata locks), 1213 ER_LOCK_DEADLOCK
+func IsLockError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "55P03", "40P01":
+			return true
+		}
+		return false
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case 1205, 1213:
+			return true
+		}
+	}
+
+	return false
+}
+
+// MaxRetryBackoff caps the wait RetryBackoff returns.
+const MaxRetryBackoff = 10 * time.Minute
+
+// RetryBackoff returns the wait before retry number attempt (from 1): base,
+// then twice as long each time, up to MaxRetryBackoff. It doubles by
+// repeated comparison rather than shifting, so a high attempt can't
+// overflow into a zero or negative wait.
+func RetryBackoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	wait := min(base, MaxRetryBackoff)
+	for i := 1; i < attempt && wait < MaxRetryBackoff; i++ {
+		wait = min(wait*2, MaxRetryBackoff)
+	}
+	return wait
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestIsLockError(t *testing.T) {
+	t.Run("it recognises postgres lock timeouts and deadlocks", func(t *testing.T) {
+		for _, code := range []string{"55P03", "40P01"} {
+			err := fmt.Errorf("applying migration 240101000000: %w", &pq.Error{Code: pq.ErrorCode(code)})
+			if !IsLockError(err) {
+				t.Errorf("expected %s to be a lock error", code)
+			}
+		}
+	})
+
+	t.Run("it recognises mysql lock wait timeouts and deadlocks", func(t *testing.T) {
+		for _, n := range []uint16{1205, 1213} {
+			err := fmt.Errorf("applying migration 240101000000: %w", &mysql.MySQLError{Number: n})
+			if !IsLockError(err) {
+				t.Errorf("expected %d to be a lock error", n)
+			}
+		}
+	})
+
+	t.Run("it rejects other failure classes", func(t *testing.T) {
+		cases := []error{
+			errors.New("connection reset"),
+			&pq.Error{Code: "42P01"},        // undefined_table
+			&pq.Error{Code: "57014"},        // query_canceled (statement_timeout)
+			&mysql.MySQLError{Number: 1064}, // syntax error
+		}
+		for _, err := range cases {
+			if IsLockError(err) {
+				t.Errorf("expected %v not to be a lock error", err)
+			}
+		}
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	t.Run("it doubles the wait on each attempt", func(t *testing.T) {
+		for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second} {
+			if got := RetryBackoff(10*time.Second, attempt); got != want {
+				t.Errorf("attempt %d: expected %s, got %s", attempt, want, got)
+			}
+		}
+	})
+
+	t.Run("it caps the wait instead of overflowing", func(t *testing.T) {
+		for _, attempt := range []int{7, 64, 100, 1 << 20} {
+			if got := RetryBackoff(10*time.Second, attempt); got != MaxRetryBackoff {
+				t.Errorf("attempt %d: expected %s, got %s", attempt, MaxRetryBackoff, got)
+			}
+		}
+		if got := RetryBackoff(time.Hour, 1); got != MaxRetryBackoff {
+			t.Errorf("expected a long base to be capped, got %s", got)
+		}
+	})
+}
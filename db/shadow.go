@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Target identifies the server and database a DSN points at, without
+// credentials. It is used to describe shadow databases to the user and to
+// refuse to drop the database joka is operating on.
+type Target struct {
+	Driver   Driver
+	Host     string
+	Database string
+}
+
+// String renders the target for display, e.g. "postgres db.local:5432/app_shadow".
+func (t Target) String() string {
+	return fmt.Sprintf("%s %s/%s", t.Driver, t.Host, t.Database)
+}
+
+// SameDatabase reports whether t and o name the same database on the same
+// server. Hosts are compared with the driver's default port filled in, and
+// localhost, 127.0.0.1 and ::1 count as the same host.
+func (t Target) SameDatabase(o Target) bool {
+	return t.Driver == o.Driver && t.Database == o.Database &&
+		normalizeAddr(t.Driver, t.Host) == normalizeAddr(o.Driver, o.Host)
+}
+
+// normalizeAddr lowercases a host[:port], maps loopback names to localhost
+// and adds the driver's default port when none is given.
+func normalizeAddr(d Driver, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		host = "localhost"
+	}
+	if port == "" {
+		port = "3306"
+		if d == Postgres {
+			port = "5432"
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// systemDatabases can never be used as a shadow database.
+var systemDatabases = map[string]bool{
+	"mysql": true, "information_schema": true, "performance_schema": true, "sys": true,
+	"postgres": true, "template0": true, "template1": true,
+}
+
+// ParseTarget extracts the driver, host and database name from a DSN.
+func ParseTarget(dsn string) (Target, error) {
+	t := Target{Driver: DetectDriver(dsn)}
+
+	if t.Driver == Postgres {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return t, fmt.Errorf("parsing DSN: %w", err)
+		}
+		t.Host = u.Host
+		t.Database = strings.TrimPrefix(u.Path, "/")
+		return t, nil
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return t, fmt.Errorf("parsing DSN: %w", err)
+	}
+	t.Host = cfg.Addr
+	t.Database = cfg.DBName
+	return t, nil
+}
+
+// RecreateDatabase drops the database named in dsn if it exists, creates it
+// empty, and returns an open connection to it. It connects to the server's
+// maintenance database (postgres) or with no default database (MySQL) to do
+// so. Intended only for throwaway shadow databases; system databases are
+// refused.
+func RecreateDatabase(ctx context.Context, dsn string) (*sql.DB, Driver, error) {
+	t, err := ParseTarget(dsn)
+	if err != nil {
+		return nil, t.Driver, err
+	}
+	if t.Database == "" {
+		return nil, t.Driver, fmt.Errorf("shadow DSN must name a database")
+	}
+	if systemDatabases[strings.ToLower(t.Database)] {
+		return nil, t.Driver, fmt.Errorf("refusing to use system database %q as a shadow database", t.Database)
+	}
+
+	var adminDriver, adminDSN, quoted string
+	switch t.Driver {
+	case Postgres:
+		u, _ := url.Parse(dsn)
+		u.Path = "/postgres"
+		adminDriver, adminDSN = "postgres", u.String()
+		quoted = pq.QuoteIdentifier(t.Database)
+	default:
+		cfg, _ := mysql.ParseDSN(dsn)
+		cfg.DBName = ""
+		adminDriver, adminDSN = "mysql", cfg.FormatDSN()
+		quoted = "`" + strings.ReplaceAll(t.Database, "`", "``") + "`"
+	}
+
+	admin, err := sql.Open(adminDriver, adminDSN)
+	if err != nil {
+		return nil, t.Driver, err
+	}
+	defer admin.Close()
+
+	if _, err := admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoted); err != nil {
+		return nil, t.Driver, fmt.Errorf("dropping shadow database %s: %w", t.Database, err)
+	}
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+quoted); err != nil {
+		return nil, t.Driver, fmt.Errorf("creating shadow database %s: %w", t.Database, err)
+	}
+
+	return Open(dsn)
+}
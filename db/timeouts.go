@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Timeouts holds the per-session lock and statement timeouts joka applies to
+// the transactions it opens. A zero field means "leave the server setting
+// alone". LockTimeout and StatementTimeout only apply to PostgreSQL;
+// LockWaitTimeout and MaxExecutionTime only apply to MySQL.
+type Timeouts struct {
+	LockTimeout      time.Duration // Postgres lock_timeout
+	StatementTimeout time.Duration // Postgres statement_timeout
+	LockWaitTimeout  time.Duration // MySQL lock_wait_timeout (whole seconds)
+	MaxExecutionTime time.Duration // MySQL max_execution_time (milliseconds, SELECT only)
+}
+
+// Execer is the minimal interface needed to run SET statements. Satisfied by
+// *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// IsZero reports whether no timeout is set.
+func (t Timeouts) IsZero() bool {
+	return t == Timeouts{}
+}
+
+// Merge returns t with every non-zero field of o overlaid on it.
+func (t Timeouts) Merge(o Timeouts) Timeouts {
+	if o.LockTimeout != 0 {
+		t.LockTimeout = o.LockTimeout
+	}
+	if o.StatementTimeout != 0 {
+		t.StatementTimeout = o.StatementTimeout
+	}
+	if o.LockWaitTimeout != 0 {
+		t.LockWaitTimeout = o.LockWaitTimeout
+	}
+	if o.MaxExecutionTime != 0 {
+		t.MaxExecutionTime = o.MaxExecutionTime
+	}
+	return t
+}
+
+// Settings returns the timeouts that apply to driver and are set, keyed by
+// their server variable name, formatted for display (e.g. "15s").
+func (t Timeouts) Settings(driver Driver) map[string]string {
+	settings := make(map[string]string)
+	for _, s := range t.settings(driver) {
+		if s.value != 0 {
+			settings[s.name] = s.value.String()
+		}
+	}
+	return settings
+}
+
+// timeoutSetting is a single server variable and the duration it should hold.
+type timeoutSetting struct {
+	name  string
+	value time.Duration
+}
+
+// settings lists the server variables relevant to driver, in a stable order.
+func (t Timeouts) settings(driver Driver) []timeoutSetting {
+	if driver == Postgres {
+		return []timeoutSetting{
+			{"lock_timeout", t.LockTimeout},
+			{"statement_timeout", t.StatementTimeout},
+		}
+	}
+	return []timeoutSetting{
+		{"lock_wait_timeout", t.LockWaitTimeout},
+		{"max_execution_time", t.MaxExecutionTime},
+	}
+}
+
+// TimeoutStatements returns the SET statements that apply t for driver. Only
+// set fields relevant to the driver produce a statement.
+//
+// PostgreSQL uses SET LOCAL so the values are scoped to the current
+// transaction. MySQL has no transaction-scoped variables, so SET SESSION is
+// used; the values live on the transaction's connection until reset.
+func TimeoutStatements(driver Driver, t Timeouts) []string {
+	var stmts []string
+	for _, s := range t.settings(driver) {
+		if s.value == 0 {
+			continue
+		}
+		stmts = append(stmts, setTimeoutStatement(driver, s))
+	}
+	return stmts
+}
+
+// ResetTimeoutStatements returns statements that restore every variable set in
+// t back to the server default for driver.
+func ResetTimeoutStatements(driver Driver, t Timeouts) []string {
+	var stmts []string
+	for _, s := range t.settings(driver) {
+		if s.value == 0 {
+			continue
+		}
+		if driver == Postgres {
+			stmts = append(stmts, fmt.Sprintf("SET LOCAL %s TO DEFAULT", s.name))
+		} else {
+			stmts = append(stmts, fmt.Sprintf("SET SESSION %s = DEFAULT", s.name))
+		}
+	}
+	return stmts
+}
+
+// setTimeoutStatement formats a single SET statement in the unit the server
+// expects for that variable. Values are rounded up to the unit, since 0
+// disables the timeout (or, for lock_wait_timeout, is rejected).
+func setTimeoutStatement(driver Driver, s timeoutSetting) string {
+	if driver == Postgres {
+		return fmt.Sprintf("SET LOCAL %s = '%dms'", s.name, roundUp(s.value, time.Millisecond))
+	}
+	if s.name == "lock_wait_timeout" {
+		// Whole seconds only.
+		return fmt.Sprintf("SET SESSION %s = %d", s.name, roundUp(s.value, time.Second))
+	}
+	return fmt.Sprintf("SET SESSION %s = %d", s.name, roundUp(s.value, time.Millisecond))
+}
+
+// roundUp returns d in whole units, rounding up.
+func roundUp(d, unit time.Duration) int64 {
+	return int64((d + unit - 1) / unit)
+}
+
+// Validate rejects a negative timeout. Zero means unset.
+func (t Timeouts) Validate() error {
+	for _, s := range append(t.settings(Postgres), t.settings(MySQL)...) {
+		if s.value < 0 {
+			return fmt.Errorf("%s must be positive, got %s", s.name, s.value)
+		}
+	}
+	return nil
+}
+
+// ApplyTimeouts runs the statements from TimeoutStatements against e.
+func ApplyTimeouts(ctx context.Context, e Execer, driver Driver, t Timeouts) error {
+	for _, stmt := range TimeoutStatements(driver, t) {
+		if _, err := e.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("setting session timeouts: %w", err)
+		}
+	}
+	return nil
+}
+
+// BeginTx starts a transaction on a connection of its own. end must be called
+// once the transaction has committed or rolled back: on MySQL it resets the
+// timeouts set in t, which are session variables that would otherwise stay on
+// the pooled connection, then releases the connection. If the reset fails the
+// connection is discarded instead. PostgreSQL's SET LOCAL values end with the
+// transaction.
+func BeginTx(ctx context.Context, db *sql.DB, d Driver, t Timeouts) (tx *sql.Tx, end func(), err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err = conn.BeginTx(ctx, nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	end = func() {
+		if d != Postgres {
+			if err := ResetTimeouts(context.WithoutCancel(ctx), conn, d, t); err != nil {
+				conn.Raw(func(any) error { return driver.ErrBadConn })
+			}
+		}
+		conn.Close()
+	}
+	return tx, end, nil
+}
+
+// ResetTimeouts runs the statements from ResetTimeoutStatements against e.
+func ResetTimeouts(ctx context.Context, e Execer, driver Driver, t Timeouts) error {
+	for _, stmt := range ResetTimeoutStatements(driver, t) {
+		if _, err := e.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("resetting session timeouts: %w", err)
+		}
+	}
+	return nil
+}
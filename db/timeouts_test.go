@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apsdsm/joka/testlib"
+)
+
+func TestTimeoutStatements(t *testing.T) {
+	t.Run("it emits SET LOCAL statements in milliseconds for postgres", func(t *testing.T) {
+		got := TimeoutStatements(Postgres, Timeouts{LockTimeout: 15 * time.Second, StatementTimeout: 5 * time.Minute})
+		want := []string{
+			"SET LOCAL lock_timeout = '15000ms'",
+			"SET LOCAL statement_timeout = '300000ms'",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("it emits SET SESSION statements in the units mysql expects", func(t *testing.T) {
+		got := TimeoutStatements(MySQL, Timeouts{LockWaitTimeout: 1500 * time.Millisecond, MaxExecutionTime: 2 * time.Second})
+		want := []string{
+			"SET SESSION lock_wait_timeout = 2",
+			"SET SESSION max_execution_time = 2000",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v, want %#v", got, want)
+		}
+	})
+
+	t.Run("it rounds values below the unit up rather than down to 0", func(t *testing.T) {
+		got := TimeoutStatements(Postgres, Timeouts{LockTimeout: 500 * time.Microsecond})
+		if !reflect.DeepEqual(got, []string{"SET LOCAL lock_timeout = '1ms'"}) {
+			t.Fatalf("got %#v", got)
+		}
+		got = TimeoutStatements(MySQL, Timeouts{MaxExecutionTime: 1500 * time.Microsecond})
+		if !reflect.DeepEqual(got, []string{"SET SESSION max_execution_time = 2"}) {
+			t.Fatalf("got %#v", got)
+		}
+	})
+
+	t.Run("it ignores timeouts that belong to the other driver", func(t *testing.T) {
+		if got := TimeoutStatements(MySQL, Timeouts{LockTimeout: time.Second}); len(got) != 0 {
+			t.Fatalf("expected no statements, got %#v", got)
+		}
+		if got := TimeoutStatements(Postgres, Timeouts{LockWaitTimeout: time.Second}); len(got) != 0 {
+			t.Fatalf("expected no statements, got %#v", got)
+		}
+	})
+
+	t.Run("it resets only the variables that were set", func(t *testing.T) {
+		got := ResetTimeoutStatements(Postgres, Timeouts{StatementTimeout: time.Second})
+		want := []string{"SET LOCAL statement_timeout TO DEFAULT"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v, want %#v", got, want)
+		}
+	})
+}
+
+func TestTimeoutsMerge(t *testing.T) {
+	t.Run("it overlays non-zero fields only", func(t *testing.T) {
+		base := Timeouts{LockTimeout: 15 * time.Second, StatementTimeout: time.Minute}
+		got := base.Merge(Timeouts{LockTimeout: 30 * time.Second})
+		want := Timeouts{LockTimeout: 30 * time.Second, StatementTimeout: time.Minute}
+		if got != want {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("it reports settings for the driver only", func(t *testing.T) {
+		got := Timeouts{LockTimeout: 15 * time.Second, LockWaitTimeout: 10 * time.Second}.Settings(Postgres)
+		want := map[string]string{"lock_timeout": "15s"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v, want %#v", got, want)
+		}
+	})
+}
+
+func TestTimeoutsValidate(t *testing.T) {
+	if err := (Timeouts{LockTimeout: time.Second}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (Timeouts{MaxExecutionTime: -time.Second}).Validate(); err == nil {
+		t.Error("expected an error for a negative timeout")
+	}
+}
+
+func TestBeginTx(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	conn, err := testlib.GetTestDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("it resets MySQL session timeouts once the transaction ends", func(t *testing.T) {
+		conn.SetMaxOpenConns(1)
+		t.Cleanup(func() { conn.SetMaxOpenConns(0) })
+
+		var before int
+		if err := conn.QueryRowContext(ctx, "SELECT @@SESSION.lock_wait_timeout").Scan(&before); err != nil {
+			t.Fatalf("reading lock_wait_timeout: %v", err)
+		}
+
+		timeouts := Timeouts{LockWaitTimeout: 7 * time.Second}
+		tx, end, err := BeginTx(ctx, conn, MySQL, timeouts)
+		if err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+		if err := ApplyTimeouts(ctx, tx, MySQL, timeouts); err != nil {
+			t.Fatalf("ApplyTimeouts: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		end()
+
+		// With one connection in the pool, this runs on the same session.
+		var after int
+		if err := conn.QueryRowContext(ctx, "SELECT @@SESSION.lock_wait_timeout").Scan(&after); err != nil {
+			t.Fatalf("reading lock_wait_timeout: %v", err)
+		}
+		if after != before {
+			t.Errorf("expected lock_wait_timeout back at %d, got %d", before, after)
+		}
+	})
+}
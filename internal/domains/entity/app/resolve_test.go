@@ -351,19 +351,6 @@ func TestSecretTemplatePredicates(t *testing.T) {
 	})
 }
 
-func TestParseSecretRef(t *testing.T) {
-	source, key, ok := parseSecretRef("asm.seed.api_key")
-	if !ok || source != "seed" || key != "api_key" {
-		t.Errorf("expected (seed, api_key), got (%s, %s, %v)", source, key, ok)
-	}
-
-	for _, s := range []string{"asm.seed", "asm.seed.a.b", "asm..key", "asm.seed.", "notasm.seed.key"} {
-		if _, _, ok := parseSecretRef(s); ok {
-			t.Errorf("%s: expected parse to fail", s)
-		}
-	}
-}
-
 func TestResolveColumns(t *testing.T) {
 	t.Run("it resolves mixed column types including templates and refs", func(t *testing.T) {
 		columns := map[string]any{
@@ -1,6 +1,10 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/apsdsm/joka/internal/expr"
+)
 
 var (
 	// ErrInvalidReference is returned when a template expression references
@@ -8,16 +12,16 @@ var (
 	ErrInvalidReference = errors.New("invalid entity reference")
 
 	// ErrInvalidTemplate is returned when a {{ ... }} expression cannot be
-	// parsed or contains an unknown function.
-	ErrInvalidTemplate = errors.New("invalid template expression")
+	// parsed or contains an unknown function. Shared with data sync files.
+	ErrInvalidTemplate = expr.ErrInvalidTemplate
 
 	// ErrEntityParseFailed is returned when a YAML entity file cannot be
 	// decoded into the expected structure.
 	ErrEntityParseFailed = errors.New("entity parse failed")
 
 	// ErrLookupNotFound is returned when a {{ lookup|... }} expression
-	// matches zero rows in the target table.
-	ErrLookupNotFound = errors.New("lookup returned no rows")
+	// matches zero rows in the target table. Shared with data sync files.
+	ErrLookupNotFound = expr.ErrLookupNotFound
 
 	// ErrDuplicateRefID is returned when two entities in the same file
 	// share the same _id handle.
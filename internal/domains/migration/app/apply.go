@@ -4,32 +4,94 @@ import (
 	"context"
 	"fmt"
 
+	jokadb "github.com/apsdsm/joka/db"
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
+	"github.com/apsdsm/joka/internal/domains/migration/infra"
 )
 
 // ApplyAction encapsulates the dependencies needed to apply a single migration.
 type ApplyAction struct {
 	DB        DBAdapter
 	Migration domain.Migration
+	// Timeouts are the run-level session timeouts already applied to the
+	// transaction. Restored after the migration's SQL if Overrides changed them.
+	Timeouts jokadb.Timeouts
+	// Overrides are timeouts declared in the migration file's header (see
+	// infra.ReadMigrationTimeouts). They apply to this migration's SQL only.
+	Overrides jokadb.Timeouts
+	// Profile is the selected .jokarc.yaml profile, used to evaluate
+	// `-- joka:if profile=...` blocks in the migration.
+	Profile string
+	// Snapshot controls how the schema snapshot taken after the migration is
+	// stored.
+	Snapshot domain.SnapshotOptions
+	// Schema, when set, is shared by the migrations of a batch so each
+	// snapshot only recomputes the tables touched since the previous one.
+	// Nil computes the full schema.
+	Schema *SchemaTracker
+	// SkipSnapshot leaves the snapshot to a later migration in the batch
+	// (domain.SnapshotEndOfBatch). The tables the migration touches are still
+	// recorded in Schema.
+	SkipSnapshot bool
 }
 
 // Execute applies a single migration in three steps:
-//  1. Run the SQL from the migration file against the database.
+//  1. Run the SQL from the migration file against the database, with its
+//     conditional blocks evaluated and any header timeout overrides in effect.
 //  2. Record the migration as applied in joka_migrations.
 //  3. Capture a schema snapshot into joka_snapshots so the full DB state
-//     at this point in the migration chain is preserved.
+//     at this point in the migration chain is preserved (unless SkipSnapshot).
 func (a ApplyAction) Execute(ctx context.Context) error {
-	if err := a.DB.ApplySQLFromFile(ctx, a.Migration.FileFullPath); err != nil {
+	if !a.Overrides.IsZero() {
+		if err := a.DB.SetSessionTimeouts(ctx, a.Timeouts.Merge(a.Overrides)); err != nil {
+			return fmt.Errorf("applying timeouts for migration %s: %w", a.Migration.MigrationIndex, err)
+		}
+	}
+
+	if err := a.DB.ApplySQLFromFile(ctx, a.Migration.FileFullPath, a.Profile); err != nil {
 		return fmt.Errorf("applying migration %s: %w", a.Migration.MigrationIndex, err)
 	}
 
+	if !a.Overrides.IsZero() {
+		// Put overridden variables back to the server default, then re-apply
+		// the run-level values so later migrations see what they expect.
+		if err := a.DB.ResetSessionTimeouts(ctx, a.Overrides); err != nil {
+			return fmt.Errorf("restoring timeouts after migration %s: %w", a.Migration.MigrationIndex, err)
+		}
+		if err := a.DB.SetSessionTimeouts(ctx, a.Timeouts); err != nil {
+			return fmt.Errorf("restoring timeouts after migration %s: %w", a.Migration.MigrationIndex, err)
+		}
+	}
+
 	if err := a.DB.RecordMigrationApplied(ctx, a.Migration.MigrationIndex); err != nil {
 		return fmt.Errorf("recording migration %s: %w", a.Migration.MigrationIndex, err)
 	}
 
-	if err := a.DB.CaptureSchemaSnapshot(ctx, a.Migration.MigrationIndex); err != nil {
-		return fmt.Errorf("capturing snapshot for migration %s: %w", a.Migration.MigrationIndex, err)
+	if a.Schema == nil {
+		if a.SkipSnapshot {
+			return nil
+		}
+		if err := a.DB.CaptureSchemaSnapshot(ctx, a.Migration.MigrationIndex, a.Snapshot); err != nil {
+			return fmt.Errorf("capturing snapshot for migration %s: %w", a.Migration.MigrationIndex, err)
+		}
+		return nil
+	}
+
+	tables, known, err := infra.ReadTouchedTables(a.Migration.FileFullPath)
+	if err != nil {
+		return fmt.Errorf("reading migration %s: %w", a.Migration.MigrationIndex, err)
+	}
+	a.Schema.Touch(tables, known)
+	if a.SkipSnapshot {
+		return nil
 	}
 
+	schema, err := a.Schema.Schema(ctx, a.DB)
+	if err != nil {
+		return fmt.Errorf("capturing snapshot for migration %s: %w", a.Migration.MigrationIndex, err)
+	}
+	if err := a.DB.StoreSchemaSnapshot(ctx, a.Migration.MigrationIndex, schema, a.Snapshot); err != nil {
+		return fmt.Errorf("capturing snapshot for migration %s: %w", a.Migration.MigrationIndex, err)
+	}
 	return nil
 }
@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	jokadb "github.com/apsdsm/joka/db"
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
 )
 
@@ -74,3 +76,60 @@ func TestApply(t *testing.T) {
 		}
 	})
 }
+
+func TestApplyTimeoutOverrides(t *testing.T) {
+	t.Run("it applies header overrides and restores the run-level timeouts", func(t *testing.T) {
+		dir := t.TempDir()
+		sqlFile := filepath.Join(dir, "240101000000_test.sql")
+		os.WriteFile(sqlFile, []byte("SELECT 1;"), 0644)
+
+		run := jokadb.Timeouts{LockTimeout: 15 * time.Second}
+		override := jokadb.Timeouts{LockTimeout: 2 * time.Minute, StatementTimeout: time.Hour}
+
+		adapter := &mockDBAdapter{hasMigrationsTable: true}
+		err := ApplyAction{
+			DB: adapter,
+			Migration: domain.Migration{
+				MigrationIndex: "240101000000",
+				FileFullPath:   sqlFile,
+			},
+			Timeouts:  run,
+			Overrides: override,
+		}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(adapter.sessionTimeouts) != 2 {
+			t.Fatalf("expected 2 SetSessionTimeouts calls, got %d", len(adapter.sessionTimeouts))
+		}
+		if adapter.sessionTimeouts[0] != override {
+			t.Errorf("expected override %+v applied first, got %+v", override, adapter.sessionTimeouts[0])
+		}
+		if adapter.sessionTimeouts[1] != run {
+			t.Errorf("expected run-level %+v restored, got %+v", run, adapter.sessionTimeouts[1])
+		}
+		if len(adapter.resetTimeouts) != 1 || adapter.resetTimeouts[0] != override {
+			t.Errorf("expected overridden variables reset, got %+v", adapter.resetTimeouts)
+		}
+	})
+
+	t.Run("it leaves the session alone when there are no overrides", func(t *testing.T) {
+		dir := t.TempDir()
+		sqlFile := filepath.Join(dir, "240101000000_test.sql")
+		os.WriteFile(sqlFile, []byte("SELECT 1;"), 0644)
+
+		adapter := &mockDBAdapter{hasMigrationsTable: true}
+		err := ApplyAction{
+			DB:        adapter,
+			Migration: domain.Migration{MigrationIndex: "240101000000", FileFullPath: sqlFile},
+			Timeouts:  jokadb.Timeouts{LockTimeout: 15 * time.Second},
+		}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(adapter.sessionTimeouts) != 0 || len(adapter.resetTimeouts) != 0 {
+			t.Errorf("expected no session changes, got set=%v reset=%v", adapter.sessionTimeouts, adapter.resetTimeouts)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -121,3 +123,42 @@ func GenerateConsolidatedSQL(schema map[string]string, order []string) string {
 	header := "-- Consolidated migration\n-- Generated by joka migrate consolidate\n"
 	return header + "\n" + strings.Join(parts, "\n\n") + "\n"
 }
+
+// ValidateConsolidationAction applies a proposed consolidated migration to an
+// empty shadow database and diffs the resulting schema against the snapshot
+// it was generated from. A result without drift means the consolidated file
+// reproduces the schema and the originals can safely be replaced.
+type ValidateConsolidationAction struct {
+	Shadow   DBAdapter
+	SQL      string
+	Snapshot map[string]string
+}
+
+// Execute writes SQL to a temporary file, applies it on the shadow, and
+// returns the diff with the snapshot as the expected side.
+func (a ValidateConsolidationAction) Execute(ctx context.Context) (VerifyResult, error) {
+	f, err := os.CreateTemp("", "joka-consolidated-*.sql")
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("writing consolidated SQL: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(a.SQL)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("writing consolidated SQL: %w", err)
+	}
+
+	if err := a.Shadow.ApplySQLFromFile(ctx, f.Name(), ""); err != nil {
+		return VerifyResult{}, fmt.Errorf("applying consolidated SQL on shadow: %w", err)
+	}
+
+	replayed, err := a.Shadow.ComputeSchema(ctx)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("computing shadow schema: %w", err)
+	}
+
+	return DiffSchemas(a.Snapshot, replayed), nil
+}
@@ -1,6 +1,9 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"strings"
 	"testing"
 )
@@ -322,3 +325,46 @@ func TestGenerateConsolidatedSQL(t *testing.T) {
 		}
 	})
 }
+
+func TestValidateConsolidation(t *testing.T) {
+	ctx := context.Background()
+	snapshot := map[string]string{"users": "CREATE TABLE users (id INT)"}
+
+	t.Run("it reports no drift when the consolidated SQL reproduces the snapshot", func(t *testing.T) {
+		shadow := &mockDBAdapter{schemaSteps: []map[string]string{{}, snapshot}}
+
+		result, err := ValidateConsolidationAction{Shadow: shadow, SQL: "CREATE TABLE users (id INT);", Snapshot: snapshot}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.HasDrift() {
+			t.Errorf("expected no drift, got %+v", result)
+		}
+		if len(shadow.appliedFiles) != 1 {
+			t.Fatalf("expected one applied file, got %v", shadow.appliedFiles)
+		}
+		if _, err := os.Stat(shadow.appliedFiles[0]); !os.IsNotExist(err) {
+			t.Errorf("expected temporary file to be removed, got %v", err)
+		}
+	})
+
+	t.Run("it reports tables the consolidated SQL does not reproduce", func(t *testing.T) {
+		shadow := &mockDBAdapter{schemaSteps: []map[string]string{{}, {"users": "CREATE TABLE users (id BIGINT)"}}}
+
+		result, err := ValidateConsolidationAction{Shadow: shadow, SQL: "CREATE TABLE users (id BIGINT);", Snapshot: snapshot}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Modified) != 1 || result.Modified[0].Table != "users" {
+			t.Errorf("expected users modified, got %+v", result)
+		}
+	})
+
+	t.Run("it returns an error when the SQL fails to apply", func(t *testing.T) {
+		shadow := &mockDBAdapter{applySQLErr: fmt.Errorf("syntax error")}
+
+		if _, err := (ValidateConsolidationAction{Shadow: shadow, SQL: "CREATE", Snapshot: snapshot}).Execute(ctx); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
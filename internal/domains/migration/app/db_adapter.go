@@ -2,7 +2,10 @@ package app
 
 import (
 	"context"
+	"time"
 
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
 	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
 )
 
@@ -15,23 +18,58 @@ type DBAdapter interface {
 	// CreateMigrationsTable creates the joka_migrations table. Returns an error
 	// if the table already exists.
 	CreateMigrationsTable(ctx context.Context) error
+	// DropMigrationsTable drops the joka_migrations table if it exists. Used
+	// to undo a failed import that created it.
+	DropMigrationsTable(ctx context.Context) error
 	// GetAppliedMigrations returns all rows from joka_migrations ordered by id.
 	GetAppliedMigrations(ctx context.Context) ([]models.MigrationRow, error)
-	// ApplySQLFromFile reads and executes the SQL from the given file path.
-	ApplySQLFromFile(ctx context.Context, filePath string) error
+	// ApplySQLFromFile reads the SQL from the given file path, evaluates its
+	// `-- joka:if` blocks for the adapter's driver and the given profile, and
+	// executes the result.
+	ApplySQLFromFile(ctx context.Context, filePath string, profile string) error
+	// SetSessionTimeouts applies lock/statement timeouts to the adapter's
+	// session (the migration transaction during `migrate up`). Timeouts that
+	// don't apply to the driver are ignored.
+	SetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error
+	// ResetSessionTimeouts restores every timeout set in t to the server default.
+	ResetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error
 	// RecordMigrationApplied inserts a row into joka_migrations for the given index.
 	RecordMigrationApplied(ctx context.Context, migrationIndex string) error
-	// EnsureSnapshotsTable creates the joka_snapshots table if it doesn't exist.
+	// RecordMigrationAppliedAt inserts a row into joka_migrations with an
+	// explicit applied_at (zero means the column default). Used by import.
+	RecordMigrationAppliedAt(ctx context.Context, migrationIndex string, appliedAt time.Time) error
+	// GetForeignHistory reads the tracking table of another migration tool.
+	// Returns domain.ErrForeignTableMissing if the table doesn't exist.
+	GetForeignHistory(ctx context.Context, source domain.ImportSource) ([]models.ForeignHistoryRow, error)
+	// EnsureSnapshotsTable creates the joka_snapshots and joka_snapshot_tables
+	// tables if they don't exist.
 	EnsureSnapshotsTable(ctx context.Context) error
 	// CaptureSchemaSnapshot records the full database schema (all non-joka tables)
-	// as a JSON snapshot associated with the given migration index.
-	CaptureSchemaSnapshot(ctx context.Context, migrationIndex string) error
+	// as a snapshot associated with the given migration index. Table DDL is
+	// stored deduplicated, and compressed if opts asks for it.
+	CaptureSchemaSnapshot(ctx context.Context, migrationIndex string, opts domain.SnapshotOptions) error
+	// StoreSchemaSnapshot records an already computed schema (see
+	// SchemaTracker) as the snapshot for the given migration index.
+	StoreSchemaSnapshot(ctx context.Context, migrationIndex string, schema map[string]string, opts domain.SnapshotOptions) error
 	// ComputeSchema returns the current database schema as a map of
 	// table name to its CREATE TABLE statement (or DB-specific reconstruction).
 	// Non-joka tables only. Used by snapshot capture and drift verification.
 	ComputeSchema(ctx context.Context) (map[string]string, error)
+	// ListTables returns the names of all non-joka tables.
+	ListTables(ctx context.Context) ([]string, error)
+	// ComputeTables is ComputeSchema restricted to the named tables. The
+	// adapters fetch them in bulk rather than one table at a time.
+	ComputeTables(ctx context.Context, tables []string) (map[string]string, error)
 	// GetSchemaSnapshot retrieves the stored schema JSON for a specific migration.
+	// Returns domain.ErrNoSnapshot if there is none.
 	GetSchemaSnapshot(ctx context.Context, migrationIndex string) (string, error)
 	// GetLatestSnapshotIndex returns the migration index of the most recent snapshot.
 	GetLatestSnapshotIndex(ctx context.Context) (string, error)
+	// ListSnapshotIndexes returns the migration index of every stored
+	// snapshot, oldest first.
+	ListSnapshotIndexes(ctx context.Context) ([]string, error)
+	// PruneSnapshots deletes all but the newest keep snapshots and any stored
+	// table DDL no remaining snapshot refers to. Returns how many snapshots
+	// and table rows were deleted.
+	PruneSnapshots(ctx context.Context, keep int) (int, int, error)
 }
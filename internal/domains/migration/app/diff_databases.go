@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"fmt"
+)
+
+// HistoryDiff compares the joka_migrations rows of two databases. A database
+// without the table has an empty history.
+type HistoryDiff struct {
+	FromLastApplied string   `json:"from_last_applied"`
+	ToLastApplied   string   `json:"to_last_applied"`
+	OnlyFrom        []string `json:"only_from"` // applied on from but not on to, in apply order
+	OnlyTo          []string `json:"only_to"`   // applied on to but not on from, in apply order
+}
+
+// HasDifferences reports whether one database applied a migration the other
+// didn't.
+func (h HistoryDiff) HasDifferences() bool {
+	return len(h.OnlyFrom) > 0 || len(h.OnlyTo) > 0
+}
+
+// DatabaseDiffResult is the outcome of comparing two databases. In Schema,
+// tables only in the to database are Added and tables only in the from
+// database are Removed; a Modified entry's Snapshot holds the from DDL and
+// Live the to DDL.
+type DatabaseDiffResult struct {
+	Schema  VerifyResult `json:"schema"`
+	History HistoryDiff  `json:"history"`
+}
+
+// HasDifferences reports whether the schemas or the migration histories
+// differ.
+func (r DatabaseDiffResult) HasDifferences() bool {
+	return r.Schema.HasDrift() || r.History.HasDifferences()
+}
+
+// DiffDatabasesAction compares the live schemas and migration histories of
+// two databases, e.g. staging and production.
+type DiffDatabasesAction struct {
+	From DBAdapter
+	To   DBAdapter
+}
+
+// Execute computes both schemas and diffs them with DiffSchemas, so the same
+// normalization as migrate verify applies, then compares the histories.
+func (a DiffDatabasesAction) Execute(ctx context.Context) (DatabaseDiffResult, error) {
+	var result DatabaseDiffResult
+
+	from, err := a.From.ComputeSchema(ctx)
+	if err != nil {
+		return result, fmt.Errorf("computing from schema: %w", err)
+	}
+	to, err := a.To.ComputeSchema(ctx)
+	if err != nil {
+		return result, fmt.Errorf("computing to schema: %w", err)
+	}
+	result.Schema = DiffSchemas(from, to)
+
+	fromHistory, err := appliedIndexes(ctx, a.From)
+	if err != nil {
+		return result, fmt.Errorf("reading from history: %w", err)
+	}
+	toHistory, err := appliedIndexes(ctx, a.To)
+	if err != nil {
+		return result, fmt.Errorf("reading to history: %w", err)
+	}
+	result.History = DiffHistories(fromHistory, toHistory)
+
+	return result, nil
+}
+
+// DiffHistories compares two lists of applied migration indexes, each in
+// apply order.
+func DiffHistories(from, to []string) HistoryDiff {
+	var diff HistoryDiff
+	if len(from) > 0 {
+		diff.FromLastApplied = from[len(from)-1]
+	}
+	if len(to) > 0 {
+		diff.ToLastApplied = to[len(to)-1]
+	}
+	diff.OnlyFrom = missingFrom(from, to)
+	diff.OnlyTo = missingFrom(to, from)
+	return diff
+}
+
+// missingFrom returns the indexes of a that are not in b, keeping a's order.
+func missingFrom(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, index := range b {
+		seen[index] = true
+	}
+	var missing []string
+	for _, index := range a {
+		if !seen[index] {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
+// appliedIndexes returns the migration indexes recorded in joka_migrations, in
+// apply order, or none when the table doesn't exist.
+func appliedIndexes(ctx context.Context, db DBAdapter) ([]string, error) {
+	exists, err := db.HasMigrationsTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+	rows, err := db.GetAppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]string, len(rows))
+	for i, row := range rows {
+		indexes[i] = row.MigrationIndex
+	}
+	return indexes, nil
+}
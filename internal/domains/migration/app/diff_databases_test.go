@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
+)
+
+func TestDiffDatabases(t *testing.T) {
+	ctx := context.Background()
+
+	rows := func(indexes ...string) []models.MigrationRow {
+		var out []models.MigrationRow
+		for i, index := range indexes {
+			out = append(out, models.MigrationRow{ID: i + 1, MigrationIndex: index})
+		}
+		return out
+	}
+
+	t.Run("it reports no differences between identical databases", func(t *testing.T) {
+		from := &mockDBAdapter{
+			hasMigrationsTable: true,
+			appliedMigrations:  rows("240101000000"),
+			computedSchema:     map[string]string{"users": "CREATE TABLE `users` (`id` int) AUTO_INCREMENT=12"},
+		}
+		to := &mockDBAdapter{
+			hasMigrationsTable: true,
+			appliedMigrations:  rows("240101000000"),
+			computedSchema:     map[string]string{"users": "CREATE TABLE `users` (`id` int) AUTO_INCREMENT=90"},
+		}
+
+		result, err := DiffDatabasesAction{From: from, To: to}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.HasDifferences() {
+			t.Errorf("expected no differences, got %+v", result)
+		}
+	})
+
+	t.Run("it reports schema and history differences from the from side", func(t *testing.T) {
+		from := &mockDBAdapter{
+			hasMigrationsTable: true,
+			appliedMigrations:  rows("240101000000", "240201000000", "240301000000"),
+			computedSchema: map[string]string{
+				"users":  "CREATE TABLE users (id INT, email TEXT)",
+				"orders": "CREATE TABLE orders (id INT)",
+			},
+		}
+		to := &mockDBAdapter{
+			hasMigrationsTable: true,
+			appliedMigrations:  rows("240101000000", "240215000000"),
+			computedSchema: map[string]string{
+				"users":     "CREATE TABLE users (id INT)",
+				"audit_log": "CREATE TABLE audit_log (id INT)",
+			},
+		}
+
+		result, err := DiffDatabasesAction{From: from, To: to}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(result.Schema.Added, []string{"audit_log"}) {
+			t.Errorf("expected audit_log added, got %v", result.Schema.Added)
+		}
+		if !reflect.DeepEqual(result.Schema.Removed, []string{"orders"}) {
+			t.Errorf("expected orders removed, got %v", result.Schema.Removed)
+		}
+		if len(result.Schema.Modified) != 1 || result.Schema.Modified[0].Snapshot != from.computedSchema["users"] {
+			t.Errorf("expected users modified with the from DDL first, got %+v", result.Schema.Modified)
+		}
+
+		want := HistoryDiff{
+			FromLastApplied: "240301000000",
+			ToLastApplied:   "240215000000",
+			OnlyFrom:        []string{"240201000000", "240301000000"},
+			OnlyTo:          []string{"240215000000"},
+		}
+		if !reflect.DeepEqual(result.History, want) {
+			t.Errorf("expected %+v, got %+v", want, result.History)
+		}
+	})
+
+	t.Run("it treats a database without joka_migrations as having no history", func(t *testing.T) {
+		from := &mockDBAdapter{hasMigrationsTable: true, appliedMigrations: rows("240101000000")}
+		to := &mockDBAdapter{appliedMigrations: rows("240101000000")}
+
+		result, err := DiffDatabasesAction{From: from, To: to}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(result.History.OnlyFrom, []string{"240101000000"}) || result.History.ToLastApplied != "" {
+			t.Errorf("expected only the from side to have history, got %+v", result.History)
+		}
+	})
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
+	jokadb "github.com/apsdsm/joka/db"
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
 	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
 )
@@ -22,6 +24,17 @@ type mockDBAdapter struct {
 	latestSnapshotIndex   string
 	schemaSnapshot        string
 	computedSchema        map[string]string
+	sessionTimeouts       []jokadb.Timeouts
+	resetTimeouts         []jokadb.Timeouts
+	foreignHistory        []models.ForeignHistoryRow
+	recorded              []string
+	snapshots             []string
+	appliedFiles          []string
+	schemaSteps           []map[string]string // ComputeSchema after n applied files, when set
+	snapshotsByIndex      map[string]string   // GetSchemaSnapshot per index, when set
+	pruneKeep             int
+	computedTables        [][]string // names passed to each ComputeTables call
+	storedSchemas         map[string]map[string]string
 }
 
 func (m *mockDBAdapter) HasMigrationsTable(ctx context.Context) (bool, error) {
@@ -32,31 +45,100 @@ func (m *mockDBAdapter) CreateMigrationsTable(ctx context.Context) error {
 	return m.createTableErr
 }
 
+func (m *mockDBAdapter) DropMigrationsTable(ctx context.Context) error { return nil }
+
 func (m *mockDBAdapter) GetAppliedMigrations(ctx context.Context) ([]models.MigrationRow, error) {
 	return m.appliedMigrations, m.appliedMigrationsErr
 }
 
-func (m *mockDBAdapter) ApplySQLFromFile(ctx context.Context, filePath string) error {
-	return m.applySQLErr
+func (m *mockDBAdapter) ApplySQLFromFile(ctx context.Context, filePath string, profile string) error {
+	if m.applySQLErr != nil {
+		return m.applySQLErr
+	}
+	m.appliedFiles = append(m.appliedFiles, filePath)
+	return nil
+}
+
+func (m *mockDBAdapter) SetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error {
+	m.sessionTimeouts = append(m.sessionTimeouts, t)
+	return nil
+}
+
+func (m *mockDBAdapter) ResetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error {
+	m.resetTimeouts = append(m.resetTimeouts, t)
+	return nil
 }
 
 func (m *mockDBAdapter) RecordMigrationApplied(ctx context.Context, migrationIndex string) error {
 	return m.recordAppliedErr
 }
 
+func (m *mockDBAdapter) RecordMigrationAppliedAt(ctx context.Context, migrationIndex string, appliedAt time.Time) error {
+	m.recorded = append(m.recorded, migrationIndex)
+	return m.recordAppliedErr
+}
+
+func (m *mockDBAdapter) GetForeignHistory(ctx context.Context, source domain.ImportSource) ([]models.ForeignHistoryRow, error) {
+	return m.foreignHistory, nil
+}
+
 func (m *mockDBAdapter) EnsureSnapshotsTable(ctx context.Context) error { return nil }
-func (m *mockDBAdapter) CaptureSchemaSnapshot(ctx context.Context, migrationIndex string) error {
+func (m *mockDBAdapter) CaptureSchemaSnapshot(ctx context.Context, migrationIndex string, opts domain.SnapshotOptions) error {
+	m.snapshots = append(m.snapshots, migrationIndex)
+	return nil
+}
+func (m *mockDBAdapter) StoreSchemaSnapshot(ctx context.Context, migrationIndex string, schema map[string]string, opts domain.SnapshotOptions) error {
+	m.snapshots = append(m.snapshots, migrationIndex)
+	if m.storedSchemas == nil {
+		m.storedSchemas = make(map[string]map[string]string)
+	}
+	m.storedSchemas[migrationIndex] = schema
 	return nil
 }
 func (m *mockDBAdapter) GetSchemaSnapshot(ctx context.Context, migrationIndex string) (string, error) {
+	if m.snapshotsByIndex != nil {
+		snapshot, ok := m.snapshotsByIndex[migrationIndex]
+		if !ok {
+			return "", domain.ErrNoSnapshot
+		}
+		return snapshot, nil
+	}
 	return m.schemaSnapshot, nil
 }
 func (m *mockDBAdapter) GetLatestSnapshotIndex(ctx context.Context) (string, error) {
 	return m.latestSnapshotIndex, nil
 }
+func (m *mockDBAdapter) ListSnapshotIndexes(ctx context.Context) ([]string, error) {
+	return m.snapshots, nil
+}
+func (m *mockDBAdapter) PruneSnapshots(ctx context.Context, keep int) (int, int, error) {
+	m.pruneKeep = keep
+	return 0, 0, nil
+}
 func (m *mockDBAdapter) ComputeSchema(ctx context.Context) (map[string]string, error) {
+	if m.schemaSteps != nil {
+		return m.schemaSteps[len(m.appliedFiles)], nil
+	}
 	return m.computedSchema, nil
 }
+func (m *mockDBAdapter) ListTables(ctx context.Context) ([]string, error) {
+	schema, _ := m.ComputeSchema(ctx)
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+func (m *mockDBAdapter) ComputeTables(ctx context.Context, tables []string) (map[string]string, error) {
+	m.computedTables = append(m.computedTables, tables)
+	schema, _ := m.ComputeSchema(ctx)
+	out := make(map[string]string, len(tables))
+	for _, name := range tables {
+		out[name] = schema[name]
+	}
+	return out, nil
+}
 
 func createTestFile(t *testing.T, dir, name string) {
 	t.Helper()
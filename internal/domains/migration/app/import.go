@@ -0,0 +1,315 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+	"github.com/apsdsm/joka/internal/domains/migration/infra"
+	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
+)
+
+// timestampVersion matches a YYYYMMDDHHMMSS version, the default naming for
+// goose, dbmate and golang-migrate's -format flag. These map directly onto a
+// joka index by dropping the century.
+var timestampVersion = regexp.MustCompile(`^\d{14}$`)
+
+// ImportEntry is one foreign migration and the joka migration it becomes.
+type ImportEntry struct {
+	Version     string // version in the source tool
+	Index       string // joka migration index (YYMMDDHHMMSS or zero-padded sequence)
+	FileName    string // joka file name, <index>_<name>.sql
+	SourceFiles []string
+	Applied     bool      // recorded as applied by the source tool
+	AppliedAt   time.Time // zero if the source tool doesn't record it
+	Up          string
+	Down        string
+	Warnings    []string
+}
+
+// ImportPlan describes everything an import will do. It is built without
+// side effects so it can be shown as a dry-run report before ApplyImportAction
+// writes anything.
+type ImportPlan struct {
+	Source   domain.ImportSource
+	Entries  []ImportEntry
+	Warnings []string
+	// CreateTable is true when joka_migrations doesn't exist yet and will be
+	// created by the import.
+	CreateTable bool
+}
+
+// Applied returns the entries the source tool recorded as applied. They are
+// always a prefix of Entries.
+func (p ImportPlan) Applied() []ImportEntry {
+	for i, e := range p.Entries {
+		if !e.Applied {
+			return p.Entries[:i]
+		}
+	}
+	return p.Entries
+}
+
+// PlanImportAction reads another tool's migration files and tracking table
+// and works out the joka migrations and history they translate to.
+type PlanImportAction struct {
+	DB            DBAdapter
+	Source        domain.ImportSource
+	SourceDir     string
+	MigrationsDir string
+}
+
+// Execute builds the import plan. It refuses to plan an import into a
+// project that already has joka migration files or history, since the
+// imported chain must start from an empty joka_migrations.
+func (a PlanImportAction) Execute(ctx context.Context) (ImportPlan, error) {
+	plan := ImportPlan{Source: a.Source}
+
+	exists, err := a.DB.HasMigrationsTable(ctx)
+	if err != nil {
+		return plan, err
+	}
+	if exists {
+		rows, err := a.DB.GetAppliedMigrations(ctx)
+		if err != nil {
+			return plan, err
+		}
+		if len(rows) > 0 {
+			return plan, fmt.Errorf("%w: %d migrations already recorded", domain.ErrImportNotEmpty, len(rows))
+		}
+	}
+	plan.CreateTable = !exists
+
+	existing, err := infra.ListMigrationFiles(a.MigrationsDir)
+	if err != nil {
+		return plan, err
+	}
+	if len(existing) > 0 {
+		return plan, fmt.Errorf("migrations directory %s already contains %d joka migrations", a.MigrationsDir, len(existing))
+	}
+
+	files, warnings, err := infra.ListForeignMigrationFiles(a.Source, a.SourceDir)
+	if err != nil {
+		return plan, err
+	}
+	if len(files) == 0 {
+		return plan, fmt.Errorf("no %s migrations found in %s", a.Source, a.SourceDir)
+	}
+	plan.Warnings = warnings
+
+	history, err := a.DB.GetForeignHistory(ctx, a.Source)
+	if err != nil {
+		return plan, err
+	}
+
+	applied, err := appliedVersions(a.Source, history, files)
+	if err != nil {
+		return plan, err
+	}
+
+	known := make(map[string]bool, len(files))
+	for _, f := range files {
+		known[f.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return plan, fmt.Errorf("%s records version %s as applied but no migration file for it was found in %s", a.Source, version, a.SourceDir)
+		}
+	}
+
+	indexes := importIndexes(files)
+	seenPending := ""
+	for i, f := range files {
+		appliedAt, isApplied := applied[f.Version]
+		if isApplied && seenPending != "" {
+			return plan, fmt.Errorf("%s applied version %s out of order (%s is still pending); joka requires applied migrations to precede pending ones", a.Source, f.Version, seenPending)
+		}
+		if !isApplied && seenPending == "" {
+			seenPending = f.Version
+		}
+
+		plan.Entries = append(plan.Entries, ImportEntry{
+			Version:     f.RawVersion,
+			Index:       indexes[i],
+			FileName:    fmt.Sprintf("%s_%s.sql", indexes[i], f.Name),
+			SourceFiles: f.SourceFiles,
+			Applied:     isApplied,
+			AppliedAt:   appliedAt,
+			Up:          f.Up,
+			Down:        f.Down,
+			Warnings:    f.Warnings,
+		})
+	}
+
+	return plan, nil
+}
+
+// appliedVersions interprets a tool's tracking rows and returns the set of
+// versions it considers applied, with their apply time where recorded.
+func appliedVersions(source domain.ImportSource, history []models.ForeignHistoryRow, files []models.ForeignMigrationFile) (map[string]time.Time, error) {
+	applied := make(map[string]time.Time)
+
+	switch source {
+	case domain.ImportGoose:
+		// goose appends a row per up and per down; the latest row for a
+		// version is its current state. Version 0 is goose's own marker row.
+		for _, r := range history {
+			if r.Version == "0" {
+				continue
+			}
+			if r.Applied {
+				applied[r.Version] = r.AppliedAt
+			} else {
+				delete(applied, r.Version)
+			}
+		}
+
+	case domain.ImportGolangMigrate:
+		// golang-migrate only stores the current version: everything up to
+		// and including it is applied.
+		if len(history) == 0 {
+			return applied, nil
+		}
+		current := history[0]
+		if current.Dirty {
+			return nil, fmt.Errorf("golang-migrate marks version %s as dirty; resolve it with `migrate force` before importing", current.Version)
+		}
+		found := false
+		for _, f := range files {
+			if infra.CompareVersions(f.Version, current.Version) <= 0 {
+				applied[f.Version] = time.Time{}
+			}
+			found = found || f.Version == current.Version
+		}
+		if !found {
+			return nil, fmt.Errorf("golang-migrate is at version %s but no migration file for it was found", current.Version)
+		}
+
+	case domain.ImportFlyway:
+		for _, r := range history {
+			if !r.Applied {
+				return nil, fmt.Errorf("flyway records a failed migration at version %s; run `flyway repair` before importing", r.Version)
+			}
+			if r.Baseline {
+				// A baseline stands in for every version at or below it. Only
+				// the files that exist are imported as applied.
+				for _, f := range files {
+					if infra.CompareVersions(f.Version, r.Version) <= 0 {
+						applied[f.Version] = r.AppliedAt
+					}
+				}
+				continue
+			}
+			applied[r.Version] = r.AppliedAt
+		}
+
+	case domain.ImportDbmate:
+		for _, r := range history {
+			applied[r.Version] = r.AppliedAt
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnknownImportSource, source)
+	}
+
+	return applied, nil
+}
+
+// importIndexes assigns joka indexes to the sorted foreign migrations. When
+// every version is a YYYYMMDDHHMMSS timestamp the index is the same moment in
+// joka's YYMMDDHHMMSS form; otherwise (sequential or dotted versions) the
+// migrations are numbered 000000000001, 000000000002, ... in order, which
+// still sorts before any migration later created by `joka make`.
+func importIndexes(files []models.ForeignMigrationFile) []string {
+	indexes := make([]string, len(files))
+
+	timestamps := true
+	for _, f := range files {
+		if !timestampVersion.MatchString(f.RawVersion) {
+			timestamps = false
+			break
+		}
+	}
+
+	for i, f := range files {
+		if timestamps {
+			indexes[i] = f.RawVersion[2:]
+		} else {
+			indexes[i] = fmt.Sprintf("%012d", i+1)
+		}
+	}
+	return indexes
+}
+
+// ApplyImportAction writes the migrations of an import plan into the joka
+// migrations directory and seeds joka_migrations with the applied ones.
+type ApplyImportAction struct {
+	DB            DBAdapter
+	Plan          ImportPlan
+	MigrationsDir string
+	Snapshot      domain.SnapshotOptions
+}
+
+// Execute writes every planned migration (down SQL goes to the down/
+// subdirectory), records the applied entries in order, and captures a schema
+// snapshot at the last applied index so drift verification works straight
+// away. On any error the files written so far are removed; the caller is
+// responsible for rolling back the transaction behind DB. joka_migrations must
+// already exist: when Plan.CreateTable is set the caller creates it before
+// the transaction, since DDL would commit a MySQL transaction.
+func (a ApplyImportAction) Execute(ctx context.Context) ([]string, error) {
+	var written []string
+	cleanup := func() {
+		for _, path := range written {
+			os.Remove(path)
+		}
+		// Only removes the down directory if the import created it empty.
+		os.Remove(filepath.Join(a.MigrationsDir, "down"))
+	}
+
+	for _, e := range a.Plan.Entries {
+		header := fmt.Sprintf("-- Imported from %s: %s\n\n", a.Plan.Source, sourceNames(e.SourceFiles))
+		down := ""
+		if e.Down != "" {
+			down = header + e.Down + "\n"
+		}
+		paths, err := infra.WriteMigrationFile(a.MigrationsDir, e.FileName, header+e.Up+"\n", down)
+		written = append(written, paths...)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	applied := a.Plan.Applied()
+	for _, e := range applied {
+		if err := a.DB.RecordMigrationAppliedAt(ctx, e.Index, e.AppliedAt); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("recording migration %s: %w", e.Index, err)
+		}
+	}
+
+	if len(applied) > 0 {
+		last := applied[len(applied)-1].Index
+		if err := a.DB.CaptureSchemaSnapshot(ctx, last, a.Snapshot); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("capturing snapshot for migration %s: %w", last, err)
+		}
+	}
+
+	return written, nil
+}
+
+// sourceNames joins the base names of a migration's source files.
+func sourceNames(paths []string) string {
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	return strings.Join(names, ", ")
+}
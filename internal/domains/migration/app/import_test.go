@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
+)
+
+func writeGooseFiles(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		content := "-- +goose Up\nSELECT 1;\n-- +goose Down\nSELECT 0;\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPlanImport(t *testing.T) {
+	t.Run("it maps timestamp versions and takes goose's latest state per version", func(t *testing.T) {
+		src := writeGooseFiles(t, "20240101000000_a.sql", "20240102000000_b.sql", "20240103000000_c.sql")
+		applied := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+		adapter := &mockDBAdapter{
+			foreignHistory: []models.ForeignHistoryRow{
+				{Version: "0", Applied: true},
+				{Version: "20240101000000", Applied: true, AppliedAt: applied},
+				{Version: "20240102000000", Applied: true},
+				{Version: "20240103000000", Applied: true},
+				{Version: "20240103000000", Applied: false},
+			},
+		}
+
+		plan, err := PlanImportAction{DB: adapter, Source: domain.ImportGoose, SourceDir: src, MigrationsDir: t.TempDir()}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !plan.CreateTable {
+			t.Error("expected joka_migrations to be created")
+		}
+		if len(plan.Entries) != 3 || plan.Entries[0].FileName != "240101000000_a.sql" {
+			t.Fatalf("unexpected entries: %+v", plan.Entries)
+		}
+		if len(plan.Applied()) != 2 {
+			t.Errorf("expected 2 applied (c was rolled back), got %d", len(plan.Applied()))
+		}
+		if !plan.Entries[0].AppliedAt.Equal(applied) {
+			t.Errorf("expected applied_at to be carried over, got %v", plan.Entries[0].AppliedAt)
+		}
+	})
+
+	t.Run("it numbers sequential versions in order", func(t *testing.T) {
+		src := writeGooseFiles(t, "00002_b.sql", "00001_a.sql")
+		adapter := &mockDBAdapter{}
+
+		plan, err := PlanImportAction{DB: adapter, Source: domain.ImportGoose, SourceDir: src, MigrationsDir: t.TempDir()}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if plan.Entries[0].Index != "000000000001" || plan.Entries[1].FileName != "000000000002_b.sql" {
+			t.Errorf("unexpected indexes: %+v", plan.Entries)
+		}
+	})
+
+	t.Run("it treats everything up to golang-migrate's version as applied", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"1_a.up.sql", "2_b.up.sql", "3_c.up.sql"} {
+			os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0644)
+		}
+		adapter := &mockDBAdapter{foreignHistory: []models.ForeignHistoryRow{{Version: "2", Applied: true}}}
+
+		plan, err := PlanImportAction{DB: adapter, Source: domain.ImportGolangMigrate, SourceDir: dir, MigrationsDir: t.TempDir()}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(plan.Applied()) != 2 {
+			t.Errorf("expected 2 applied, got %d", len(plan.Applied()))
+		}
+	})
+
+	t.Run("it refuses a dirty golang-migrate database", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "1_a.up.sql"), []byte("SELECT 1;"), 0644)
+		adapter := &mockDBAdapter{foreignHistory: []models.ForeignHistoryRow{{Version: "1", Applied: true, Dirty: true}}}
+
+		_, err := PlanImportAction{DB: adapter, Source: domain.ImportGolangMigrate, SourceDir: dir, MigrationsDir: t.TempDir()}.Execute(context.Background())
+		if err == nil {
+			t.Fatal("expected error for dirty database")
+		}
+	})
+
+	t.Run("it refuses history applied out of order", func(t *testing.T) {
+		src := writeGooseFiles(t, "1_a.sql", "2_b.sql")
+		adapter := &mockDBAdapter{foreignHistory: []models.ForeignHistoryRow{{Version: "2", Applied: true}}}
+
+		_, err := PlanImportAction{DB: adapter, Source: domain.ImportGoose, SourceDir: src, MigrationsDir: t.TempDir()}.Execute(context.Background())
+		if err == nil {
+			t.Fatal("expected error for out-of-order history")
+		}
+	})
+
+	t.Run("it refuses an applied version with no file", func(t *testing.T) {
+		src := writeGooseFiles(t, "1_a.sql")
+		adapter := &mockDBAdapter{foreignHistory: []models.ForeignHistoryRow{{Version: "1", Applied: true}, {Version: "7", Applied: true}}}
+
+		_, err := PlanImportAction{DB: adapter, Source: domain.ImportGoose, SourceDir: src, MigrationsDir: t.TempDir()}.Execute(context.Background())
+		if err == nil {
+			t.Fatal("expected error for missing file")
+		}
+	})
+
+	t.Run("it refuses when joka already has history", func(t *testing.T) {
+		src := writeGooseFiles(t, "1_a.sql")
+		adapter := &mockDBAdapter{
+			hasMigrationsTable: true,
+			appliedMigrations:  []models.MigrationRow{{ID: 1, MigrationIndex: "240101000000"}},
+		}
+
+		_, err := PlanImportAction{DB: adapter, Source: domain.ImportGoose, SourceDir: src, MigrationsDir: t.TempDir()}.Execute(context.Background())
+		if !errors.Is(err, domain.ErrImportNotEmpty) {
+			t.Fatalf("expected ErrImportNotEmpty, got %v", err)
+		}
+	})
+}
+
+func TestApplyImport(t *testing.T) {
+	t.Run("it writes files and records applied migrations in order", func(t *testing.T) {
+		src := writeGooseFiles(t, "1_a.sql", "2_b.sql", "3_c.sql")
+		dest := t.TempDir()
+		adapter := &mockDBAdapter{
+			foreignHistory: []models.ForeignHistoryRow{{Version: "1", Applied: true}, {Version: "2", Applied: true}},
+		}
+
+		plan, err := PlanImportAction{DB: adapter, Source: domain.ImportGoose, SourceDir: src, MigrationsDir: dest}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		written, err := ApplyImportAction{DB: adapter, Plan: plan, MigrationsDir: dest}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(written) != 6 {
+			t.Errorf("expected 3 up and 3 down files, got %d", len(written))
+		}
+		if len(adapter.recorded) != 2 || adapter.recorded[1] != "000000000002" {
+			t.Errorf("unexpected recorded migrations: %v", adapter.recorded)
+		}
+		if len(adapter.snapshots) != 1 || adapter.snapshots[0] != "000000000002" {
+			t.Errorf("expected a snapshot at the last applied index, got %v", adapter.snapshots)
+		}
+	})
+
+	t.Run("it removes written files when recording fails", func(t *testing.T) {
+		src := writeGooseFiles(t, "1_a.sql")
+		dest := t.TempDir()
+		adapter := &mockDBAdapter{foreignHistory: []models.ForeignHistoryRow{{Version: "1", Applied: true}}}
+
+		plan, err := PlanImportAction{DB: adapter, Source: domain.ImportGoose, SourceDir: src, MigrationsDir: dest}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		adapter.recordAppliedErr = errors.New("boom")
+		if _, err := (ApplyImportAction{DB: adapter, Plan: plan, MigrationsDir: dest}).Execute(context.Background()); err == nil {
+			t.Fatal("expected error")
+		}
+		entries, _ := os.ReadDir(dest)
+		if len(entries) != 0 {
+			t.Errorf("expected destination to be cleaned up, found %d entries", len(entries))
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"fmt"
+)
+
+// PruneSnapshotsAction deletes old schema snapshots, keeping the newest Keep,
+// and the stored table DDL only they referred to.
+type PruneSnapshotsAction struct {
+	DB   DBAdapter
+	Keep int
+}
+
+// PruneResult reports what PruneSnapshotsAction deleted.
+type PruneResult struct {
+	Snapshots int `json:"snapshots"`
+	Tables    int `json:"tables"`
+}
+
+// Execute performs the prune. Keep must be at least 1: verify and consolidate
+// need the latest snapshot.
+func (a PruneSnapshotsAction) Execute(ctx context.Context) (PruneResult, error) {
+	if a.Keep < 1 {
+		return PruneResult{}, fmt.Errorf("keep must be at least 1 (got %d)", a.Keep)
+	}
+	snapshots, tables, err := a.DB.PruneSnapshots(ctx, a.Keep)
+	if err != nil {
+		return PruneResult{}, err
+	}
+	return PruneResult{Snapshots: snapshots, Tables: tables}, nil
+}
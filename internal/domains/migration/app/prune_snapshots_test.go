@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	t.Run("it passes keep to the adapter", func(t *testing.T) {
+		adapter := &mockDBAdapter{}
+		_, err := PruneSnapshotsAction{DB: adapter, Keep: 3}.Execute(context.Background())
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if adapter.pruneKeep != 3 {
+			t.Errorf("expected keep 3, got %d", adapter.pruneKeep)
+		}
+	})
+
+	t.Run("it refuses to keep fewer than one snapshot", func(t *testing.T) {
+		adapter := &mockDBAdapter{pruneKeep: -1}
+		_, err := PruneSnapshotsAction{DB: adapter, Keep: 0}.Execute(context.Background())
+
+		if err == nil {
+			t.Fatal("expected error for keep 0")
+		}
+		if adapter.pruneKeep != -1 {
+			t.Error("expected adapter not to be called")
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/migration/infra"
+)
+
+// Schema files are plain SQL with a comment header and one section per table:
+//
+//	-- Schema as of migration 250116140000
+//	-- Generated by joka migrate snapshot --write. Do not edit by hand.
+//
+//	-- table: users
+//	CREATE TABLE users (...);
+//
+// The "-- table:" markers let the file be read back table by table even when a
+// table's entry holds several statements (PostgreSQL indexes).
+const (
+	schemaFileIndexPrefix = "-- Schema as of migration "
+	schemaFileTablePrefix = "-- table: "
+)
+
+// GenerateSchemaFile renders a snapshot as a schema file, with tables in the
+// given order (see ParseFKDependencies / TopologicalSort). MySQL
+// AUTO_INCREMENT counters are stripped so the file only changes when the
+// schema does.
+func GenerateSchemaFile(migrationIndex string, schema map[string]string, order []string) string {
+	var b strings.Builder
+	b.WriteString(schemaFileIndexPrefix + migrationIndex + "\n")
+	b.WriteString("-- Generated by joka migrate snapshot --write. Do not edit by hand.\n")
+	for _, table := range order {
+		ddl := strings.TrimSpace(autoIncPattern.ReplaceAllString(schema[table], ""))
+		if !strings.HasSuffix(ddl, ";") {
+			ddl += ";"
+		}
+		b.WriteString("\n" + schemaFileTablePrefix + table + "\n" + ddl + "\n")
+	}
+	return b.String()
+}
+
+// ParseSchemaFile reads a schema file written by GenerateSchemaFile back into
+// the migration index it was generated at and a table → DDL map.
+func ParseSchemaFile(content string) (string, map[string]string, error) {
+	var index, table string
+	var body []string
+	schema := make(map[string]string)
+
+	flush := func() {
+		if table != "" {
+			schema[table] = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		body = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, schemaFileTablePrefix):
+			flush()
+			table = strings.TrimSpace(strings.TrimPrefix(line, schemaFileTablePrefix))
+			if _, dup := schema[table]; dup || table == "" {
+				return "", nil, fmt.Errorf("line %d: duplicate or empty table section %q", lineNo, table)
+			}
+			schema[table] = ""
+		case table == "" && strings.HasPrefix(line, schemaFileIndexPrefix):
+			index = strings.TrimSpace(strings.TrimPrefix(line, schemaFileIndexPrefix))
+		case table == "":
+			if t := strings.TrimSpace(line); t != "" && !strings.HasPrefix(t, "--") {
+				return "", nil, fmt.Errorf("line %d: SQL outside a %q section", lineNo, strings.TrimSpace(schemaFileTablePrefix))
+			}
+		default:
+			body = append(body, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, err
+	}
+	flush()
+
+	return index, schema, nil
+}
+
+// VerifyFileAction compares a database's schema against a schema file. When
+// ReplayDir is set, DB is treated as an empty shadow database and every
+// migration file in ReplayDir is applied to it first, so the file is checked
+// against what the migrations produce rather than against a live database.
+type VerifyFileAction struct {
+	DB        DBAdapter
+	Path      string
+	ReplayDir string
+	Profile   string
+}
+
+// Execute returns the diff with the schema file as the expected side. The
+// result's MigrationIndex is the index recorded in the file's header.
+func (a VerifyFileAction) Execute(ctx context.Context) (VerifyResult, error) {
+	content, err := os.ReadFile(a.Path)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("reading schema file: %w", err)
+	}
+	index, expected, err := ParseSchemaFile(string(content))
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("parsing schema file %s: %w", a.Path, err)
+	}
+
+	if a.ReplayDir != "" {
+		files, err := infra.ListMigrationFiles(a.ReplayDir)
+		if err != nil {
+			return VerifyResult{}, err
+		}
+		for _, f := range files {
+			if err := a.DB.ApplySQLFromFile(ctx, f.FullPath, a.Profile); err != nil {
+				return VerifyResult{}, fmt.Errorf("replaying migration %s on shadow: %w", f.Index, err)
+			}
+		}
+	}
+
+	actual, err := a.DB.ComputeSchema(ctx)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("computing schema: %w", err)
+	}
+
+	result := DiffSchemas(expected, actual)
+	result.MigrationIndex = index
+	return result, nil
+}
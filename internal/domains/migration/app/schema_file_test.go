@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaFile(t *testing.T) {
+	schema := map[string]string{
+		"users":  "CREATE TABLE `users` (\n  `id` int NOT NULL\n) ENGINE=InnoDB AUTO_INCREMENT=42",
+		"orders": "CREATE TABLE orders (\n  id integer NOT NULL\n)\nCREATE INDEX idx_orders ON public.orders USING btree (id);",
+	}
+	order := []string{"users", "orders"}
+
+	t.Run("it writes tables in the given order without AUTO_INCREMENT counters", func(t *testing.T) {
+		out := GenerateSchemaFile("240102000000", schema, order)
+
+		if !strings.HasPrefix(out, "-- Schema as of migration 240102000000\n") {
+			t.Errorf("missing header:\n%s", out)
+		}
+		if strings.Index(out, "-- table: users") > strings.Index(out, "-- table: orders") {
+			t.Errorf("expected users before orders:\n%s", out)
+		}
+		if strings.Contains(out, "AUTO_INCREMENT=") {
+			t.Errorf("expected AUTO_INCREMENT to be stripped:\n%s", out)
+		}
+		if strings.Contains(out, ";;") {
+			t.Errorf("expected no doubled semicolons:\n%s", out)
+		}
+	})
+
+	t.Run("it round-trips through ParseSchemaFile without drift", func(t *testing.T) {
+		index, parsed, err := ParseSchemaFile(GenerateSchemaFile("240102000000", schema, order))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if index != "240102000000" {
+			t.Errorf("expected index 240102000000, got %q", index)
+		}
+		if diff := DiffSchemas(parsed, schema); diff.HasDrift() {
+			t.Errorf("expected round trip without drift, got %+v", diff)
+		}
+	})
+
+	t.Run("it rejects SQL outside a table section", func(t *testing.T) {
+		if _, _, err := ParseSchemaFile("-- header\nDROP TABLE users;\n"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("it rejects duplicate table sections", func(t *testing.T) {
+		if _, _, err := ParseSchemaFile("-- table: a\nCREATE TABLE a (id INT);\n-- table: a\nCREATE TABLE a (id INT);\n"); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestVerifyFile(t *testing.T) {
+	ctx := context.Background()
+	users := map[string]string{"users": "CREATE TABLE users (id INT)"}
+
+	writeSchema := func(t *testing.T) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "schema.sql")
+		os.WriteFile(path, []byte(GenerateSchemaFile("240101000000", users, []string{"users"})), 0644)
+		return path
+	}
+
+	t.Run("it compares the live schema against the file", func(t *testing.T) {
+		adapter := &mockDBAdapter{computedSchema: map[string]string{"users": "CREATE TABLE users (id BIGINT)"}}
+
+		result, err := VerifyFileAction{DB: adapter, Path: writeSchema(t)}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.MigrationIndex != "240101000000" || len(result.Modified) != 1 {
+			t.Errorf("expected users modified at 240101000000, got %+v", result)
+		}
+		if len(adapter.appliedFiles) != 0 {
+			t.Errorf("expected no replay, got %v", adapter.appliedFiles)
+		}
+	})
+
+	t.Run("it replays the migration files first when ReplayDir is set", func(t *testing.T) {
+		dir := t.TempDir()
+		createTestFile(t, dir, "240101000000_a.sql")
+		shadow := &mockDBAdapter{schemaSteps: []map[string]string{{}, users}}
+
+		result, err := VerifyFileAction{DB: shadow, Path: writeSchema(t), ReplayDir: dir}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.HasDrift() {
+			t.Errorf("expected no drift, got %+v", result)
+		}
+		if len(shadow.appliedFiles) != 1 {
+			t.Errorf("expected one replayed file, got %v", shadow.appliedFiles)
+		}
+	})
+}
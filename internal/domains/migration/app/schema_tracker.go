@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"strings"
+)
+
+// SchemaTracker carries the computed schema from one snapshot to the next
+// within a migrate up batch, so a snapshot only recomputes the tables the
+// migrations since the previous one touched. The first snapshot of a batch
+// always computes every table, so drift made outside migrations is never
+// carried forward from an older snapshot.
+//
+// Besides the tables named by Touch, a snapshot recomputes tables that are
+// new since the last one and tables with a foreign key to a touched table
+// (renaming a referenced table or column rewrites the referencing DDL).
+// Dropped tables fall out of the table list. The zero value is ready to use.
+type SchemaTracker struct {
+	schema  map[string]string
+	touched map[string]bool // lower-cased, as identifiers may fold case
+	unknown bool
+}
+
+// Touch records the tables a migration may have changed. known is false when
+// the migration's effect can't be determined, which makes the next snapshot
+// compute every table.
+func (t *SchemaTracker) Touch(tables []string, known bool) {
+	if !known {
+		t.unknown = true
+		return
+	}
+	if t.touched == nil {
+		t.touched = make(map[string]bool)
+	}
+	for _, name := range tables {
+		t.touched[strings.ToLower(name)] = true
+	}
+}
+
+// Schema returns the current schema, recomputing only what changed since the
+// last call. The returned map is not modified by later calls.
+func (t *SchemaTracker) Schema(ctx context.Context, db DBAdapter) (map[string]string, error) {
+	if t.schema == nil || t.unknown {
+		schema, err := db.ComputeSchema(ctx)
+		if err != nil {
+			return nil, err
+		}
+		t.reset(schema)
+		return schema, nil
+	}
+
+	names, err := db.ListTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := t.stale()
+	schema := make(map[string]string, len(names))
+	var recompute []string
+	for _, name := range names {
+		ddl, ok := t.schema[name]
+		if !ok || stale[strings.ToLower(name)] {
+			recompute = append(recompute, name)
+			continue
+		}
+		schema[name] = ddl
+	}
+
+	if len(recompute) > 0 {
+		fresh, err := db.ComputeTables(ctx, recompute)
+		if err != nil {
+			return nil, err
+		}
+		for name, ddl := range fresh {
+			schema[name] = ddl
+		}
+	}
+
+	t.reset(schema)
+	return schema, nil
+}
+
+// stale returns the lower-cased names of the touched tables and of every
+// table whose foreign keys reference one of them.
+func (t *SchemaTracker) stale() map[string]bool {
+	stale := make(map[string]bool, len(t.touched))
+	for name := range t.touched {
+		stale[name] = true
+	}
+	for table, refs := range ParseFKDependencies(t.schema) {
+		for _, ref := range refs {
+			if t.touched[strings.ToLower(ref)] {
+				stale[strings.ToLower(table)] = true
+				break
+			}
+		}
+	}
+	return stale
+}
+
+func (t *SchemaTracker) reset(schema map[string]string) {
+	t.schema = schema
+	t.touched = nil
+	t.unknown = false
+}
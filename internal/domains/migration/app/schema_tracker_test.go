@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+)
+
+func TestSchemaTracker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it computes the full schema on the first call", func(t *testing.T) {
+		adapter := &mockDBAdapter{computedSchema: map[string]string{"users": "CREATE TABLE users"}}
+		tracker := &SchemaTracker{}
+
+		schema, err := tracker.Schema(ctx, adapter)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if schema["users"] != "CREATE TABLE users" || len(adapter.computedTables) != 0 {
+			t.Errorf("expected a full compute, got %v (ComputeTables calls %v)", schema, adapter.computedTables)
+		}
+	})
+
+	t.Run("it recomputes only touched, new and referencing tables", func(t *testing.T) {
+		adapter := &mockDBAdapter{computedSchema: map[string]string{
+			"users":    "CREATE TABLE users (id INT)",
+			"orders":   "CREATE TABLE orders (user_id INT REFERENCES users (id))",
+			"settings": "CREATE TABLE settings (k TEXT)",
+			"old":      "CREATE TABLE old (id INT)",
+		}}
+		tracker := &SchemaTracker{}
+		if _, err := tracker.Schema(ctx, adapter); err != nil {
+			t.Fatal(err)
+		}
+
+		adapter.computedSchema = map[string]string{
+			"users":    "CREATE TABLE users (id BIGINT)",
+			"orders":   "CREATE TABLE orders (user_id BIGINT REFERENCES users (id))",
+			"settings": "changed outside the migration",
+			"fresh":    "CREATE TABLE fresh (id INT)",
+		}
+		tracker.Touch([]string{"Users"}, true)
+
+		schema, err := tracker.Schema(ctx, adapter)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(adapter.computedTables, [][]string{{"fresh", "orders", "users"}}) {
+			t.Errorf("unexpected ComputeTables calls: %v", adapter.computedTables)
+		}
+		want := map[string]string{
+			"users":    "CREATE TABLE users (id BIGINT)",
+			"orders":   "CREATE TABLE orders (user_id BIGINT REFERENCES users (id))",
+			"settings": "CREATE TABLE settings (k TEXT)",
+			"fresh":    "CREATE TABLE fresh (id INT)",
+		}
+		if !reflect.DeepEqual(schema, want) {
+			t.Errorf("expected %v, got %v", want, schema)
+		}
+	})
+
+	t.Run("it computes everything after an unknown migration", func(t *testing.T) {
+		adapter := &mockDBAdapter{computedSchema: map[string]string{"users": "v1"}}
+		tracker := &SchemaTracker{}
+		if _, err := tracker.Schema(ctx, adapter); err != nil {
+			t.Fatal(err)
+		}
+
+		adapter.computedSchema = map[string]string{"users": "v2"}
+		tracker.Touch(nil, false)
+		tracker.Touch([]string{"other"}, true)
+
+		schema, err := tracker.Schema(ctx, adapter)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if schema["users"] != "v2" || len(adapter.computedTables) != 0 {
+			t.Errorf("expected a full compute, got %v (ComputeTables calls %v)", schema, adapter.computedTables)
+		}
+	})
+}
+
+func TestApplyWithSchemaTracker(t *testing.T) {
+	ctx := context.Background()
+
+	writeMigration := func(t *testing.T, dir, name, sql string) domain.Migration {
+		t.Helper()
+		path := filepath.Join(dir, name+".sql")
+		if err := os.WriteFile(path, []byte(sql), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return domain.Migration{MigrationIndex: name, FileFullPath: path}
+	}
+
+	t.Run("it snapshots only at the end of the batch when told to skip", func(t *testing.T) {
+		dir := t.TempDir()
+		first := writeMigration(t, dir, "240101000000", "ALTER TABLE users ADD a INT;")
+		second := writeMigration(t, dir, "240102000000", "ALTER TABLE orders ADD b INT;")
+
+		adapter := &mockDBAdapter{computedSchema: map[string]string{"users": "u1", "orders": "o1", "tags": "t1"}}
+		tracker := &SchemaTracker{}
+		if _, err := tracker.Schema(ctx, adapter); err != nil {
+			t.Fatal(err)
+		}
+		adapter.computedSchema = map[string]string{"users": "u2", "orders": "o2", "tags": "t1"}
+
+		if err := (ApplyAction{DB: adapter, Migration: first, Schema: tracker, SkipSnapshot: true}).Execute(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(adapter.snapshots) != 0 {
+			t.Fatalf("expected no snapshot yet, got %v", adapter.snapshots)
+		}
+		if err := (ApplyAction{DB: adapter, Migration: second, Schema: tracker}).Execute(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(adapter.snapshots, []string{"240102000000"}) {
+			t.Errorf("expected one snapshot at the last migration, got %v", adapter.snapshots)
+		}
+		if !reflect.DeepEqual(adapter.computedTables, [][]string{{"orders", "users"}}) {
+			t.Errorf("expected touches from both migrations to be recomputed, got %v", adapter.computedTables)
+		}
+		if got := adapter.storedSchemas["240102000000"]["users"]; got != "u2" {
+			t.Errorf("expected users u2 in the snapshot, got %q", got)
+		}
+	})
+}
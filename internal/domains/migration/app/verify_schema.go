@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strings"
 )
 
 // ModifiedTable describes a single table whose live schema differs from its
@@ -62,8 +63,19 @@ func (a VerifySchemaAction) Execute(ctx context.Context) (VerifyResult, error) {
 		return result, fmt.Errorf("computing live schema: %w", err)
 	}
 
-	for table, liveStmt := range live {
-		snapStmt, ok := snapshot[table]
+	result = DiffSchemas(snapshot, live)
+	result.MigrationIndex = index
+	return result, nil
+}
+
+// DiffSchemas compares an expected schema (a snapshot, or a replay of the
+// migration files) against an actual one. Tables only in actual are Added,
+// tables only in expected are Removed; MigrationIndex is left for the caller.
+func DiffSchemas(expected, actual map[string]string) VerifyResult {
+	var result VerifyResult
+
+	for table, liveStmt := range actual {
+		snapStmt, ok := expected[table]
 		if !ok {
 			result.Added = append(result.Added, table)
 			continue
@@ -77,8 +89,8 @@ func (a VerifySchemaAction) Execute(ctx context.Context) (VerifyResult, error) {
 		}
 	}
 
-	for table := range snapshot {
-		if _, ok := live[table]; !ok {
+	for table := range expected {
+		if _, ok := actual[table]; !ok {
 			result.Removed = append(result.Removed, table)
 		}
 	}
@@ -89,7 +101,7 @@ func (a VerifySchemaAction) Execute(ctx context.Context) (VerifyResult, error) {
 		return result.Modified[i].Table < result.Modified[j].Table
 	})
 
-	return result, nil
+	return result
 }
 
 // autoIncrementRE strips `AUTO_INCREMENT=<n>` from MySQL SHOW CREATE TABLE
@@ -98,7 +110,9 @@ func (a VerifySchemaAction) Execute(ctx context.Context) (VerifyResult, error) {
 var autoIncrementRE = regexp.MustCompile(`\s*AUTO_INCREMENT=\d+`)
 
 // normalizeCreateTable removes non-structural noise so two equivalent schemas
-// compare equal. Currently strips MySQL's AUTO_INCREMENT counter.
+// compare equal. Strips MySQL's AUTO_INCREMENT counter and the trailing
+// semicolon a schema file adds to each statement.
 func normalizeCreateTable(stmt string) string {
-	return autoIncrementRE.ReplaceAllString(stmt, "")
+	stmt = autoIncrementRE.ReplaceAllString(stmt, "")
+	return strings.TrimSuffix(strings.TrimSpace(stmt), ";")
 }
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+)
+
+// ShadowDivergence is the first point where a replay of the migration files
+// stopped matching what the target database recorded.
+type ShadowDivergence struct {
+	MigrationIndex string       `json:"migration_index"`
+	Against        string       `json:"against"` // "snapshot" or "live"
+	Diff           VerifyResult `json:"diff"`
+}
+
+// ShadowVerifyResult is the outcome of replaying the migration chain on a
+// shadow database.
+type ShadowVerifyResult struct {
+	Replayed         []string          `json:"replayed"`
+	SnapshotsChecked int               `json:"snapshots_checked"`
+	LastApplied      string            `json:"last_applied"`
+	Divergence       *ShadowDivergence `json:"divergence"`
+	// Live compares the replay at LastApplied (expected) with the target's
+	// live schema (actual).
+	Live VerifyResult `json:"live"`
+}
+
+// HasDrift reports whether the replay diverged from a snapshot or the live
+// schema.
+func (r ShadowVerifyResult) HasDrift() bool {
+	return r.Divergence != nil
+}
+
+// ShadowVerifyAction replays every migration file, in chain order, on an empty
+// shadow database. After each migration the target has applied, the shadow
+// schema is compared with the snapshot the target stored for that index, and
+// after the last applied migration with the target's live schema. Pending
+// migrations are replayed too, so a file that no longer applies is caught.
+type ShadowVerifyAction struct {
+	Target        DBAdapter
+	Shadow        DBAdapter
+	MigrationsDir string
+	Profile       string
+}
+
+// Execute performs the replay and returns the first divergence, if any.
+func (a ShadowVerifyAction) Execute(ctx context.Context) (ShadowVerifyResult, error) {
+	var result ShadowVerifyResult
+
+	chain, err := GetMigrationChainAction{DB: a.Target, MigrationsDir: a.MigrationsDir}.Execute(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, m := range chain {
+		if m.Status == domain.StatusApplied {
+			result.LastApplied = m.MigrationIndex
+		}
+	}
+
+	// Nothing applied: the target should be as empty as the shadow.
+	if result.LastApplied == "" {
+		if err := a.compareLive(ctx, &result); err != nil {
+			return result, err
+		}
+	}
+
+	for _, m := range chain {
+		if err := a.Shadow.ApplySQLFromFile(ctx, m.FileFullPath, a.Profile); err != nil {
+			return result, fmt.Errorf("replaying migration %s on shadow: %w", m.MigrationIndex, err)
+		}
+		result.Replayed = append(result.Replayed, m.MigrationIndex)
+
+		if m.Status != domain.StatusApplied {
+			continue
+		}
+
+		if err := a.compareSnapshot(ctx, m.MigrationIndex, &result); err != nil {
+			return result, err
+		}
+		if m.MigrationIndex == result.LastApplied {
+			if err := a.compareLive(ctx, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// compareSnapshot diffs the shadow schema against the target's snapshot for
+// index. Indexes without a snapshot (e.g. applied before snapshots existed)
+// are skipped.
+func (a ShadowVerifyAction) compareSnapshot(ctx context.Context, index string, result *ShadowVerifyResult) error {
+	snapshotJSON, err := a.Target.GetSchemaSnapshot(ctx, index)
+	if errors.Is(err, domain.ErrNoSnapshot) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading snapshot %s: %w", index, err)
+	}
+
+	var snapshot map[string]string
+	if err := json.Unmarshal([]byte(snapshotJSON), &snapshot); err != nil {
+		return fmt.Errorf("parsing snapshot %s: %w", index, err)
+	}
+
+	replayed, err := a.Shadow.ComputeSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("computing shadow schema at %s: %w", index, err)
+	}
+
+	result.SnapshotsChecked++
+	diff := DiffSchemas(replayed, snapshot)
+	diff.MigrationIndex = index
+	if diff.HasDrift() && result.Divergence == nil {
+		result.Divergence = &ShadowDivergence{MigrationIndex: index, Against: "snapshot", Diff: diff}
+	}
+	return nil
+}
+
+// compareLive diffs the shadow schema against the target's live schema.
+func (a ShadowVerifyAction) compareLive(ctx context.Context, result *ShadowVerifyResult) error {
+	replayed, err := a.Shadow.ComputeSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("computing shadow schema: %w", err)
+	}
+	live, err := a.Target.ComputeSchema(ctx)
+	if err != nil {
+		return fmt.Errorf("computing live schema: %w", err)
+	}
+
+	result.Live = DiffSchemas(replayed, live)
+	result.Live.MigrationIndex = result.LastApplied
+	if result.Live.HasDrift() && result.Divergence == nil {
+		result.Divergence = &ShadowDivergence{MigrationIndex: result.LastApplied, Against: "live", Diff: result.Live}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
+)
+
+func TestShadowVerify(t *testing.T) {
+	ctx := context.Background()
+
+	users := map[string]string{"users": "CREATE TABLE users (id INT)"}
+	usersEmail := map[string]string{"users": "CREATE TABLE users (id INT, email TEXT)"}
+
+	setup := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		createTestFile(t, dir, "240101000000_a.sql")
+		createTestFile(t, dir, "240102000000_b.sql")
+		return dir
+	}
+	applied := []models.MigrationRow{
+		{ID: 1, MigrationIndex: "240101000000", AppliedAt: time.Now()},
+		{ID: 2, MigrationIndex: "240102000000", AppliedAt: time.Now()},
+	}
+
+	t.Run("it reports no drift when replay matches snapshots and live", func(t *testing.T) {
+		target := &mockDBAdapter{
+			appliedMigrations: applied,
+			snapshotsByIndex: map[string]string{
+				"240101000000": `{"users":"CREATE TABLE users (id INT)"}`,
+				"240102000000": `{"users":"CREATE TABLE users (id INT, email TEXT)"}`,
+			},
+			computedSchema: usersEmail,
+		}
+		shadow := &mockDBAdapter{schemaSteps: []map[string]string{{}, users, usersEmail}}
+
+		result, err := ShadowVerifyAction{Target: target, Shadow: shadow, MigrationsDir: setup(t)}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.HasDrift() {
+			t.Errorf("expected no drift, got %+v", result.Divergence)
+		}
+		if len(result.Replayed) != 2 || result.SnapshotsChecked != 2 {
+			t.Errorf("expected 2 replayed and 2 checked, got %+v", result)
+		}
+	})
+
+	t.Run("it reports the first snapshot that diverges from the replay", func(t *testing.T) {
+		target := &mockDBAdapter{
+			appliedMigrations: applied,
+			snapshotsByIndex: map[string]string{
+				"240101000000": `{"users":"CREATE TABLE users (id BIGINT)"}`,
+				"240102000000": `{"users":"CREATE TABLE users (id BIGINT, email TEXT)"}`,
+			},
+			computedSchema: usersEmail,
+		}
+		shadow := &mockDBAdapter{schemaSteps: []map[string]string{{}, users, usersEmail}}
+
+		result, err := ShadowVerifyAction{Target: target, Shadow: shadow, MigrationsDir: setup(t)}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Divergence == nil || result.Divergence.MigrationIndex != "240101000000" || result.Divergence.Against != "snapshot" {
+			t.Fatalf("expected divergence at first snapshot, got %+v", result.Divergence)
+		}
+		if len(result.Divergence.Diff.Modified) != 1 {
+			t.Errorf("expected users modified, got %+v", result.Divergence.Diff)
+		}
+	})
+
+	t.Run("it skips missing snapshots and reports live drift", func(t *testing.T) {
+		target := &mockDBAdapter{
+			appliedMigrations: applied,
+			snapshotsByIndex:  map[string]string{},
+			computedSchema: map[string]string{
+				"users":  "CREATE TABLE users (id INT, email TEXT)",
+				"hotfix": "CREATE TABLE hotfix (id INT)",
+			},
+		}
+		shadow := &mockDBAdapter{schemaSteps: []map[string]string{{}, users, usersEmail}}
+
+		result, err := ShadowVerifyAction{Target: target, Shadow: shadow, MigrationsDir: setup(t)}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.SnapshotsChecked != 0 {
+			t.Errorf("expected no snapshots checked, got %d", result.SnapshotsChecked)
+		}
+		if result.Divergence == nil || result.Divergence.Against != "live" || result.Divergence.MigrationIndex != "240102000000" {
+			t.Fatalf("expected live divergence at last applied, got %+v", result.Divergence)
+		}
+		if len(result.Live.Added) != 1 || result.Live.Added[0] != "hotfix" {
+			t.Errorf("expected hotfix added, got %+v", result.Live)
+		}
+	})
+
+	t.Run("it compares live at the last applied index and still replays pending files", func(t *testing.T) {
+		target := &mockDBAdapter{
+			appliedMigrations: applied[:1],
+			snapshotsByIndex:  map[string]string{},
+			computedSchema:    users,
+		}
+		shadow := &mockDBAdapter{schemaSteps: []map[string]string{{}, users, usersEmail}}
+
+		result, err := ShadowVerifyAction{Target: target, Shadow: shadow, MigrationsDir: setup(t)}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.HasDrift() || result.LastApplied != "240101000000" || len(result.Replayed) != 2 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("it names the migration that fails to replay", func(t *testing.T) {
+		target := &mockDBAdapter{appliedMigrations: applied, computedSchema: usersEmail}
+		shadow := &mockDBAdapter{applySQLErr: fmt.Errorf("syntax error")}
+
+		_, err := ShadowVerifyAction{Target: target, Shadow: shadow, MigrationsDir: setup(t)}.Execute(ctx)
+		if err == nil || err.Error() != "replaying migration 240101000000 on shadow: syntax error" {
+			t.Errorf("expected replay error naming the index, got %v", err)
+		}
+	})
+}
@@ -6,4 +6,11 @@ var (
 	ErrNoMigrationTable       = errors.New("migrations table does not exist")
 	ErrMigrationAlreadyExists = errors.New("migrations table already exists")
 	ErrMigrationTableCreation = errors.New("error creating migrations table")
+	ErrNoSnapshot             = errors.New("no snapshot found")
+)
+
+var (
+	ErrUnknownImportSource = errors.New("unknown import source")
+	ErrImportNotEmpty      = errors.New("joka migration history is not empty")
+	ErrForeignTableMissing = errors.New("migration history table not found")
 )
@@ -0,0 +1,27 @@
+package domain
+
+import "fmt"
+
+// ImportSource identifies another migration tool whose history can be
+// imported with `joka migrate import --from`.
+type ImportSource string
+
+const (
+	ImportGoose         ImportSource = "goose"
+	ImportGolangMigrate ImportSource = "golang-migrate"
+	ImportFlyway        ImportSource = "flyway"
+	ImportDbmate        ImportSource = "dbmate"
+)
+
+// ImportSources lists every supported source, in the order shown in help text.
+var ImportSources = []ImportSource{ImportGoose, ImportGolangMigrate, ImportFlyway, ImportDbmate}
+
+// ParseImportSource validates a --from value.
+func ParseImportSource(s string) (ImportSource, error) {
+	for _, src := range ImportSources {
+		if string(src) == s {
+			return src, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q (expected goose, golang-migrate, flyway or dbmate)", ErrUnknownImportSource, s)
+}
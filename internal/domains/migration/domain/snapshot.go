@@ -0,0 +1,39 @@
+package domain
+
+import "fmt"
+
+// SnapshotMode selects when migrate up captures schema snapshots.
+type SnapshotMode string
+
+const (
+	// SnapshotPerMigration captures a snapshot after every applied migration.
+	// It is the default.
+	SnapshotPerMigration SnapshotMode = "per-migration"
+	// SnapshotEndOfBatch captures a single snapshot after the last migration
+	// of a migrate up run. Migrations earlier in the batch get none, so they
+	// can't be used with consolidate --up-to or checked by verify --shadow.
+	SnapshotEndOfBatch SnapshotMode = "end-of-batch"
+)
+
+// ParseSnapshotMode validates a snapshots.mode / --snapshot value. An empty
+// value is SnapshotPerMigration.
+func ParseSnapshotMode(s string) (SnapshotMode, error) {
+	switch SnapshotMode(s) {
+	case "", SnapshotPerMigration:
+		return SnapshotPerMigration, nil
+	case SnapshotEndOfBatch:
+		return SnapshotEndOfBatch, nil
+	}
+	return "", fmt.Errorf("unknown snapshot mode %q (expected per-migration or end-of-batch)", s)
+}
+
+// SnapshotOptions controls how migrate up captures schema snapshots and how
+// they are stored in joka_snapshots.
+type SnapshotOptions struct {
+	// Compress stores each table's DDL gzipped. Reading is transparent either
+	// way, so the setting can be changed at any time.
+	Compress bool
+	// Mode selects when snapshots are captured. The zero value behaves as
+	// SnapshotPerMigration.
+	Mode SnapshotMode
+}
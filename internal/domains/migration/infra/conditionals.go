@@ -0,0 +1,189 @@
+package infra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	jokadb "github.com/apsdsm/joka/db"
+)
+
+// Conditions are the values `-- joka:if` blocks in a migration are evaluated
+// against.
+type Conditions struct {
+	Driver  jokadb.Driver
+	Profile string // selected .jokarc.yaml profile; empty when none
+}
+
+// conditionalBlock tracks one open `-- joka:if` while evaluating.
+type conditionalBlock struct {
+	line     int  // line the block opened on, for unterminated-block errors
+	parentOn bool // whether the enclosing block is emitting lines
+	taken    bool // whether an earlier branch of this block matched
+	on       bool // whether the current branch is emitting lines
+	sawElse  bool
+}
+
+// EvaluateConditionals resolves the conditional blocks in a migration:
+//
+//	-- joka:if driver=postgres
+//	CREATE INDEX CONCURRENTLY ...;
+//	-- joka:elif profile=staging,production
+//	...
+//	-- joka:else
+//	CREATE INDEX ...;
+//	-- joka:end
+//
+// A condition is key=value or key!=value, where key is driver or profile and
+// value may list alternatives separated by commas. Blocks can nest. Lines in
+// branches that don't match, and the directive lines themselves, are blanked;
+// everything else is returned unchanged. name is used to prefix error
+// messages with file:line.
+func EvaluateConditionals(name, content string, c Conditions) (string, error) {
+	var out strings.Builder
+	var stack []conditionalBlock
+	on := true
+
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		lineNo := i + 1
+		text := strings.TrimSpace(line)
+		directive, arg, isDirective := conditionalDirective(text)
+		if !isDirective && on {
+			out.WriteString(line)
+			continue
+		}
+		// Dropped lines and directives become blank lines so line numbers in
+		// the evaluated SQL still match the file.
+		if strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+		if !isDirective {
+			continue
+		}
+
+		switch directive {
+		case "if":
+			match, err := evalCondition(arg, c)
+			if err != nil {
+				return "", fmt.Errorf("%s:%d: %w", name, lineNo, err)
+			}
+			stack = append(stack, conditionalBlock{line: lineNo, parentOn: on, taken: match, on: match})
+		case "elif":
+			if len(stack) == 0 {
+				return "", fmt.Errorf("%s:%d: joka:elif without joka:if", name, lineNo)
+			}
+			top := &stack[len(stack)-1]
+			if top.sawElse {
+				return "", fmt.Errorf("%s:%d: joka:elif after joka:else", name, lineNo)
+			}
+			match, err := evalCondition(arg, c)
+			if err != nil {
+				return "", fmt.Errorf("%s:%d: %w", name, lineNo, err)
+			}
+			top.on = match && !top.taken
+			top.taken = top.taken || match
+		case "else":
+			if len(stack) == 0 {
+				return "", fmt.Errorf("%s:%d: joka:else without joka:if", name, lineNo)
+			}
+			top := &stack[len(stack)-1]
+			if top.sawElse {
+				return "", fmt.Errorf("%s:%d: duplicate joka:else", name, lineNo)
+			}
+			top.sawElse = true
+			top.on = !top.taken
+			top.taken = true
+		case "end":
+			if len(stack) == 0 {
+				return "", fmt.Errorf("%s:%d: joka:end without joka:if", name, lineNo)
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		on = true
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			on = top.parentOn && top.on
+		}
+	}
+
+	if len(stack) > 0 {
+		return "", fmt.Errorf("%s:%d: joka:if is never closed with joka:end", name, stack[len(stack)-1].line)
+	}
+	return out.String(), nil
+}
+
+// conditionalDirective reports whether a trimmed line is one of the
+// conditional directives (if/elif/else/end), returning its name and argument.
+// Other `-- joka:` directives, such as timeouts, are not conditional.
+func conditionalDirective(text string) (string, string, bool) {
+	if !strings.HasPrefix(text, directivePrefix) {
+		return "", "", false
+	}
+	name, arg, _ := strings.Cut(strings.TrimPrefix(text, directivePrefix), " ")
+	switch name {
+	case "if", "elif", "else", "end":
+		return name, strings.TrimSpace(arg), true
+	}
+	return "", "", false
+}
+
+// evalCondition evaluates a single key=value or key!=value condition.
+func evalCondition(cond string, c Conditions) (bool, error) {
+	negate := false
+	key, value, ok := strings.Cut(cond, "!=")
+	if ok {
+		negate = true
+	} else if key, value, ok = strings.Cut(cond, "="); !ok {
+		return false, fmt.Errorf("invalid condition %q (expected key=value or key!=value)", cond)
+	}
+	key = strings.TrimSpace(key)
+
+	var actual string
+	switch key {
+	case "driver":
+		actual = c.Driver.String()
+	case "profile":
+		actual = c.Profile
+	default:
+		return false, fmt.Errorf("unknown condition %q (expected driver or profile)", key)
+	}
+
+	match := false
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if key == "driver" && v == "postgresql" {
+			v = "postgres"
+		}
+		if v == actual {
+			match = true
+			break
+		}
+	}
+	return match != negate, nil
+}
+
+// ReadMigrationSQL reads the migration file at path and returns its SQL with
+// conditional blocks evaluated, ready for jokadb.SplitSQLStatements.
+func ReadMigrationSQL(path string, c Conditions) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading migration file: %w", err)
+	}
+	return EvaluateConditionals(path, string(content), c)
+}
+
+// MigrationChecksum returns the hex SHA-256 of the raw migration file. It
+// covers the file as written, before conditional blocks are evaluated, so the
+// same file has the same checksum on every driver and profile.
+func MigrationChecksum(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading migration file: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,131 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jokadb "github.com/apsdsm/joka/db"
+)
+
+func TestEvaluateConditionals(t *testing.T) {
+	const migration = `CREATE TABLE t (id INT);
+-- joka:if driver=postgres
+CREATE INDEX CONCURRENTLY i ON t (id);
+-- joka:else
+CREATE INDEX i ON t (id);
+-- joka:end
+`
+
+	t.Run("it keeps the branch matching the driver", func(t *testing.T) {
+		got, err := EvaluateConditionals("m.sql", migration, Conditions{Driver: jokadb.Postgres})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stmts := jokadb.SplitSQLStatements(got)
+		if len(stmts) != 2 || stmts[1] != "CREATE INDEX CONCURRENTLY i ON t (id)" {
+			t.Errorf("unexpected statements: %q", stmts)
+		}
+	})
+
+	t.Run("it falls through to else for other drivers", func(t *testing.T) {
+		got, err := EvaluateConditionals("m.sql", migration, Conditions{Driver: jokadb.MySQL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stmts := jokadb.SplitSQLStatements(got)
+		if len(stmts) != 2 || stmts[1] != "CREATE INDEX i ON t (id)" {
+			t.Errorf("unexpected statements: %q", stmts)
+		}
+	})
+
+	t.Run("it preserves line numbers", func(t *testing.T) {
+		got, err := EvaluateConditionals("m.sql", migration, Conditions{Driver: jokadb.MySQL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Count(got, "\n") != strings.Count(migration, "\n") {
+			t.Errorf("expected %d lines, got %d", strings.Count(migration, "\n"), strings.Count(got, "\n"))
+		}
+	})
+
+	t.Run("it matches profile lists, negation and elif", func(t *testing.T) {
+		src := `-- joka:if profile=staging,production
+SELECT 'deployed';
+-- joka:elif profile!=
+SELECT 'other profile';
+-- joka:else
+SELECT 'no profile';
+-- joka:end
+`
+		cases := map[string]string{
+			"production": "SELECT 'deployed'",
+			"dev":        "SELECT 'other profile'",
+			"":           "SELECT 'no profile'",
+		}
+		for profile, want := range cases {
+			got, err := EvaluateConditionals("m.sql", src, Conditions{Profile: profile})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			stmts := jokadb.SplitSQLStatements(got)
+			if len(stmts) != 1 || stmts[0] != want {
+				t.Errorf("profile %q: expected %q, got %q", profile, want, stmts)
+			}
+		}
+	})
+
+	t.Run("it evaluates nested blocks inside a skipped branch as skipped", func(t *testing.T) {
+		src := `-- joka:if driver=postgres
+-- joka:if profile=dev
+SELECT 1;
+-- joka:end
+-- joka:end
+`
+		got, err := EvaluateConditionals("m.sql", src, Conditions{Driver: jokadb.MySQL, Profile: "dev"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stmts := jokadb.SplitSQLStatements(got); len(stmts) != 0 {
+			t.Errorf("expected no statements, got %q", stmts)
+		}
+	})
+
+	t.Run("it reports malformed blocks with the file and line", func(t *testing.T) {
+		cases := map[string]string{
+			"unclosed":      "-- joka:if driver=mysql\nSELECT 1;\n",
+			"stray end":     "SELECT 1;\n-- joka:end\n",
+			"unknown key":   "-- joka:if engine=innodb\n-- joka:end\n",
+			"double else":   "-- joka:if driver=mysql\n-- joka:else\n-- joka:else\n-- joka:end\n",
+			"bad condition": "-- joka:if postgres\n-- joka:end\n",
+		}
+		for name, src := range cases {
+			_, err := EvaluateConditionals("m.sql", src, Conditions{})
+			if err == nil || !strings.HasPrefix(err.Error(), "m.sql:") {
+				t.Errorf("%s: expected positioned error, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestMigrationChecksum(t *testing.T) {
+	t.Run("it covers the raw file regardless of conditions", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "240101000000_a.sql")
+		os.WriteFile(path, []byte("-- joka:if driver=postgres\nSELECT 1;\n-- joka:end\n"), 0644)
+
+		sum, err := MigrationChecksum(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(sum) != 64 {
+			t.Errorf("expected a hex sha256, got %q", sum)
+		}
+
+		os.WriteFile(path, []byte("-- joka:if driver=mysql\nSELECT 1;\n-- joka:end\n"), 0644)
+		changed, _ := MigrationChecksum(path)
+		if changed == sum {
+			t.Error("expected checksum to change when an inactive branch changes")
+		}
+	})
+}
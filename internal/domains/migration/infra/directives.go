@@ -0,0 +1,76 @@
+package infra
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	jokadb "github.com/apsdsm/joka/db"
+)
+
+// directivePrefix marks a comment line as a joka directive rather than a plain
+// SQL comment, e.g. `-- joka:lock_timeout 30s`.
+const directivePrefix = "-- joka:"
+
+// ReadMigrationTimeouts reads the header of the migration file at path and
+// returns any timeout overrides it declares. Conditional blocks are evaluated
+// first (see EvaluateConditionals), so a timeout can be scoped to a driver or
+// profile. The header is the run of blank and comment lines at the top of the
+// file; directives further down are not considered. Recognised directives:
+//
+//	-- joka:lock_timeout 30s
+//	-- joka:statement_timeout 10m
+//	-- joka:lock_wait_timeout 60s
+//	-- joka:max_execution_time 5s
+func ReadMigrationTimeouts(path string, c Conditions) (jokadb.Timeouts, error) {
+	content, err := ReadMigrationSQL(path, c)
+	if err != nil {
+		return jokadb.Timeouts{}, err
+	}
+
+	var t jokadb.Timeouts
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if !strings.HasPrefix(text, "--") {
+			break
+		}
+		if !strings.HasPrefix(text, directivePrefix) {
+			continue
+		}
+
+		name, value, _ := strings.Cut(strings.TrimPrefix(text, directivePrefix), " ")
+		value = strings.TrimSpace(value)
+
+		var field *time.Duration
+		switch name {
+		case "lock_timeout":
+			field = &t.LockTimeout
+		case "statement_timeout":
+			field = &t.StatementTimeout
+		case "lock_wait_timeout":
+			field = &t.LockWaitTimeout
+		case "max_execution_time":
+			field = &t.MaxExecutionTime
+		default:
+			return jokadb.Timeouts{}, fmt.Errorf("%s:%d: unknown directive %q", path, line, name)
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return jokadb.Timeouts{}, fmt.Errorf("%s:%d: invalid duration %q for %s", path, line, value, name)
+		}
+		*field = d
+	}
+	if err := scanner.Err(); err != nil {
+		return jokadb.Timeouts{}, fmt.Errorf("reading migration file: %w", err)
+	}
+
+	return t, nil
+}
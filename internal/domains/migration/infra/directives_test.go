@@ -0,0 +1,73 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jokadb "github.com/apsdsm/joka/db"
+)
+
+func TestReadMigrationTimeouts(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "240101000000_test.sql")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("it reads timeout directives from the header", func(t *testing.T) {
+		path := write(t, "-- add an index to a busy table\n-- joka:lock_timeout 30s\n-- joka:statement_timeout 10m\n\nCREATE INDEX i ON t (c);\n")
+
+		got, err := ReadMigrationTimeouts(path, Conditions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.LockTimeout != 30*time.Second || got.StatementTimeout != 10*time.Minute {
+			t.Errorf("unexpected timeouts: %+v", got)
+		}
+	})
+
+	t.Run("it ignores directives after the first statement", func(t *testing.T) {
+		path := write(t, "SELECT 1;\n-- joka:lock_timeout 30s\n")
+
+		got, err := ReadMigrationTimeouts(path, Conditions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !got.IsZero() {
+			t.Errorf("expected no timeouts, got %+v", got)
+		}
+	})
+
+	t.Run("it only applies timeouts from the matching conditional branch", func(t *testing.T) {
+		path := write(t, "-- joka:if driver=postgres\n-- joka:lock_timeout 30s\n-- joka:else\n-- joka:lock_wait_timeout 60s\n-- joka:end\nSELECT 1;\n")
+
+		got, err := ReadMigrationTimeouts(path, Conditions{Driver: jokadb.Postgres})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.LockTimeout != 30*time.Second || got.LockWaitTimeout != 0 {
+			t.Errorf("unexpected timeouts: %+v", got)
+		}
+	})
+
+	t.Run("it rejects an unknown directive", func(t *testing.T) {
+		path := write(t, "-- joka:lock_timout 30s\nSELECT 1;\n")
+
+		if _, err := ReadMigrationTimeouts(path, Conditions{}); err == nil {
+			t.Fatal("expected error for unknown directive")
+		}
+	})
+
+	t.Run("it rejects an invalid duration", func(t *testing.T) {
+		path := write(t, "-- joka:lock_timeout soon\nSELECT 1;\n")
+
+		if _, err := ReadMigrationTimeouts(path, Conditions{}); err == nil {
+			t.Fatal("expected error for invalid duration")
+		}
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
-	"time"
 
 	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
 )
@@ -57,22 +56,45 @@ func ListMigrationFiles(dir string) ([]models.MigrationFile, error) {
 	return files, nil
 }
 
-// CreateMigrationFile creates a new empty SQL migration file in dir using the
-// current timestamp as a prefix. It returns the generated filename (not the
-// full path). The migrations directory must already exist.
-func CreateMigrationFile(dir string, name string) (string, error) {
-	info, err := os.Stat(dir)
-	if err != nil || !info.IsDir() {
-		return "", fmt.Errorf("migrations directory not found: %s", dir)
+// WriteMigrationFile writes a migration into dir as fileName, and its down SQL
+// (if any) to down/<fileName>.
+// The down directory sits below the migrations directory so it is never
+// mistaken for part of the chain. It refuses to overwrite existing files and
+// returns the paths it created.
+func WriteMigrationFile(dir, fileName, up, down string) ([]string, error) {
+	var written []string
+	write := func(path, content string) error {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		if _, err := f.WriteString(content); err != nil {
+			f.Close()
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		written = append(written, path)
+		return f.Close()
 	}
 
-	timestamp := time.Now().Format("060102150405")
-	filename := fmt.Sprintf("%s_%s.sql", timestamp, name)
-	filePath := filepath.Join(dir, filename)
+	if err := write(filepath.Join(dir, fileName), up); err != nil {
+		return written, err
+	}
+	if down == "" {
+		return written, nil
+	}
 
-	if err := os.WriteFile(filePath, []byte("-- Write your migration SQL here\n"), 0644); err != nil {
-		return "", fmt.Errorf("creating migration file: %w", err)
+	downPath := DownFilePath(dir, fileName)
+	if err := os.MkdirAll(filepath.Dir(downPath), 0755); err != nil {
+		return written, fmt.Errorf("creating down directory: %w", err)
 	}
+	if err := write(downPath, down); err != nil {
+		return written, err
+	}
+	return written, nil
+}
 
-	return filename, nil
+// DownFilePath returns where the down SQL of the migration fileName in dir
+// lives, whether or not it exists.
+func DownFilePath(dir, fileName string) string {
+	return filepath.Join(dir, "down", fileName)
 }
@@ -87,28 +87,36 @@ func TestListMigrationFiles(t *testing.T) {
 	})
 }
 
-func TestCreateMigrationFile(t *testing.T) {
-	t.Run("it creates a file matching the migration pattern", func(t *testing.T) {
+func TestWriteMigrationFile(t *testing.T) {
+	t.Run("it writes the down section under down/", func(t *testing.T) {
 		dir := t.TempDir()
-		filename, err := CreateMigrationFile(dir, "add_users")
+
+		paths, err := WriteMigrationFile(dir, "240101000000_a.sql", "CREATE TABLE a (id INT);\n", "DROP TABLE a;\n")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-
-		if !migrationPattern.MatchString(filename) {
-			t.Errorf("filename %q does not match migration pattern", filename)
+		if len(paths) != 2 {
+			t.Fatalf("expected 2 files, got %v", paths)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "down", "240101000000_a.sql")); err != nil {
+			t.Errorf("expected down file: %v", err)
 		}
 
-		fullPath := filepath.Join(dir, filename)
-		if _, err := os.Stat(fullPath); err != nil {
-			t.Errorf("migration file not found on disk: %v", err)
+		files, err := ListMigrationFiles(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(files) != 1 {
+			t.Errorf("expected down file to stay out of the chain, got %d files", len(files))
 		}
 	})
 
-	t.Run("it returns an error for a missing directory", func(t *testing.T) {
-		_, err := CreateMigrationFile("/nonexistent/dir", "test")
-		if err == nil {
-			t.Fatal("expected error for missing directory")
+	t.Run("it refuses to overwrite an existing file", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "240101000000_a.sql"), []byte("SELECT 1;"), 0644)
+
+		if _, err := WriteMigrationFile(dir, "240101000000_a.sql", "SELECT 2;", ""); err == nil {
+			t.Fatal("expected error for existing file")
 		}
 	})
 }
@@ -0,0 +1,283 @@
+package infra
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
+)
+
+var (
+	// goose and dbmate: <version>_<name>.sql with up and down in one file.
+	singleFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+	// golang-migrate: <version>_<name>.up.sql / <version>_<name>.down.sql.
+	upDownPattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	// flyway: V<version>__<desc>.sql (versioned), U<version>__<desc>.sql (undo).
+	flywayPattern = regexp.MustCompile(`^([VU])(\d+(?:[._]\d+)*)__(.+)\.sql$`)
+	// flyway repeatable migrations have no version and can't join a linear chain.
+	flywayRepeatablePattern = regexp.MustCompile(`^R__.+\.sql$`)
+
+	nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ListForeignMigrationFiles reads the migrations directory of another tool and
+// returns its migrations sorted by version, with up and down SQL separated.
+// Files the tool would ignore are skipped silently; files that can't be
+// imported (e.g. flyway repeatables) are skipped with a warning.
+func ListForeignMigrationFiles(source domain.ImportSource, dir string) ([]models.ForeignMigrationFile, []string, error) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, nil, fmt.Errorf("source directory not found: %s", dir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading source directory: %w", err)
+	}
+
+	var files []models.ForeignMigrationFile
+	var warnings []string
+	switch source {
+	case domain.ImportGoose, domain.ImportDbmate:
+		files, err = readSingleFileMigrations(source, dir, entries)
+	case domain.ImportGolangMigrate:
+		files, err = readUpDownMigrations(dir, entries)
+	case domain.ImportFlyway:
+		files, warnings, err = readFlywayMigrations(dir, entries)
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", domain.ErrUnknownImportSource, source)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return CompareVersions(files[i].Version, files[j].Version) < 0
+	})
+	for i := 1; i < len(files); i++ {
+		if files[i].Version == files[i-1].Version {
+			return nil, nil, fmt.Errorf("duplicate version %s: %s and %s", files[i].Version, files[i-1].SourceFiles[0], files[i].SourceFiles[0])
+		}
+	}
+
+	return files, warnings, nil
+}
+
+// readSingleFileMigrations reads goose and dbmate files, where one file holds
+// both directions separated by marker comments.
+func readSingleFileMigrations(source domain.ImportSource, dir string, entries []os.DirEntry) ([]models.ForeignMigrationFile, error) {
+	upMarker, downMarker := "-- +goose up", "-- +goose down"
+	if source == domain.ImportDbmate {
+		upMarker, downMarker = "-- migrate:up", "-- migrate:down"
+	}
+
+	var files []models.ForeignMigrationFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := singleFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		f := models.ForeignMigrationFile{
+			Version:     NormalizeVersion(m[1]),
+			RawVersion:  m[1],
+			Name:        sanitizeName(m[2]),
+			SourceFiles: []string{path},
+		}
+
+		var up, down []string
+		var section *[]string
+		for _, line := range strings.SplitAfter(string(content), "\n") {
+			marker := strings.ToLower(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(marker, upMarker):
+				section = &up
+				if strings.Contains(marker, "transaction:false") {
+					f.Warnings = append(f.Warnings, "runs outside a transaction in dbmate; joka applies it inside the migration transaction")
+				}
+				continue
+			case strings.HasPrefix(marker, downMarker):
+				section = &down
+				continue
+			case marker == "-- +goose no transaction":
+				f.Warnings = append(f.Warnings, "runs outside a transaction in goose; joka applies it inside the migration transaction")
+				continue
+			case marker == "-- +goose statementbegin":
+				if section == &up {
+					f.Warnings = append(f.Warnings, "uses StatementBegin/StatementEnd; joka splits on top-level semicolons, check the statement survives")
+				}
+			}
+			if section != nil {
+				*section = append(*section, line)
+			}
+		}
+		if section == nil {
+			return nil, fmt.Errorf("%s: no %q section found", path, upMarker)
+		}
+
+		f.Up = strings.TrimSpace(strings.Join(up, ""))
+		f.Down = strings.TrimSpace(strings.Join(down, ""))
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// readUpDownMigrations reads golang-migrate files, where each direction lives
+// in its own file.
+func readUpDownMigrations(dir string, entries []os.DirEntry) ([]models.ForeignMigrationFile, error) {
+	byVersion := make(map[string]*models.ForeignMigrationFile)
+	var order []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := upDownPattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		version := NormalizeVersion(m[1])
+		f, ok := byVersion[version]
+		if !ok {
+			f = &models.ForeignMigrationFile{Version: version, RawVersion: m[1], Name: sanitizeName(m[2])}
+			byVersion[version] = f
+			order = append(order, version)
+		}
+		f.SourceFiles = append(f.SourceFiles, path)
+		if m[3] == "up" {
+			f.Up = strings.TrimSpace(string(content))
+		} else {
+			f.Down = strings.TrimSpace(string(content))
+		}
+	}
+
+	var files []models.ForeignMigrationFile
+	for _, version := range order {
+		f := byVersion[version]
+		if f.Up == "" && f.Down != "" {
+			return nil, fmt.Errorf("migration %s has a down file but no up file", f.RawVersion)
+		}
+		files = append(files, *f)
+	}
+	return files, nil
+}
+
+// readFlywayMigrations reads flyway versioned (V) migrations and attaches any
+// matching undo (U) migration as the down SQL.
+func readFlywayMigrations(dir string, entries []os.DirEntry) ([]models.ForeignMigrationFile, []string, error) {
+	byVersion := make(map[string]*models.ForeignMigrationFile)
+	undo := make(map[string]string)
+	var order []string
+	var warnings []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if flywayRepeatablePattern.MatchString(name) {
+			warnings = append(warnings, fmt.Sprintf("skipping repeatable migration %s: joka has no repeatable migrations", name))
+			continue
+		}
+		m := flywayPattern.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		raw := strings.ReplaceAll(m[2], "_", ".")
+		version := NormalizeVersion(raw)
+		if m[1] == "U" {
+			undo[version] = strings.TrimSpace(string(content))
+			continue
+		}
+		byVersion[version] = &models.ForeignMigrationFile{
+			Version:     version,
+			RawVersion:  raw,
+			Name:        sanitizeName(m[3]),
+			SourceFiles: []string{path},
+			Up:          strings.TrimSpace(string(content)),
+		}
+		order = append(order, version)
+	}
+
+	var files []models.ForeignMigrationFile
+	for _, version := range order {
+		f := byVersion[version]
+		f.Down = undo[version]
+		files = append(files, *f)
+	}
+	return files, warnings, nil
+}
+
+// NormalizeVersion trims leading zeros from each dotted part of a version so
+// that file names ("0003") and tracking rows (3) compare equal.
+func NormalizeVersion(v string) string {
+	parts := strings.Split(strings.TrimSpace(v), ".")
+	for i, p := range parts {
+		p = strings.TrimLeft(p, "0")
+		if p == "" {
+			p = "0"
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, ".")
+}
+
+// CompareVersions compares two normalized versions part by part, numerically.
+// It returns -1, 0 or 1. Parts are compared as digit strings so versions wider
+// than an int64 still order correctly.
+func CompareVersions(a, b string) int {
+	ap, bp := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(ap) || i < len(bp); i++ {
+		x, y := "0", "0"
+		if i < len(ap) {
+			x = ap[i]
+		}
+		if i < len(bp) {
+			y = bp[i]
+		}
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sanitizeName turns a foreign migration description into a joka file name
+// fragment: lowercase, with runs of other characters collapsed to "_".
+func sanitizeName(name string) string {
+	name = nonNameChars.ReplaceAllString(strings.ToLower(name), "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "imported"
+	}
+	return name
+}
@@ -0,0 +1,153 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestListForeignMigrationFiles(t *testing.T) {
+	t.Run("it splits goose up and down sections", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"20240102000000_add_posts.sql":    "-- +goose Up\nCREATE TABLE posts (id INT);\n\n-- +goose Down\nDROP TABLE posts;\n",
+			"20240101000000_create_users.sql": "-- +goose Up\nCREATE TABLE users (id INT);\n",
+			"README.md":                       "not a migration",
+		})
+
+		files, _, err := ListForeignMigrationFiles(domain.ImportGoose, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("expected 2 files, got %d", len(files))
+		}
+		if files[0].Name != "create_users" || files[0].Down != "" {
+			t.Errorf("unexpected first file: %+v", files[0])
+		}
+		if files[1].Up != "CREATE TABLE posts (id INT);" || files[1].Down != "DROP TABLE posts;" {
+			t.Errorf("unexpected sections: up=%q down=%q", files[1].Up, files[1].Down)
+		}
+	})
+
+	t.Run("it warns about goose migrations that run outside a transaction", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"1_index.sql": "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY i ON t (c);\n",
+		})
+
+		files, _, err := ListForeignMigrationFiles(domain.ImportGoose, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(files[0].Warnings) != 1 {
+			t.Errorf("expected 1 warning, got %v", files[0].Warnings)
+		}
+	})
+
+	t.Run("it splits dbmate up and down sections", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"20240101000000_create_users.sql": "-- migrate:up\nCREATE TABLE users (id INT);\n\n-- migrate:down\nDROP TABLE users;\n",
+		})
+
+		files, _, err := ListForeignMigrationFiles(domain.ImportDbmate, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if files[0].Up != "CREATE TABLE users (id INT);" || files[0].Down != "DROP TABLE users;" {
+			t.Errorf("unexpected sections: up=%q down=%q", files[0].Up, files[0].Down)
+		}
+	})
+
+	t.Run("it rejects a dbmate file with no up section", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{"20240101000000_x.sql": "CREATE TABLE x (id INT);\n"})
+
+		if _, _, err := ListForeignMigrationFiles(domain.ImportDbmate, dir); err == nil {
+			t.Fatal("expected error for missing up section")
+		}
+	})
+
+	t.Run("it pairs golang-migrate up and down files in numeric order", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"10_b.up.sql":   "CREATE TABLE b (id INT);",
+			"10_b.down.sql": "DROP TABLE b;",
+			"9_a.up.sql":    "CREATE TABLE a (id INT);",
+		})
+
+		files, _, err := ListForeignMigrationFiles(domain.ImportGolangMigrate, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(files) != 2 || files[0].Version != "9" || files[1].Version != "10" {
+			t.Fatalf("unexpected order: %+v", files)
+		}
+		if files[1].Down != "DROP TABLE b;" || len(files[1].SourceFiles) != 2 {
+			t.Errorf("expected paired down file, got %+v", files[1])
+		}
+	})
+
+	t.Run("it reads flyway versioned and undo migrations and skips repeatables", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"V1__Create users.sql": "CREATE TABLE users (id INT);",
+			"V1_1__Add email.sql":  "ALTER TABLE users ADD email TEXT;",
+			"U1_1__Add email.sql":  "ALTER TABLE users DROP email;",
+			"R__refresh_view.sql":  "CREATE OR REPLACE VIEW v AS SELECT 1;",
+		})
+
+		files, warnings, err := ListForeignMigrationFiles(domain.ImportFlyway, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("expected 2 files, got %d", len(files))
+		}
+		if files[0].Name != "create_users" || files[1].Version != "1.1" || files[1].Down == "" {
+			t.Errorf("unexpected files: %+v", files)
+		}
+		if len(warnings) != 1 {
+			t.Errorf("expected a warning for the repeatable migration, got %v", warnings)
+		}
+	})
+
+	t.Run("it rejects duplicate versions", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"001_a.sql": "-- +goose Up\nSELECT 1;\n",
+			"1_b.sql":   "-- +goose Up\nSELECT 2;\n",
+		})
+
+		if _, _, err := ListForeignMigrationFiles(domain.ImportGoose, dir); err == nil {
+			t.Fatal("expected error for duplicate versions")
+		}
+	})
+}
+
+func TestCompareVersions(t *testing.T) {
+	t.Run("it compares dotted versions numerically", func(t *testing.T) {
+		cases := []struct {
+			a, b string
+			want int
+		}{
+			{"2", "10", -1},
+			{"1.1", "1", 1},
+			{"1.0", "1", 0},
+			{"1.10", "1.9", 1},
+			{"20240101000000", "20240101000000", 0},
+		}
+		for _, c := range cases {
+			if got := CompareVersions(c.a, c.b); got != c.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+			}
+		}
+	})
+}
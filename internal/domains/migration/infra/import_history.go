@@ -0,0 +1,90 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
+	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
+)
+
+// foreignTables maps each import source to the table its tool tracks applied
+// migrations in. golang-migrate and dbmate share a name but not a shape.
+var foreignTables = map[domain.ImportSource]string{
+	domain.ImportGoose:         "goose_db_version",
+	domain.ImportGolangMigrate: "schema_migrations",
+	domain.ImportFlyway:        "flyway_schema_history",
+	domain.ImportDbmate:        "schema_migrations",
+}
+
+// readForeignHistory reads the tracking table of another migration tool and
+// returns its rows in the order the tool wrote them. The queries are plain
+// SELECTs with no parameters, so they are shared by both drivers.
+func readForeignHistory(ctx context.Context, db DBTX, conn *sql.DB, driver jokadb.Driver, source domain.ImportSource) ([]models.ForeignHistoryRow, error) {
+	table, ok := foreignTables[source]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnknownImportSource, source)
+	}
+
+	exists, err := jokadb.TableExists(ctx, conn, driver, table)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrForeignTableMissing, table)
+	}
+
+	var query string
+	switch source {
+	case domain.ImportGoose:
+		query = `SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY id`
+	case domain.ImportGolangMigrate:
+		query = `SELECT version, dirty FROM schema_migrations`
+	case domain.ImportFlyway:
+		query = `SELECT version, type, success, installed_on FROM flyway_schema_history ORDER BY installed_rank`
+	case domain.ImportDbmate:
+		query = `SELECT version FROM schema_migrations ORDER BY version`
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var history []models.ForeignHistoryRow
+	for rows.Next() {
+		var r models.ForeignHistoryRow
+		var appliedAt sql.NullTime
+		switch source {
+		case domain.ImportGoose:
+			err = rows.Scan(&r.Version, &r.Applied, &appliedAt)
+		case domain.ImportGolangMigrate:
+			err = rows.Scan(&r.Version, &r.Dirty)
+			r.Applied = true
+		case domain.ImportFlyway:
+			// Repeatable migrations have a NULL version; they are skipped.
+			var version, kind sql.NullString
+			err = rows.Scan(&version, &kind, &r.Applied, &appliedAt)
+			if err == nil && !version.Valid {
+				continue
+			}
+			r.Version = version.String
+			r.Baseline = kind.String == "BASELINE"
+		case domain.ImportDbmate:
+			err = rows.Scan(&r.Version)
+			r.Applied = true
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", table, err)
+		}
+		r.Version = NormalizeVersion(r.Version)
+		if appliedAt.Valid {
+			r.AppliedAt = appliedAt.Time
+		}
+		history = append(history, r)
+	}
+	return history, rows.Err()
+}
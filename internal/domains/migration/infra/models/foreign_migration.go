@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ForeignMigrationFile is a migration discovered in another tool's migrations
+// directory, with its up and down SQL already separated.
+type ForeignMigrationFile struct {
+	Version     string   // normalized version (leading zeros trimmed per dotted part)
+	RawVersion  string   // version exactly as it appears in the filename
+	Name        string   // descriptive name, snake_cased
+	SourceFiles []string // file(s) the migration was read from
+	Up          string   // SQL applied going forward
+	Down        string   // SQL that reverts it; empty if the tool file had none
+	Warnings    []string // tool features joka can't honour (e.g. no-transaction)
+}
+
+// ForeignHistoryRow is a single row read from another tool's tracking table.
+// Fields a tool doesn't record are left at their zero value.
+type ForeignHistoryRow struct {
+	Version   string    // normalized version
+	Applied   bool      // goose is_applied / flyway success; true for tools that only record applies
+	AppliedAt time.Time // zero when the tool doesn't record it
+	Dirty     bool      // golang-migrate: the last migration failed part-way
+	Baseline  bool      // flyway BASELINE marker
+}
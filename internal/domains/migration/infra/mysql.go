@@ -3,10 +3,9 @@ package infra
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
 	jokadb "github.com/apsdsm/joka/db"
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
@@ -73,14 +72,14 @@ func (m *MySQLDBAdapter) GetAppliedMigrations(ctx context.Context) ([]models.Mig
 	return migrations, rows.Err()
 }
 
-// ApplySQLFromFile reads and executes the SQL statements from the specified file.
-func (m *MySQLDBAdapter) ApplySQLFromFile(ctx context.Context, filePath string) error {
-	content, err := os.ReadFile(filePath)
+// ApplySQLFromFile reads the specified file, evaluates its conditional blocks
+// for this driver and the given profile, and executes the resulting statements.
+func (m *MySQLDBAdapter) ApplySQLFromFile(ctx context.Context, filePath string, profile string) error {
+	sqlContent, err := ReadMigrationSQL(filePath, Conditions{Driver: m.driver, Profile: profile})
 	if err != nil {
-		return fmt.Errorf("reading migration file: %w", err)
+		return err
 	}
 
-	sqlContent := string(content)
 	if strings.TrimSpace(sqlContent) == "" {
 		return nil
 	}
@@ -93,12 +92,38 @@ func (m *MySQLDBAdapter) ApplySQLFromFile(ctx context.Context, filePath string)
 	return nil
 }
 
+// SetSessionTimeouts applies the given timeouts to the adapter's session.
+func (m *MySQLDBAdapter) SetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error {
+	return jokadb.ApplyTimeouts(ctx, m.db, m.driver, t)
+}
+
+// ResetSessionTimeouts restores the timeouts set in t to the server default.
+func (m *MySQLDBAdapter) ResetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error {
+	return jokadb.ResetTimeouts(ctx, m.db, m.driver, t)
+}
+
 // RecordMigrationApplied records a migration as applied in the migrations table.
 func (m *MySQLDBAdapter) RecordMigrationApplied(ctx context.Context, migrationIndex string) error {
 	_, err := m.db.ExecContext(ctx, `INSERT INTO joka_migrations (migration_index) VALUES (?)`, migrationIndex)
 	return err
 }
 
+// RecordMigrationAppliedAt records a migration as applied at the given time.
+// Used when importing history from another tool; a zero time falls back to
+// the column default.
+func (m *MySQLDBAdapter) RecordMigrationAppliedAt(ctx context.Context, migrationIndex string, appliedAt time.Time) error {
+	if appliedAt.IsZero() {
+		return m.RecordMigrationApplied(ctx, migrationIndex)
+	}
+	_, err := m.db.ExecContext(ctx, `INSERT INTO joka_migrations (migration_index, applied_at) VALUES (?, ?)`, migrationIndex, appliedAt.UTC())
+	return err
+}
+
+// GetForeignHistory reads the tracking table of another migration tool.
+func (m *MySQLDBAdapter) GetForeignHistory(ctx context.Context, source domain.ImportSource) ([]models.ForeignHistoryRow, error) {
+	return readForeignHistory(ctx, m.db, m.conn, m.driver, source)
+}
+
 // HasMigrationsTable checks if the migrations table exists in the database.
 func (m *MySQLDBAdapter) HasMigrationsTable(ctx context.Context) (bool, error) {
 	return jokadb.TableExists(ctx, m.conn, m.driver, "joka_migrations")
@@ -127,33 +152,68 @@ func (m *MySQLDBAdapter) CreateMigrationsTable(ctx context.Context) error {
 	return nil
 }
 
-// EnsureSnapshotsTable creates the joka_snapshots table if it doesn't already
-// exist. Called automatically before any snapshot read/write so callers don't
-// need to run a separate init step.
+// DropMigrationsTable drops the migrations table if it exists.
+func (m *MySQLDBAdapter) DropMigrationsTable(ctx context.Context) error {
+	_, err := m.conn.ExecContext(ctx, "DROP TABLE IF EXISTS joka_migrations")
+	return err
+}
+
+// EnsureSnapshotsTable creates the joka_snapshots and joka_snapshot_tables
+// tables if they don't already exist, and adds the storage column to a
+// joka_snapshots table created before snapshots were deduplicated.
 func (m *MySQLDBAdapter) EnsureSnapshotsTable(ctx context.Context) error {
 	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, "joka_snapshots")
 	if err != nil {
 		return err
 	}
-	if exists {
-		return nil
+	if !exists {
+		_, err = m.conn.ExecContext(ctx, `
+			CREATE TABLE joka_snapshots (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				migration_index VARCHAR(255) NOT NULL UNIQUE,
+				schema_snapshot LONGTEXT NOT NULL,
+				storage VARCHAR(16) NOT NULL DEFAULT 'json',
+				captured_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)
+		`)
+		if err != nil {
+			return err
+		}
+	} else {
+		hasStorage, err := jokadb.ColumnExists(ctx, m.conn, m.driver, "joka_snapshots", "storage")
+		if err != nil {
+			return err
+		}
+		if !hasStorage {
+			if _, err := m.conn.ExecContext(ctx, `ALTER TABLE joka_snapshots ADD COLUMN storage VARCHAR(16) NOT NULL DEFAULT 'json'`); err != nil {
+				return err
+			}
+		}
 	}
 
+	exists, err = jokadb.TableExists(ctx, m.conn, m.driver, "joka_snapshot_tables")
+	if err != nil || exists {
+		return err
+	}
 	_, err = m.conn.ExecContext(ctx, `
-		CREATE TABLE joka_snapshots (
-			id INT AUTO_INCREMENT PRIMARY KEY,
-			migration_index VARCHAR(255) NOT NULL UNIQUE,
-			schema_snapshot LONGTEXT NOT NULL,
-			captured_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		CREATE TABLE joka_snapshot_tables (
+			hash CHAR(64) NOT NULL PRIMARY KEY,
+			encoding VARCHAR(16) NOT NULL,
+			ddl LONGTEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
 	return err
 }
 
-// ComputeSchema queries SHOW CREATE TABLE for all user tables (excluding
-// joka_* tables) and returns the map of table -> CREATE TABLE statement.
-func (m *MySQLDBAdapter) ComputeSchema(ctx context.Context) (map[string]string, error) {
-	rows, err := m.conn.QueryContext(ctx, `
+// showCreateBatch is how many SHOW CREATE TABLE statements ComputeTables
+// sends to the server in one round trip. Connections opened by jokadb.Open
+// always have multiStatements enabled.
+const showCreateBatch = 100
+
+// ListTables returns the names of all user tables (excluding joka_* tables).
+func (m *MySQLDBAdapter) ListTables(ctx context.Context) ([]string, error) {
+	names, err := queryStrings(ctx, m.conn, `
 		SELECT table_name
 		FROM information_schema.tables
 		WHERE table_schema = DATABASE()
@@ -163,72 +223,222 @@ func (m *MySQLDBAdapter) ComputeSchema(ctx context.Context) (map[string]string,
 	if err != nil {
 		return nil, fmt.Errorf("listing tables: %w", err)
 	}
-	defer rows.Close()
+	return names, nil
+}
 
-	schema := make(map[string]string)
-	var tableNames []string
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
+// ComputeSchema queries SHOW CREATE TABLE for all user tables (excluding
+// joka_* tables) and returns the map of table -> CREATE TABLE statement.
+func (m *MySQLDBAdapter) ComputeSchema(ctx context.Context) (map[string]string, error) {
+	names, err := m.ListTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.ComputeTables(ctx, names)
+}
+
+// ComputeTables returns the SHOW CREATE TABLE output for the named tables.
+// MySQL has no catalog query that reproduces it, so the statements are sent
+// showCreateBatch at a time as one multi-statement query and read back one
+// result set per table, instead of one round trip per table.
+func (m *MySQLDBAdapter) ComputeTables(ctx context.Context, tables []string) (map[string]string, error) {
+	schema := make(map[string]string, len(tables))
+	for start := 0; start < len(tables); start += showCreateBatch {
+		batch := tables[start:min(start+showCreateBatch, len(tables))]
+		if err := m.showCreateTables(ctx, batch, schema); err != nil {
 			return nil, err
 		}
-		tableNames = append(tableNames, name)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return schema, nil
+}
+
+// showCreateTables runs SHOW CREATE TABLE for every table in batch in a
+// single query and stores the results in schema.
+func (m *MySQLDBAdapter) showCreateTables(ctx context.Context, batch []string, schema map[string]string) error {
+	stmts := make([]string, len(batch))
+	for i, name := range batch {
+		stmts[i] = "SHOW CREATE TABLE `" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+
+	rows, err := m.conn.QueryContext(ctx, strings.Join(stmts, ";\n"))
+	if err != nil {
+		return fmt.Errorf("getting schema for table %s: %w", batch[0], err)
 	}
+	defer rows.Close()
 
-	for _, name := range tableNames {
-		var tbl, createStmt string
-		err := m.conn.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE TABLE `%s`", name)).Scan(&tbl, &createStmt)
+	for i := 0; i < len(batch); i++ {
+		if i > 0 && !rows.NextResultSet() {
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("getting schema for table %s: %w", batch[i], err)
+			}
+			return fmt.Errorf("getting schema for table %s: no result returned", batch[i])
+		}
+		// Tables return (Table, Create Table); views return four columns
+		// with the CREATE VIEW statement second.
+		cols, err := rows.Columns()
 		if err != nil {
-			return nil, fmt.Errorf("getting schema for table %s: %w", name, err)
+			return err
+		}
+		vals := make([]sql.NullString, len(cols))
+		dest := make([]any, len(cols))
+		for j := range vals {
+			dest[j] = &vals[j]
+		}
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				return fmt.Errorf("getting schema for table %s: %w", batch[i], err)
+			}
+			schema[batch[i]] = vals[1].String
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("getting schema for table %s: %w", batch[i], err)
 		}
-		schema[name] = createStmt
 	}
+	return nil
+}
 
-	return schema, nil
+// CaptureSchemaSnapshot computes the full schema and stores it as the
+// snapshot for the given migration index (see StoreSchemaSnapshot).
+func (m *MySQLDBAdapter) CaptureSchemaSnapshot(ctx context.Context, migrationIndex string, opts domain.SnapshotOptions) error {
+	schema, err := m.ComputeSchema(ctx)
+	if err != nil {
+		return err
+	}
+	return m.StoreSchemaSnapshot(ctx, migrationIndex, schema, opts)
 }
 
-// CaptureSchemaSnapshot captures the current database schema and stores it
-// associated with the given migration index.
-func (m *MySQLDBAdapter) CaptureSchemaSnapshot(ctx context.Context, migrationIndex string) error {
+// StoreSchemaSnapshot stores an already computed schema as the snapshot for
+// the given migration index. Each table's DDL is stored once in
+// joka_snapshot_tables; the joka_snapshots row holds a manifest of hashes.
+func (m *MySQLDBAdapter) StoreSchemaSnapshot(ctx context.Context, migrationIndex string, schema map[string]string, opts domain.SnapshotOptions) error {
 	if err := m.EnsureSnapshotsTable(ctx); err != nil {
 		return fmt.Errorf("ensuring snapshots table: %w", err)
 	}
 
-	schema, err := m.ComputeSchema(ctx)
+	manifest, blobs, err := buildSnapshotManifest(schema, opts.Compress)
 	if err != nil {
 		return err
 	}
 
-	jsonBytes, err := json.Marshal(schema)
-	if err != nil {
-		return fmt.Errorf("marshaling schema: %w", err)
+	for _, b := range blobs {
+		_, err := m.conn.ExecContext(ctx,
+			`INSERT IGNORE INTO joka_snapshot_tables (hash, encoding, ddl) VALUES (?, ?, ?)`,
+			b.Hash, b.Encoding, b.Payload,
+		)
+		if err != nil {
+			return fmt.Errorf("storing snapshot table: %w", err)
+		}
 	}
 
 	_, err = m.conn.ExecContext(ctx,
-		`INSERT INTO joka_snapshots (migration_index, schema_snapshot) VALUES (?, ?)`,
-		migrationIndex, string(jsonBytes),
+		`INSERT INTO joka_snapshots (migration_index, schema_snapshot, storage) VALUES (?, ?, ?)`,
+		migrationIndex, manifest, snapshotStorageManifest,
 	)
 	return err
 }
 
-// GetSchemaSnapshot retrieves the stored schema snapshot for a given migration index.
+// GetSchemaSnapshot retrieves the stored schema snapshot for a given migration
+// index as {table: DDL} JSON, whichever format the row was stored in.
 func (m *MySQLDBAdapter) GetSchemaSnapshot(ctx context.Context, migrationIndex string) (string, error) {
 	if err := m.EnsureSnapshotsTable(ctx); err != nil {
 		return "", fmt.Errorf("ensuring snapshots table: %w", err)
 	}
 
-	var snapshot string
+	var snapshot, storage string
 	err := m.conn.QueryRowContext(ctx,
-		`SELECT schema_snapshot FROM joka_snapshots WHERE migration_index = ?`,
+		`SELECT schema_snapshot, storage FROM joka_snapshots WHERE migration_index = ?`,
 		migrationIndex,
-	).Scan(&snapshot)
+	).Scan(&snapshot, &storage)
 	if err == sql.ErrNoRows {
-		return "", fmt.Errorf("no snapshot found for migration %s", migrationIndex)
+		return "", fmt.Errorf("%w for migration %s", domain.ErrNoSnapshot, migrationIndex)
+	}
+	if err != nil || storage != snapshotStorageManifest {
+		return snapshot, err
+	}
+
+	manifest, hashes, err := parseSnapshotManifest(snapshot)
+	if err != nil {
+		return "", err
+	}
+	blobs, err := m.loadSnapshotBlobs(ctx, hashes)
+	if err != nil {
+		return "", err
+	}
+	return assembleSnapshot(manifest, blobs)
+}
+
+// loadSnapshotBlobs reads the joka_snapshot_tables rows for the given hashes.
+func (m *MySQLDBAdapter) loadSnapshotBlobs(ctx context.Context, hashes []string) (map[string]snapshotBlob, error) {
+	blobs := make(map[string]snapshotBlob, len(hashes))
+	if len(hashes) == 0 {
+		return blobs, nil
+	}
+
+	args := make([]any, len(hashes))
+	for i, h := range hashes {
+		args[i] = h
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(hashes)), ",")
+	rows, err := m.conn.QueryContext(ctx,
+		`SELECT hash, encoding, ddl FROM joka_snapshot_tables WHERE hash IN (`+placeholders+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading snapshot tables: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b snapshotBlob
+		if err := rows.Scan(&b.Hash, &b.Encoding, &b.Payload); err != nil {
+			return nil, err
+		}
+		blobs[b.Hash] = b
+	}
+	return blobs, rows.Err()
+}
+
+// PruneSnapshots deletes all but the newest keep snapshots, then deletes the
+// joka_snapshot_tables rows no remaining snapshot refers to. Returns the
+// number of snapshots and table rows deleted.
+func (m *MySQLDBAdapter) PruneSnapshots(ctx context.Context, keep int) (int, int, error) {
+	if err := m.EnsureSnapshotsTable(ctx); err != nil {
+		return 0, 0, fmt.Errorf("ensuring snapshots table: %w", err)
+	}
+
+	// MySQL can't LIMIT inside an IN subquery, but can inside a derived table.
+	res, err := m.conn.ExecContext(ctx, `
+		DELETE FROM joka_snapshots
+		WHERE id NOT IN (
+			SELECT id FROM (SELECT id FROM joka_snapshots ORDER BY id DESC LIMIT ?) AS kept
+		)
+	`, keep)
+	if err != nil {
+		return 0, 0, fmt.Errorf("pruning snapshots: %w", err)
+	}
+	pruned, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
 	}
-	return snapshot, err
+
+	manifests, err := queryStrings(ctx, m.conn, `SELECT schema_snapshot FROM joka_snapshots WHERE storage = 'manifest'`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading snapshot manifests: %w", err)
+	}
+	stored, err := queryStrings(ctx, m.conn, `SELECT hash FROM joka_snapshot_tables`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading snapshot tables: %w", err)
+	}
+	orphans, err := unreferencedHashes(manifests, stored)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, h := range orphans {
+		if _, err := m.conn.ExecContext(ctx, `DELETE FROM joka_snapshot_tables WHERE hash = ?`, h); err != nil {
+			return 0, 0, fmt.Errorf("pruning snapshot tables: %w", err)
+		}
+	}
+
+	return int(pruned), len(orphans), nil
 }
 
 // GetLatestSnapshotIndex returns the migration index of the most recent snapshot.
@@ -242,7 +452,16 @@ func (m *MySQLDBAdapter) GetLatestSnapshotIndex(ctx context.Context) (string, er
 		`SELECT migration_index FROM joka_snapshots ORDER BY id DESC LIMIT 1`,
 	).Scan(&index)
 	if err == sql.ErrNoRows {
-		return "", fmt.Errorf("no snapshots found")
+		return "", domain.ErrNoSnapshot
 	}
 	return index, err
 }
+
+// ListSnapshotIndexes returns the migration index of every stored snapshot,
+// oldest first.
+func (m *MySQLDBAdapter) ListSnapshotIndexes(ctx context.Context) ([]string, error) {
+	if err := m.EnsureSnapshotsTable(ctx); err != nil {
+		return nil, fmt.Errorf("ensuring snapshots table: %w", err)
+	}
+	return queryStrings(ctx, m.conn, `SELECT migration_index FROM joka_snapshots ORDER BY id`)
+}
@@ -3,8 +3,10 @@ package infra_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
@@ -58,6 +60,19 @@ func TestCreateMigrationsTable(t *testing.T) {
 			t.Fatalf("expected ErrMigrationAlreadyExists, got: %v", err)
 		}
 	})
+
+	t.Run("it drops the table", func(t *testing.T) {
+		if err := adapter.DropMigrationsTable(ctx); err != nil {
+			t.Fatalf("DropMigrationsTable: %v", err)
+		}
+		exists, err := adapter.HasMigrationsTable(ctx)
+		if err != nil {
+			t.Fatalf("HasMigrationsTable after drop: %v", err)
+		}
+		if exists {
+			t.Fatal("expected migrations table to be gone after drop")
+		}
+	})
 }
 
 func TestRecordAndGetAppliedMigrations(t *testing.T) {
@@ -127,7 +142,7 @@ func TestApplySQLFromFile(t *testing.T) {
 			t.Fatalf("writing sql file: %v", err)
 		}
 
-		if err := adapter.ApplySQLFromFile(ctx, sqlFile); err != nil {
+		if err := adapter.ApplySQLFromFile(ctx, sqlFile, ""); err != nil {
 			t.Fatalf("ApplySQLFromFile: %v", err)
 		}
 
@@ -152,7 +167,7 @@ func TestApplySQLFromFile(t *testing.T) {
 			t.Fatalf("writing sql file: %v", err)
 		}
 
-		if err := adapter.ApplySQLFromFile(ctx, sqlFile); err != nil {
+		if err := adapter.ApplySQLFromFile(ctx, sqlFile, ""); err != nil {
 			t.Fatalf("ApplySQLFromFile multi-statement: %v", err)
 		}
 
@@ -175,7 +190,10 @@ func TestEnsureSnapshotsTable(t *testing.T) {
 		t.Fatalf("getting test db: %v", err)
 	}
 
-	t.Cleanup(func() { testlib.DropTable(t, db, "joka_snapshots") })
+	t.Cleanup(func() {
+		testlib.DropTable(t, db, "joka_snapshots")
+		testlib.DropTable(t, db, "joka_snapshot_tables")
+	})
 
 	t.Run("it creates the table and is idempotent", func(t *testing.T) {
 		adapter := infra.NewMySQLDBAdapter(db)
@@ -204,6 +222,7 @@ func TestCaptureAndGetSchemaSnapshot(t *testing.T) {
 	userTable := "test_snapshot_users"
 	t.Cleanup(func() {
 		testlib.DropTable(t, db, "joka_snapshots")
+		testlib.DropTable(t, db, "joka_snapshot_tables")
 		testlib.DropTable(t, db, userTable)
 	})
 
@@ -217,7 +236,7 @@ func TestCaptureAndGetSchemaSnapshot(t *testing.T) {
 
 		adapter := infra.NewMySQLDBAdapter(db)
 
-		if err := adapter.CaptureSchemaSnapshot(ctx, "240101120000"); err != nil {
+		if err := adapter.CaptureSchemaSnapshot(ctx, "240101120000", domain.SnapshotOptions{}); err != nil {
 			t.Fatalf("CaptureSchemaSnapshot: %v", err)
 		}
 
@@ -251,7 +270,10 @@ func TestGetLatestSnapshotIndex(t *testing.T) {
 		t.Fatalf("getting test db: %v", err)
 	}
 
-	t.Cleanup(func() { testlib.DropTable(t, db, "joka_snapshots") })
+	t.Cleanup(func() {
+		testlib.DropTable(t, db, "joka_snapshots")
+		testlib.DropTable(t, db, "joka_snapshot_tables")
+	})
 
 	t.Run("it returns the most recent snapshot index", func(t *testing.T) {
 		adapter := infra.NewMySQLDBAdapter(db)
@@ -278,6 +300,104 @@ func TestGetLatestSnapshotIndex(t *testing.T) {
 			t.Errorf("expected latest snapshot 240202120000, got %s", latest)
 		}
 	})
+
+	t.Run("it lists every snapshot index oldest first", func(t *testing.T) {
+		indexes, err := infra.NewMySQLDBAdapter(db).ListSnapshotIndexes(context.Background())
+		if err != nil {
+			t.Fatalf("ListSnapshotIndexes: %v", err)
+		}
+		if strings.Join(indexes, ",") != "240101120000,240202120000" {
+			t.Errorf("expected both snapshots in order, got %v", indexes)
+		}
+	})
+}
+
+func TestMySQLSnapshotStorage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+	ctx := context.Background()
+
+	cleanup := func() {
+		testlib.DropTable(t, db, "joka_snapshots")
+		testlib.DropTable(t, db, "joka_snapshot_tables")
+		testlib.DropTable(t, db, "test_store_mysql_users")
+	}
+	t.Cleanup(cleanup)
+
+	t.Run("it reads rows written before snapshots were deduplicated", func(t *testing.T) {
+		cleanup()
+		_, err := db.ExecContext(ctx, `CREATE TABLE joka_snapshots (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			migration_index VARCHAR(255) NOT NULL UNIQUE,
+			schema_snapshot LONGTEXT NOT NULL
+		)`)
+		if err != nil {
+			t.Fatalf("creating legacy snapshots table: %v", err)
+		}
+		legacy := `{"users":"CREATE TABLE users (id INT)"}`
+		if _, err := db.ExecContext(ctx, "INSERT INTO joka_snapshots (migration_index, schema_snapshot) VALUES (?, ?)", "240101120000", legacy); err != nil {
+			t.Fatalf("inserting legacy snapshot: %v", err)
+		}
+
+		snapshot, err := infra.NewMySQLDBAdapter(db).GetSchemaSnapshot(ctx, "240101120000")
+		if err != nil {
+			t.Fatalf("GetSchemaSnapshot: %v", err)
+		}
+		if snapshot != legacy {
+			t.Errorf("expected legacy snapshot unchanged, got %s", snapshot)
+		}
+	})
+
+	t.Run("it round-trips compressed snapshots and prunes unreferenced tables", func(t *testing.T) {
+		cleanup()
+		adapter := infra.NewMySQLDBAdapter(db)
+		opts := domain.SnapshotOptions{Compress: true}
+
+		if _, err := db.ExecContext(ctx, "CREATE TABLE test_store_mysql_users (id INT PRIMARY KEY)"); err != nil {
+			t.Fatalf("creating user table: %v", err)
+		}
+		if err := adapter.CaptureSchemaSnapshot(ctx, "240101120000", opts); err != nil {
+			t.Fatalf("CaptureSchemaSnapshot: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE test_store_mysql_users ADD COLUMN email VARCHAR(255)"); err != nil {
+			t.Fatalf("altering user table: %v", err)
+		}
+		if err := adapter.CaptureSchemaSnapshot(ctx, "240102120000", opts); err != nil {
+			t.Fatalf("CaptureSchemaSnapshot: %v", err)
+		}
+
+		snapshot, err := adapter.GetSchemaSnapshot(ctx, "240102120000")
+		if err != nil {
+			t.Fatalf("GetSchemaSnapshot: %v", err)
+		}
+		var schema map[string]string
+		if err := json.Unmarshal([]byte(snapshot), &schema); err != nil {
+			t.Fatalf("unmarshaling snapshot: %v", err)
+		}
+		if !strings.Contains(schema["test_store_mysql_users"], "email") {
+			t.Errorf("expected the altered table in the latest snapshot, got %q", schema["test_store_mysql_users"])
+		}
+
+		snapshots, tables, err := adapter.PruneSnapshots(ctx, 1)
+		if err != nil {
+			t.Fatalf("PruneSnapshots: %v", err)
+		}
+		if snapshots != 1 || tables != 1 {
+			t.Errorf("expected 1 snapshot and 1 table pruned, got %d and %d", snapshots, tables)
+		}
+		if _, err := adapter.GetSchemaSnapshot(ctx, "240101120000"); !errors.Is(err, domain.ErrNoSnapshot) {
+			t.Errorf("expected pruned snapshot to be gone, got %v", err)
+		}
+		if _, err := adapter.GetSchemaSnapshot(ctx, "240102120000"); err != nil {
+			t.Errorf("expected kept snapshot to stay readable, got %v", err)
+		}
+	})
 }
 
 func TestMySQLComputeSchema(t *testing.T) {
@@ -312,6 +432,46 @@ func TestMySQLComputeSchema(t *testing.T) {
 			t.Errorf("expected joka_snapshots to be filtered out, got %v", keys(schema))
 		}
 	})
+
+	t.Run("it computes named tables in one batch, matching the full schema", func(t *testing.T) {
+		for _, stmt := range []string{
+			"CREATE TABLE test_bulk_users (id INT PRIMARY KEY)",
+			"CREATE TABLE test_bulk_orders (id INT PRIMARY KEY, user_id INT, FOREIGN KEY (user_id) REFERENCES test_bulk_users (id))",
+			"CREATE VIEW test_bulk_view AS SELECT id FROM test_bulk_users",
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+		t.Cleanup(func() {
+			db.ExecContext(ctx, "DROP VIEW IF EXISTS test_bulk_view")
+			testlib.DropTable(t, db, "test_bulk_orders")
+			testlib.DropTable(t, db, "test_bulk_users")
+		})
+
+		adapter := infra.NewMySQLDBAdapter(db)
+		names := []string{"test_bulk_orders", "test_bulk_users", "test_bulk_view"}
+		subset, err := adapter.ComputeTables(ctx, names)
+		if err != nil {
+			t.Fatalf("ComputeTables: %v", err)
+		}
+		full, err := adapter.ComputeSchema(ctx)
+		if err != nil {
+			t.Fatalf("ComputeSchema: %v", err)
+		}
+
+		if len(subset) != len(names) {
+			t.Fatalf("expected %d tables, got %v", len(names), keys(subset))
+		}
+		for _, name := range names {
+			if subset[name] == "" || subset[name] != full[name] {
+				t.Errorf("%s: ComputeTables %q, ComputeSchema %q", name, subset[name], full[name])
+			}
+		}
+		if !strings.Contains(subset["test_bulk_view"], "VIEW") {
+			t.Errorf("expected a CREATE VIEW statement, got %q", subset["test_bulk_view"])
+		}
+	})
 }
 
 func keys(m map[string]string) []string {
@@ -3,14 +3,14 @@ package infra
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
 	jokadb "github.com/apsdsm/joka/db"
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
 	"github.com/apsdsm/joka/internal/domains/migration/infra/models"
+	"github.com/lib/pq"
 )
 
 // PostgresDBAdapter implements the app.DBAdapter interface for PostgreSQL databases.
@@ -57,14 +57,14 @@ func (p *PostgresDBAdapter) GetAppliedMigrations(ctx context.Context) ([]models.
 	return migrations, rows.Err()
 }
 
-// ApplySQLFromFile reads and executes the SQL statements from the specified file.
-func (p *PostgresDBAdapter) ApplySQLFromFile(ctx context.Context, filePath string) error {
-	content, err := os.ReadFile(filePath)
+// ApplySQLFromFile reads the specified file, evaluates its conditional blocks
+// for this driver and the given profile, and executes the resulting statements.
+func (p *PostgresDBAdapter) ApplySQLFromFile(ctx context.Context, filePath string, profile string) error {
+	sqlContent, err := ReadMigrationSQL(filePath, Conditions{Driver: p.driver, Profile: profile})
 	if err != nil {
-		return fmt.Errorf("reading migration file: %w", err)
+		return err
 	}
 
-	sqlContent := string(content)
 	if strings.TrimSpace(sqlContent) == "" {
 		return nil
 	}
@@ -77,12 +77,38 @@ func (p *PostgresDBAdapter) ApplySQLFromFile(ctx context.Context, filePath strin
 	return nil
 }
 
+// SetSessionTimeouts applies the given timeouts to the adapter's session.
+func (p *PostgresDBAdapter) SetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error {
+	return jokadb.ApplyTimeouts(ctx, p.db, p.driver, t)
+}
+
+// ResetSessionTimeouts restores the timeouts set in t to the server default.
+func (p *PostgresDBAdapter) ResetSessionTimeouts(ctx context.Context, t jokadb.Timeouts) error {
+	return jokadb.ResetTimeouts(ctx, p.db, p.driver, t)
+}
+
 // RecordMigrationApplied records a migration as applied in the migrations table.
 func (p *PostgresDBAdapter) RecordMigrationApplied(ctx context.Context, migrationIndex string) error {
 	_, err := p.db.ExecContext(ctx, `INSERT INTO joka_migrations (migration_index) VALUES ($1)`, migrationIndex)
 	return err
 }
 
+// RecordMigrationAppliedAt records a migration as applied at the given time.
+// Used when importing history from another tool; a zero time falls back to
+// the column default.
+func (p *PostgresDBAdapter) RecordMigrationAppliedAt(ctx context.Context, migrationIndex string, appliedAt time.Time) error {
+	if appliedAt.IsZero() {
+		return p.RecordMigrationApplied(ctx, migrationIndex)
+	}
+	_, err := p.db.ExecContext(ctx, `INSERT INTO joka_migrations (migration_index, applied_at) VALUES ($1, $2)`, migrationIndex, appliedAt.UTC())
+	return err
+}
+
+// GetForeignHistory reads the tracking table of another migration tool.
+func (p *PostgresDBAdapter) GetForeignHistory(ctx context.Context, source domain.ImportSource) ([]models.ForeignHistoryRow, error) {
+	return readForeignHistory(ctx, p.db, p.conn, p.driver, source)
+}
+
 // HasMigrationsTable checks if the migrations table exists in the database.
 func (p *PostgresDBAdapter) HasMigrationsTable(ctx context.Context) (bool, error) {
 	return jokadb.TableExists(ctx, p.conn, p.driver, "joka_migrations")
@@ -111,27 +137,76 @@ func (p *PostgresDBAdapter) CreateMigrationsTable(ctx context.Context) error {
 	return nil
 }
 
-// EnsureSnapshotsTable creates the joka_snapshots table if it doesn't already exist.
+// DropMigrationsTable drops the migrations table if it exists.
+func (p *PostgresDBAdapter) DropMigrationsTable(ctx context.Context) error {
+	_, err := p.conn.ExecContext(ctx, "DROP TABLE IF EXISTS joka_migrations")
+	return err
+}
+
+// EnsureSnapshotsTable creates the joka_snapshots and joka_snapshot_tables
+// tables if they don't already exist, and adds the storage column to a
+// joka_snapshots table created before snapshots were deduplicated.
 func (p *PostgresDBAdapter) EnsureSnapshotsTable(ctx context.Context) error {
 	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, "joka_snapshots")
 	if err != nil {
 		return err
 	}
-	if exists {
-		return nil
+	if !exists {
+		_, err = p.conn.ExecContext(ctx, `
+			CREATE TABLE joka_snapshots (
+				id SERIAL PRIMARY KEY,
+				migration_index VARCHAR(255) NOT NULL UNIQUE,
+				schema_snapshot TEXT NOT NULL,
+				storage VARCHAR(16) NOT NULL DEFAULT 'json',
+				captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)
+		`)
+		if err != nil {
+			return err
+		}
+	} else {
+		hasStorage, err := jokadb.ColumnExists(ctx, p.conn, p.driver, "joka_snapshots", "storage")
+		if err != nil {
+			return err
+		}
+		if !hasStorage {
+			if _, err := p.conn.ExecContext(ctx, `ALTER TABLE joka_snapshots ADD COLUMN storage VARCHAR(16) NOT NULL DEFAULT 'json'`); err != nil {
+				return err
+			}
+		}
 	}
 
+	exists, err = jokadb.TableExists(ctx, p.conn, p.driver, "joka_snapshot_tables")
+	if err != nil || exists {
+		return err
+	}
 	_, err = p.conn.ExecContext(ctx, `
-		CREATE TABLE joka_snapshots (
-			id SERIAL PRIMARY KEY,
-			migration_index VARCHAR(255) NOT NULL UNIQUE,
-			schema_snapshot TEXT NOT NULL,
-			captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		CREATE TABLE joka_snapshot_tables (
+			hash CHAR(64) NOT NULL PRIMARY KEY,
+			encoding VARCHAR(16) NOT NULL,
+			ddl TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)
 	`)
 	return err
 }
 
+// ListTables returns the names of all user tables in the current schema
+// (excluding joka_* tables). Reads through p.db; see ComputeSchema.
+func (p *PostgresDBAdapter) ListTables(ctx context.Context) ([]string, error) {
+	names, err := queryStrings(ctx, p.db, `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = current_schema()
+		AND table_name NOT LIKE 'joka\_%' ESCAPE '\'
+		ORDER BY table_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+	return names, nil
+}
+
 // ComputeSchema returns the current database schema as a map of table name to
 // a reconstructed CREATE TABLE-like statement, for all non-joka user tables.
 //
@@ -143,91 +218,44 @@ func (p *PostgresDBAdapter) EnsureSnapshotsTable(ctx context.Context) error {
 // deadlock. Reading on the same tx connection avoids it (and correctly sees the
 // uncommitted in-tx schema).
 func (p *PostgresDBAdapter) ComputeSchema(ctx context.Context) (map[string]string, error) {
-	rows, err := p.db.QueryContext(ctx, `
-		SELECT table_name
-		FROM information_schema.tables
-		WHERE table_schema = current_schema()
-		AND table_name NOT LIKE 'joka\_%' ESCAPE '\'
-		ORDER BY table_name
-	`)
+	names, err := p.ListTables(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("listing tables: %w", err)
-	}
-	defer rows.Close()
-
-	var tableNames []string
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, err
-		}
-		tableNames = append(tableNames, name)
-	}
-	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-
-	schema := make(map[string]string)
-	for _, name := range tableNames {
-		stmt, err := p.reconstructCreateTable(ctx, name)
-		if err != nil {
-			return nil, fmt.Errorf("getting schema for table %s: %w", name, err)
-		}
-		schema[name] = stmt
-	}
-	return schema, nil
+	return p.ComputeTables(ctx, names)
 }
 
-// CaptureSchemaSnapshot captures the current database schema and stores it
-// associated with the given migration index.
-func (p *PostgresDBAdapter) CaptureSchemaSnapshot(ctx context.Context, migrationIndex string) error {
-	if err := p.EnsureSnapshotsTable(ctx); err != nil {
-		return fmt.Errorf("ensuring snapshots table: %w", err)
-	}
-
-	schema, err := p.ComputeSchema(ctx)
-	if err != nil {
-		return err
-	}
-
-	jsonBytes, err := json.Marshal(schema)
-	if err != nil {
-		return fmt.Errorf("marshaling schema: %w", err)
-	}
+// ComputeTables builds a pseudo-CREATE TABLE statement for each named table
+// from information_schema and pg_catalog: columns, then primary key, unique,
+// foreign key and other constraints, then indexes not backing a constraint.
+// Three catalog queries cover every table, however many are asked for. Reads
+// through p.db; see ComputeSchema.
+func (p *PostgresDBAdapter) ComputeTables(ctx context.Context, tables []string) (map[string]string, error) {
+	parts := make(map[string][]string, len(tables))
+	indexes := make(map[string]string, len(tables))
+	names := pq.Array(tables)
 
-	_, err = p.db.ExecContext(ctx,
-		`INSERT INTO joka_snapshots (migration_index, schema_snapshot) VALUES ($1, $2)`,
-		migrationIndex, string(jsonBytes),
-	)
-	return err
-}
-
-// reconstructCreateTable builds a pseudo-CREATE TABLE statement from
-// information_schema and pg_catalog for snapshot purposes. Includes columns,
-// primary keys, unique constraints, foreign keys, and indexes.
-func (p *PostgresDBAdapter) reconstructCreateTable(ctx context.Context, tableName string) (string, error) {
 	// 1. Columns
 	colRows, err := p.db.QueryContext(ctx, `
-		SELECT column_name, data_type, is_nullable, column_default,
+		SELECT table_name, column_name, data_type, is_nullable, column_default,
 		       character_maximum_length, numeric_precision, numeric_scale
 		FROM information_schema.columns
 		WHERE table_schema = current_schema()
-		AND table_name = $1
-		ORDER BY ordinal_position
-	`, tableName)
+		AND table_name = ANY($1)
+		ORDER BY table_name, ordinal_position
+	`, names)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("reading columns: %w", err)
 	}
 	defer colRows.Close()
 
-	var parts []string
 	for colRows.Next() {
-		var colName, dataType, isNullable string
+		var tableName, colName, dataType, isNullable string
 		var colDefault sql.NullString
 		var charMaxLen, numPrecision, numScale sql.NullInt64
 
-		if err := colRows.Scan(&colName, &dataType, &isNullable, &colDefault, &charMaxLen, &numPrecision, &numScale); err != nil {
-			return "", err
+		if err := colRows.Scan(&tableName, &colName, &dataType, &isNullable, &colDefault, &charMaxLen, &numPrecision, &numScale); err != nil {
+			return nil, err
 		}
 
 		col := fmt.Sprintf("  %s %s", colName, dataType)
@@ -246,93 +274,218 @@ func (p *PostgresDBAdapter) reconstructCreateTable(ctx context.Context, tableNam
 		if colDefault.Valid {
 			col += fmt.Sprintf(" DEFAULT %s", colDefault.String)
 		}
-		parts = append(parts, col)
+		parts[tableName] = append(parts[tableName], col)
 	}
 	if err := colRows.Err(); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// 2. Table constraints (primary keys, unique, foreign keys)
 	conRows, err := p.db.QueryContext(ctx, `
 		SELECT
+			t.relname,
 			c.conname,
-			c.contype,
 			pg_get_constraintdef(c.oid, true) AS def
 		FROM pg_constraint c
 		JOIN pg_class t ON t.oid = c.conrelid
 		JOIN pg_namespace n ON n.oid = t.relnamespace
-		WHERE t.relname = $1
+		WHERE t.relname = ANY($1)
 		AND n.nspname = current_schema()
 		ORDER BY
+			t.relname,
 			CASE c.contype WHEN 'p' THEN 0 WHEN 'u' THEN 1 WHEN 'f' THEN 2 ELSE 3 END,
 			c.conname
-	`, tableName)
+	`, names)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("reading constraints: %w", err)
 	}
 	defer conRows.Close()
 
 	for conRows.Next() {
-		var conName, conType, conDef string
-		if err := conRows.Scan(&conName, &conType, &conDef); err != nil {
-			return "", err
+		var tableName, conName, conDef string
+		if err := conRows.Scan(&tableName, &conName, &conDef); err != nil {
+			return nil, err
 		}
-		parts = append(parts, fmt.Sprintf("  CONSTRAINT %s %s", conName, conDef))
+		parts[tableName] = append(parts[tableName], fmt.Sprintf("  CONSTRAINT %s %s", conName, conDef))
 	}
 	if err := conRows.Err(); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	result := fmt.Sprintf("CREATE TABLE %s (\n%s\n)", tableName, strings.Join(parts, ",\n"))
-
 	// 3. Indexes (exclude those backing constraints — already covered above)
 	idxRows, err := p.db.QueryContext(ctx, `
-		SELECT indexname, indexdef
-		FROM pg_indexes
-		WHERE schemaname = current_schema()
-		AND tablename = $1
-		AND indexname NOT IN (
-			SELECT conname FROM pg_constraint c
+		SELECT i.tablename, i.indexdef
+		FROM pg_indexes i
+		WHERE i.schemaname = current_schema()
+		AND i.tablename = ANY($1)
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_constraint c
 			JOIN pg_class t ON t.oid = c.conrelid
 			JOIN pg_namespace n ON n.oid = t.relnamespace
-			WHERE t.relname = $1 AND n.nspname = current_schema()
+			WHERE t.relname = i.tablename AND n.nspname = current_schema()
+			AND c.conname = i.indexname
 		)
-		ORDER BY indexname
-	`, tableName)
+		ORDER BY i.tablename, i.indexname
+	`, names)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("reading indexes: %w", err)
 	}
 	defer idxRows.Close()
 
 	for idxRows.Next() {
-		var idxName, idxDef string
-		if err := idxRows.Scan(&idxName, &idxDef); err != nil {
-			return "", err
+		var tableName, idxDef string
+		if err := idxRows.Scan(&tableName, &idxDef); err != nil {
+			return nil, err
 		}
-		result += fmt.Sprintf("\n%s;", idxDef)
+		indexes[tableName] += fmt.Sprintf("\n%s;", idxDef)
 	}
 	if err := idxRows.Err(); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	schema := make(map[string]string, len(tables))
+	for _, name := range tables {
+		schema[name] = fmt.Sprintf("CREATE TABLE %s (\n%s\n)", name, strings.Join(parts[name], ",\n")) + indexes[name]
+	}
+	return schema, nil
+}
+
+// CaptureSchemaSnapshot computes the full schema and stores it as the
+// snapshot for the given migration index (see StoreSchemaSnapshot).
+func (p *PostgresDBAdapter) CaptureSchemaSnapshot(ctx context.Context, migrationIndex string, opts domain.SnapshotOptions) error {
+	schema, err := p.ComputeSchema(ctx)
+	if err != nil {
+		return err
+	}
+	return p.StoreSchemaSnapshot(ctx, migrationIndex, schema, opts)
+}
+
+// StoreSchemaSnapshot stores an already computed schema as the snapshot for
+// the given migration index. Each table's DDL is stored once in
+// joka_snapshot_tables; the joka_snapshots row holds a manifest of hashes.
+// Both are written through p.db so they commit with the migration.
+func (p *PostgresDBAdapter) StoreSchemaSnapshot(ctx context.Context, migrationIndex string, schema map[string]string, opts domain.SnapshotOptions) error {
+	if err := p.EnsureSnapshotsTable(ctx); err != nil {
+		return fmt.Errorf("ensuring snapshots table: %w", err)
+	}
+
+	manifest, blobs, err := buildSnapshotManifest(schema, opts.Compress)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range blobs {
+		_, err := p.db.ExecContext(ctx,
+			`INSERT INTO joka_snapshot_tables (hash, encoding, ddl) VALUES ($1, $2, $3) ON CONFLICT (hash) DO NOTHING`,
+			b.Hash, b.Encoding, b.Payload,
+		)
+		if err != nil {
+			return fmt.Errorf("storing snapshot table: %w", err)
+		}
 	}
 
-	return result, nil
+	_, err = p.db.ExecContext(ctx,
+		`INSERT INTO joka_snapshots (migration_index, schema_snapshot, storage) VALUES ($1, $2, $3)`,
+		migrationIndex, manifest, snapshotStorageManifest,
+	)
+	return err
 }
 
-// GetSchemaSnapshot retrieves the stored schema snapshot for a given migration index.
+// GetSchemaSnapshot retrieves the stored schema snapshot for a given migration
+// index as {table: DDL} JSON, whichever format the row was stored in.
 func (p *PostgresDBAdapter) GetSchemaSnapshot(ctx context.Context, migrationIndex string) (string, error) {
 	if err := p.EnsureSnapshotsTable(ctx); err != nil {
 		return "", fmt.Errorf("ensuring snapshots table: %w", err)
 	}
 
-	var snapshot string
+	var snapshot, storage string
 	err := p.conn.QueryRowContext(ctx,
-		`SELECT schema_snapshot FROM joka_snapshots WHERE migration_index = $1`,
+		`SELECT schema_snapshot, storage FROM joka_snapshots WHERE migration_index = $1`,
 		migrationIndex,
-	).Scan(&snapshot)
+	).Scan(&snapshot, &storage)
 	if err == sql.ErrNoRows {
-		return "", fmt.Errorf("no snapshot found for migration %s", migrationIndex)
+		return "", fmt.Errorf("%w for migration %s", domain.ErrNoSnapshot, migrationIndex)
+	}
+	if err != nil || storage != snapshotStorageManifest {
+		return snapshot, err
+	}
+
+	manifest, hashes, err := parseSnapshotManifest(snapshot)
+	if err != nil {
+		return "", err
 	}
-	return snapshot, err
+	blobs, err := p.loadSnapshotBlobs(ctx, hashes)
+	if err != nil {
+		return "", err
+	}
+	return assembleSnapshot(manifest, blobs)
+}
+
+// loadSnapshotBlobs reads the joka_snapshot_tables rows for the given hashes.
+func (p *PostgresDBAdapter) loadSnapshotBlobs(ctx context.Context, hashes []string) (map[string]snapshotBlob, error) {
+	blobs := make(map[string]snapshotBlob, len(hashes))
+	if len(hashes) == 0 {
+		return blobs, nil
+	}
+
+	rows, err := p.conn.QueryContext(ctx,
+		`SELECT hash, encoding, ddl FROM joka_snapshot_tables WHERE hash = ANY($1)`,
+		pq.Array(hashes),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading snapshot tables: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b snapshotBlob
+		if err := rows.Scan(&b.Hash, &b.Encoding, &b.Payload); err != nil {
+			return nil, err
+		}
+		blobs[b.Hash] = b
+	}
+	return blobs, rows.Err()
+}
+
+// PruneSnapshots deletes all but the newest keep snapshots, then deletes the
+// joka_snapshot_tables rows no remaining snapshot refers to. Returns the
+// number of snapshots and table rows deleted.
+func (p *PostgresDBAdapter) PruneSnapshots(ctx context.Context, keep int) (int, int, error) {
+	if err := p.EnsureSnapshotsTable(ctx); err != nil {
+		return 0, 0, fmt.Errorf("ensuring snapshots table: %w", err)
+	}
+
+	res, err := p.conn.ExecContext(ctx, `
+		DELETE FROM joka_snapshots
+		WHERE id NOT IN (SELECT id FROM joka_snapshots ORDER BY id DESC LIMIT $1)
+	`, keep)
+	if err != nil {
+		return 0, 0, fmt.Errorf("pruning snapshots: %w", err)
+	}
+	pruned, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	manifests, err := queryStrings(ctx, p.conn, `SELECT schema_snapshot FROM joka_snapshots WHERE storage = 'manifest'`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading snapshot manifests: %w", err)
+	}
+	stored, err := queryStrings(ctx, p.conn, `SELECT hash FROM joka_snapshot_tables`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading snapshot tables: %w", err)
+	}
+	orphans, err := unreferencedHashes(manifests, stored)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(orphans) > 0 {
+		if _, err := p.conn.ExecContext(ctx, `DELETE FROM joka_snapshot_tables WHERE hash = ANY($1)`, pq.Array(orphans)); err != nil {
+			return 0, 0, fmt.Errorf("pruning snapshot tables: %w", err)
+		}
+	}
+
+	return int(pruned), len(orphans), nil
 }
 
 // GetLatestSnapshotIndex returns the migration index of the most recent snapshot.
@@ -346,7 +499,16 @@ func (p *PostgresDBAdapter) GetLatestSnapshotIndex(ctx context.Context) (string,
 		`SELECT migration_index FROM joka_snapshots ORDER BY id DESC LIMIT 1`,
 	).Scan(&index)
 	if err == sql.ErrNoRows {
-		return "", fmt.Errorf("no snapshots found")
+		return "", domain.ErrNoSnapshot
 	}
 	return index, err
 }
+
+// ListSnapshotIndexes returns the migration index of every stored snapshot,
+// oldest first.
+func (p *PostgresDBAdapter) ListSnapshotIndexes(ctx context.Context) ([]string, error) {
+	if err := p.EnsureSnapshotsTable(ctx); err != nil {
+		return nil, fmt.Errorf("ensuring snapshots table: %w", err)
+	}
+	return queryStrings(ctx, p.conn, `SELECT migration_index FROM joka_snapshots ORDER BY id`)
+}
@@ -3,8 +3,10 @@ package infra_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/apsdsm/joka/internal/domains/migration/domain"
@@ -58,6 +60,19 @@ func TestPostgresCreateMigrationsTable(t *testing.T) {
 			t.Fatalf("expected ErrMigrationAlreadyExists, got: %v", err)
 		}
 	})
+
+	t.Run("it drops the table", func(t *testing.T) {
+		if err := adapter.DropMigrationsTable(ctx); err != nil {
+			t.Fatalf("DropMigrationsTable: %v", err)
+		}
+		exists, err := adapter.HasMigrationsTable(ctx)
+		if err != nil {
+			t.Fatalf("HasMigrationsTable after drop: %v", err)
+		}
+		if exists {
+			t.Fatal("expected migrations table to be gone after drop")
+		}
+	})
 }
 
 func TestPostgresRecordAndGetAppliedMigrations(t *testing.T) {
@@ -127,7 +142,7 @@ func TestPostgresApplySQLFromFile(t *testing.T) {
 			t.Fatalf("writing sql file: %v", err)
 		}
 
-		if err := adapter.ApplySQLFromFile(ctx, sqlFile); err != nil {
+		if err := adapter.ApplySQLFromFile(ctx, sqlFile, ""); err != nil {
 			t.Fatalf("ApplySQLFromFile: %v", err)
 		}
 
@@ -152,7 +167,7 @@ func TestPostgresApplySQLFromFile(t *testing.T) {
 			t.Fatalf("writing sql file: %v", err)
 		}
 
-		if err := adapter.ApplySQLFromFile(ctx, sqlFile); err != nil {
+		if err := adapter.ApplySQLFromFile(ctx, sqlFile, ""); err != nil {
 			t.Fatalf("ApplySQLFromFile multi-statement: %v", err)
 		}
 
@@ -175,7 +190,10 @@ func TestPostgresEnsureSnapshotsTable(t *testing.T) {
 		t.Fatalf("getting test db: %v", err)
 	}
 
-	t.Cleanup(func() { testlib.DropTablePostgres(t, db, "joka_snapshots") })
+	t.Cleanup(func() {
+		testlib.DropTablePostgres(t, db, "joka_snapshots")
+		testlib.DropTablePostgres(t, db, "joka_snapshot_tables")
+	})
 
 	t.Run("it creates the table and is idempotent", func(t *testing.T) {
 		adapter := infra.NewPostgresDBAdapter(db)
@@ -204,6 +222,7 @@ func TestPostgresCaptureAndGetSchemaSnapshot(t *testing.T) {
 	userTable := "test_pg_snapshot_users"
 	t.Cleanup(func() {
 		testlib.DropTablePostgres(t, db, "joka_snapshots")
+		testlib.DropTablePostgres(t, db, "joka_snapshot_tables")
 		testlib.DropTablePostgres(t, db, userTable)
 	})
 
@@ -217,7 +236,7 @@ func TestPostgresCaptureAndGetSchemaSnapshot(t *testing.T) {
 
 		adapter := infra.NewPostgresDBAdapter(db)
 
-		if err := adapter.CaptureSchemaSnapshot(ctx, "240101120000"); err != nil {
+		if err := adapter.CaptureSchemaSnapshot(ctx, "240101120000", domain.SnapshotOptions{}); err != nil {
 			t.Fatalf("CaptureSchemaSnapshot: %v", err)
 		}
 
@@ -251,7 +270,10 @@ func TestPostgresGetLatestSnapshotIndex(t *testing.T) {
 		t.Fatalf("getting test db: %v", err)
 	}
 
-	t.Cleanup(func() { testlib.DropTablePostgres(t, db, "joka_snapshots") })
+	t.Cleanup(func() {
+		testlib.DropTablePostgres(t, db, "joka_snapshots")
+		testlib.DropTablePostgres(t, db, "joka_snapshot_tables")
+	})
 
 	t.Run("it returns the most recent snapshot index", func(t *testing.T) {
 		adapter := infra.NewPostgresDBAdapter(db)
@@ -278,6 +300,104 @@ func TestPostgresGetLatestSnapshotIndex(t *testing.T) {
 			t.Errorf("expected latest snapshot 240202120000, got %s", latest)
 		}
 	})
+
+	t.Run("it lists every snapshot index oldest first", func(t *testing.T) {
+		indexes, err := infra.NewPostgresDBAdapter(db).ListSnapshotIndexes(context.Background())
+		if err != nil {
+			t.Fatalf("ListSnapshotIndexes: %v", err)
+		}
+		if strings.Join(indexes, ",") != "240101120000,240202120000" {
+			t.Errorf("expected both snapshots in order, got %v", indexes)
+		}
+	})
+}
+
+func TestPostgresSnapshotStorage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+	ctx := context.Background()
+
+	cleanup := func() {
+		testlib.DropTablePostgres(t, db, "joka_snapshots")
+		testlib.DropTablePostgres(t, db, "joka_snapshot_tables")
+		testlib.DropTablePostgres(t, db, "test_store_postgres_users")
+	}
+	t.Cleanup(cleanup)
+
+	t.Run("it reads rows written before snapshots were deduplicated", func(t *testing.T) {
+		cleanup()
+		_, err := db.ExecContext(ctx, `CREATE TABLE joka_snapshots (
+			id SERIAL PRIMARY KEY,
+			migration_index VARCHAR(255) NOT NULL UNIQUE,
+			schema_snapshot TEXT NOT NULL
+		)`)
+		if err != nil {
+			t.Fatalf("creating legacy snapshots table: %v", err)
+		}
+		legacy := `{"users":"CREATE TABLE users (id INT)"}`
+		if _, err := db.ExecContext(ctx, "INSERT INTO joka_snapshots (migration_index, schema_snapshot) VALUES ($1, $2)", "240101120000", legacy); err != nil {
+			t.Fatalf("inserting legacy snapshot: %v", err)
+		}
+
+		snapshot, err := infra.NewPostgresDBAdapter(db).GetSchemaSnapshot(ctx, "240101120000")
+		if err != nil {
+			t.Fatalf("GetSchemaSnapshot: %v", err)
+		}
+		if snapshot != legacy {
+			t.Errorf("expected legacy snapshot unchanged, got %s", snapshot)
+		}
+	})
+
+	t.Run("it round-trips compressed snapshots and prunes unreferenced tables", func(t *testing.T) {
+		cleanup()
+		adapter := infra.NewPostgresDBAdapter(db)
+		opts := domain.SnapshotOptions{Compress: true}
+
+		if _, err := db.ExecContext(ctx, "CREATE TABLE test_store_postgres_users (id INT PRIMARY KEY)"); err != nil {
+			t.Fatalf("creating user table: %v", err)
+		}
+		if err := adapter.CaptureSchemaSnapshot(ctx, "240101120000", opts); err != nil {
+			t.Fatalf("CaptureSchemaSnapshot: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE test_store_postgres_users ADD COLUMN email VARCHAR(255)"); err != nil {
+			t.Fatalf("altering user table: %v", err)
+		}
+		if err := adapter.CaptureSchemaSnapshot(ctx, "240102120000", opts); err != nil {
+			t.Fatalf("CaptureSchemaSnapshot: %v", err)
+		}
+
+		snapshot, err := adapter.GetSchemaSnapshot(ctx, "240102120000")
+		if err != nil {
+			t.Fatalf("GetSchemaSnapshot: %v", err)
+		}
+		var schema map[string]string
+		if err := json.Unmarshal([]byte(snapshot), &schema); err != nil {
+			t.Fatalf("unmarshaling snapshot: %v", err)
+		}
+		if !strings.Contains(schema["test_store_postgres_users"], "email") {
+			t.Errorf("expected the altered table in the latest snapshot, got %q", schema["test_store_postgres_users"])
+		}
+
+		snapshots, tables, err := adapter.PruneSnapshots(ctx, 1)
+		if err != nil {
+			t.Fatalf("PruneSnapshots: %v", err)
+		}
+		if snapshots != 1 || tables != 1 {
+			t.Errorf("expected 1 snapshot and 1 table pruned, got %d and %d", snapshots, tables)
+		}
+		if _, err := adapter.GetSchemaSnapshot(ctx, "240101120000"); !errors.Is(err, domain.ErrNoSnapshot) {
+			t.Errorf("expected pruned snapshot to be gone, got %v", err)
+		}
+		if _, err := adapter.GetSchemaSnapshot(ctx, "240102120000"); err != nil {
+			t.Errorf("expected kept snapshot to stay readable, got %v", err)
+		}
+	})
 }
 
 func TestPostgresComputeSchema(t *testing.T) {
@@ -311,6 +431,46 @@ func TestPostgresComputeSchema(t *testing.T) {
 			t.Errorf("expected joka_snapshots to be filtered out, got %v", pgKeys(schema))
 		}
 	})
+
+	t.Run("it computes named tables with bulk catalog queries", func(t *testing.T) {
+		for _, stmt := range []string{
+			`CREATE TABLE test_pg_bulk_users (id INT PRIMARY KEY, email VARCHAR(100) UNIQUE)`,
+			`CREATE TABLE test_pg_bulk_orders (id INT PRIMARY KEY, user_id INT REFERENCES test_pg_bulk_users (id), total NUMERIC(10,2) DEFAULT 0)`,
+			`CREATE INDEX test_pg_bulk_orders_total ON test_pg_bulk_orders (total)`,
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+		t.Cleanup(func() {
+			testlib.DropTablePostgres(t, db, "test_pg_bulk_orders")
+			testlib.DropTablePostgres(t, db, "test_pg_bulk_users")
+		})
+
+		adapter := infra.NewPostgresDBAdapter(db)
+		schema, err := adapter.ComputeTables(ctx, []string{"test_pg_bulk_orders", "test_pg_bulk_users"})
+		if err != nil {
+			t.Fatalf("ComputeTables: %v", err)
+		}
+
+		want := "CREATE TABLE test_pg_bulk_orders (\n" +
+			"  id integer NOT NULL,\n" +
+			"  user_id integer,\n" +
+			"  total numeric(10,2) DEFAULT 0,\n" +
+			"  CONSTRAINT test_pg_bulk_orders_pkey PRIMARY KEY (id),\n" +
+			"  CONSTRAINT test_pg_bulk_orders_user_id_fkey FOREIGN KEY (user_id) REFERENCES test_pg_bulk_users(id)\n" +
+			")\n" +
+			"CREATE INDEX test_pg_bulk_orders_total ON public.test_pg_bulk_orders USING btree (total);"
+		if schema["test_pg_bulk_orders"] != want {
+			t.Errorf("unexpected orders DDL:\n%s\nwant:\n%s", schema["test_pg_bulk_orders"], want)
+		}
+		if !strings.Contains(schema["test_pg_bulk_users"], "CONSTRAINT test_pg_bulk_users_email_key UNIQUE (email)") {
+			t.Errorf("expected the unique constraint on users, got %q", schema["test_pg_bulk_users"])
+		}
+		if strings.Contains(schema["test_pg_bulk_users"], "CREATE UNIQUE INDEX") {
+			t.Errorf("expected constraint-backed indexes to be left out, got %q", schema["test_pg_bulk_users"])
+		}
+	})
 }
 
 func pgKeys(m map[string]string) []string {
@@ -0,0 +1,251 @@
+package infra
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	jokadb "github.com/apsdsm/joka/db"
+)
+
+// ScaffoldOptions controls what CreateMigrationFile writes.
+type ScaffoldOptions struct {
+	// Template is the scaffold to render: a built-in (create_table,
+	// add_column) or a custom template in TemplateDir. Empty means the blank
+	// scaffold.
+	Template string
+	// TemplateDir holds custom scaffolds as <name>.sql.tmpl, with an optional
+	// <name>.down.sql.tmpl for the down section. A custom scaffold shadows a
+	// built-in of the same name. Optional.
+	TemplateDir string
+	Driver      jokadb.Driver
+	// Table and Column override the values derived from the migration name
+	// (create_<table>, add_<column>_to_<table>).
+	Table  string
+	Column string
+	// Down also writes down/<file> with SQL that reverts the migration.
+	Down bool
+	// Meta prepends a comment header recording how the file was generated.
+	Meta bool
+	// Now is the creation time; zero means time.Now(). Always used as UTC.
+	Now time.Time
+}
+
+// ScaffoldData is the input every scaffold template is rendered with.
+type ScaffoldData struct {
+	Name   string // migration name as given to `joka make`
+	Index  string // YYMMDDHHMMSS index (UTC)
+	Driver string // "mysql" or "postgres"
+	Table  string
+	Column string
+}
+
+// CreatedMigration reports the files CreateMigrationFile wrote.
+type CreatedMigration struct {
+	File     string // migration file name (not the full path)
+	DownFile string // path of the down file relative to dir, empty if none
+}
+
+// builtinScaffolds are the scaffolds available without a template directory.
+// Each has an up and a down template.
+var builtinScaffolds = map[string][2]string{
+	"": {
+		"-- Write your migration SQL here\n",
+		"-- Write the SQL that reverts {{.Name}} here\n",
+	},
+	"create_table": {
+		`{{if eq .Driver "postgres"}}CREATE TABLE {{.Table}} (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+{{else}}CREATE TABLE ` + "`{{.Table}}`" + ` (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+{{end}}`,
+		`{{if eq .Driver "postgres"}}DROP TABLE {{.Table}};
+{{else}}DROP TABLE ` + "`{{.Table}}`" + `;
+{{end}}`,
+	},
+	"add_column": {
+		`{{if eq .Driver "postgres"}}ALTER TABLE {{.Table}} ADD COLUMN {{.Column}} TEXT NULL;
+{{else}}ALTER TABLE ` + "`{{.Table}}`" + ` ADD COLUMN ` + "`{{.Column}}`" + ` VARCHAR(255) NULL;
+{{end}}`,
+		`{{if eq .Driver "postgres"}}ALTER TABLE {{.Table}} DROP COLUMN {{.Column}};
+{{else}}ALTER TABLE ` + "`{{.Table}}`" + ` DROP COLUMN ` + "`{{.Column}}`" + `;
+{{end}}`,
+	},
+}
+
+// CreateMigrationFile creates a new SQL migration file in dir named
+// <UTC timestamp>_<name>.sql, rendered from the scaffold in opts. It refuses
+// to write if a migration with the same index already exists, so two files
+// made in the same second can't silently share a position in the chain. The
+// migrations directory must already exist.
+func CreateMigrationFile(dir string, name string, opts ScaffoldOptions) (CreatedMigration, error) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return CreatedMigration{}, fmt.Errorf("migrations directory not found: %s", dir)
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.UTC()
+	index := now.Format("060102150405")
+
+	existing, err := ListMigrationFiles(dir)
+	if err != nil {
+		return CreatedMigration{}, err
+	}
+	for _, f := range existing {
+		if f.Index == index {
+			return CreatedMigration{}, fmt.Errorf("a migration with index %s already exists: %s_%s.sql", index, f.Index, f.Name)
+		}
+	}
+
+	up, down, err := loadScaffold(opts.Template, opts.TemplateDir)
+	if err != nil {
+		return CreatedMigration{}, err
+	}
+
+	table, column := scaffoldTarget(name)
+	if opts.Table != "" {
+		table = opts.Table
+	}
+	if opts.Column != "" {
+		column = opts.Column
+	}
+	data := ScaffoldData{Name: name, Index: index, Driver: opts.Driver.String(), Table: table, Column: column}
+
+	body, err := renderScaffold(up, data)
+	if err != nil {
+		return CreatedMigration{}, err
+	}
+	if opts.Meta {
+		body = scaffoldMeta(data, opts.Template, now) + body
+	}
+
+	created := CreatedMigration{File: fmt.Sprintf("%s_%s.sql", index, name)}
+
+	var downBody string
+	if opts.Down {
+		downBody, err = renderScaffold(down, data)
+		if err != nil {
+			return CreatedMigration{}, err
+		}
+		created.DownFile = filepath.Join("down", created.File)
+	}
+
+	// Reuses the import writer: no overwrites, down SQL under down/.
+	if _, err := WriteMigrationFile(dir, created.File, body, downBody); err != nil {
+		return CreatedMigration{}, fmt.Errorf("creating migration file: %w", err)
+	}
+
+	return created, nil
+}
+
+// ListScaffolds returns the names of the built-in scaffolds and any custom
+// ones in templateDir, sorted.
+func ListScaffolds(templateDir string) []string {
+	seen := map[string]bool{}
+	for name := range builtinScaffolds {
+		if name != "" {
+			seen[name] = true
+		}
+	}
+	if templateDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(templateDir, "*.sql.tmpl"))
+		for _, m := range matches {
+			name := strings.TrimSuffix(filepath.Base(m), ".sql.tmpl")
+			if !strings.HasSuffix(name, ".down") {
+				seen[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadScaffold returns the up and down template sources for name, looking in
+// templateDir before the built-ins.
+func loadScaffold(name, templateDir string) (string, string, error) {
+	if name != "" && templateDir != "" {
+		up, err := os.ReadFile(filepath.Join(templateDir, name+".sql.tmpl"))
+		if err == nil {
+			down, err := os.ReadFile(filepath.Join(templateDir, name+".down.sql.tmpl"))
+			if errors.Is(err, os.ErrNotExist) {
+				return string(up), builtinScaffolds[""][1], nil
+			}
+			if err != nil {
+				return "", "", fmt.Errorf("reading scaffold %s: %w", name, err)
+			}
+			return string(up), string(down), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", fmt.Errorf("reading scaffold %s: %w", name, err)
+		}
+	}
+
+	s, ok := builtinScaffolds[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(ListScaffolds(templateDir), ", "))
+	}
+	return s[0], s[1], nil
+}
+
+// renderScaffold executes a scaffold template. A typo'd field in a custom
+// template fails here rather than producing a half-written migration.
+func renderScaffold(src string, data ScaffoldData) (string, error) {
+	tmpl, err := template.New("scaffold").Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("parsing scaffold: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering scaffold: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// scaffoldMeta returns the metadata comment header for a generated file. It
+// is plain comments, so it sits in the migration header alongside any
+// `-- joka:` directives.
+func scaffoldMeta(data ScaffoldData, tmpl string, now time.Time) string {
+	if tmpl == "" {
+		tmpl = "blank"
+	}
+	return fmt.Sprintf("-- Migration: %s\n-- Created: %s\n-- Driver: %s\n-- Template: %s\n\n",
+		data.Name, now.Format(time.RFC3339), data.Driver, tmpl)
+}
+
+// scaffoldTarget derives the table and column a migration name refers to:
+// create_<table>[_table] and add_<column>_to_<table>. Anything else yields
+// placeholders for the author to fill in.
+func scaffoldTarget(name string) (table, column string) {
+	table, column = "table_name", "column_name"
+
+	switch {
+	case strings.HasPrefix(name, "create_"):
+		table = strings.TrimSuffix(strings.TrimPrefix(name, "create_"), "_table")
+	case strings.HasPrefix(name, "add_"):
+		if c, t, ok := strings.Cut(strings.TrimPrefix(name, "add_"), "_to_"); ok {
+			column, table = c, strings.TrimSuffix(t, "_table")
+		}
+	}
+	return table, column
+}
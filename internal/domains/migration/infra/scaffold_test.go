@@ -0,0 +1,133 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jokadb "github.com/apsdsm/joka/db"
+)
+
+func TestCreateMigrationFile(t *testing.T) {
+	readFile := func(t *testing.T, path string) string {
+		t.Helper()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		return string(b)
+	}
+
+	t.Run("it creates a file matching the migration pattern", func(t *testing.T) {
+		dir := t.TempDir()
+		created, err := CreateMigrationFile(dir, "add_users", ScaffoldOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !migrationPattern.MatchString(created.File) {
+			t.Errorf("filename %q does not match migration pattern", created.File)
+		}
+
+		fullPath := filepath.Join(dir, created.File)
+		if _, err := os.Stat(fullPath); err != nil {
+			t.Errorf("migration file not found on disk: %v", err)
+		}
+	})
+
+	t.Run("it returns an error for a missing directory", func(t *testing.T) {
+		_, err := CreateMigrationFile("/nonexistent/dir", "test", ScaffoldOptions{})
+		if err == nil {
+			t.Fatal("expected error for missing directory")
+		}
+	})
+
+	t.Run("it names the file with a UTC timestamp", func(t *testing.T) {
+		dir := t.TempDir()
+		tokyo := time.FixedZone("JST", 9*60*60)
+		now := time.Date(2025, 6, 15, 9, 30, 22, 0, tokyo)
+
+		created, err := CreateMigrationFile(dir, "x", ScaffoldOptions{Now: now})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if created.File != "250615003022_x.sql" {
+			t.Errorf("expected UTC index, got %s", created.File)
+		}
+	})
+
+	t.Run("it refuses an index that already exists", func(t *testing.T) {
+		dir := t.TempDir()
+		now := time.Date(2025, 6, 15, 0, 30, 22, 0, time.UTC)
+		os.WriteFile(filepath.Join(dir, "250615003022_other.sql"), []byte("SELECT 1;"), 0644)
+
+		if _, err := CreateMigrationFile(dir, "x", ScaffoldOptions{Now: now}); err == nil {
+			t.Fatal("expected error for duplicate index")
+		}
+	})
+
+	t.Run("it renders driver-aware create_table boilerplate with a down file", func(t *testing.T) {
+		dir := t.TempDir()
+
+		created, err := CreateMigrationFile(dir, "create_users_table", ScaffoldOptions{Template: "create_table", Driver: jokadb.Postgres, Down: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		up := readFile(t, filepath.Join(dir, created.File))
+		if !strings.Contains(up, "CREATE TABLE users (") || !strings.Contains(up, "GENERATED ALWAYS AS IDENTITY") {
+			t.Errorf("unexpected postgres scaffold:\n%s", up)
+		}
+		down := readFile(t, filepath.Join(dir, created.DownFile))
+		if !strings.Contains(down, "DROP TABLE users;") {
+			t.Errorf("unexpected down scaffold:\n%s", down)
+		}
+	})
+
+	t.Run("it derives table and column for add_column on mysql", func(t *testing.T) {
+		dir := t.TempDir()
+
+		created, err := CreateMigrationFile(dir, "add_email_to_users", ScaffoldOptions{Template: "add_column", Driver: jokadb.MySQL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		up := readFile(t, filepath.Join(dir, created.File))
+		if !strings.Contains(up, "ALTER TABLE `users` ADD COLUMN `email`") {
+			t.Errorf("unexpected mysql scaffold:\n%s", up)
+		}
+	})
+
+	t.Run("it prefers a custom template and prepends metadata", func(t *testing.T) {
+		dir := t.TempDir()
+		tmplDir := t.TempDir()
+		os.WriteFile(filepath.Join(tmplDir, "create_table.sql.tmpl"), []byte("-- custom {{.Name}} for {{.Driver}}\n"), 0644)
+
+		created, err := CreateMigrationFile(dir, "create_orders", ScaffoldOptions{Template: "create_table", TemplateDir: tmplDir, Driver: jokadb.Postgres, Meta: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		up := readFile(t, filepath.Join(dir, created.File))
+		if !strings.HasPrefix(up, "-- Migration: create_orders\n") || !strings.Contains(up, "-- custom create_orders for postgres") {
+			t.Errorf("unexpected custom scaffold:\n%s", up)
+		}
+	})
+
+	t.Run("it rejects an unknown template", func(t *testing.T) {
+		if _, err := CreateMigrationFile(t.TempDir(), "x", ScaffoldOptions{Template: "nope"}); err == nil {
+			t.Fatal("expected error for unknown template")
+		}
+	})
+
+	t.Run("it rejects a template referencing an unknown field", func(t *testing.T) {
+		tmplDir := t.TempDir()
+		os.WriteFile(filepath.Join(tmplDir, "bad.sql.tmpl"), []byte("{{.Tabel}}"), 0644)
+
+		if _, err := CreateMigrationFile(t.TempDir(), "x", ScaffoldOptions{Template: "bad", TemplateDir: tmplDir}); err == nil {
+			t.Fatal("expected error for unknown field")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package infra_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/migration/infra"
+	"github.com/apsdsm/joka/testlib"
+)
+
+// benchTables is the schema size the capture benchmarks run against.
+const benchTables = 200
+
+// schemaComputer is the part of both adapters the benchmarks exercise.
+type schemaComputer interface {
+	ComputeSchema(ctx context.Context) (map[string]string, error)
+	ComputeTables(ctx context.Context, tables []string) (map[string]string, error)
+	ListTables(ctx context.Context) ([]string, error)
+}
+
+// benchmarkSchemaCapture compares the ways a snapshot can be computed:
+//
+//   - per-table: one ComputeTables call per table, the round trips capture
+//     made before bulk queries (one SHOW CREATE TABLE, or three catalog
+//     queries, per table)
+//   - bulk: ComputeSchema, which fetches every table at once
+//   - touched: what a snapshot costs when SchemaTracker knows the migration
+//     only touched one table (list the tables, recompute one)
+//
+// A migrate up run of N migrations used to cost N x per-table; it now costs
+// one bulk capture plus N-1 touched captures, or a single bulk capture with
+// the end-of-batch mode.
+func benchmarkSchemaCapture(b *testing.B, db *sql.DB, adapter schemaComputer, createSQL string) {
+	ctx := context.Background()
+	names := make([]string, benchTables)
+	for i := range names {
+		names[i] = fmt.Sprintf("bench_snapshot_%03d", i)
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(createSQL, names[i])); err != nil {
+			b.Fatalf("creating %s: %v", names[i], err)
+		}
+	}
+	b.Cleanup(func() {
+		for _, name := range names {
+			db.ExecContext(ctx, "DROP TABLE IF EXISTS "+name)
+		}
+	})
+
+	b.Run("per-table", func(b *testing.B) {
+		for b.Loop() {
+			for _, name := range names {
+				if _, err := adapter.ComputeTables(ctx, []string{name}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("bulk", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := adapter.ComputeSchema(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("touched", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := adapter.ListTables(ctx); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := adapter.ComputeTables(ctx, names[:1]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkMySQLSchemaCapture(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping integration benchmark")
+	}
+	db, err := testlib.GetTestDB()
+	if err != nil {
+		b.Fatalf("getting test db: %v", err)
+	}
+	benchmarkSchemaCapture(b, db, infra.NewMySQLDBAdapter(db),
+		"CREATE TABLE %s (id INT PRIMARY KEY, name VARCHAR(100) NOT NULL, created_at TIMESTAMP NULL, INDEX idx_name (name))")
+}
+
+func BenchmarkPostgresSchemaCapture(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping integration benchmark")
+	}
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		b.Fatalf("getting test db: %v", err)
+	}
+	benchmarkSchemaCapture(b, db, infra.NewPostgresDBAdapter(db),
+		"CREATE TABLE %[1]s (id INT PRIMARY KEY, name VARCHAR(100) NOT NULL UNIQUE, created_at TIMESTAMP); CREATE INDEX %[1]s_created ON %[1]s (created_at)")
+}
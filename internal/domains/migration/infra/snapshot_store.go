@@ -0,0 +1,198 @@
+package infra
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// Snapshots are stored content-addressed: each table's DDL is written once to
+// joka_snapshot_tables under its SHA-256, and a joka_snapshots row holds a
+// manifest mapping table name to hash. A migration that touches one table
+// therefore adds one DDL row, not a copy of the whole schema.
+//
+// joka_snapshots.storage tells the two row formats apart. Rows written before
+// deduplication have storage 'json' and hold the full {table: DDL} map.
+const (
+	snapshotStorageJSON     = "json"
+	snapshotStorageManifest = "manifest"
+
+	snapshotEncodingPlain = "plain"
+	snapshotEncodingGzip  = "gzip" // gzip, then base64 so it fits a text column
+)
+
+// snapshotAutoIncrement strips MySQL's AUTO_INCREMENT=<n> table option before
+// hashing. The counter moves with every insert; keeping it would defeat
+// deduplication without telling anyone anything about the schema.
+var snapshotAutoIncrement = regexp.MustCompile(`\s*AUTO_INCREMENT=\d+`)
+
+// snapshotBlob is one row of joka_snapshot_tables.
+type snapshotBlob struct {
+	Hash     string
+	Encoding string
+	Payload  string
+}
+
+// buildSnapshotManifest splits a computed schema into a manifest (table →
+// hash, as JSON) and the DDL rows it refers to, one per distinct DDL.
+func buildSnapshotManifest(schema map[string]string, compress bool) (string, []snapshotBlob, error) {
+	manifest := make(map[string]string, len(schema))
+	seen := make(map[string]bool)
+	var blobs []snapshotBlob
+
+	tables := make([]string, 0, len(schema))
+	for table := range schema {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		ddl := snapshotAutoIncrement.ReplaceAllString(schema[table], "")
+		sum := sha256.Sum256([]byte(ddl))
+		hash := hex.EncodeToString(sum[:])
+		manifest[table] = hash
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		blob := snapshotBlob{Hash: hash, Encoding: snapshotEncodingPlain, Payload: ddl}
+		if compress {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			if _, err := zw.Write([]byte(ddl)); err != nil {
+				return "", nil, fmt.Errorf("compressing snapshot: %w", err)
+			}
+			if err := zw.Close(); err != nil {
+				return "", nil, fmt.Errorf("compressing snapshot: %w", err)
+			}
+			blob.Encoding = snapshotEncodingGzip
+			blob.Payload = base64.StdEncoding.EncodeToString(buf.Bytes())
+		}
+		blobs = append(blobs, blob)
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshaling snapshot manifest: %w", err)
+	}
+	return string(manifestJSON), blobs, nil
+}
+
+// parseSnapshotManifest returns the table → hash map of a manifest row and
+// the distinct hashes it references.
+func parseSnapshotManifest(manifestJSON string) (map[string]string, []string, error) {
+	var manifest map[string]string
+	if err := json.Unmarshal([]byte(manifestJSON), &manifest); err != nil {
+		return nil, nil, fmt.Errorf("parsing snapshot manifest: %w", err)
+	}
+	seen := make(map[string]bool)
+	var hashes []string
+	for _, hash := range manifest {
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+	return manifest, hashes, nil
+}
+
+// decodeSnapshotBlob returns the DDL stored in a joka_snapshot_tables row.
+func decodeSnapshotBlob(b snapshotBlob) (string, error) {
+	switch b.Encoding {
+	case snapshotEncodingPlain:
+		return b.Payload, nil
+	case snapshotEncodingGzip:
+		raw, err := base64.StdEncoding.DecodeString(b.Payload)
+		if err != nil {
+			return "", fmt.Errorf("decoding snapshot table %s: %w", b.Hash, err)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return "", fmt.Errorf("decompressing snapshot table %s: %w", b.Hash, err)
+		}
+		ddl, err := io.ReadAll(zr)
+		if err != nil {
+			return "", fmt.Errorf("decompressing snapshot table %s: %w", b.Hash, err)
+		}
+		return string(ddl), nil
+	default:
+		return "", fmt.Errorf("snapshot table %s has unknown encoding %q", b.Hash, b.Encoding)
+	}
+}
+
+// assembleSnapshot resolves a manifest against its DDL rows and returns the
+// snapshot in the original {table: DDL} JSON form, so readers don't need to
+// know how it was stored.
+func assembleSnapshot(manifest map[string]string, blobs map[string]snapshotBlob) (string, error) {
+	schema := make(map[string]string, len(manifest))
+	for table, hash := range manifest {
+		blob, ok := blobs[hash]
+		if !ok {
+			return "", fmt.Errorf("snapshot table %s for %s is missing from joka_snapshot_tables", hash, table)
+		}
+		ddl, err := decodeSnapshotBlob(blob)
+		if err != nil {
+			return "", err
+		}
+		schema[table] = ddl
+	}
+
+	out, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("marshaling schema: %w", err)
+	}
+	return string(out), nil
+}
+
+// unreferencedHashes returns the stored DDL hashes no remaining manifest
+// refers to.
+func unreferencedHashes(manifests []string, stored []string) ([]string, error) {
+	referenced := make(map[string]bool)
+	for _, m := range manifests {
+		_, hashes, err := parseSnapshotManifest(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hashes {
+			referenced[h] = true
+		}
+	}
+
+	var orphans []string
+	for _, h := range stored {
+		if !referenced[h] {
+			orphans = append(orphans, h)
+		}
+	}
+	return orphans, nil
+}
+
+// queryStrings runs a parameterless query returning one string column. The
+// queries it is used for are identical on both drivers.
+func queryStrings(ctx context.Context, db DBTX, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
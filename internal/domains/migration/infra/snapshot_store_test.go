@@ -0,0 +1,75 @@
+package infra
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshotManifest(t *testing.T) {
+	schema := map[string]string{
+		"users":  "CREATE TABLE `users` (\n  `id` int NOT NULL\n) ENGINE=InnoDB AUTO_INCREMENT=42",
+		"admins": "CREATE TABLE `users` (\n  `id` int NOT NULL\n) ENGINE=InnoDB AUTO_INCREMENT=7",
+		"orders": "CREATE TABLE orders (id INT)",
+	}
+
+	for _, compress := range []bool{false, true} {
+		manifestJSON, blobs, err := buildSnapshotManifest(schema, compress)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		t.Run("it stores identical DDL once regardless of AUTO_INCREMENT counters", func(t *testing.T) {
+			if len(blobs) != 2 {
+				t.Errorf("expected 2 distinct blobs, got %d", len(blobs))
+			}
+		})
+
+		t.Run("it reassembles the schema from the manifest", func(t *testing.T) {
+			manifest, hashes, err := parseSnapshotManifest(manifestJSON)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(hashes) != 2 {
+				t.Errorf("expected 2 referenced hashes, got %v", hashes)
+			}
+
+			byHash := make(map[string]snapshotBlob)
+			for _, b := range blobs {
+				byHash[b.Hash] = b
+			}
+			out, err := assembleSnapshot(manifest, byHash)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got map[string]string
+			json.Unmarshal([]byte(out), &got)
+			if got["orders"] != schema["orders"] {
+				t.Errorf("compress=%v: expected orders DDL unchanged, got %q", compress, got["orders"])
+			}
+			if got["users"] != "CREATE TABLE `users` (\n  `id` int NOT NULL\n) ENGINE=InnoDB" {
+				t.Errorf("compress=%v: unexpected users DDL %q", compress, got["users"])
+			}
+		})
+	}
+
+	t.Run("it reports a manifest entry whose DDL is missing", func(t *testing.T) {
+		if _, err := assembleSnapshot(map[string]string{"users": "abc"}, nil); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestUnreferencedHashes(t *testing.T) {
+	t.Run("it returns stored hashes no manifest refers to", func(t *testing.T) {
+		manifests := []string{`{"users":"a","orders":"b"}`, `{"users":"a"}`}
+
+		orphans, err := unreferencedHashes(manifests, []string{"a", "b", "c"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(orphans) != 1 || orphans[0] != "c" {
+			t.Errorf("expected [c], got %v", orphans)
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/apsdsm/joka/internal/domains/migration/domain"
 	"github.com/apsdsm/joka/internal/domains/migration/infra"
 	"github.com/apsdsm/joka/testlib"
 )
@@ -33,6 +34,7 @@ func TestPostgresSnapshotRunsInMigrationTx(t *testing.T) {
 	t.Cleanup(func() {
 		testlib.DropTablePostgres(t, db, "gadget")
 		testlib.DropTablePostgres(t, db, "joka_snapshots")
+		testlib.DropTablePostgres(t, db, "joka_snapshot_tables")
 	})
 
 	ctx := context.Background()
@@ -56,7 +58,7 @@ func TestPostgresSnapshotRunsInMigrationTx(t *testing.T) {
 	}
 
 	txAdapter := infra.NewPostgresTxDBAdapter(tx, db)
-	if err := txAdapter.CaptureSchemaSnapshot(ctx, "test-snapshot-001"); err != nil {
+	if err := txAdapter.CaptureSchemaSnapshot(ctx, "test-snapshot-001", domain.SnapshotOptions{}); err != nil {
 		t.Fatalf("CaptureSchemaSnapshot in tx: %v", err)
 	}
 
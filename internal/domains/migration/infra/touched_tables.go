@@ -0,0 +1,270 @@
+package infra
+
+import (
+	"os"
+	"strings"
+
+	jokadb "github.com/apsdsm/joka/db"
+)
+
+// ReadTouchedTables reads a migration file and returns the tables whose DDL
+// its statements may change (see TouchedTables). Every `-- joka:if` branch is
+// included, so the result covers whichever branch the migration ran.
+func ReadTouchedTables(path string) ([]string, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	tables, known := TouchedTables(string(content))
+	return tables, known, nil
+}
+
+// TouchedTables returns the tables whose DDL the statements in sqlContent may
+// change: tables that are created, altered, dropped or renamed, and tables an
+// index is created on. known is false when a statement's effect on the schema
+// can't be read from its text (a DO block, a CALL, DROP INDEX without ON, ...);
+// the caller must then assume any table changed.
+//
+// Names are returned unquoted and without a schema qualifier. Data statements
+// and objects that don't appear in table DDL (functions, triggers, sequences)
+// touch nothing.
+func TouchedTables(sqlContent string) ([]string, bool) {
+	seen := make(map[string]bool)
+	var tables []string
+	add := func(names ...string) {
+		for _, n := range names {
+			if n != "" && !seen[n] {
+				seen[n] = true
+				tables = append(tables, n)
+			}
+		}
+	}
+
+	for _, stmt := range jokadb.SplitSQLStatements(sqlContent) {
+		names, known := statementTables(sqlTokens(stmt))
+		if !known {
+			return nil, false
+		}
+		add(names...)
+	}
+	return tables, true
+}
+
+// statementTables applies the TouchedTables rules to one tokenized statement.
+func statementTables(toks []sqlToken) ([]string, bool) {
+	if len(toks) == 0 {
+		return nil, true
+	}
+
+	switch toks[0].keyword() {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "TRUNCATE", "SET", "BEGIN", "START", "COMMIT", "LOCK", "UNLOCK", "COMMENT", "GRANT", "REVOKE":
+		return nil, true
+
+	case "CREATE":
+		for i := 1; i < len(toks); i++ {
+			switch toks[i].keyword() {
+			case "TABLE", "VIEW":
+				i = skipKeywords(toks, i+1, "IF", "NOT", "EXISTS")
+				name, _ := readTableName(toks, i)
+				return []string{name}, name != ""
+			case "INDEX":
+				// CREATE [UNIQUE] INDEX [CONCURRENTLY] [IF NOT EXISTS] [name] ON [ONLY] table
+				for j := i + 1; j < len(toks); j++ {
+					if toks[j].keyword() == "ON" {
+						name, _ := readTableName(toks, skipKeywords(toks, j+1, "ONLY"))
+						return []string{name}, name != ""
+					}
+				}
+				return nil, false
+			case "FUNCTION", "PROCEDURE", "TRIGGER", "SEQUENCE", "EXTENSION", "TYPE", "ROLE", "USER":
+				return nil, true
+			case "OR", "REPLACE", "TEMPORARY", "TEMP", "UNLOGGED", "GLOBAL", "LOCAL", "UNIQUE", "FULLTEXT", "SPATIAL":
+				continue
+			default:
+				return nil, false
+			}
+		}
+		return nil, false
+
+	case "ALTER":
+		if len(toks) < 2 {
+			return nil, false
+		}
+		switch toks[1].keyword() {
+		case "TABLE", "VIEW":
+			name, _ := readTableName(toks, skipKeywords(toks, 2, "IF", "EXISTS", "ONLY"))
+			return []string{name}, name != ""
+		}
+		return nil, false
+
+	case "DROP":
+		if len(toks) < 2 {
+			return nil, false
+		}
+		switch toks[1].keyword() {
+		case "TABLE", "VIEW", "TEMPORARY":
+			i := skipKeywords(toks, 1, "TEMPORARY", "TABLE", "VIEW", "IF", "EXISTS")
+			return readTableNameList(toks, i)
+		case "INDEX":
+			// MySQL names the table; PostgreSQL doesn't, so the table is unknown.
+			for j := 2; j < len(toks); j++ {
+				if toks[j].keyword() == "ON" {
+					name, _ := readTableName(toks, j+1)
+					return []string{name}, name != ""
+				}
+			}
+			return nil, false
+		case "FUNCTION", "PROCEDURE", "TRIGGER", "SEQUENCE", "EXTENSION", "TYPE", "ROLE", "USER":
+			// CASCADE can take columns or constraints with it.
+			for _, t := range toks[2:] {
+				if t.keyword() == "CASCADE" {
+					return nil, false
+				}
+			}
+			return nil, true
+		}
+		return nil, false
+
+	case "RENAME":
+		// RENAME TABLE a TO b [, c TO d]
+		if len(toks) < 2 || toks[1].keyword() != "TABLE" {
+			return nil, false
+		}
+		var names []string
+		for i := 2; i < len(toks); {
+			name, next := readTableName(toks, i)
+			if name == "" {
+				return nil, false
+			}
+			names = append(names, name)
+			i = next
+			if i < len(toks) && (toks[i].keyword() == "TO" || toks[i].text == ",") {
+				i++
+			}
+		}
+		return names, len(names) > 0
+	}
+
+	return nil, false
+}
+
+// readTableNameList reads "a, b, c" from toks[i:], stopping at the first
+// token that isn't part of the list (RESTRICT, CASCADE).
+func readTableNameList(toks []sqlToken, i int) ([]string, bool) {
+	var names []string
+	for i < len(toks) {
+		name, next := readTableName(toks, i)
+		if name == "" {
+			break
+		}
+		names = append(names, name)
+		i = next
+		if i >= len(toks) || toks[i].text != "," {
+			break
+		}
+		i++
+	}
+	return names, len(names) > 0
+}
+
+// readTableName reads a possibly schema-qualified name starting at toks[i]
+// and returns its last part and the index after it. An empty name means
+// toks[i] isn't a name.
+func readTableName(toks []sqlToken, i int) (string, int) {
+	name := ""
+	for i < len(toks) && toks[i].isName() {
+		name = toks[i].text
+		i++
+		if i+1 < len(toks) && toks[i].text == "." && toks[i+1].isName() {
+			i++
+			continue
+		}
+		break
+	}
+	return name, i
+}
+
+// skipKeywords advances i past any of the given keywords.
+func skipKeywords(toks []sqlToken, i int, keywords ...string) int {
+	for i < len(toks) {
+		matched := false
+		for _, k := range keywords {
+			if toks[i].keyword() == k {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// sqlToken is a word, a quoted identifier, or a single punctuation character.
+type sqlToken struct {
+	text   string
+	quoted bool
+}
+
+func (t sqlToken) isName() bool {
+	if t.quoted {
+		return true
+	}
+	c := t.text[0]
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// keyword returns the upper-cased text of an unquoted word, or "" for quoted
+// identifiers and punctuation.
+func (t sqlToken) keyword() string {
+	if t.quoted || !t.isName() {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// sqlTokens splits the leading part of a statement into tokens, skipping
+// comments and whitespace. Tokenizing stops at the first string literal or
+// opening parenthesis; nothing TouchedTables looks at comes after one.
+func sqlTokens(stmt string) []sqlToken {
+	var toks []sqlToken
+	for i := 0; i < len(stmt); {
+		c := stmt[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(stmt) && stmt[i+1] == '-':
+			for i < len(stmt) && stmt[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(stmt) && stmt[i+1] == '*':
+			end := strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				return toks
+			}
+			i += end + 4
+		case c == '`' || c == '"':
+			end := strings.IndexByte(stmt[i+1:], c)
+			if end < 0 {
+				return toks
+			}
+			toks = append(toks, sqlToken{text: stmt[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case c == '\'' || c == '(' || c == '$':
+			return toks
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(stmt) && (stmt[j] == '_' || stmt[j] == '$' || stmt[j] >= 'a' && stmt[j] <= 'z' || stmt[j] >= 'A' && stmt[j] <= 'Z' || stmt[j] >= '0' && stmt[j] <= '9') {
+				j++
+			}
+			toks = append(toks, sqlToken{text: stmt[i:j]})
+			i = j
+		default:
+			toks = append(toks, sqlToken{text: stmt[i : i+1]})
+			i++
+		}
+	}
+	return toks
+}
@@ -0,0 +1,65 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTouchedTables(t *testing.T) {
+	cases := []struct {
+		name   string
+		sql    string
+		tables []string
+		known  bool
+	}{
+		{"it reads CREATE TABLE", "CREATE TABLE IF NOT EXISTS users (id INT);", []string{"users"}, true},
+		{"it reads ALTER TABLE", "ALTER TABLE ONLY public.orders ADD COLUMN total INT;", []string{"orders"}, true},
+		{"it reads quoted names", "ALTER TABLE `order items` ADD x INT; ALTER TABLE \"Users\" DROP y;", []string{"order items", "Users"}, true},
+		{"it reads every dropped table", "DROP TABLE IF EXISTS a, b CASCADE;", []string{"a", "b"}, true},
+		{"it reads both sides of RENAME TABLE", "RENAME TABLE a TO b, c TO d;", []string{"a", "b", "c", "d"}, true},
+		{"it reads the table an index is created on", "CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx ON ONLY users (email);", []string{"users"}, true},
+		{"it reads DROP INDEX ... ON", "DROP INDEX idx ON users;", []string{"users"}, true},
+		{"it reads views", "CREATE OR REPLACE VIEW active_users AS SELECT * FROM users;", []string{"active_users"}, true},
+		{"it ignores data statements", "INSERT INTO users (id) VALUES (1); UPDATE users SET id = 2; TRUNCATE users;", nil, true},
+		{"it ignores comments", "-- ALTER TABLE nope\n/* DROP TABLE nope; */ CREATE TABLE yes (id INT);", []string{"yes"}, true},
+		{"it deduplicates", "ALTER TABLE users ADD a INT; ALTER TABLE users ADD b INT;", []string{"users"}, true},
+		{"it ignores functions and triggers", "CREATE OR REPLACE FUNCTION f() RETURNS int AS $$ SELECT 1 $$ LANGUAGE sql; DROP TRIGGER t;", nil, true},
+		{"it gives up on DROP INDEX without ON", "DROP INDEX idx;", nil, false},
+		{"it gives up on DO blocks", "ALTER TABLE users ADD a INT; DO $$ BEGIN END $$;", nil, false},
+		{"it gives up on CALL", "CALL make_tables();", nil, false},
+		{"it gives up on CASCADE drops of other objects", "DROP TYPE mood CASCADE;", nil, false},
+		{"it gives up on ALTER INDEX", "ALTER INDEX idx RENAME TO idx2;", nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tables, known := TouchedTables(c.sql)
+			if known != c.known {
+				t.Fatalf("expected known=%v, got %v", c.known, known)
+			}
+			if !reflect.DeepEqual(tables, c.tables) {
+				t.Errorf("expected %q, got %q", c.tables, tables)
+			}
+		})
+	}
+}
+
+func TestReadTouchedTables(t *testing.T) {
+	t.Run("it includes every conditional branch", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "240101000000_m.sql")
+		content := "-- joka:if driver=postgres\nALTER TABLE a ADD x INT;\n-- joka:else\nALTER TABLE b ADD x INT;\n-- joka:end\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		tables, known, err := ReadTouchedTables(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !known || !reflect.DeepEqual(tables, []string{"a", "b"}) {
+			t.Errorf("expected [a b] known, got %q known=%v", tables, known)
+		}
+	})
+}
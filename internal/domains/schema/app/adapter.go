@@ -0,0 +1,26 @@
+package app
+
+import (
+	"context"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// CatalogAdapter reads what the DDL in a snapshot doesn't carry from the
+// database catalog.
+type CatalogAdapter interface {
+	// GetComments returns table and column comments (information_schema on
+	// MySQL, pg_description on PostgreSQL).
+	GetComments(ctx context.Context) (domain.Comments, error)
+	// GetEnums returns the enum type of each enum column, by table and
+	// column. Only PostgreSQL has any: MySQL ENUM values are part of the
+	// column type in the DDL.
+	GetEnums(ctx context.Context) (map[string]map[string]domain.Enum, error)
+}
+
+// SnapshotReader is the part of the migration domain's adapter the history
+// needs: the stored snapshots, oldest first, and the {table: DDL} JSON of each.
+type SnapshotReader interface {
+	ListSnapshotIndexes(ctx context.Context) ([]string, error)
+	GetSchemaSnapshot(ctx context.Context, migrationIndex string) (string, error)
+}
@@ -0,0 +1,21 @@
+package app
+
+import "github.com/apsdsm/joka/internal/domains/schema/domain"
+
+// ApplyComments fills in table and column comments from the catalog. They
+// replace comments parsed from the DDL, which PostgreSQL's reconstruction
+// doesn't carry at all. Comments for tables or columns not in tables are
+// ignored.
+func ApplyComments(tables []domain.Table, comments domain.Comments) {
+	for i := range tables {
+		t := &tables[i]
+		if c, ok := comments.Tables[t.Name]; ok {
+			t.Comment = c
+		}
+		for j := range t.Columns {
+			if c, ok := comments.Columns[t.Name][t.Columns[j].Name]; ok {
+				t.Columns[j].Comment = c
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package app
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// nonIdent matches characters that aren't safe in Mermaid/PlantUML names.
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// RenderDiagram renders tables as an entity-relationship diagram. Foreign
+// keys become edges from the referenced table to the referencing one; keys
+// pointing at tables outside the set are left out.
+func RenderDiagram(tables []domain.Table, format domain.DiagramFormat) string {
+	switch format {
+	case domain.DiagramDot:
+		return renderDot(tables)
+	case domain.DiagramPlantUML:
+		return renderPlantUML(tables)
+	default:
+		return renderMermaid(tables)
+	}
+}
+
+// relation is one foreign key edge, with the cardinality read from the DDL:
+// the child may have no parent when its key columns are nullable, and has
+// at most one row per parent when they are unique.
+type relation struct {
+	parent, child string
+	fk            domain.ForeignKey
+	optional      bool
+	oneToOne      bool
+}
+
+func relations(tables []domain.Table) []relation {
+	present := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		present[t.Name] = true
+	}
+
+	var rels []relation
+	for _, t := range tables {
+		for _, fk := range t.ForeignKeys {
+			if !present[fk.RefTable] {
+				continue
+			}
+			optional := false
+			for _, name := range fk.Columns {
+				if c, ok := t.Column(name); ok && c.Nullable {
+					optional = true
+				}
+			}
+			rels = append(rels, relation{
+				parent:   fk.RefTable,
+				child:    t.Name,
+				fk:       fk,
+				optional: optional,
+				oneToOne: t.IsUnique(fk.Columns),
+			})
+		}
+	}
+	return rels
+}
+
+// columnKeys returns the PK/FK/UK markers of a column.
+func columnKeys(t domain.Table, c domain.Column) []string {
+	var keys []string
+	if t.IsPrimaryKey(c.Name) {
+		keys = append(keys, "PK")
+	}
+	if t.IsForeignKey(c.Name) {
+		keys = append(keys, "FK")
+	}
+	if !t.IsPrimaryKey(c.Name) && t.IsUnique([]string{c.Name}) {
+		keys = append(keys, "UK")
+	}
+	return keys
+}
+
+// shortType trims enum and set value lists, which would swamp a diagram.
+func shortType(typ string) string {
+	lower := strings.ToLower(typ)
+	for _, prefix := range []string{"enum(", "set("} {
+		if strings.HasPrefix(lower, prefix) {
+			return lower[:len(prefix)-1]
+		}
+	}
+	return typ
+}
+
+func identifier(name string) string {
+	id := nonIdent.ReplaceAllString(name, "_")
+	if id == "" || id[0] >= '0' && id[0] <= '9' {
+		id = "_" + id
+	}
+	return id
+}
+
+func renderMermaid(tables []domain.Table) string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+
+	for _, t := range tables {
+		if len(t.Columns) == 0 {
+			fmt.Fprintf(&b, "    %s\n", identifier(t.Name))
+			continue
+		}
+		fmt.Fprintf(&b, "    %s {\n", identifier(t.Name))
+		for _, c := range t.Columns {
+			typ := strings.NewReplacer(" ", "_", ",", "-", "'", "", `"`, "").Replace(shortType(c.Type))
+			if typ == "" {
+				typ = "unknown"
+			}
+			line := fmt.Sprintf("        %s %s", typ, identifier(c.Name))
+			if keys := columnKeys(t, c); len(keys) > 0 {
+				line += " " + strings.Join(keys, ", ")
+			}
+			if c.Comment != "" {
+				line += fmt.Sprintf(" %q", strings.ReplaceAll(c.Comment, `"`, "'"))
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("    }\n")
+	}
+
+	for _, r := range relations(tables) {
+		left, right := "||", "o{"
+		if r.optional {
+			left = "|o"
+		}
+		if r.oneToOne {
+			right = "o|"
+		}
+		fmt.Fprintf(&b, "    %s %s--%s %s : \"%s\"\n",
+			identifier(r.parent), left, right, identifier(r.child), strings.Join(r.fk.Columns, ", "))
+	}
+
+	return b.String()
+}
+
+func renderDot(tables []domain.Table) string {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=plaintext, fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [arrowhead=crow, arrowtail=none, dir=both];\n")
+
+	for _, t := range tables {
+		title := "<b>" + html.EscapeString(t.Name) + "</b>"
+		if t.View {
+			title += " <i>(view)</i>"
+		}
+		fmt.Fprintf(&b, "  %q [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\">\n", t.Name)
+		fmt.Fprintf(&b, "    <tr><td bgcolor=\"lightgrey\">%s</td></tr>\n", title)
+		for _, c := range t.Columns {
+			text := html.EscapeString(c.Name + " : " + shortType(c.Type))
+			if keys := columnKeys(t, c); len(keys) > 0 {
+				text += " (" + strings.Join(keys, ", ") + ")"
+			}
+			if t.IsPrimaryKey(c.Name) {
+				text = "<u>" + text + "</u>"
+			}
+			fmt.Fprintf(&b, "    <tr><td port=%q align=\"left\">%s</td></tr>\n", c.Name, text)
+		}
+		b.WriteString("  </table>>];\n")
+	}
+
+	for _, r := range relations(tables) {
+		from := fmt.Sprintf("%q", r.child)
+		if len(r.fk.Columns) > 0 {
+			from += fmt.Sprintf(":%q", r.fk.Columns[0])
+		}
+		to := fmt.Sprintf("%q", r.parent)
+		if len(r.fk.RefColumns) > 0 {
+			to += fmt.Sprintf(":%q", r.fk.RefColumns[0])
+		}
+		var attrs []string
+		if r.oneToOne {
+			attrs = append(attrs, "arrowhead=tee")
+		}
+		if r.optional {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "  %s -> %s [%s];\n", to, from, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s;\n", to, from)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func renderPlantUML(tables []domain.Table) string {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	b.WriteString("hide circle\n")
+	b.WriteString("skinparam linetype ortho\n")
+
+	for _, t := range tables {
+		stereotype := ""
+		if t.View {
+			stereotype = " <<view>>"
+		}
+		fmt.Fprintf(&b, "\nentity %q as %s%s {\n", t.Name, identifier(t.Name), stereotype)
+
+		var keyCols, otherCols []domain.Column
+		for _, c := range t.Columns {
+			if t.IsPrimaryKey(c.Name) {
+				keyCols = append(keyCols, c)
+			} else {
+				otherCols = append(otherCols, c)
+			}
+		}
+		writeCol := func(c domain.Column) {
+			line := "  "
+			if !c.Nullable {
+				line += "* "
+			}
+			line += c.Name + " : " + shortType(c.Type)
+			for _, k := range columnKeys(t, c) {
+				line += " <<" + k + ">>"
+			}
+			b.WriteString(line + "\n")
+		}
+		for _, c := range keyCols {
+			writeCol(c)
+		}
+		if len(keyCols) > 0 && len(otherCols) > 0 {
+			b.WriteString("  --\n")
+		}
+		for _, c := range otherCols {
+			writeCol(c)
+		}
+		b.WriteString("}\n")
+	}
+
+	if rels := relations(tables); len(rels) > 0 {
+		b.WriteString("\n")
+		for _, r := range rels {
+			left, right := "||", "o{"
+			if r.optional {
+				left = "|o"
+			}
+			if r.oneToOne {
+				right = "o|"
+			}
+			fmt.Fprintf(&b, "%s %s--%s %s : %s\n",
+				identifier(r.parent), left, right, identifier(r.child), strings.Join(r.fk.Columns, ", "))
+		}
+	}
+
+	b.WriteString("@enduml\n")
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+func diagramTables() []domain.Table {
+	return ParseSchema(map[string]string{
+		"users": "CREATE TABLE `users` (\n" +
+			"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+			"  `email` varchar(255) NOT NULL COMMENT 'login \"name\"',\n" +
+			"  `role` enum('admin','member') NOT NULL,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  UNIQUE KEY `uq_email` (`email`)\n" +
+			") ENGINE=InnoDB",
+		"orders": "CREATE TABLE `orders` (\n" +
+			"  `id` int NOT NULL,\n" +
+			"  `user_id` int NOT NULL,\n" +
+			"  `referrer_id` int DEFAULT NULL,\n" +
+			"  `audit_id` int DEFAULT NULL,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),\n" +
+			"  CONSTRAINT `fk_referrer` FOREIGN KEY (`referrer_id`) REFERENCES `users` (`id`),\n" +
+			"  CONSTRAINT `fk_audit` FOREIGN KEY (`audit_id`) REFERENCES `audits` (`id`)\n" +
+			") ENGINE=InnoDB",
+		"profiles": "CREATE TABLE `profiles` (\n" +
+			"  `user_id` int NOT NULL,\n" +
+			"  PRIMARY KEY (`user_id`),\n" +
+			"  CONSTRAINT `fk_profile_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n" +
+			") ENGINE=InnoDB",
+	})
+}
+
+func assertContains(t *testing.T, out string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, out)
+		}
+	}
+}
+
+func TestRenderDiagram(t *testing.T) {
+	t.Run("it renders Mermaid with keys and cardinality", func(t *testing.T) {
+		out := RenderDiagram(diagramTables(), domain.DiagramMermaid)
+
+		assertContains(t, out,
+			"erDiagram\n",
+			"    users {\n",
+			"        int id PK\n",
+			"        varchar(255) email UK \"login 'name'\"\n",
+			"        enum role\n",
+			"        int user_id FK\n",
+			"    users ||--o{ orders : \"user_id\"\n",
+			"    users |o--o{ orders : \"referrer_id\"\n",
+			"    users ||--o| profiles : \"user_id\"\n",
+		)
+		if strings.Contains(out, "audits") {
+			t.Errorf("expected keys to tables outside the diagram to be dropped, got:\n%s", out)
+		}
+	})
+
+	t.Run("it renders DOT with column ports", func(t *testing.T) {
+		out := RenderDiagram(diagramTables(), domain.DiagramDot)
+
+		assertContains(t, out,
+			"digraph schema {\n",
+			`<tr><td port="email" align="left">email : varchar(255) (UK)</td></tr>`,
+			`<tr><td port="id" align="left"><u>id : int (PK)</u></td></tr>`,
+			"  \"users\":\"id\" -> \"orders\":\"user_id\";\n",
+			"  \"users\":\"id\" -> \"orders\":\"referrer_id\" [style=dashed];\n",
+			"  \"users\":\"id\" -> \"profiles\":\"user_id\" [arrowhead=tee];\n",
+		)
+	})
+
+	t.Run("it renders PlantUML entities", func(t *testing.T) {
+		out := RenderDiagram(diagramTables(), domain.DiagramPlantUML)
+
+		assertContains(t, out,
+			"@startuml\n",
+			"entity \"orders\" as orders {\n  * id : int <<PK>>\n  --\n  * user_id : int <<FK>>\n  referrer_id : int <<FK>>\n",
+			"users ||--o{ orders : user_id\n",
+			"users |o--o{ orders : referrer_id\n",
+			"@enduml\n",
+		)
+	})
+}
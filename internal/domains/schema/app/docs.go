@@ -0,0 +1,361 @@
+package app
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// unsafeFileChars matches characters kept out of page file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// TablesDir is the subdirectory of the output directory holding one page per
+// table. Pages there that no longer match a table can be removed.
+const TablesDir = "tables"
+
+// DocFile is one generated page.
+type DocFile struct {
+	Path    string // relative to the output directory, slash separated
+	Content string
+}
+
+// DocsInput is what the documentation is generated from.
+type DocsInput struct {
+	Tables  []domain.Table
+	History []domain.TableHistory
+	Source  string // where Tables came from, e.g. "live database"
+}
+
+// RenderDocs renders an index page, a page per table and a history page. The
+// output only depends on the input, so regenerating an unchanged schema gives
+// identical files.
+func RenderDocs(in DocsInput, format domain.DocsFormat) []DocFile {
+	pages := buildDocPages(in)
+	files := make([]DocFile, 0, len(pages))
+	for _, p := range pages {
+		var content string
+		if format == domain.DocsHTML {
+			content = renderPageHTML(p)
+		} else {
+			content = renderPageMarkdown(p)
+		}
+		files = append(files, DocFile{Path: p.path + format.Ext(), Content: content})
+	}
+	return files
+}
+
+// TablePagePath returns the path of a table's page, without extension.
+func TablePagePath(table string) string {
+	return TablesDir + "/" + unsafeFileChars.ReplaceAllString(table, "_")
+}
+
+// docPage is a format-independent page: a title, some paragraphs and
+// sections holding a table each.
+type docPage struct {
+	path     string // without extension
+	title    string
+	intro    [][]span // paragraphs
+	sections []docSection
+}
+
+type docSection struct {
+	heading string
+	empty   string // shown instead of the table when there are no rows
+	header  []string
+	rows    [][]span // one span per cell
+}
+
+// span is a piece of text, optionally code-formatted or linking to another
+// page (a path without extension).
+type span struct {
+	text string
+	code bool
+	link string
+}
+
+func text(s string) span { return span{text: s} }
+func code(s string) span { return span{text: s, code: true} }
+
+func buildDocPages(in DocsInput) []docPage {
+	history := make(map[string]domain.TableHistory, len(in.History))
+	for _, h := range in.History {
+		history[h.Table] = h
+	}
+	present := make(map[string]bool, len(in.Tables))
+	for _, t := range in.Tables {
+		present[t.Name] = true
+	}
+
+	pages := []docPage{indexPage(in), historyPage(in.History, present)}
+	for _, t := range in.Tables {
+		h, ok := history[t.Name]
+		pages = append(pages, tablePage(t, in.Tables, present, h, ok))
+	}
+	return pages
+}
+
+func indexPage(in DocsInput) docPage {
+	p := docPage{
+		path:  "index",
+		title: "Database schema",
+		intro: [][]span{
+			{text(fmt.Sprintf("Generated by joka from the %s. %d table(s). See also the ", in.Source, len(in.Tables))), {text: "schema history", link: "history"}, text(".")},
+		},
+	}
+	s := docSection{heading: "Tables", empty: "No tables.", header: []string{"Table", "Columns", "Comment"}}
+	for _, t := range in.Tables {
+		name := t.Name
+		if t.View {
+			name += " (view)"
+		}
+		s.rows = append(s.rows, []span{
+			{text: name, link: TablePagePath(t.Name)},
+			text(fmt.Sprint(len(t.Columns))),
+			text(t.Comment),
+		})
+	}
+	p.sections = append(p.sections, s)
+	return p
+}
+
+func historyPage(history []domain.TableHistory, present map[string]bool) docPage {
+	p := docPage{path: "history", title: "Schema history"}
+	p.intro = [][]span{
+		{text("Which migration introduced, last altered or dropped each table, found by comparing consecutive schema snapshots. " +
+			"A table already in the oldest snapshot may be older than that migration. Back to the "), {text: "schema", link: "index"}, text(".")},
+	}
+
+	s := docSection{
+		heading: "Tables",
+		empty:   "No snapshots are stored.",
+		header:  []string{"Table", "Introduced", "Last altered", "Dropped"},
+	}
+	for _, h := range history {
+		name := span{text: h.Table}
+		if present[h.Table] && h.Dropped == "" {
+			name.link = TablePagePath(h.Table)
+		}
+		s.rows = append(s.rows, []span{name, introducedSpan(h), optionalCode(h.LastAltered), optionalCode(h.Dropped)})
+	}
+	p.sections = append(p.sections, s)
+	return p
+}
+
+func tablePage(t domain.Table, all []domain.Table, present map[string]bool, h domain.TableHistory, hasHistory bool) docPage {
+	p := docPage{path: TablePagePath(t.Name), title: t.Name}
+	if t.View {
+		p.intro = append(p.intro, []span{text("View.")})
+	}
+	if t.Comment != "" {
+		p.intro = append(p.intro, []span{text(t.Comment)})
+	}
+	p.intro = append(p.intro, []span{text("Back to the "), {text: "schema", link: "index"}, text(".")})
+
+	columns := docSection{
+		heading: "Columns",
+		empty:   "No columns recorded.",
+		header:  []string{"Column", "Type", "Nullable", "Default", "Key", "Comment"},
+	}
+	for _, c := range t.Columns {
+		nullable := "no"
+		if c.Nullable {
+			nullable = "yes"
+		}
+		def := optionalCode(c.Default)
+		if c.AutoIncrement && c.Default == "" {
+			def = text("auto increment")
+		}
+		columns.rows = append(columns.rows, []span{
+			code(c.Name), code(c.Type), text(nullable), def, text(strings.Join(columnKeys(t, c), ", ")), text(c.Comment),
+		})
+	}
+
+	indexes := docSection{heading: "Indexes", empty: "None.", header: []string{"Name", "Columns", "Unique"}}
+	if len(t.PrimaryKey) > 0 {
+		indexes.rows = append(indexes.rows, []span{text("PRIMARY"), code(strings.Join(t.PrimaryKey, ", ")), text("yes")})
+	}
+	for _, idx := range t.Indexes {
+		unique := "no"
+		if idx.Unique {
+			unique = "yes"
+		}
+		indexes.rows = append(indexes.rows, []span{optionalCode(idx.Name), code(strings.Join(idx.Columns, ", ")), text(unique)})
+	}
+
+	outgoing := docSection{
+		heading: "Foreign keys",
+		empty:   "None.",
+		header:  []string{"Name", "Columns", "References", "On delete", "On update"},
+	}
+	for _, fk := range t.ForeignKeys {
+		outgoing.rows = append(outgoing.rows, []span{
+			optionalCode(fk.Name),
+			code(strings.Join(fk.Columns, ", ")),
+			tableRef(fk.RefTable, fk.RefColumns, present),
+			text(fk.OnDelete),
+			text(fk.OnUpdate),
+		})
+	}
+
+	incoming := docSection{
+		heading: "Referenced by",
+		empty:   "None.",
+		header:  []string{"Table", "Referenced columns", "Foreign key"},
+	}
+	for _, other := range all {
+		for _, fk := range other.ForeignKeys {
+			if fk.RefTable == t.Name {
+				incoming.rows = append(incoming.rows, []span{
+					tableRef(other.Name, fk.Columns, present),
+					code(strings.Join(fk.RefColumns, ", ")),
+					optionalCode(fk.Name),
+				})
+			}
+		}
+	}
+
+	hist := docSection{heading: "History", empty: "No snapshot records this table."}
+	if hasHistory {
+		hist.header = []string{"Introduced", "Last altered"}
+		hist.rows = [][]span{{introducedSpan(h), optionalCode(h.LastAltered)}}
+	}
+
+	p.sections = append(p.sections, columns, indexes, outgoing, incoming, hist)
+	return p
+}
+
+// tableRef is "table (columns)", linked when the table has a page.
+func tableRef(table string, columns []string, present map[string]bool) span {
+	s := span{text: table}
+	if len(columns) > 0 {
+		s.text += " (" + strings.Join(columns, ", ") + ")"
+	}
+	if present[table] {
+		s.link = TablePagePath(table)
+	}
+	return s
+}
+
+func introducedSpan(h domain.TableHistory) span {
+	if h.Baseline {
+		return text(h.Introduced + " or earlier")
+	}
+	return code(h.Introduced)
+}
+
+func optionalCode(s string) span {
+	if s == "" {
+		return text("")
+	}
+	return code(s)
+}
+
+// relativeLink returns the path from page from to page to, with extension.
+func relativeLink(from, to, ext string) string {
+	rel, err := filepath.Rel(path.Dir(from), to)
+	if err != nil {
+		return to + ext
+	}
+	return filepath.ToSlash(rel) + ext
+}
+
+func renderPageMarkdown(p docPage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", p.title)
+	for _, para := range p.intro {
+		b.WriteString("\n" + markdownSpans(p.path, para) + "\n")
+	}
+
+	for _, s := range p.sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.heading)
+		if len(s.rows) == 0 {
+			b.WriteString(s.empty + "\n")
+			continue
+		}
+		b.WriteString("| " + strings.Join(s.header, " | ") + " |\n")
+		b.WriteString("|" + strings.Repeat("---|", len(s.header)) + "\n")
+		for _, row := range s.rows {
+			cells := make([]string, len(row))
+			for i, c := range row {
+				cells[i] = markdownSpans(p.path, []span{c})
+			}
+			b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		}
+	}
+	return b.String()
+}
+
+func markdownSpans(page string, spans []span) string {
+	var b strings.Builder
+	for _, s := range spans {
+		t := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(s.text)
+		if s.code && t != "" && !strings.Contains(t, "`") {
+			t = "`" + t + "`"
+		}
+		if s.link != "" {
+			t = fmt.Sprintf("[%s](%s)", t, relativeLink(page, s.link, domain.DocsMarkdown.Ext()))
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+const htmlStyle = `body { font-family: sans-serif; margin: 2em auto; max-width: 60em; padding: 0 1em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; }
+code { font-size: 0.9em; }`
+
+func renderPageHTML(p docPage) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", html.EscapeString(p.title), htmlStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(p.title))
+	for _, para := range p.intro {
+		fmt.Fprintf(&b, "<p>%s</p>\n", htmlSpans(p.path, para))
+	}
+
+	for _, s := range p.sections {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(s.heading))
+		if len(s.rows) == 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(s.empty))
+			continue
+		}
+		b.WriteString("<table>\n<tr>")
+		for _, h := range s.header {
+			fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(h))
+		}
+		b.WriteString("</tr>\n")
+		for _, row := range s.rows {
+			b.WriteString("<tr>")
+			for _, c := range row {
+				fmt.Fprintf(&b, "<td>%s</td>", htmlSpans(p.path, []span{c}))
+			}
+			b.WriteString("</tr>\n")
+		}
+		b.WriteString("</table>\n")
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+func htmlSpans(page string, spans []span) string {
+	var b strings.Builder
+	for _, s := range spans {
+		t := strings.ReplaceAll(html.EscapeString(s.text), "\n", "<br>")
+		if s.code && t != "" {
+			t = "<code>" + t + "</code>"
+		}
+		if s.link != "" {
+			t = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(relativeLink(page, s.link, domain.DocsHTML.Ext())), t)
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
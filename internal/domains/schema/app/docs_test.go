@@ -0,0 +1,101 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+func docsInput() DocsInput {
+	tables := ParseSchema(map[string]string{
+		"users": "CREATE TABLE users (\n" +
+			"  id integer NOT NULL DEFAULT nextval('users_id_seq'::regclass),\n" +
+			"  email character varying(255) NOT NULL,\n" +
+			"  CONSTRAINT users_pkey PRIMARY KEY (id)\n" +
+			")\n" +
+			"CREATE UNIQUE INDEX users_email_key ON public.users USING btree (email);",
+		"orders": "CREATE TABLE orders (\n" +
+			"  id integer NOT NULL,\n" +
+			"  user_id integer,\n" +
+			"  note text,\n" +
+			"  CONSTRAINT orders_pkey PRIMARY KEY (id),\n" +
+			"  CONSTRAINT orders_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE\n" +
+			")",
+	})
+	ApplyComments(tables, domain.Comments{
+		Tables:  map[string]string{"orders": "Customer orders"},
+		Columns: map[string]map[string]string{"orders": {"note": "Free text | from checkout", "gone": "ignored"}},
+	})
+	return DocsInput{
+		Tables: tables,
+		History: []domain.TableHistory{
+			{Table: "users", Introduced: "240101000000", Baseline: true},
+			{Table: "orders", Introduced: "240102000000", LastAltered: "240105000000"},
+			{Table: "legacy", Introduced: "240101000000", Baseline: true, Dropped: "240103000000"},
+		},
+		Source: "snapshot 240105000000",
+	}
+}
+
+func docFile(t *testing.T, files []DocFile, path string) string {
+	t.Helper()
+	for _, f := range files {
+		if f.Path == path {
+			return f.Content
+		}
+	}
+	t.Fatalf("no file %s", path)
+	return ""
+}
+
+func TestRenderDocs(t *testing.T) {
+	t.Run("it writes an index, a history and a page per table", func(t *testing.T) {
+		files := RenderDocs(docsInput(), domain.DocsMarkdown)
+
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		if got := strings.Join(paths, ","); got != "index.md,history.md,tables/orders.md,tables/users.md" {
+			t.Errorf("unexpected files %s", got)
+		}
+
+		assertContains(t, docFile(t, files, "index.md"),
+			"Generated by joka from the snapshot 240105000000. 2 table(s). See also the [schema history](history.md).",
+			"| [orders](tables/orders.md) | 3 | Customer orders |",
+		)
+		assertContains(t, docFile(t, files, "history.md"),
+			"| [users](tables/users.md) | 240101000000 or earlier |  |  |",
+			"| legacy | 240101000000 or earlier |  | `240103000000` |",
+		)
+	})
+
+	t.Run("it documents columns, indexes and keys in both directions", func(t *testing.T) {
+		files := RenderDocs(docsInput(), domain.DocsMarkdown)
+
+		assertContains(t, docFile(t, files, "tables/orders.md"),
+			"# orders\n\nCustomer orders\n\nBack to the [schema](../index.md).\n",
+			"| `user_id` | `integer` | yes |  | FK |  |",
+			"| `note` | `text` | yes |  |  | Free text \\| from checkout |",
+			"| `orders_user_id_fkey` | `user_id` | [users (id)](users.md) | CASCADE |  |",
+			"| `240102000000` | `240105000000` |",
+		)
+		assertContains(t, docFile(t, files, "tables/users.md"),
+			"| `id` | `integer` | no | `nextval('users_id_seq'::regclass)` | PK |  |",
+			"| PRIMARY | `id` | yes |",
+			"| `users_email_key` | `email` | yes |",
+			"## Referenced by\n\n| Table | Referenced columns | Foreign key |\n|---|---|---|\n| [orders (user_id)](orders.md) | `id` | `orders_user_id_fkey` |",
+		)
+	})
+
+	t.Run("it renders the same pages as HTML", func(t *testing.T) {
+		files := RenderDocs(docsInput(), domain.DocsHTML)
+
+		assertContains(t, docFile(t, files, "tables/orders.html"),
+			"<title>orders</title>",
+			`<td><a href="users.html">users (id)</a></td>`,
+			`<p>Back to the <a href="../index.html">schema</a>.</p>`,
+		)
+	})
+}
@@ -0,0 +1,279 @@
+package app
+
+import (
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// GoFileName is the file `joka schema gen go` writes in the output directory.
+const GoFileName = "schema_gen.go"
+
+// goInitialisms are name parts written in capitals, as golint expects.
+var goInitialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "SKU": true,
+	"SQL": true, "SSH": true, "TLS": true, "TTL": true, "UI": true, "UID": true, "URI": true,
+	"URL": true, "UTF8": true, "UUID": true, "XML": true,
+}
+
+// typeParams matches a type's (length) or (precision,scale).
+var typeParams = regexp.MustCompile(`\(.*\)`)
+
+// goType is the Go type of a column: Name when NOT NULL, and the type to use
+// when nullable in each style.
+type goType struct {
+	name    string
+	sqlNull string // database/sql's dedicated wrapper, if there is one
+	pkg     string // import needed by name
+	noNull  bool   // nil already means NULL (slices)
+}
+
+var (
+	goString   = goType{name: "string", sqlNull: "sql.NullString"}
+	goBool     = goType{name: "bool", sqlNull: "sql.NullBool"}
+	goInt16    = goType{name: "int16", sqlNull: "sql.NullInt16"}
+	goInt32    = goType{name: "int32", sqlNull: "sql.NullInt32"}
+	goInt64    = goType{name: "int64", sqlNull: "sql.NullInt64"}
+	goFloat64  = goType{name: "float64", sqlNull: "sql.NullFloat64"}
+	goTime     = goType{name: "time.Time", sqlNull: "sql.NullTime", pkg: "time"}
+	goBytes    = goType{name: "[]byte", noNull: true}
+	goJSON     = goType{name: "json.RawMessage", pkg: "encoding/json"}
+	goUnsigned = map[string]goType{
+		"tinyint":   {name: "uint8", sqlNull: "sql.NullByte"},
+		"smallint":  {name: "uint16"},
+		"mediumint": {name: "uint32"},
+		"int":       {name: "uint32"},
+		"integer":   {name: "uint32"},
+		"bigint":    {name: "uint64"},
+	}
+)
+
+// goTypeOf maps a column type as written in MySQL or PostgreSQL DDL to Go.
+// Exact numerics become strings so no precision is lost; types it doesn't
+// know become []byte, which every driver can scan into.
+func goTypeOf(sqlType string) goType {
+	t := strings.ToLower(strings.TrimSpace(sqlType))
+	if t == "tinyint(1)" {
+		return goBool // MySQL's BOOLEAN
+	}
+	unsigned := strings.Contains(t, "unsigned")
+	base := strings.TrimSpace(typeParams.ReplaceAllString(t, ""))
+	base = strings.TrimSpace(strings.NewReplacer("unsigned", "", "zerofill", "").Replace(base))
+
+	if unsigned {
+		if gt, ok := goUnsigned[base]; ok {
+			return gt
+		}
+	}
+
+	switch {
+	case base == "bool" || base == "boolean":
+		return goBool
+	case base == "tinyint" || base == "smallint" || base == "smallserial" || base == "int2":
+		return goInt16
+	case base == "mediumint" || base == "int" || base == "integer" || base == "serial" || base == "int4":
+		return goInt32
+	case base == "bigint" || base == "bigserial" || base == "int8":
+		return goInt64
+	case base == "float" || base == "double" || base == "double precision" || base == "real" || base == "float4" || base == "float8":
+		return goFloat64
+	case base == "decimal" || base == "numeric" || base == "money":
+		return goString
+	case base == "date" || base == "datetime" || strings.HasPrefix(base, "timestamp"):
+		return goTime
+	case base == "json" || base == "jsonb":
+		return goJSON
+	case strings.Contains(base, "blob") || strings.Contains(base, "binary") || base == "bytea" || base == "bit":
+		return goBytes
+	case strings.Contains(base, "char") || strings.Contains(base, "text") || base == "uuid" || base == "time" ||
+		strings.HasPrefix(base, "time ") || base == "interval" || base == "year" || base == "inet" ||
+		base == "cidr" || base == "macaddr" || base == "citext" || base == "xml" || base == "array" ||
+		strings.HasPrefix(base, "enum") || strings.HasPrefix(base, "set"):
+		return goString
+	}
+	return goBytes
+}
+
+// nullable returns the type of a nullable column of type gt.
+func (gt goType) nullable(style domain.NullableStyle) string {
+	switch {
+	case gt.noNull:
+		return gt.name
+	case style == domain.NullablePointer:
+		return "*" + gt.name
+	case style == domain.NullableSQL && gt.sqlNull != "":
+		return gt.sqlNull
+	}
+	return "sql.Null[" + gt.name + "]"
+}
+
+// goEnum is an enum type to declare.
+type goEnum struct {
+	name   string
+	values []string
+}
+
+// GenerateGo renders one struct per table, with db and json tags, and a
+// string type with constants for each enum: MySQL ENUM columns (named after
+// table and column) and PostgreSQL enum types (named after the type, from
+// enums). The result is gofmt-formatted.
+func GenerateGo(tables []domain.Table, enums map[string]map[string]domain.Enum, opts domain.GoGenOptions) ([]byte, error) {
+	imports := make(map[string]bool)
+	enumNames := make(map[string]string) // PostgreSQL type or MySQL column -> Go type
+	var enumOrder []goEnum
+	typeNames := make(map[string]bool)
+
+	var body strings.Builder
+	for _, t := range tables {
+		if len(t.Columns) == 0 {
+			continue // a MySQL view: its DDL has no column list
+		}
+		structName := uniqueName(goName(t.Name), typeNames)
+
+		if t.View {
+			fmt.Fprintf(&body, "// %s is a row of the %s view.\n", structName, t.Name)
+		} else {
+			fmt.Fprintf(&body, "// %s is a row of the %s table.\n", structName, t.Name)
+		}
+		if t.Comment != "" {
+			body.WriteString("//\n")
+			writeComment(&body, "", t.Comment)
+		}
+		fmt.Fprintf(&body, "type %s struct {\n", structName)
+
+		fieldNames := make(map[string]bool)
+		for _, c := range t.Columns {
+			gt := goTypeOf(c.Type)
+
+			var values []string
+			key, enumName := "", ""
+			if e, ok := enums[t.Name][c.Name]; ok {
+				values, key, enumName = e.Values, "type "+e.Name, goName(e.Name)
+			} else if v, ok := enumValues(c.Type); ok {
+				values, key, enumName = v, "column "+t.Name+"."+c.Name, structName+goName(c.Name)
+			}
+			if key != "" {
+				if _, ok := enumNames[key]; !ok {
+					enumNames[key] = uniqueName(enumName, typeNames)
+					enumOrder = append(enumOrder, goEnum{name: enumNames[key], values: values})
+				}
+				gt = goType{name: enumNames[key]}
+			}
+
+			typ := gt.name
+			if c.Nullable {
+				typ = gt.nullable(opts.Nullable)
+			}
+			if gt.pkg != "" && strings.Contains(typ, gt.name) {
+				imports[gt.pkg] = true
+			}
+			if strings.HasPrefix(typ, "sql.") {
+				imports["database/sql"] = true
+			}
+
+			field := uniqueName(goName(c.Name), fieldNames)
+			line := fmt.Sprintf("\t%s %s `db:%q json:%q`", field, typ, c.Name, c.Name)
+			if c.Comment != "" {
+				line += " // " + strings.Join(strings.Fields(c.Comment), " ")
+			}
+			body.WriteString(line + "\n")
+		}
+		body.WriteString("}\n\n")
+	}
+
+	for _, e := range enumOrder {
+		fmt.Fprintf(&body, "// %s is an enum type of the schema.\ntype %s string\n\nconst (\n", e.name, e.name)
+		for _, v := range e.values {
+			fmt.Fprintf(&body, "\t%s %s = %q\n", uniqueName(e.name+goName(v), typeNames), e.name, v)
+		}
+		body.WriteString(")\n\n")
+	}
+
+	var out strings.Builder
+	out.WriteString("// Code generated by joka schema gen go. DO NOT EDIT.\n")
+	if opts.MigrationIndex != "" {
+		fmt.Fprintf(&out, "// Schema as of migration %s.\n", opts.MigrationIndex)
+	} else {
+		out.WriteString("// Schema with no migrations applied.\n")
+	}
+	fmt.Fprintf(&out, "\npackage %s\n", opts.Package)
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for p := range imports {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		out.WriteString("\nimport (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&out, "\t%q\n", p)
+		}
+		out.WriteString(")\n")
+	}
+	out.WriteString("\n" + body.String())
+
+	src, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// enumValues returns the values of a MySQL enum('a','b') column type.
+func enumValues(sqlType string) ([]string, bool) {
+	lower := strings.ToLower(sqlType)
+	if !strings.HasPrefix(lower, "enum(") || !strings.HasSuffix(sqlType, ")") {
+		return nil, false
+	}
+	var values []string
+	for _, v := range splitTopLevel(sqlType[len("enum("):len(sqlType)-1], ',') {
+		values = append(values, unquoteSQLString(strings.TrimSpace(v)))
+	}
+	return values, true
+}
+
+// goName turns a snake_case (or any) SQL name into an exported Go name:
+// user_id -> UserID, order-items -> OrderItems.
+func goName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if upper := strings.ToUpper(part); goInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(part)
+		b.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+	}
+	name := b.String()
+	if name == "" {
+		return "X"
+	}
+	if r := []rune(name)[0]; !unicode.IsLetter(r) {
+		name = "X" + name
+	}
+	return name
+}
+
+// uniqueName returns name, or name with a number appended if it's taken, and
+// marks the result taken.
+func uniqueName(name string, taken map[string]bool) string {
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	taken[candidate] = true
+	return candidate
+}
+
+func writeComment(b *strings.Builder, indent, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		fmt.Fprintf(b, "%s// %s\n", indent, strings.TrimSpace(line))
+	}
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+func TestGenerateGo(t *testing.T) {
+	mysqlTables := ParseSchema(map[string]string{
+		"user_accounts": "CREATE TABLE `user_accounts` (\n" +
+			"  `id` bigint unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `email` varchar(255) NOT NULL COMMENT 'login name',\n" +
+			"  `api_key` char(36) DEFAULT NULL,\n" +
+			"  `is_admin` tinyint(1) NOT NULL DEFAULT '0',\n" +
+			"  `role` enum('admin','read-only') DEFAULT NULL,\n" +
+			"  `balance` decimal(10,2) NOT NULL,\n" +
+			"  `last_login` datetime DEFAULT NULL,\n" +
+			"  `avatar` blob,\n" +
+			"  PRIMARY KEY (`id`)\n" +
+			") ENGINE=InnoDB COMMENT='People who can sign in'",
+	})
+
+	t.Run("it renders structs, tags, nullable types and enums", func(t *testing.T) {
+		src, err := GenerateGo(mysqlTables, nil, domain.GoGenOptions{Package: "models", Nullable: domain.NullableSQL, MigrationIndex: "240101000000"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := "// Code generated by joka schema gen go. DO NOT EDIT.\n" +
+			"// Schema as of migration 240101000000.\n" +
+			"\n" +
+			"package models\n" +
+			"\n" +
+			"import (\n" +
+			"\t\"database/sql\"\n" +
+			")\n" +
+			"\n" +
+			"// UserAccounts is a row of the user_accounts table.\n" +
+			"//\n" +
+			"// People who can sign in\n" +
+			"type UserAccounts struct {\n" +
+			"\tID        uint64                     `db:\"id\" json:\"id\"`\n" +
+			"\tEmail     string                     `db:\"email\" json:\"email\"` // login name\n" +
+			"\tAPIKey    sql.NullString             `db:\"api_key\" json:\"api_key\"`\n" +
+			"\tIsAdmin   bool                       `db:\"is_admin\" json:\"is_admin\"`\n" +
+			"\tRole      sql.Null[UserAccountsRole] `db:\"role\" json:\"role\"`\n" +
+			"\tBalance   string                     `db:\"balance\" json:\"balance\"`\n" +
+			"\tLastLogin sql.NullTime               `db:\"last_login\" json:\"last_login\"`\n" +
+			"\tAvatar    []byte                     `db:\"avatar\" json:\"avatar\"`\n" +
+			"}\n" +
+			"\n" +
+			"// UserAccountsRole is an enum type of the schema.\n" +
+			"type UserAccountsRole string\n" +
+			"\n" +
+			"const (\n" +
+			"\tUserAccountsRoleAdmin    UserAccountsRole = \"admin\"\n" +
+			"\tUserAccountsRoleReadOnly UserAccountsRole = \"read-only\"\n" +
+			")\n"
+		if string(src) != want {
+			t.Errorf("expected\n%s\ngot\n%s", want, src)
+		}
+	})
+
+	t.Run("it uses pointers when asked", func(t *testing.T) {
+		src, err := GenerateGo(mysqlTables, nil, domain.GoGenOptions{Package: "models", Nullable: domain.NullablePointer})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assertContains(t, string(src),
+			"// Schema with no migrations applied.\n",
+			"APIKey    *string ",
+			"Role      *UserAccountsRole ",
+			"LastLogin *time.Time ",
+			"Avatar    []byte ",
+		)
+		if strings.Contains(string(src), "database/sql") {
+			t.Errorf("expected no database/sql import, got:\n%s", src)
+		}
+	})
+
+	t.Run("it declares a PostgreSQL enum type once for every column using it", func(t *testing.T) {
+		tables := ParseSchema(map[string]string{
+			"orders":    "CREATE TABLE orders (\n  id integer NOT NULL,\n  state USER-DEFINED NOT NULL,\n  data jsonb\n)",
+			"shipments": "CREATE TABLE shipments (\n  id integer NOT NULL,\n  state USER-DEFINED\n)",
+		})
+		enum := domain.Enum{Name: "order_state", Values: []string{"new", "paid"}}
+		enums := map[string]map[string]domain.Enum{
+			"orders":    {"state": enum},
+			"shipments": {"state": enum},
+		}
+
+		src, err := GenerateGo(tables, enums, domain.GoGenOptions{Package: "db", Nullable: domain.NullableGeneric})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		out := string(src)
+		assertContains(t, out,
+			"\t\"encoding/json\"\n",
+			"State OrderState ",
+			"Data  sql.Null[json.RawMessage] ",
+			"State sql.Null[OrderState] ",
+			"\tOrderStateNew  OrderState = \"new\"\n",
+		)
+		if n := strings.Count(out, "type OrderState string"); n != 1 {
+			t.Errorf("expected OrderState declared once, got %d:\n%s", n, out)
+		}
+	})
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":     "UserID",
+		"api_key":     "APIKey",
+		"order-items": "OrderItems",
+		"2fa_secret":  "X2faSecret",
+		"createdAt":   "CreatedAt",
+	}
+	for in, want := range tests {
+		t.Run("it converts "+in, func(t *testing.T) {
+			if got := goName(in); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// autoIncrementOption matches MySQL's AUTO_INCREMENT=N table option, which
+// changes with the data rather than the schema.
+var autoIncrementOption = regexp.MustCompile(`\s*AUTO_INCREMENT=\d+`)
+
+// TableHistoryAction derives each table's history from the stored schema
+// snapshots: a table is introduced by the first migration whose snapshot has
+// it, altered by each migration whose snapshot has different DDL for it, and
+// dropped by the first whose snapshot no longer has it. A table dropped and
+// created again starts a new history.
+type TableHistoryAction struct {
+	Snapshots SnapshotReader
+}
+
+// Execute returns the history of every table any snapshot has seen, sorted by
+// table name. With no snapshots stored it returns nothing.
+func (a TableHistoryAction) Execute(ctx context.Context) ([]domain.TableHistory, error) {
+	indexes, err := a.Snapshots.ListSnapshotIndexes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots: %w", err)
+	}
+
+	history := make(map[string]*domain.TableHistory)
+	var previous map[string]string
+	for i, index := range indexes {
+		raw, err := a.Snapshots.GetSchemaSnapshot(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+		var schema map[string]string
+		if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+			return nil, fmt.Errorf("parsing snapshot for migration %s: %w", index, err)
+		}
+
+		for name, ddl := range schema {
+			ddl = autoIncrementOption.ReplaceAllString(ddl, "")
+			schema[name] = ddl
+
+			h, seen := history[name]
+			switch {
+			case !seen || h.Dropped != "":
+				history[name] = &domain.TableHistory{Table: name, Introduced: index, Baseline: i == 0}
+			case previous[name] != ddl:
+				h.LastAltered = index
+			}
+		}
+		for name := range previous {
+			if _, ok := schema[name]; !ok {
+				history[name].Dropped = index
+			}
+		}
+		previous = schema
+	}
+
+	out := make([]domain.TableHistory, 0, len(history))
+	for _, h := range history {
+		out = append(out, *h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Table < out[j].Table })
+	return out, nil
+}
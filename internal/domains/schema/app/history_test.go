@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// mockSnapshotReader serves snapshots in the order given.
+type mockSnapshotReader struct {
+	indexes   []string
+	snapshots map[string]map[string]string
+}
+
+func (m *mockSnapshotReader) ListSnapshotIndexes(ctx context.Context) ([]string, error) {
+	return m.indexes, nil
+}
+
+func (m *mockSnapshotReader) GetSchemaSnapshot(ctx context.Context, migrationIndex string) (string, error) {
+	raw, err := json.Marshal(m.snapshots[migrationIndex])
+	return string(raw), err
+}
+
+func TestTableHistoryAction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it finds when each table was introduced, altered and dropped", func(t *testing.T) {
+		reader := &mockSnapshotReader{
+			indexes: []string{"240101000000", "240102000000", "240103000000", "240104000000"},
+			snapshots: map[string]map[string]string{
+				"240101000000": {"users": "CREATE TABLE users (id INT) AUTO_INCREMENT=1"},
+				"240102000000": {"users": "CREATE TABLE users (id INT) AUTO_INCREMENT=9", "tmp": "CREATE TABLE tmp (id INT)"},
+				"240103000000": {"users": "CREATE TABLE users (id BIGINT)", "tmp": "CREATE TABLE tmp (id INT)"},
+				"240104000000": {"users": "CREATE TABLE users (id BIGINT)"},
+			},
+		}
+
+		history, err := TableHistoryAction{Snapshots: reader}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := []domain.TableHistory{
+			{Table: "tmp", Introduced: "240102000000", Dropped: "240104000000"},
+			{Table: "users", Introduced: "240101000000", Baseline: true, LastAltered: "240103000000"},
+		}
+		if !reflect.DeepEqual(history, want) {
+			t.Errorf("expected %+v, got %+v", want, history)
+		}
+	})
+
+	t.Run("it starts a new history for a table created again", func(t *testing.T) {
+		reader := &mockSnapshotReader{
+			indexes: []string{"240101000000", "240102000000", "240103000000"},
+			snapshots: map[string]map[string]string{
+				"240101000000": {"tags": "CREATE TABLE tags (id INT)"},
+				"240102000000": {},
+				"240103000000": {"tags": "CREATE TABLE tags (id INT, name TEXT)"},
+			},
+		}
+
+		history, err := TableHistoryAction{Snapshots: reader}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := []domain.TableHistory{{Table: "tags", Introduced: "240103000000"}}
+		if !reflect.DeepEqual(history, want) {
+			t.Errorf("expected %+v, got %+v", want, history)
+		}
+	})
+}
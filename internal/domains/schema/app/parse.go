@@ -0,0 +1,490 @@
+package app
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// tableCommentPattern matches MySQL's COMMENT='...' table option.
+var tableCommentPattern = regexp.MustCompile(`(?i)\bCOMMENT\s*=?\s*'((?:[^'\\]|''|\\.)*)'`)
+
+// prefixLengthPattern matches what may follow a column name in a key list: a
+// MySQL prefix length and/or a sort order.
+var prefixLengthPattern = regexp.MustCompile(`(?i)^(\(\d+\))?\s*(ASC|DESC)?$`)
+
+// columnStopWords end a column's type in its definition. CHARACTER only
+// counts when followed by SET, since PostgreSQL types start with it.
+var columnStopWords = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "AUTO_INCREMENT": true, "COMMENT": true,
+	"PRIMARY": true, "UNIQUE": true, "REFERENCES": true, "CHECK": true, "COLLATE": true,
+	"GENERATED": true, "AS": true, "ON": true, "CONSTRAINT": true, "INVISIBLE": true,
+	"VISIBLE": true, "SRID": true, "COLUMN_FORMAT": true, "STORAGE": true,
+}
+
+// ParseSchema parses every table of a snapshot ({table: DDL}) and returns them
+// sorted by name.
+func ParseSchema(schema map[string]string) []domain.Table {
+	tables := make([]domain.Table, 0, len(schema))
+	for name, ddl := range schema {
+		tables = append(tables, ParseTable(name, ddl))
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
+
+// ParseTable parses the DDL of one table: MySQL SHOW CREATE TABLE output,
+// joka's PostgreSQL reconstruction (CREATE TABLE followed by CREATE INDEX
+// statements), or a hand-written CREATE TABLE. Parsing is best effort:
+// clauses it doesn't understand are skipped rather than reported.
+func ParseTable(name, ddl string) domain.Table {
+	t := domain.Table{Name: name}
+
+	body, after, ok := tableBody(ddl)
+	if !ok {
+		t.View = isView(ddl)
+		return t
+	}
+
+	for _, item := range splitTopLevel(body, ',') {
+		parseTableItem(&t, strings.TrimSpace(item))
+	}
+
+	// MySQL table options, then any PostgreSQL CREATE INDEX statements.
+	options := after
+	if i := strings.Index(strings.ToUpper(after), "CREATE "); i >= 0 {
+		options = after[:i]
+		for _, stmt := range splitTopLevel(after[i:], ';') {
+			parseCreateIndex(&t, strings.TrimSpace(stmt))
+		}
+	}
+	if m := tableCommentPattern.FindStringSubmatch(options); m != nil {
+		t.Comment = unescapeSQLString(m[1])
+	}
+
+	return t
+}
+
+// tableBody returns the text between the parentheses of a CREATE TABLE and
+// what follows the closing parenthesis.
+func tableBody(ddl string) (string, string, bool) {
+	s := &ddlScanner{s: ddl}
+	if !s.keyword("CREATE") {
+		return "", "", false
+	}
+	s.keyword("TEMPORARY")
+	s.keyword("UNLOGGED")
+	if !s.keyword("TABLE") {
+		return "", "", false
+	}
+	s.keyword("IF", "NOT", "EXISTS")
+	s.ident()
+	s.space()
+	if !s.peek('(') {
+		return "", "", false
+	}
+	start := s.i
+	end := matchingParen(ddl, start)
+	if end < 0 {
+		return "", "", false
+	}
+	return ddl[start+1 : end], ddl[end+1:], true
+}
+
+func isView(ddl string) bool {
+	head := strings.ToUpper(ddl)
+	if i := strings.Index(head, " AS "); i >= 0 {
+		head = head[:i]
+	}
+	return strings.HasPrefix(head, "CREATE") && strings.Contains(head, " VIEW ")
+}
+
+// parseTableItem handles one comma-separated entry of a CREATE TABLE body: a
+// column or a table-level key or constraint.
+func parseTableItem(t *domain.Table, item string) {
+	s := &ddlScanner{s: item}
+
+	constraintName := ""
+	if s.keyword("CONSTRAINT") {
+		constraintName = s.ident()
+	}
+
+	switch {
+	case s.keyword("PRIMARY", "KEY"):
+		t.PrimaryKey = s.list()
+	case s.keyword("FOREIGN", "KEY"):
+		s.ident() // MySQL allows an index name here
+		fk := domain.ForeignKey{Name: constraintName, Columns: s.list()}
+		parseReferences(s, &fk)
+		t.ForeignKeys = append(t.ForeignKeys, fk)
+	case s.keyword("UNIQUE"):
+		if !s.keyword("KEY") {
+			s.keyword("INDEX")
+		}
+		idxName := constraintName
+		if !s.peek('(') {
+			idxName = s.ident()
+		}
+		t.Indexes = append(t.Indexes, domain.Index{Name: idxName, Columns: s.list(), Unique: true})
+	case s.keyword("KEY"), s.keyword("INDEX"), s.keyword("FULLTEXT"), s.keyword("SPATIAL"):
+		if !s.keyword("KEY") {
+			s.keyword("INDEX")
+		}
+		idxName := ""
+		if !s.peek('(') {
+			idxName = s.ident()
+		}
+		cols := s.list()
+		if cols == nil {
+			// A column that happens to be called key or index.
+			parseColumn(t, &ddlScanner{s: item})
+			return
+		}
+		t.Indexes = append(t.Indexes, domain.Index{Name: idxName, Columns: cols})
+	case constraintName != "", s.keyword("CHECK"), s.keyword("EXCLUDE"), s.keyword("PERIOD"):
+		// CHECK / EXCLUDE constraints don't describe columns or relations.
+	default:
+		parseColumn(t, s)
+	}
+}
+
+// parseColumn reads a column definition: name, type, then attributes.
+func parseColumn(t *domain.Table, s *ddlScanner) {
+	col := domain.Column{Name: s.ident(), Nullable: true}
+	if col.Name == "" {
+		return
+	}
+
+	words := splitWords(s.rest())
+	i := 0
+	var typeWords []string
+	for ; i < len(words); i++ {
+		w := strings.ToUpper(words[i])
+		if columnStopWords[w] || w == "CHARACTER" && i+1 < len(words) && strings.EqualFold(words[i+1], "SET") {
+			break
+		}
+		typeWords = append(typeWords, words[i])
+	}
+	col.Type = strings.Join(typeWords, " ")
+
+	for i < len(words) {
+		w := strings.ToUpper(words[i])
+		i++
+		switch w {
+		case "NOT":
+			if i < len(words) && strings.EqualFold(words[i], "NULL") {
+				col.Nullable = false
+				i++
+			}
+		case "NULL":
+			col.Nullable = true
+		case "DEFAULT":
+			start := i
+			for i++; i < len(words) && !columnStopWords[strings.ToUpper(words[i])]; i++ {
+			}
+			if start < len(words) {
+				col.Default = strings.Join(words[start:min(i, len(words))], " ")
+			}
+			if strings.HasPrefix(strings.ToLower(col.Default), "nextval(") {
+				col.AutoIncrement = true
+			}
+		case "AUTO_INCREMENT":
+			col.AutoIncrement = true
+		case "COMMENT":
+			if i < len(words) {
+				col.Comment = unquoteSQLString(words[i])
+				i++
+			}
+		case "PRIMARY":
+			t.PrimaryKey = append(t.PrimaryKey, col.Name)
+			col.Nullable = false
+		case "UNIQUE":
+			t.Indexes = append(t.Indexes, domain.Index{Columns: []string{col.Name}, Unique: true})
+		case "REFERENCES":
+			ref := &ddlScanner{s: "REFERENCES " + strings.Join(words[i:], " ")}
+			fk := domain.ForeignKey{Columns: []string{col.Name}}
+			parseReferences(ref, &fk)
+			t.ForeignKeys = append(t.ForeignKeys, fk)
+			i = len(words)
+		}
+	}
+
+	t.Columns = append(t.Columns, col)
+}
+
+// parseReferences reads "REFERENCES table (cols) [ON DELETE x] [ON UPDATE y]".
+func parseReferences(s *ddlScanner, fk *domain.ForeignKey) {
+	if !s.keyword("REFERENCES") {
+		return
+	}
+	fk.RefTable = s.ident()
+	fk.RefColumns = s.list()
+
+	words := splitWords(s.rest())
+	for i := 0; i+2 < len(words); i++ {
+		if !strings.EqualFold(words[i], "ON") {
+			continue
+		}
+		action := strings.ToUpper(words[i+2])
+		if (action == "SET" || action == "NO") && i+3 < len(words) {
+			action += " " + strings.ToUpper(words[i+3])
+		}
+		switch strings.ToUpper(words[i+1]) {
+		case "DELETE":
+			fk.OnDelete = action
+		case "UPDATE":
+			fk.OnUpdate = action
+		}
+	}
+}
+
+// parseCreateIndex reads "CREATE [UNIQUE] INDEX name ON [ONLY] table [USING m] (cols)".
+func parseCreateIndex(t *domain.Table, stmt string) {
+	s := &ddlScanner{s: stmt}
+	if !s.keyword("CREATE") {
+		return
+	}
+	unique := s.keyword("UNIQUE")
+	if !s.keyword("INDEX") {
+		return
+	}
+	s.keyword("CONCURRENTLY")
+	s.keyword("IF", "NOT", "EXISTS")
+	idx := domain.Index{Name: s.ident(), Unique: unique}
+	if !s.keyword("ON") {
+		return
+	}
+	s.keyword("ONLY")
+	s.ident()
+	if s.keyword("USING") {
+		s.ident()
+	}
+	idx.Columns = s.list()
+	t.Indexes = append(t.Indexes, idx)
+}
+
+// ddlScanner reads identifiers, keywords and parenthesized lists from DDL.
+type ddlScanner struct {
+	s string
+	i int
+}
+
+func (s *ddlScanner) space() {
+	for s.i < len(s.s) && isSpace(s.s[s.i]) {
+		s.i++
+	}
+}
+
+func (s *ddlScanner) peek(c byte) bool {
+	s.space()
+	return s.i < len(s.s) && s.s[s.i] == c
+}
+
+// keyword consumes the given words, in order and case-insensitively, if the
+// input continues with all of them.
+func (s *ddlScanner) keyword(words ...string) bool {
+	i := s.i
+	for _, w := range words {
+		for i < len(s.s) && isSpace(s.s[i]) {
+			i++
+		}
+		j := i
+		for j < len(s.s) && isWordByte(s.s[j]) {
+			j++
+		}
+		if !strings.EqualFold(s.s[i:j], w) {
+			return false
+		}
+		i = j
+	}
+	s.i = i
+	return true
+}
+
+// ident reads a possibly quoted, possibly schema-qualified identifier and
+// returns its last part, unquoted.
+func (s *ddlScanner) ident() string {
+	var name string
+	for {
+		s.space()
+		if s.i >= len(s.s) {
+			return name
+		}
+		switch c := s.s[s.i]; {
+		case c == '`' || c == '"':
+			end := strings.IndexByte(s.s[s.i+1:], c)
+			if end < 0 {
+				name = s.s[s.i+1:]
+				s.i = len(s.s)
+				return name
+			}
+			name = s.s[s.i+1 : s.i+1+end]
+			s.i += end + 2
+		case isWordByte(c):
+			j := s.i
+			for j < len(s.s) && isWordByte(s.s[j]) {
+				j++
+			}
+			name = s.s[s.i:j]
+			s.i = j
+		default:
+			return name
+		}
+		if s.i < len(s.s) && s.s[s.i] == '.' {
+			s.i++
+			continue
+		}
+		return name
+	}
+}
+
+// list reads a parenthesized, comma-separated list of columns. Elements that
+// aren't plain column names (expressions) are returned as written; a MySQL
+// prefix length or an ASC/DESC suffix is dropped.
+func (s *ddlScanner) list() []string {
+	if !s.peek('(') {
+		return nil
+	}
+	end := matchingParen(s.s, s.i)
+	if end < 0 {
+		return nil
+	}
+	inner := s.s[s.i+1 : end]
+	s.i = end + 1
+
+	var out []string
+	for _, el := range splitTopLevel(inner, ',') {
+		el = strings.TrimSpace(el)
+		es := &ddlScanner{s: el}
+		name := es.ident()
+		rest := strings.TrimSpace(es.rest())
+		if name != "" && (rest == "" || prefixLengthPattern.MatchString(rest)) {
+			out = append(out, name)
+		} else if el != "" {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+func (s *ddlScanner) rest() string {
+	if s.i >= len(s.s) {
+		return ""
+	}
+	return s.s[s.i:]
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// matchingParen returns the index of the parenthesis closing the one at
+// open, skipping quoted text, or -1.
+func matchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '"', '`':
+			i = skipQuoted(s, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// skipQuoted returns the index of the quote closing the one at i. A doubled
+// quote or a backslash escape inside '...' does not close it.
+func skipQuoted(s string, i int) int {
+	q := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\' && q == '\'':
+			j++
+		case s[j] == q:
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(s) - 1
+}
+
+// splitTopLevel splits s on sep outside parentheses and quotes.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(s, i)
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// splitWords splits s on whitespace outside parentheses and quotes, so
+// "enum('a b','c') NOT NULL" is three words.
+func splitWords(s string) []string {
+	var words []string
+	depth, start := 0, -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isSpace(c) && depth == 0 {
+			if start >= 0 {
+				words = append(words, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		switch c {
+		case '\'', '"', '`':
+			i = skipQuoted(s, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// unquoteSQLString strips the quotes from a '...' literal and unescapes it.
+func unquoteSQLString(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return unescapeSQLString(s[1 : len(s)-1])
+	}
+	return s
+}
+
+func unescapeSQLString(s string) string {
+	s = strings.ReplaceAll(s, "''", "'")
+	return strings.NewReplacer(`\'`, "'", `\\`, `\`, `\n`, "\n").Replace(s)
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+func TestParseTable(t *testing.T) {
+	t.Run("it parses MySQL SHOW CREATE TABLE output", func(t *testing.T) {
+		ddl := "CREATE TABLE `orders` (\n" +
+			"  `id` bigint unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"  `user_id` int NOT NULL COMMENT 'who placed it',\n" +
+			"  `coupon_id` int DEFAULT NULL,\n" +
+			"  `status` enum('new','paid, shipped') NOT NULL DEFAULT 'new',\n" +
+			"  `total` decimal(10,2) NOT NULL DEFAULT '0.00',\n" +
+			"  `note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin DEFAULT NULL,\n" +
+			"  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  UNIQUE KEY `uq_coupon` (`coupon_id`),\n" +
+			"  KEY `idx_user` (`user_id`,`created_at`),\n" +
+			"  KEY `idx_note` (`note`(20)),\n" +
+			"  CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE NO ACTION,\n" +
+			"  CONSTRAINT `chk_total` CHECK ((`total` >= 0))\n" +
+			") ENGINE=InnoDB AUTO_INCREMENT=5 DEFAULT CHARSET=utf8mb4 COMMENT='Customer orders'"
+
+		got := ParseTable("orders", ddl)
+
+		want := domain.Table{
+			Name: "orders",
+			Columns: []domain.Column{
+				{Name: "id", Type: "bigint unsigned", AutoIncrement: true},
+				{Name: "user_id", Type: "int", Comment: "who placed it"},
+				{Name: "coupon_id", Type: "int", Nullable: true, Default: "NULL"},
+				{Name: "status", Type: "enum('new','paid, shipped')", Default: "'new'"},
+				{Name: "total", Type: "decimal(10,2)", Default: "'0.00'"},
+				{Name: "note", Type: "varchar(255)", Nullable: true, Default: "NULL"},
+				{Name: "created_at", Type: "timestamp", Default: "CURRENT_TIMESTAMP"},
+			},
+			PrimaryKey: []string{"id"},
+			ForeignKeys: []domain.ForeignKey{
+				{Name: "fk_orders_user", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}, OnDelete: "CASCADE", OnUpdate: "NO ACTION"},
+			},
+			Indexes: []domain.Index{
+				{Name: "uq_coupon", Columns: []string{"coupon_id"}, Unique: true},
+				{Name: "idx_user", Columns: []string{"user_id", "created_at"}},
+				{Name: "idx_note", Columns: []string{"note"}},
+			},
+			Comment: "Customer orders",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected\n%+v\ngot\n%+v", want, got)
+		}
+	})
+
+	t.Run("it parses joka's PostgreSQL reconstruction", func(t *testing.T) {
+		ddl := "CREATE TABLE orders (\n" +
+			"  id integer NOT NULL DEFAULT nextval('orders_id_seq'::regclass),\n" +
+			"  user_id integer NOT NULL,\n" +
+			"  code character varying(20) DEFAULT 'x'::character varying,\n" +
+			"  key text,\n" +
+			"  CONSTRAINT orders_pkey PRIMARY KEY (id),\n" +
+			"  CONSTRAINT orders_code_key UNIQUE (code),\n" +
+			"  CONSTRAINT orders_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL\n" +
+			")\n" +
+			"CREATE INDEX orders_user_idx ON public.orders USING btree (user_id);\n" +
+			"CREATE UNIQUE INDEX orders_lower_code ON public.orders USING btree (lower((code)::text));"
+
+		got := ParseTable("orders", ddl)
+
+		wantCols := []domain.Column{
+			{Name: "id", Type: "integer", Default: "nextval('orders_id_seq'::regclass)", AutoIncrement: true},
+			{Name: "user_id", Type: "integer"},
+			{Name: "code", Type: "character varying(20)", Nullable: true, Default: "'x'::character varying"},
+			{Name: "key", Type: "text", Nullable: true},
+		}
+		if !reflect.DeepEqual(got.Columns, wantCols) {
+			t.Errorf("expected columns\n%+v\ngot\n%+v", wantCols, got.Columns)
+		}
+		if !reflect.DeepEqual(got.PrimaryKey, []string{"id"}) {
+			t.Errorf("expected primary key [id], got %v", got.PrimaryKey)
+		}
+		wantFK := []domain.ForeignKey{{Name: "orders_user_id_fkey", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}, OnDelete: "SET NULL"}}
+		if !reflect.DeepEqual(got.ForeignKeys, wantFK) {
+			t.Errorf("expected foreign keys %+v, got %+v", wantFK, got.ForeignKeys)
+		}
+		wantIdx := []domain.Index{
+			{Name: "orders_code_key", Columns: []string{"code"}, Unique: true},
+			{Name: "orders_user_idx", Columns: []string{"user_id"}},
+			{Name: "orders_lower_code", Columns: []string{"lower((code)::text)"}, Unique: true},
+		}
+		if !reflect.DeepEqual(got.Indexes, wantIdx) {
+			t.Errorf("expected indexes %+v, got %+v", wantIdx, got.Indexes)
+		}
+	})
+
+	t.Run("it reads inline keys from hand-written DDL", func(t *testing.T) {
+		got := ParseTable("posts", `CREATE TABLE IF NOT EXISTS "posts" (id INT PRIMARY KEY, author_id INT NOT NULL REFERENCES authors (id) ON DELETE CASCADE, slug TEXT UNIQUE)`)
+
+		if !reflect.DeepEqual(got.PrimaryKey, []string{"id"}) {
+			t.Errorf("expected primary key [id], got %v", got.PrimaryKey)
+		}
+		if len(got.ForeignKeys) != 1 || got.ForeignKeys[0].RefTable != "authors" || got.ForeignKeys[0].OnDelete != "CASCADE" {
+			t.Errorf("unexpected foreign keys %+v", got.ForeignKeys)
+		}
+		if !got.IsUnique([]string{"slug"}) {
+			t.Errorf("expected slug to be unique, got indexes %+v", got.Indexes)
+		}
+	})
+
+	t.Run("it marks views", func(t *testing.T) {
+		got := ParseTable("active_users", "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `active_users` AS select `users`.`id` AS `id` from `users`")
+
+		if !got.View || len(got.Columns) != 0 {
+			t.Errorf("expected an empty view, got %+v", got)
+		}
+	})
+}
+
+func TestParseSchema(t *testing.T) {
+	t.Run("it returns tables sorted by name", func(t *testing.T) {
+		tables := ParseSchema(map[string]string{
+			"b": "CREATE TABLE b (id INT)",
+			"a": "CREATE TABLE a (id INT)",
+		})
+
+		if len(tables) != 2 || tables[0].Name != "a" || tables[1].Name != "b" {
+			t.Errorf("unexpected tables %+v", tables)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// TableFilter narrows the tables a diagram or document covers.
+type TableFilter struct {
+	// Include keeps only tables matching one of these glob patterns
+	// (path.Match syntax, e.g. "user_*"). Empty keeps every table.
+	Include []string
+	// Exclude drops tables matching any of these patterns.
+	Exclude []string
+	// Focus, when set, keeps only tables within Depth foreign key hops of
+	// this table, following keys in both directions.
+	Focus string
+	Depth int
+}
+
+// SelectTables applies the filter to tables, keeping their order. Patterns are
+// applied before Focus, so an excluded table also breaks the paths through it.
+func SelectTables(tables []domain.Table, f TableFilter) ([]domain.Table, error) {
+	for _, p := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %q: %w", p, err)
+		}
+	}
+
+	var kept []domain.Table
+	for _, t := range tables {
+		if (len(f.Include) == 0 || matchAny(f.Include, t.Name)) && !matchAny(f.Exclude, t.Name) {
+			kept = append(kept, t)
+		}
+	}
+
+	if f.Focus == "" {
+		return kept, nil
+	}
+
+	names := make(map[string]bool, len(kept))
+	for _, t := range kept {
+		names[t.Name] = true
+	}
+	if !names[f.Focus] {
+		return nil, fmt.Errorf("focus table %q not found", f.Focus)
+	}
+
+	neighbours := make(map[string][]string)
+	for _, t := range kept {
+		for _, fk := range t.ForeignKeys {
+			if names[fk.RefTable] {
+				neighbours[t.Name] = append(neighbours[t.Name], fk.RefTable)
+				neighbours[fk.RefTable] = append(neighbours[fk.RefTable], t.Name)
+			}
+		}
+	}
+
+	reached := map[string]bool{f.Focus: true}
+	frontier := []string{f.Focus}
+	for depth := 0; depth < f.Depth && len(frontier) > 0; depth++ {
+		var next []string
+		for _, name := range frontier {
+			for _, n := range neighbours[name] {
+				if !reached[n] {
+					reached[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	var focused []domain.Table
+	for _, t := range kept {
+		if reached[t.Name] {
+			focused = append(focused, t)
+		}
+	}
+	return focused, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
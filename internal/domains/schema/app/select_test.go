@@ -0,0 +1,75 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+func tableNames(tables []domain.Table) []string {
+	var names []string
+	for _, t := range tables {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestSelectTables(t *testing.T) {
+	// countries <- users <- orders <- order_items -> products
+	tables := []domain.Table{
+		{Name: "audit_log"},
+		{Name: "countries"},
+		{Name: "order_items", ForeignKeys: []domain.ForeignKey{
+			{RefTable: "orders"}, {RefTable: "products"},
+		}},
+		{Name: "orders", ForeignKeys: []domain.ForeignKey{{RefTable: "users"}}},
+		{Name: "products"},
+		{Name: "users", ForeignKeys: []domain.ForeignKey{{RefTable: "countries"}}},
+	}
+
+	tests := []struct {
+		name   string
+		filter TableFilter
+		want   []string
+	}{
+		{"it keeps everything without a filter", TableFilter{},
+			[]string{"audit_log", "countries", "order_items", "orders", "products", "users"}},
+		{"it keeps tables matching an include pattern", TableFilter{Include: []string{"order*"}},
+			[]string{"order_items", "orders"}},
+		{"it drops tables matching an exclude pattern", TableFilter{Exclude: []string{"audit_*", "countries"}},
+			[]string{"order_items", "orders", "products", "users"}},
+		{"it follows keys both ways around the focus table", TableFilter{Focus: "orders", Depth: 1},
+			[]string{"order_items", "orders", "users"}},
+		{"it follows keys up to the depth", TableFilter{Focus: "orders", Depth: 2},
+			[]string{"countries", "order_items", "orders", "products", "users"}},
+		{"it keeps only the focus table at depth 0", TableFilter{Focus: "orders"},
+			[]string{"orders"}},
+		{"it does not walk through excluded tables", TableFilter{Focus: "order_items", Depth: 3, Exclude: []string{"orders"}},
+			[]string{"order_items", "products"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectTables(tables, tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tableNames(got), tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, tableNames(got))
+			}
+		})
+	}
+
+	t.Run("it errors when the focus table is missing", func(t *testing.T) {
+		if _, err := SelectTables(tables, TableFilter{Focus: "nope", Depth: 1}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("it errors on a bad pattern", func(t *testing.T) {
+		if _, err := SelectTables(tables, TableFilter{Include: []string{"["}}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package domain
+
+// Comments holds the table and column comments stored in the database
+// catalog. Tables with no comment are left out of Tables, and columns with no
+// comment out of Columns.
+type Comments struct {
+	Tables  map[string]string            // table -> comment
+	Columns map[string]map[string]string // table -> column -> comment
+}
+
+// Enum is a PostgreSQL enum type (CREATE TYPE ... AS ENUM) used by a column.
+// Snapshot DDL only records such columns as USER-DEFINED.
+type Enum struct {
+	Name   string
+	Values []string // in declaration order
+}
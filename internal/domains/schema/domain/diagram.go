@@ -0,0 +1,21 @@
+package domain
+
+import "fmt"
+
+// DiagramFormat is an output format of `joka schema diagram`.
+type DiagramFormat string
+
+const (
+	DiagramMermaid  DiagramFormat = "mermaid"
+	DiagramDot      DiagramFormat = "dot"
+	DiagramPlantUML DiagramFormat = "plantuml"
+)
+
+// ParseDiagramFormat validates a --format value.
+func ParseDiagramFormat(s string) (DiagramFormat, error) {
+	switch f := DiagramFormat(s); f {
+	case DiagramMermaid, DiagramDot, DiagramPlantUML:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown diagram format %q (expected mermaid, dot or plantuml)", s)
+}
@@ -0,0 +1,17 @@
+package domain
+
+// DocsFormat is a page format `joka schema docs` writes.
+type DocsFormat string
+
+const (
+	DocsMarkdown DocsFormat = "markdown"
+	DocsHTML     DocsFormat = "html"
+)
+
+// Ext returns the file extension of the format's pages.
+func (f DocsFormat) Ext() string {
+	if f == DocsHTML {
+		return ".html"
+	}
+	return ".md"
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "fmt"
+
+// NullableStyle is how `joka schema gen go` types nullable columns.
+type NullableStyle string
+
+const (
+	// NullableSQL uses database/sql's NullString, NullInt64, ... and
+	// sql.Null[T] for types without one.
+	NullableSQL NullableStyle = "sql"
+	// NullablePointer uses *T.
+	NullablePointer NullableStyle = "pointer"
+	// NullableGeneric uses sql.Null[T] throughout.
+	NullableGeneric NullableStyle = "generic"
+)
+
+// ParseNullableStyle validates a --nullable value.
+func ParseNullableStyle(s string) (NullableStyle, error) {
+	switch n := NullableStyle(s); n {
+	case NullableSQL, NullablePointer, NullableGeneric:
+		return n, nil
+	}
+	return "", fmt.Errorf("unknown nullable style %q (expected sql, pointer or generic)", s)
+}
+
+// GoGenOptions configures Go code generation.
+type GoGenOptions struct {
+	Package  string
+	Nullable NullableStyle
+	// MigrationIndex is recorded in the file header: the snapshot's
+	// migration, or the last applied one when generating from the live
+	// database. Empty when there is none.
+	MigrationIndex string
+}
@@ -0,0 +1,15 @@
+package domain
+
+// TableHistory is when a table appeared, last changed and went away, as seen
+// by comparing consecutive schema snapshots. Each field holds the migration
+// index whose snapshot first showed the change.
+type TableHistory struct {
+	Table      string
+	Introduced string
+	// Baseline is set when the table is already in the oldest stored
+	// snapshot. Older snapshots may have been pruned (or the history
+	// imported), so Introduced is then the latest it can have appeared.
+	Baseline    bool
+	LastAltered string // empty when the DDL never changed after Introduced
+	Dropped     string // empty while the table exists
+}
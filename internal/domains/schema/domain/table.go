@@ -0,0 +1,106 @@
+package domain
+
+// Table is one table of a schema, parsed from the DDL stored in a snapshot
+// (MySQL SHOW CREATE TABLE output or joka's PostgreSQL reconstruction).
+type Table struct {
+	Name        string
+	Columns     []Column
+	PrimaryKey  []string // column names, in key order
+	ForeignKeys []ForeignKey
+	Indexes     []Index // secondary indexes and unique constraints
+	Comment     string
+	// View is set for views. Their DDL has no column list on MySQL, so
+	// Columns may be empty.
+	View bool
+}
+
+// Column is one column of a Table.
+type Column struct {
+	Name     string
+	Type     string // as written in the DDL, e.g. "varchar(255)" or "character varying(255)"
+	Nullable bool
+	// Default is the DEFAULT expression as written; empty when there is none.
+	Default       string
+	AutoIncrement bool // AUTO_INCREMENT, or a nextval() default on PostgreSQL
+	Comment       string
+}
+
+// ForeignKey is a foreign key from Columns to RefColumns of RefTable.
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string // e.g. "CASCADE"; empty when not stated
+	OnUpdate   string
+}
+
+// Index is a secondary index or unique constraint. Columns holds column names,
+// or the expression text for expression indexes.
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// Column returns the named column, or false.
+func (t Table) Column(name string) (Column, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// IsPrimaryKey reports whether column is part of the primary key.
+func (t Table) IsPrimaryKey(column string) bool {
+	for _, c := range t.PrimaryKey {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// IsForeignKey reports whether column is part of a foreign key.
+func (t Table) IsForeignKey(column string) bool {
+	for _, fk := range t.ForeignKeys {
+		for _, c := range fk.Columns {
+			if c == column {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUnique reports whether the given columns are exactly the primary key or a
+// unique index, i.e. at most one row can hold each value.
+func (t Table) IsUnique(columns []string) bool {
+	if sameColumns(t.PrimaryKey, columns) {
+		return true
+	}
+	for _, idx := range t.Indexes {
+		if idx.Unique && sameColumns(idx.Columns, columns) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, c := range a {
+		seen[c] = true
+	}
+	for _, c := range b {
+		if !seen[c] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// queryComments runs a query returning (table, column, comment) rows, with an
+// empty column for table comments, and collects them.
+func queryComments(ctx context.Context, conn *sql.DB, query string) (domain.Comments, error) {
+	comments := domain.Comments{
+		Tables:  make(map[string]string),
+		Columns: make(map[string]map[string]string),
+	}
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return comments, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column, comment string
+		if err := rows.Scan(&table, &column, &comment); err != nil {
+			return comments, err
+		}
+		if column == "" {
+			comments.Tables[table] = comment
+			continue
+		}
+		if comments.Columns[table] == nil {
+			comments.Columns[table] = make(map[string]string)
+		}
+		comments.Columns[table][column] = comment
+	}
+	return comments, rows.Err()
+}
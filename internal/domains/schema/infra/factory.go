@@ -0,0 +1,16 @@
+package infra
+
+import (
+	"database/sql"
+
+	jokadb "github.com/apsdsm/joka/db"
+	"github.com/apsdsm/joka/internal/domains/schema/app"
+)
+
+// NewCatalogAdapter returns the appropriate CatalogAdapter for the given driver.
+func NewCatalogAdapter(driver jokadb.Driver, conn *sql.DB) app.CatalogAdapter {
+	if driver == jokadb.Postgres {
+		return NewPostgresCatalogAdapter(conn)
+	}
+	return NewMySQLCatalogAdapter(conn)
+}
@@ -0,0 +1,37 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// MySQLCatalogAdapter reads comments from information_schema.
+type MySQLCatalogAdapter struct {
+	conn *sql.DB
+}
+
+// NewMySQLCatalogAdapter creates a catalog adapter for the given connection.
+func NewMySQLCatalogAdapter(conn *sql.DB) *MySQLCatalogAdapter {
+	return &MySQLCatalogAdapter{conn: conn}
+}
+
+// GetComments returns the non-empty table and column comments of the current
+// database. Views are skipped: MySQL reports "VIEW" as their comment.
+func (m *MySQLCatalogAdapter) GetComments(ctx context.Context) (domain.Comments, error) {
+	return queryComments(ctx, m.conn, `
+		SELECT table_name, '', table_comment
+		FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' AND table_comment <> ''
+		UNION ALL
+		SELECT table_name, column_name, column_comment
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND column_comment <> ''`)
+}
+
+// GetEnums returns nothing: a MySQL ENUM column's values are in its column
+// type (enum('a','b')), which the parsed DDL already has.
+func (m *MySQLCatalogAdapter) GetEnums(ctx context.Context) (map[string]map[string]domain.Enum, error) {
+	return map[string]map[string]domain.Enum{}, nil
+}
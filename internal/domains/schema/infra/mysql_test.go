@@ -0,0 +1,49 @@
+package infra_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/infra"
+	"github.com/apsdsm/joka/testlib"
+)
+
+func TestMySQLGetComments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+
+	t.Cleanup(func() { testlib.DropTable(t, db, "comment_widgets") })
+
+	t.Run("it reads table and column comments", func(t *testing.T) {
+		ctx := context.Background()
+		_, err := db.ExecContext(ctx, `CREATE TABLE comment_widgets (
+			id INT PRIMARY KEY,
+			name VARCHAR(50) COMMENT 'Display name',
+			size INT
+		) COMMENT = 'Things we sell'`)
+		if err != nil {
+			t.Fatalf("creating table: %v", err)
+		}
+
+		comments, err := infra.NewMySQLCatalogAdapter(db).GetComments(ctx)
+		if err != nil {
+			t.Fatalf("GetComments: %v", err)
+		}
+
+		if got := comments.Tables["comment_widgets"]; got != "Things we sell" {
+			t.Errorf("expected table comment, got %q", got)
+		}
+		if got := comments.Columns["comment_widgets"]["name"]; got != "Display name" {
+			t.Errorf("expected column comment, got %q", got)
+		}
+		if _, ok := comments.Columns["comment_widgets"]["size"]; ok {
+			t.Error("expected no entry for a column without a comment")
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/apsdsm/joka/internal/domains/schema/domain"
+)
+
+// PostgresCatalogAdapter reads comments (COMMENT ON TABLE / COLUMN) from
+// pg_description and enum types from pg_enum.
+type PostgresCatalogAdapter struct {
+	conn *sql.DB
+}
+
+// NewPostgresCatalogAdapter creates a catalog adapter for the given connection.
+func NewPostgresCatalogAdapter(conn *sql.DB) *PostgresCatalogAdapter {
+	return &PostgresCatalogAdapter{conn: conn}
+}
+
+// GetComments returns the table and column comments of the current schema.
+// objsubid is 0 for a comment on the table itself and the column number
+// otherwise.
+func (p *PostgresCatalogAdapter) GetComments(ctx context.Context) (domain.Comments, error) {
+	return queryComments(ctx, p.conn, `
+		SELECT c.relname, COALESCE(a.attname, ''), d.description
+		FROM pg_description d
+		JOIN pg_class c ON c.oid = d.objoid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = d.objsubid AND d.objsubid > 0
+		WHERE d.classoid = 'pg_class'::regclass
+		AND n.nspname = current_schema()
+		AND c.relkind IN ('r', 'p', 'v', 'm')
+		AND (d.objsubid = 0 OR a.attname IS NOT NULL)`)
+}
+
+// GetEnums returns the enum type, with its labels in sort order, of every
+// enum column in the current schema.
+func (p *PostgresCatalogAdapter) GetEnums(ctx context.Context) (map[string]map[string]domain.Enum, error) {
+	rows, err := p.conn.QueryContext(ctx, `
+		SELECT c.table_name, c.column_name, t.typname, e.enumlabel
+		FROM information_schema.columns c
+		JOIN pg_type t ON t.typname = c.udt_name
+		JOIN pg_namespace n ON n.oid = t.typnamespace AND n.nspname = c.udt_schema
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		WHERE c.table_schema = current_schema()
+		ORDER BY c.table_name, c.column_name, e.enumsortorder`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enums := make(map[string]map[string]domain.Enum)
+	for rows.Next() {
+		var table, column, typ, label string
+		if err := rows.Scan(&table, &column, &typ, &label); err != nil {
+			return nil, err
+		}
+		if enums[table] == nil {
+			enums[table] = make(map[string]domain.Enum)
+		}
+		e := enums[table][column]
+		e.Name = typ
+		e.Values = append(e.Values, label)
+		enums[table][column] = e
+	}
+	return enums, rows.Err()
+}
@@ -0,0 +1,88 @@
+package infra_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/schema/infra"
+	"github.com/apsdsm/joka/testlib"
+)
+
+func TestPostgresGetComments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+
+	t.Cleanup(func() { testlib.DropTablePostgres(t, db, "comment_widgets") })
+
+	t.Run("it reads table and column comments", func(t *testing.T) {
+		ctx := context.Background()
+		_, err := db.ExecContext(ctx, `
+			CREATE TABLE comment_widgets (id INT PRIMARY KEY, name VARCHAR(50), size INT);
+			COMMENT ON TABLE comment_widgets IS 'Things we sell';
+			COMMENT ON COLUMN comment_widgets.name IS 'Display name';`)
+		if err != nil {
+			t.Fatalf("creating table: %v", err)
+		}
+
+		comments, err := infra.NewPostgresCatalogAdapter(db).GetComments(ctx)
+		if err != nil {
+			t.Fatalf("GetComments: %v", err)
+		}
+
+		if got := comments.Tables["comment_widgets"]; got != "Things we sell" {
+			t.Errorf("expected table comment, got %q", got)
+		}
+		if got := comments.Columns["comment_widgets"]["name"]; got != "Display name" {
+			t.Errorf("expected column comment, got %q", got)
+		}
+		if _, ok := comments.Columns["comment_widgets"]["size"]; ok {
+			t.Error("expected no entry for a column without a comment")
+		}
+	})
+}
+
+func TestPostgresGetEnums(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+
+	t.Cleanup(func() {
+		testlib.DropTablePostgres(t, db, "enum_orders")
+		db.Exec("DROP TYPE IF EXISTS enum_order_state")
+	})
+
+	t.Run("it reads each enum column's type and labels in order", func(t *testing.T) {
+		ctx := context.Background()
+		_, err := db.ExecContext(ctx, `
+			CREATE TYPE enum_order_state AS ENUM ('new', 'paid', 'shipped');
+			CREATE TABLE enum_orders (id INT PRIMARY KEY, state enum_order_state NOT NULL, note TEXT);`)
+		if err != nil {
+			t.Fatalf("creating table: %v", err)
+		}
+
+		enums, err := infra.NewPostgresCatalogAdapter(db).GetEnums(ctx)
+		if err != nil {
+			t.Fatalf("GetEnums: %v", err)
+		}
+
+		got := enums["enum_orders"]["state"]
+		if got.Name != "enum_order_state" || strings.Join(got.Values, ",") != "new,paid,shipped" {
+			t.Errorf("unexpected enum %+v", got)
+		}
+		if _, ok := enums["enum_orders"]["note"]; ok {
+			t.Error("expected no enum for a text column")
+		}
+	})
+}
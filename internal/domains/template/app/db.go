@@ -1,14 +1,60 @@
 package app
 
-import "context"
+import (
+	"context"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
 
 type DBAdapter interface {
-	TruncateTable(ctx context.Context, tableName string) error
+	// EnsureTrackingTable creates the joka_templates table if it does not
+	// already exist.
+	EnsureTrackingTable(ctx context.Context) error
+	// GetAllSyncedTables returns every table recorded in joka_templates
+	// mapped to its content hash.
+	GetAllSyncedTables(ctx context.Context) (map[string]string, error)
+	// RecordTableSynced records, or replaces, the content hash of a synced
+	// table.
+	RecordTableSynced(ctx context.Context, tableName, contentHash string) error
+
+	// TruncateTables empties the tables, in order, with each table's
+	// TruncateOptions. Returns an error wrapping ErrTableNotFound if one of
+	// them doesn't exist.
+	TruncateTables(ctx context.Context, tables []domain.Table) error
 	InsertRows(ctx context.Context, tableName string, rows []map[string]any) (int, error)
+	// UpsertRows inserts each row, or updates the existing row with the same
+	// key, and counts the inserts, updates and rows already up to date. The
+	// key columns need a unique index.
+	UpsertRows(ctx context.Context, tableName string, key []string, rows []map[string]any) (domain.SyncResult, error)
+	// ListKeys returns the key values of every row in the table. Returns an
+	// error wrapping ErrTableNotFound if the table doesn't exist.
+	ListKeys(ctx context.Context, tableName string, key []string) ([][]any, error)
+	// ReadRows returns every row of the table, keyed by column name. Returns
+	// an error wrapping ErrTableNotFound if the table doesn't exist.
+	ReadRows(ctx context.Context, tableName string) ([]map[string]any, error)
+	// ReferencingForeignKeys returns the foreign keys, in any table, that
+	// reference the table.
+	ReferencingForeignKeys(ctx context.Context, tableName string) ([]domain.ForeignKeyRef, error)
+	// CountReferencingRows counts the rows that reference, through fk, the
+	// rows of the table with the given keys, leaving out rows that are
+	// themselves among keys.
+	CountReferencingRows(ctx context.Context, tableName string, key []string, keys [][]any, fk domain.ForeignKeyRef) (int, error)
+	// DeleteRows deletes the rows with the given keys.
+	DeleteRows(ctx context.Context, tableName string, key []string, keys [][]any) (int, error)
+	// ColumnTypes returns the type of each of the table's columns. Returns
+	// an error wrapping ErrTableNotFound if the table doesn't exist.
+	ColumnTypes(ctx context.Context, tableName string) (map[string]domain.ColumnType, error)
+	// PrimaryKey returns the table's primary key columns, or none. Returns an
+	// error wrapping ErrTableNotFound if the table doesn't exist.
+	PrimaryKey(ctx context.Context, tableName string) ([]string, error)
+	// LookupValue queries a single value from an existing table row, for
+	// {{ lookup|... }} expressions in record files.
+	LookupValue(ctx context.Context, table, returnCol, whereCol string, whereVal any) (any, error)
 
 	// DisableForeignKeys temporarily disables FK constraint checking for the
 	// current session/transaction. EnableForeignKeys re-enables it. Used by
-	// the --ignore-foreign-keys flag to allow TRUNCATE on referenced tables.
+	// the --ignore-foreign-keys flag to write rows whose parents are missing
+	// and, on MySQL, to allow TRUNCATE on referenced tables.
 	DisableForeignKeys(ctx context.Context) error
 	EnableForeignKeys(ctx context.Context) error
 }
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
+)
+
+// HashTable returns the SHA-256 hex digest of a table's record files (their
+// names and contents) together with its strategy, key, NULL token, column
+// mappings and truncate options, so changing how a table syncs counts as a
+// change too.
+func HashTable(table domain.Table) (string, error) {
+	records := append([]domain.Record(nil), table.Records...)
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+
+	h := sha256.New()
+	fmt.Fprintf(h, "strategy %s\x00key %s\x00null %s\x00", table.Strategy, strings.Join(table.Key, ","), table.NullToken)
+	for _, m := range table.Columns {
+		fmt.Fprintf(h, "column %q %q %#v %t %q\x00", m.Source, m.Column, m.Default, m.Ignore, m.Value)
+	}
+	// Written only when set, so tables without options keep their hashes.
+	if table.Truncate != (domain.TruncateOptions{}) {
+		fmt.Fprintf(h, "truncate %t %t\x00", table.Truncate.ContinueIdentity, table.Truncate.Cascade)
+	}
+	for _, r := range records {
+		data, err := os.ReadFile(r.Path)
+		if err != nil {
+			return "", fmt.Errorf("reading file for hash: %w", err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", r.Name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// errFound stops a row scan early.
+var errFound = errors.New("found")
+
+// HasExternalValues reports whether any row of the table takes a value from
+// outside its record files, through a lookup or a secret reference. Such a
+// table can change while HashTable doesn't, so data sync never skips it.
+func HasExternalValues(ctx context.Context, table domain.Table) (bool, error) {
+	err := LoadTableDataAction{Table: table}.Stream(ctx, func(row map[string]any) error {
+		for _, v := range row {
+			if expr.IsExternal(v) {
+				return errFound
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errFound) {
+		return true, nil
+	}
+	return false, err
+}
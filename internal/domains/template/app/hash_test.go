@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestHashTable(t *testing.T) {
+	t.Run("it returns the same hash for unchanged files", func(t *testing.T) {
+		table := csvTable(t, "id,value\n1,a\n")
+
+		a, err := HashTable(table)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b, err := HashTable(table)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if a != b || len(a) != 64 {
+			t.Errorf("expected a stable 64-character hash, got %q and %q", a, b)
+		}
+	})
+
+	t.Run("it changes when a file's content changes", func(t *testing.T) {
+		table := csvTable(t, "id,value\n1,a\n")
+		before, _ := HashTable(table)
+
+		if err := os.WriteFile(table.Records[0].Path, []byte("id,value\n1,b\n"), 0644); err != nil {
+			t.Fatalf("writing csv: %v", err)
+		}
+		after, err := HashTable(table)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if before == after {
+			t.Error("expected the hash to change")
+		}
+	})
+
+	t.Run("it changes when the strategy changes", func(t *testing.T) {
+		table := csvTable(t, "id,value\n1,a\n")
+		before, _ := HashTable(table)
+
+		table.Strategy = domain.StrategyDelete
+		after, _ := HashTable(table)
+		if before == after {
+			t.Error("expected the hash to change")
+		}
+	})
+
+	t.Run("it changes when a column mapping changes", func(t *testing.T) {
+		table := csvTable(t, "id,value\n1,a\n")
+		table.Columns = []domain.ColumnMapping{{Source: "value", Default: "x"}}
+		before, _ := HashTable(table)
+
+		table.Columns[0].Default = "y"
+		after, _ := HashTable(table)
+		if before == after {
+			t.Error("expected the hash to change")
+		}
+	})
+
+	t.Run("it changes when a truncate option changes", func(t *testing.T) {
+		table := csvTable(t, "id,value\n1,a\n")
+		table.Strategy = domain.StrategyTruncate
+		before, _ := HashTable(table)
+
+		table.Truncate.Cascade = true
+		after, _ := HashTable(table)
+		if before == after {
+			t.Error("expected the hash to change")
+		}
+	})
+}
+
+func TestHasExternalValues(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it finds lookups and secrets", func(t *testing.T) {
+		for _, content := range []string{
+			"id,plan_id\n1,plain\n2,\"{{ lookup|plans,id,code=pro }}\"\n",
+			"id,token\n1,{{ sha256|asm.seed.api_key }}\n",
+		} {
+			found, err := HasExternalValues(ctx, csvTable(t, content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !found {
+				t.Errorf("expected external values in %q", content)
+			}
+		}
+	})
+
+	t.Run("it ignores plain values and expressions of the files alone", func(t *testing.T) {
+		found, err := HasExternalValues(ctx, csvTable(t, "id,created,pw\n1,{{ now }},{{ argon2id|secret }}\n"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if found {
+			t.Error("expected no external values")
+		}
+	})
+}
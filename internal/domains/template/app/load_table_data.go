@@ -2,23 +2,79 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/apsdsm/joka/internal/domains/template/domain"
 	"github.com/apsdsm/joka/internal/domains/template/infra"
+	"github.com/apsdsm/joka/internal/expr"
 )
 
+// LoadTableDataAction loads the rows of every record file of a table. CSV
+// and TSV cells matching the table's NullToken load as NULL, and cells are
+// checked against Types (or a header hint) and converted. Each row is then
+// reshaped by the table's column mappings, and its {{ ... }} expressions are
+// resolved by Resolver.
 type LoadTableDataAction struct {
 	Table domain.Table
+	// Types are the table's live column types, from DBAdapter.ColumnTypes.
+	// Nil checks only the columns with a header hint.
+	Types map[string]domain.ColumnType
+	// Resolver evaluates template expressions in the rows. Nil leaves them
+	// as written.
+	Resolver *expr.Resolver
 }
 
 func (a LoadTableDataAction) Execute(ctx context.Context) ([]map[string]any, error) {
 	var allRows []map[string]any
+	err := a.Stream(ctx, func(row map[string]any) error {
+		allRows = append(allRows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allRows, nil
+}
+
+// Stream passes the table's rows to fn one at a time, record by record,
+// without holding them all in memory, and stops at the first error.
+func (a LoadTableDataAction) Stream(ctx context.Context, fn func(row map[string]any) error) error {
+	opts := infra.LoadOptions{NullToken: a.Table.NullToken, Types: sourceTypes(a.Types, a.Table.Columns)}
+
 	for _, record := range a.Table.Records {
-		rows, err := infra.LoadRecord(record)
+		err := infra.StreamRecord(record, opts, func(row map[string]any) error {
+			mapped, err := mapColumns(row, a.Table.Columns)
+			if err != nil {
+				return fmt.Errorf("%s: %w", record.Path, err)
+			}
+			if a.Resolver != nil {
+				if mapped, err = a.Resolver.Row(ctx, mapped); err != nil {
+					return fmt.Errorf("%s: %w", record.Path, err)
+				}
+			}
+			return fn(mapped)
+		})
 		if err != nil {
-			return nil, err
+			return err
 		}
-		allRows = append(allRows, rows...)
 	}
-	return allRows, nil
+	return nil
+}
+
+// loadTypedRows loads a table's rows, converting CSV and TSV cells to the
+// types of the table's live columns and resolving expressions with resolver
+// (nil leaves them as written).
+func loadTypedRows(ctx context.Context, db DBAdapter, table domain.Table, resolver *expr.Resolver) ([]map[string]any, error) {
+	types, err := db.ColumnTypes(ctx, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	return LoadTableDataAction{Table: table, Types: types, Resolver: resolver}.Execute(ctx)
+}
+
+// newResolver resolves the expressions of a sync: lookups query db, so they
+// run inside the sync transaction, and secrets come from secrets.
+func newResolver(db DBAdapter, secrets expr.SecretResolver) *expr.Resolver {
+	return &expr.Resolver{Now: time.Now().UTC().Format("2006-01-02 15:04:05"), DB: db, Secrets: secrets}
 }
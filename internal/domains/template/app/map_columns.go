@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+// mapColumns applies a table's column mappings to a loaded row: renames
+// first, then defaults for the columns the row doesn't have, then computed
+// values (which see the renamed and defaulted row, ignored columns included),
+// and finally drops the ignored columns.
+func mapColumns(row map[string]any, mappings []domain.ColumnMapping) (map[string]any, error) {
+	if len(mappings) == 0 {
+		return row, nil
+	}
+
+	renamed := make(map[string]string)
+	for _, m := range mappings {
+		if m.Column != "" {
+			renamed[m.Source] = m.Column
+		}
+	}
+	out := make(map[string]any, len(row))
+	for col, v := range row {
+		if _, ok := renamed[col]; !ok {
+			out[col] = v
+		}
+	}
+	// A renamed column wins over a column already named like its target.
+	for col, v := range row {
+		if target, ok := renamed[col]; ok {
+			out[target] = v
+		}
+	}
+
+	for _, m := range mappings {
+		if _, ok := out[m.Target()]; !ok && m.Default != nil {
+			out[m.Target()] = m.Default
+		}
+	}
+
+	computed := make(map[string]any)
+	for _, m := range mappings {
+		if m.Value == "" {
+			continue
+		}
+		var missing string
+		value := os.Expand(m.Value, func(name string) string {
+			v, ok := out[name]
+			if !ok && missing == "" {
+				missing = name
+			}
+			if v == nil {
+				return ""
+			}
+			return formatValue(v)
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("%w: computed column %q uses %q, which the row doesn't have", domain.ErrInvalidRecord, m.Target(), missing)
+		}
+		computed[m.Target()] = value
+	}
+	for col, v := range computed {
+		out[col] = v
+	}
+
+	for _, m := range mappings {
+		if m.Ignore {
+			delete(out, m.Source)
+		}
+	}
+	return out, nil
+}
+
+// sourceTypes keys column types by the names the record files use, so a
+// renamed CSV column is checked against the type of the column it's written
+// to.
+func sourceTypes(types map[string]domain.ColumnType, mappings []domain.ColumnMapping) map[string]domain.ColumnType {
+	if types == nil {
+		return nil
+	}
+	out := make(map[string]domain.ColumnType, len(types))
+	for col, t := range types {
+		out[col] = t
+	}
+	for _, m := range mappings {
+		if t, ok := types[m.Column]; ok && m.Column != "" {
+			out[m.Source] = t
+		}
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestMapColumns(t *testing.T) {
+	t.Run("it renames, defaults, computes and ignores columns", func(t *testing.T) {
+		mappings := []domain.ColumnMapping{
+			{Source: "E-mail", Column: "email"},
+			{Source: "first", Ignore: true},
+			{Source: "last", Ignore: true},
+			{Source: "name", Value: "${first} ${last}"},
+			{Source: "status", Default: "active"},
+		}
+		row := map[string]any{"E-mail": "a@test.com", "first": "Ada", "last": "Lovelace"}
+
+		got, err := mapColumns(row, mappings)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := map[string]any{"email": "a@test.com", "name": "Ada Lovelace", "status": "active"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("it keeps a row's own value over the default", func(t *testing.T) {
+		got, err := mapColumns(map[string]any{"status": nil}, []domain.ColumnMapping{{Source: "status", Default: "active"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if v, ok := got["status"]; !ok || v != nil {
+			t.Errorf("expected status to stay NULL, got %v", got)
+		}
+	})
+
+	t.Run("it rejects a computed column using a column the row doesn't have", func(t *testing.T) {
+		_, err := mapColumns(map[string]any{"first": "Ada"}, []domain.ColumnMapping{{Source: "name", Value: "${first} ${last}"}})
+		if !errors.Is(err, domain.ErrInvalidRecord) {
+			t.Errorf("expected ErrInvalidRecord, got %v", err)
+		}
+	})
+}
+
+func TestLoadTableDataColumns(t *testing.T) {
+	t.Run("it checks a renamed CSV column against the type of its target", func(t *testing.T) {
+		table := csvTable(t, "Code,Enabled\nbeta,maybe\n")
+		table.Columns = []domain.ColumnMapping{{Source: "Code", Column: "code"}, {Source: "Enabled", Column: "enabled"}}
+
+		types := map[string]domain.ColumnType{"code": domain.ColumnString, "enabled": domain.ColumnBool}
+		_, err := LoadTableDataAction{Table: table, Types: types}.Execute(context.Background())
+		if !errors.Is(err, domain.ErrInvalidRecord) {
+			t.Errorf("expected ErrInvalidRecord for a non-boolean cell, got %v", err)
+		}
+	})
+
+	t.Run("it loads rows under their mapped names", func(t *testing.T) {
+		table := csvTable(t, "Code,Enabled\nbeta,true\n")
+		table.Columns = []domain.ColumnMapping{{Source: "Code", Column: "code"}, {Source: "Enabled", Column: "enabled"}}
+
+		types := map[string]domain.ColumnType{"code": domain.ColumnString, "enabled": domain.ColumnBool}
+		rows, err := LoadTableDataAction{Table: table, Types: types}.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []map[string]any{{"code": "beta", "enabled": true}}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("expected %v, got %v", want, rows)
+		}
+	})
+}
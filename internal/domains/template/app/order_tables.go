@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+// OrderTablesAction orders tables so that each comes after the tables it
+// references through a foreign key, read from the database catalog: parents
+// are loaded before their children, and TruncateTablesAction empties them in
+// reverse. Only references between the given tables count, and a table's
+// references to itself are ignored. Otherwise tables keep their config order;
+// tables in a reference cycle, and those referencing them, go last in config
+// order.
+type OrderTablesAction struct {
+	DB     DBAdapter
+	Tables []domain.Table
+}
+
+func (a OrderTablesAction) Execute(ctx context.Context) ([]domain.Table, error) {
+	index := make(map[string]int, len(a.Tables))
+	for i, t := range a.Tables {
+		index[t.Name] = i
+	}
+
+	// parents[i] counts the tables table i references; children[i] lists the
+	// tables that reference table i.
+	parents := make([]int, len(a.Tables))
+	children := make([][]int, len(a.Tables))
+	for i, t := range a.Tables {
+		fks, err := a.DB.ReferencingForeignKeys(ctx, t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("reading foreign keys referencing %s: %w", t.Name, err)
+		}
+		seen := make(map[int]bool)
+		for _, fk := range fks {
+			j, ok := index[fk.Table]
+			if !ok || j == i || seen[j] {
+				continue
+			}
+			seen[j] = true
+			children[i] = append(children[i], j)
+			parents[j]++
+		}
+	}
+
+	ordered := make([]domain.Table, 0, len(a.Tables))
+	done := make([]bool, len(a.Tables))
+	for len(ordered) < len(a.Tables) {
+		next := -1
+		for i := range a.Tables {
+			if !done[i] && parents[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			for i, t := range a.Tables {
+				if !done[i] {
+					ordered = append(ordered, t)
+				}
+			}
+			break
+		}
+		done[next] = true
+		ordered = append(ordered, a.Tables[next])
+		for _, j := range children[next] {
+			parents[j]--
+		}
+	}
+	return ordered, nil
+}
+
+// ReferencingTablesAction adds to Tables every table of All that truncating
+// them would empty or block: the tables referencing a truncate-strategy
+// table, and in turn those referencing a table that is truncated too or
+// emptied by a cascade. A cascade also empties tables that aren't in All, so
+// it is followed through them. Without this, a child skipped as unchanged
+// would be emptied by a cascade and never reloaded, or make its parent's
+// TRUNCATE fail. The result keeps All's order.
+type ReferencingTablesAction struct {
+	DB     DBAdapter
+	Tables []domain.Table
+	All    []domain.Table
+}
+
+func (a ReferencingTablesAction) Execute(ctx context.Context) ([]domain.Table, error) {
+	configured := make(map[string]domain.Table, len(a.All))
+	for _, t := range a.All {
+		configured[t.Name] = t
+	}
+
+	// emptied is a table that will be emptied, and whether a cascade empties
+	// its children too.
+	type emptied struct {
+		name    string
+		cascade bool
+	}
+	include := make(map[string]bool, len(a.All))
+	var queue []emptied
+	for _, t := range a.Tables {
+		include[t.Name] = true
+		if t.Strategy == domain.StrategyTruncate {
+			queue = append(queue, emptied{t.Name, t.Truncate.Cascade})
+		}
+	}
+
+	// seen records the tables already followed, and whether with cascade.
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		if cascade, ok := seen[e.name]; ok && (cascade || !e.cascade) {
+			continue
+		}
+		seen[e.name] = e.cascade
+
+		fks, err := a.DB.ReferencingForeignKeys(ctx, e.name)
+		if err != nil {
+			return nil, fmt.Errorf("reading foreign keys referencing %s: %w", e.name, err)
+		}
+		for _, fk := range fks {
+			child, ok := configured[fk.Table]
+			if ok {
+				include[child.Name] = true
+			}
+			truncated := ok && child.Strategy == domain.StrategyTruncate
+			if e.cascade || truncated {
+				queue = append(queue, emptied{fk.Table, e.cascade || (truncated && child.Truncate.Cascade)})
+			}
+		}
+	}
+
+	var tables []domain.Table
+	for _, t := range a.All {
+		if include[t.Name] {
+			tables = append(tables, t)
+		}
+	}
+	return tables, nil
+}
+
+// TruncateTablesAction empties every table with the truncate strategy before
+// any of them is reloaded by SyncTableAction. Tables come parents first, as
+// OrderTablesAction returns them, and are truncated in reverse, so children
+// are emptied before their parents.
+type TruncateTablesAction struct {
+	DB     DBAdapter
+	Tables []domain.Table
+}
+
+func (a TruncateTablesAction) Execute(ctx context.Context) error {
+	var tables []domain.Table
+	for i := len(a.Tables) - 1; i >= 0; i-- {
+		if a.Tables[i].Strategy == domain.StrategyTruncate {
+			tables = append(tables, a.Tables[i])
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	return a.DB.TruncateTables(ctx, tables)
+}
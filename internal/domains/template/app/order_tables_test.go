@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func names(tables []domain.Table) []string {
+	out := make([]string, len(tables))
+	for i, t := range tables {
+		out[i] = t.Name
+	}
+	return out
+}
+
+func TestOrderTables(t *testing.T) {
+	ctx := context.Background()
+	tables := []domain.Table{{Name: "comments"}, {Name: "posts"}, {Name: "settings"}, {Name: "users"}}
+
+	t.Run("it puts parents before their children", func(t *testing.T) {
+		db := &mockDBAdapter{fksTo: map[string][]domain.ForeignKeyRef{
+			"users": {{Name: "fk_posts_user", Table: "posts"}, {Name: "fk_comments_user", Table: "comments"}},
+			"posts": {{Name: "fk_comments_post", Table: "comments"}},
+		}}
+
+		ordered, err := OrderTablesAction{DB: db, Tables: tables}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"settings", "users", "posts", "comments"}
+		if !reflect.DeepEqual(names(ordered), want) {
+			t.Errorf("expected %v, got %v", want, names(ordered))
+		}
+	})
+
+	t.Run("it ignores self references and tables that aren't synced", func(t *testing.T) {
+		db := &mockDBAdapter{fksTo: map[string][]domain.ForeignKeyRef{
+			"comments": {{Name: "fk_comments_parent", Table: "comments"}},
+			"audit":    {{Name: "fk_audit_users", Table: "users"}},
+		}}
+
+		ordered, err := OrderTablesAction{DB: db, Tables: tables}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ordered, tables) {
+			t.Errorf("expected the config order, got %v", names(ordered))
+		}
+	})
+
+	t.Run("it keeps the config order of a reference cycle", func(t *testing.T) {
+		db := &mockDBAdapter{fksTo: map[string][]domain.ForeignKeyRef{
+			"comments": {{Name: "fk_posts_pinned", Table: "posts"}},
+			"posts":    {{Name: "fk_comments_post", Table: "comments"}},
+		}}
+
+		ordered, err := OrderTablesAction{DB: db, Tables: tables}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"settings", "users", "comments", "posts"}
+		if !reflect.DeepEqual(names(ordered), want) {
+			t.Errorf("expected %v, got %v", want, names(ordered))
+		}
+	})
+}
+
+func TestReferencingTables(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it adds the tables referencing a truncated table", func(t *testing.T) {
+		all := []domain.Table{
+			{Name: "users", Strategy: domain.StrategyTruncate},
+			{Name: "posts", Strategy: domain.StrategyTruncate},
+			{Name: "comments", Strategy: domain.StrategyUpdate},
+			{Name: "settings", Strategy: domain.StrategyTruncate},
+		}
+		db := &mockDBAdapter{fksTo: map[string][]domain.ForeignKeyRef{
+			"users": {{Name: "fk_posts_user", Table: "posts"}},
+			"posts": {{Name: "fk_comments_post", Table: "comments"}},
+		}}
+
+		tables, err := ReferencingTablesAction{DB: db, Tables: all[:1], All: all}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"users", "posts", "comments"}
+		if !reflect.DeepEqual(names(tables), want) {
+			t.Errorf("expected %v, got %v", want, names(tables))
+		}
+	})
+
+	t.Run("it stops at a referencing table that isn't truncated", func(t *testing.T) {
+		all := []domain.Table{
+			{Name: "users", Strategy: domain.StrategyTruncate},
+			{Name: "posts", Strategy: domain.StrategyUpdate},
+			{Name: "comments", Strategy: domain.StrategyUpdate},
+		}
+		db := &mockDBAdapter{fksTo: map[string][]domain.ForeignKeyRef{
+			"users": {{Name: "fk_posts_user", Table: "posts"}},
+			"posts": {{Name: "fk_comments_post", Table: "comments"}},
+		}}
+
+		tables, err := ReferencingTablesAction{DB: db, Tables: all[:1], All: all}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"users", "posts"}
+		if !reflect.DeepEqual(names(tables), want) {
+			t.Errorf("expected %v, got %v", want, names(tables))
+		}
+	})
+
+	t.Run("it follows a cascade through tables that aren't configured", func(t *testing.T) {
+		all := []domain.Table{
+			{Name: "users", Strategy: domain.StrategyTruncate, Truncate: domain.TruncateOptions{Cascade: true}},
+			{Name: "comments", Strategy: domain.StrategyUpdate},
+		}
+		db := &mockDBAdapter{fksTo: map[string][]domain.ForeignKeyRef{
+			"users": {{Name: "fk_users_manager", Table: "users"}, {Name: "fk_posts_user", Table: "posts"}},
+			"posts": {{Name: "fk_comments_post", Table: "comments"}},
+		}}
+
+		tables, err := ReferencingTablesAction{DB: db, Tables: all[:1], All: all}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"users", "comments"}
+		if !reflect.DeepEqual(names(tables), want) {
+			t.Errorf("expected %v, got %v", want, names(tables))
+		}
+	})
+}
+
+func TestTruncateTables(t *testing.T) {
+	t.Run("it truncates the truncate tables children first", func(t *testing.T) {
+		db := &mockDBAdapter{}
+		tables := []domain.Table{
+			{Name: "users", Strategy: domain.StrategyTruncate},
+			{Name: "settings", Strategy: domain.StrategyUpdate},
+			{Name: "posts", Strategy: domain.StrategyTruncate},
+		}
+
+		if err := (TruncateTablesAction{DB: db, Tables: tables}).Execute(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(db.truncated, []string{"posts", "users"}) {
+			t.Errorf("expected posts then users, got %v", db.truncated)
+		}
+	})
+}
@@ -0,0 +1,378 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
+)
+
+// SyncPlan describes, without applying anything, what a data sync would do
+// to each table: the rows it would insert, update or delete.
+type SyncPlan struct {
+	Tables []TablePlan
+}
+
+// TablePlan is what syncing one table would change. Rows are matched by Key,
+// the table's configured key or primary key.
+type TablePlan struct {
+	Table    string
+	Strategy domain.StrategyType
+	Key      []string
+	Inserts  []RowPlan
+	Updates  []RowUpdatePlan
+	Deletes  []RowPlan
+}
+
+// HasChanges reports whether syncing the table would change anything.
+func (p TablePlan) HasChanges() bool {
+	return len(p.Inserts) > 0 || len(p.Updates) > 0 || len(p.Deletes) > 0
+}
+
+// RowPlan is a row that would be inserted or deleted.
+type RowPlan struct {
+	Key    []ColumnValue
+	Values []ColumnValue
+}
+
+// ColumnValue is a column and its value, as text. Note is set instead for a
+// value that can't be shown at plan time: "generated" for a non-deterministic
+// or secret expression (now, argon2id, asm.*), or "lookup, resolved at apply
+// time" for a lookup whose target row doesn't exist yet (it may be synced
+// earlier in the same run), or "redacted" for a row to delete whose column
+// the templates fill with one of those generated values.
+type ColumnValue struct {
+	Column string
+	Value  string
+	Note   string
+}
+
+// RowUpdatePlan is an existing row and the template columns that would
+// change on it.
+type RowUpdatePlan struct {
+	Key     []ColumnValue
+	Changes []ColumnChange
+}
+
+// ColumnChange is a single column whose value would change. Regenerated
+// marks a non-deterministic or secret expression, rewritten on every sync and
+// never displayed; Deferred marks a lookup that can only be resolved at apply
+// time, so After is empty.
+type ColumnChange struct {
+	Column      string
+	Before      string
+	After       string
+	Regenerated bool
+	Deferred    bool
+}
+
+// HasChanges reports whether the plan would change any table.
+func (p *SyncPlan) HasChanges() bool {
+	for _, t := range p.Tables {
+		if t.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanSyncAction computes a SyncPlan for the tables a data sync would apply.
+// It is read-only: it loads the template rows and reads the current rows of
+// each table, and diffs them by key.
+//
+// update plans inserts and updates; delete and truncate also plan deletes of
+// rows whose key isn't in the templates. A truncated table with neither a
+// configured key nor a primary key is planned as deleting every row and
+// inserting every template row. Values are compared as text, except that
+// numbers compare by value and true/false match 1/0.
+//
+// Template expressions are resolved as sync would, except that secrets are
+// never fetched: they and the non-deterministic expressions are noted rather
+// than shown. A row whose key depends on such a value, or on a lookup that
+// finds nothing yet, can't be matched and is planned as an insert.
+type PlanSyncAction struct {
+	DB     DBAdapter
+	Tables []domain.Table
+}
+
+// Execute builds the plan.
+func (a PlanSyncAction) Execute(ctx context.Context) (*SyncPlan, error) {
+	plan := &SyncPlan{}
+	for _, table := range a.Tables {
+		tp, err := a.planTable(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		plan.Tables = append(plan.Tables, tp)
+	}
+	return plan, nil
+}
+
+func (a PlanSyncAction) planTable(ctx context.Context, table domain.Table) (TablePlan, error) {
+	tp := TablePlan{Table: table.Name, Strategy: table.Strategy}
+
+	raw, err := loadTypedRows(ctx, a.DB, table, nil)
+	if err != nil {
+		return tp, err
+	}
+	rows, err := a.previewRows(ctx, table.Name, raw)
+	if err != nil {
+		return tp, err
+	}
+	existing, err := a.DB.ReadRows(ctx, table.Name)
+	if err != nil {
+		return tp, err
+	}
+
+	// Rows to delete come from the database, so their columns that the
+	// templates fill with secret or volatile expressions are redacted too.
+	hidden := generatedColumns(rows)
+
+	key, err := tableKey(ctx, a.DB, table)
+	if errors.Is(err, domain.ErrNoKey) && table.Strategy == domain.StrategyTruncate {
+		for _, row := range existing {
+			tp.Deletes = append(tp.Deletes, RowPlan{Values: columnValues(row, sortedColumns(row), hidden)})
+		}
+		for _, row := range rows {
+			tp.Inserts = append(tp.Inserts, RowPlan{Values: row.columnValues(row.columns())})
+		}
+		return tp, nil
+	}
+	if err != nil {
+		return tp, err
+	}
+	if err := checkKeyValues(table.Name, key, raw); err != nil {
+		return tp, err
+	}
+	tp.Key = key
+
+	current := make(map[string]map[string]any, len(existing))
+	for _, row := range existing {
+		current[keyString(rowKey(row, key))] = row
+	}
+
+	wanted := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		cols := nonKeyColumns(row.columns(), key)
+		if !row.known(key) {
+			tp.Inserts = append(tp.Inserts, RowPlan{Key: row.columnValues(key), Values: row.columnValues(cols)})
+			continue
+		}
+		k := keyString(rowKey(row.values, key))
+		wanted[k] = true
+
+		before, ok := current[k]
+		if !ok {
+			tp.Inserts = append(tp.Inserts, RowPlan{Key: row.columnValues(key), Values: row.columnValues(cols)})
+			continue
+		}
+		var changes []ColumnChange
+		for _, col := range cols {
+			switch row.notes[col] {
+			case noteGenerated:
+				changes = append(changes, ColumnChange{Column: col, Regenerated: true})
+			case noteDeferred:
+				changes = append(changes, ColumnChange{Column: col, Before: formatValue(before[col]), Deferred: true})
+			default:
+				if !sameValue(formatValue(before[col]), formatValue(row.values[col])) {
+					changes = append(changes, ColumnChange{Column: col, Before: formatValue(before[col]), After: formatValue(row.values[col])})
+				}
+			}
+		}
+		if len(changes) > 0 {
+			tp.Updates = append(tp.Updates, RowUpdatePlan{Key: row.columnValues(key), Changes: changes})
+		}
+	}
+
+	if table.Strategy != domain.StrategyUpdate {
+		var stale []string
+		for k := range current {
+			if !wanted[k] {
+				stale = append(stale, k)
+			}
+		}
+		sort.Strings(stale)
+		for _, k := range stale {
+			row := current[k]
+			tp.Deletes = append(tp.Deletes, RowPlan{Key: columnValues(row, key, hidden), Values: columnValues(row, nonKeyColumns(sortedColumns(row), key), hidden)})
+		}
+	}
+	return tp, nil
+}
+
+const (
+	noteGenerated = "generated"
+	noteDeferred  = "lookup, resolved at apply time"
+	noteRedacted  = "redacted"
+)
+
+// previewRow is a template row with its expressions resolved for the plan.
+// Columns whose value can't be shown have a note instead of a value.
+type previewRow struct {
+	values map[string]any
+	notes  map[string]string
+}
+
+// previewRows resolves the template expressions of rows as far as a plan
+// can: volatile and secret expressions are noted as generated, and lookups
+// that find no row yet as deferred.
+func (a PlanSyncAction) previewRows(ctx context.Context, table string, rows []map[string]any) ([]previewRow, error) {
+	resolver := expr.Resolver{DB: a.DB}
+	out := make([]previewRow, len(rows))
+	for i, row := range rows {
+		p := previewRow{values: make(map[string]any, len(row)), notes: make(map[string]string)}
+		for col, v := range row {
+			if expr.IsVolatile(v) {
+				p.notes[col] = noteGenerated
+				continue
+			}
+			val, err := resolver.Value(ctx, v)
+			if errors.Is(err, expr.ErrLookupNotFound) {
+				p.notes[col] = noteDeferred
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("previewing %s, column %q: %w", table, col, err)
+			}
+			p.values[col] = val
+		}
+		out[i] = p
+	}
+	return out, nil
+}
+
+func (p previewRow) columns() []string {
+	cols := sortedColumns(p.values)
+	for col := range p.notes {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// known reports whether the row has a value for every column of key.
+func (p previewRow) known(key []string) bool {
+	for _, k := range key {
+		if _, ok := p.notes[k]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p previewRow) columnValues(cols []string) []ColumnValue {
+	values := make([]ColumnValue, len(cols))
+	for i, col := range cols {
+		if note, ok := p.notes[col]; ok {
+			values[i] = ColumnValue{Column: col, Note: note}
+			continue
+		}
+		values[i] = ColumnValue{Column: col, Value: formatValue(p.values[col])}
+	}
+	return values
+}
+
+// rowKey returns the row's values for the key columns.
+func rowKey(row map[string]any, key []string) []any {
+	values := make([]any, len(key))
+	for i, k := range key {
+		values[i] = row[k]
+	}
+	return values
+}
+
+func sortedColumns(row map[string]any) []string {
+	cols := make([]string, 0, len(row))
+	for col := range row {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// nonKeyColumns returns the columns that aren't in key, in order.
+func nonKeyColumns(columns []string, key []string) []string {
+	var cols []string
+	for _, col := range columns {
+		isKey := false
+		for _, k := range key {
+			isKey = isKey || k == col
+		}
+		if !isKey {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+func columnValues(row map[string]any, cols []string, hidden map[string]bool) []ColumnValue {
+	values := make([]ColumnValue, len(cols))
+	for i, col := range cols {
+		if hidden[col] {
+			values[i] = ColumnValue{Column: col, Note: noteRedacted}
+			continue
+		}
+		values[i] = ColumnValue{Column: col, Value: formatValue(row[col])}
+	}
+	return values
+}
+
+// generatedColumns returns the columns noted as generated in any of rows.
+func generatedColumns(rows []previewRow) map[string]bool {
+	cols := make(map[string]bool)
+	for _, row := range rows {
+		for col, note := range row.notes {
+			if note == noteGenerated {
+				cols[col] = true
+			}
+		}
+	}
+	return cols
+}
+
+// formatValue renders a database or template value as text. Dates read as
+// midnight timestamps are shown without the time.
+func formatValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(t)
+	case time.Time:
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+			return t.Format(time.DateOnly)
+		}
+		return t.Format(time.DateTime)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sameValue reports whether a database value and a template value, as text,
+// are the same. Numbers compare by value (1.50 and 1.5), and true/false
+// match 1/0, since MySQL stores booleans as TINYINT.
+func sameValue(a, b string) bool {
+	if a == b {
+		return true
+	}
+	a, b = boolAsNumber(a), boolAsNumber(b)
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && fa == fb
+}
+
+func boolAsNumber(s string) string {
+	switch strings.ToLower(s) {
+	case "true":
+		return "1"
+	case "false":
+		return "0"
+	}
+	return s
+}
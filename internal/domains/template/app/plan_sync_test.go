@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestPlanSync(t *testing.T) {
+	ctx := context.Background()
+
+	existing := []map[string]any{
+		{"id": int64(1), "name": "alice", "score": "1.50"},
+		{"id": int64(2), "name": "bob", "score": "2.00"},
+		{"id": int64(3), "name": "carol", "score": "3.00"},
+	}
+
+	t.Run("it plans inserts and column changes, leaving other rows alone for update", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}, rows: existing}
+		table := csvTable(t, "id,name,score\n1,alice,1.5\n2,robert,2\n4,dave,4\n")
+
+		plan, err := PlanSyncAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tp := plan.Tables[0]
+		if len(tp.Deletes) != 0 {
+			t.Errorf("expected no deletes for update, got %+v", tp.Deletes)
+		}
+		wantInsert := RowPlan{
+			Key:    []ColumnValue{{Column: "id", Value: "4"}},
+			Values: []ColumnValue{{Column: "name", Value: "dave"}, {Column: "score", Value: "4"}},
+		}
+		if !reflect.DeepEqual(tp.Inserts, []RowPlan{wantInsert}) {
+			t.Errorf("expected %+v, got %+v", wantInsert, tp.Inserts)
+		}
+		wantUpdate := RowUpdatePlan{
+			Key:     []ColumnValue{{Column: "id", Value: "2"}},
+			Changes: []ColumnChange{{Column: "name", Before: "bob", After: "robert"}},
+		}
+		if !reflect.DeepEqual(tp.Updates, []RowUpdatePlan{wantUpdate}) {
+			t.Errorf("expected %+v, got %+v", wantUpdate, tp.Updates)
+		}
+	})
+
+	t.Run("it plans deletes of rows not in the templates for delete", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}, rows: existing}
+		table := csvTable(t, "id,name,score\n1,alice,1.5\n")
+		table.Strategy = domain.StrategyDelete
+
+		plan, err := PlanSyncAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tp := plan.Tables[0]
+		if len(tp.Deletes) != 2 || tp.Deletes[0].Key[0].Value != "2" || tp.Deletes[1].Key[0].Value != "3" {
+			t.Errorf("expected rows 2 and 3 deleted, got %+v", tp.Deletes)
+		}
+		if len(tp.Inserts) != 0 || len(tp.Updates) != 0 {
+			t.Errorf("expected nothing else, got %+v", tp)
+		}
+	})
+
+	t.Run("it plans a keyless truncate as replacing every row", func(t *testing.T) {
+		db := &mockDBAdapter{rows: existing}
+		table := csvTable(t, "name\nzoe\n")
+		table.Strategy = domain.StrategyTruncate
+
+		plan, err := PlanSyncAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tp := plan.Tables[0]
+		if len(tp.Deletes) != 3 || len(tp.Inserts) != 1 || tp.Key != nil {
+			t.Errorf("expected 3 deletes and 1 insert without a key, got %+v", tp)
+		}
+	})
+
+	t.Run("it reports no changes when the table matches", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}, rows: existing[:1]}
+		table := csvTable(t, "id,name,score\n1,alice,1.5\n")
+		table.Strategy = domain.StrategyDelete
+
+		plan, err := PlanSyncAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if plan.HasChanges() {
+			t.Errorf("expected no changes, got %+v", plan.Tables[0])
+		}
+	})
+
+	t.Run("it notes generated values and deferred lookups instead of showing them", func(t *testing.T) {
+		db := &mockDBAdapter{
+			primaryKey: []string{"id"},
+			rows:       []map[string]any{{"id": int64(1), "token": "old", "plan_id": int64(1)}},
+		}
+		table := csvTable(t, "id,token,plan_id\n1,{{ asm.seed.api_key }},\"{{ lookup|plans,id,code=pro }}\"\n2,{{ now }},\"{{ lookup|plans,id,code=pro }}\"\n")
+
+		plan, err := PlanSyncAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tp := plan.Tables[0]
+		wantInsert := RowPlan{
+			Key:    []ColumnValue{{Column: "id", Value: "2"}},
+			Values: []ColumnValue{{Column: "plan_id", Note: noteDeferred}, {Column: "token", Note: noteGenerated}},
+		}
+		if !reflect.DeepEqual(tp.Inserts, []RowPlan{wantInsert}) {
+			t.Errorf("expected %+v, got %+v", wantInsert, tp.Inserts)
+		}
+		wantUpdate := RowUpdatePlan{
+			Key: []ColumnValue{{Column: "id", Value: "1"}},
+			Changes: []ColumnChange{
+				{Column: "plan_id", Before: "1", Deferred: true},
+				{Column: "token", Regenerated: true},
+			},
+		}
+		if !reflect.DeepEqual(tp.Updates, []RowUpdatePlan{wantUpdate}) {
+			t.Errorf("expected %+v, got %+v", wantUpdate, tp.Updates)
+		}
+	})
+
+	t.Run("it redacts generated columns of the rows to delete", func(t *testing.T) {
+		db := &mockDBAdapter{
+			primaryKey: []string{"id"},
+			rows: []map[string]any{
+				{"id": int64(1), "token": "current-secret", "name": "a"},
+				{"id": int64(2), "token": "old-secret", "name": "b"},
+			},
+		}
+		table := csvTable(t, "id,token,name\n1,{{ asm.seed.api_key }},a\n")
+		table.Strategy = domain.StrategyDelete
+
+		plan, err := PlanSyncAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := RowPlan{
+			Key:    []ColumnValue{{Column: "id", Value: "2"}},
+			Values: []ColumnValue{{Column: "name", Value: "b"}, {Column: "token", Note: noteRedacted}},
+		}
+		if !reflect.DeepEqual(plan.Tables[0].Deletes, []RowPlan{want}) {
+			t.Errorf("expected %+v, got %+v", want, plan.Tables[0].Deletes)
+		}
+	})
+
+	t.Run("it resolves lookups that find a row", func(t *testing.T) {
+		db := &mockDBAdapter{
+			primaryKey: []string{"id"},
+			rows:       []map[string]any{{"id": int64(1), "plan_id": int64(7)}},
+			lookups:    map[string]any{"plans.id.code=pro": int64(7)},
+		}
+		table := csvTable(t, "id,plan_id\n1,\"{{ lookup|plans,id,code=pro }}\"\n")
+
+		plan, err := PlanSyncAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if plan.HasChanges() {
+			t.Errorf("expected no changes, got %+v", plan.Tables[0])
+		}
+	})
+}
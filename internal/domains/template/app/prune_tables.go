@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
+)
+
+// PruneTablesAction runs the delete phase of the delete strategy: from every
+// table with that strategy it deletes the rows whose key is no longer in the
+// templates, before any table is loaded, and returns how many rows it deleted
+// from each. UpsertTableAction then loads the template rows, so afterwards
+// the table holds exactly those. Tables come parents first, as
+// OrderTablesAction returns them, and are pruned in reverse, so a child's
+// stale rows are gone before its parent's references are checked. It refuses
+// to delete a row that another row still references, naming the foreign key,
+// rather than orphan or cascade to it.
+type PruneTablesAction struct {
+	DB     DBAdapter
+	Tables []domain.Table
+	// Secrets resolves {{ asm.<source>.<key> }} expressions in the key columns.
+	Secrets expr.SecretResolver
+}
+
+func (a PruneTablesAction) Execute(ctx context.Context) (map[string]int, error) {
+	deleted := make(map[string]int)
+	for i := len(a.Tables) - 1; i >= 0; i-- {
+		table := a.Tables[i]
+		if table.Strategy != domain.StrategyDelete {
+			continue
+		}
+		n, err := a.prune(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		deleted[table.Name] = n
+	}
+	return deleted, nil
+}
+
+// prune deletes the rows of table whose key is no longer in its templates.
+// Only the key columns' expressions are resolved, so a lookup in another
+// column may name a row that isn't loaded yet.
+func (a PruneTablesAction) prune(ctx context.Context, table domain.Table) (int, error) {
+	rows, err := loadTypedRows(ctx, a.DB, table, nil)
+	if err != nil {
+		return 0, err
+	}
+	key, err := tableKey(ctx, a.DB, table)
+	if err != nil {
+		return 0, err
+	}
+	resolver := newResolver(a.DB, a.Secrets)
+	for _, row := range rows {
+		for _, k := range key {
+			v, ok := row[k]
+			if !ok {
+				continue
+			}
+			if row[k], err = resolver.Value(ctx, v); err != nil {
+				return 0, fmt.Errorf("%s: %w", table.Name, err)
+			}
+		}
+	}
+	if err := checkKeyValues(table.Name, key, rows); err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		wanted[keyString(rowKey(row, key))] = true
+	}
+
+	existing, err := a.DB.ListKeys(ctx, table.Name, key)
+	if err != nil {
+		return 0, err
+	}
+	var doomed [][]any
+	for _, values := range existing {
+		if !wanted[keyString(values)] {
+			doomed = append(doomed, values)
+		}
+	}
+	if len(doomed) == 0 {
+		return 0, nil
+	}
+
+	if err := a.checkReferences(ctx, table.Name, key, doomed); err != nil {
+		return 0, err
+	}
+	return a.DB.DeleteRows(ctx, table.Name, key, doomed)
+}
+
+// checkReferences fails if any row outside doomed references a doomed row.
+func (a PruneTablesAction) checkReferences(ctx context.Context, table string, key []string, doomed [][]any) error {
+	fks, err := a.DB.ReferencingForeignKeys(ctx, table)
+	if err != nil {
+		return fmt.Errorf("reading foreign keys referencing %s: %w", table, err)
+	}
+	for _, fk := range fks {
+		n, err := a.DB.CountReferencingRows(ctx, table, key, doomed, fk)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%s: %w: %d row(s) of %s reference them through foreign key %s (%s -> %s)",
+				table, domain.ErrWouldOrphan, n, fk.Table, fk.Name,
+				strings.Join(fk.Columns, ", "), strings.Join(fk.RefColumns, ", "))
+		}
+	}
+	return nil
+}
+
+// keyString renders key values so a key read from the database and one read
+// from a template compare equal: both are compared as text.
+func keyString(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatValue(v)
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestPruneTables(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it deletes rows whose key is not in the templates", func(t *testing.T) {
+		db := &mockDBAdapter{
+			primaryKey: []string{"id"},
+			keys:       [][]any{{int64(1)}, {int64(2)}, {int64(3)}},
+		}
+		table := csvTable(t, "id,value\n1,a\n3,c\n4,d\n")
+		table.Strategy = domain.StrategyDelete
+
+		deleted, err := PruneTablesAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(db.deleted, [][]any{{int64(2)}}) {
+			t.Errorf("expected only key 2 deleted, got %v", db.deleted)
+		}
+		if deleted[table.Name] != 1 || db.upserted != nil {
+			t.Errorf("expected 1 deleted and nothing upserted, got %v", deleted)
+		}
+	})
+
+	t.Run("it refuses to delete rows that are still referenced", func(t *testing.T) {
+		db := &mockDBAdapter{
+			keys: [][]any{{"old"}},
+			fks: []domain.ForeignKeyRef{
+				{Name: "fk_orders_currency", Table: "orders", Columns: []string{"currency"}, RefColumns: []string{"code"}},
+			},
+			referencing: map[string]int{"fk_orders_currency": 2},
+		}
+		table := csvTable(t, "code,value\nnew,1\n", "code")
+		table.Strategy = domain.StrategyDelete
+
+		_, err := PruneTablesAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if !errors.Is(err, domain.ErrWouldOrphan) {
+			t.Fatalf("expected ErrWouldOrphan, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "fk_orders_currency") || !strings.Contains(err.Error(), "2 row(s) of orders") {
+			t.Errorf("expected the error to name the foreign key and table, got %v", err)
+		}
+		if db.deleted != nil {
+			t.Error("expected nothing to be deleted")
+		}
+	})
+
+	t.Run("it empties the table when there are no template rows", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}, keys: [][]any{{int64(1)}}}
+		table := csvTable(t, "id,value\n")
+		table.Strategy = domain.StrategyDelete
+
+		deleted, err := PruneTablesAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if deleted[table.Name] != 1 {
+			t.Errorf("expected the row deleted, got %v", deleted)
+		}
+	})
+
+	t.Run("it prunes the delete tables children first", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}, keys: [][]any{{int64(1)}}}
+		parent := csvTable(t, "id,value\n")
+		parent.Name, parent.Strategy = "currencies", domain.StrategyDelete
+		child := csvTable(t, "id,value\n")
+		child.Name, child.Strategy = "prices", domain.StrategyDelete
+		other := csvTable(t, "id,value\n")
+		other.Name, other.Strategy = "settings", domain.StrategyUpdate
+
+		deleted, err := PruneTablesAction{DB: db, Tables: []domain.Table{parent, other, child}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := []string{"prices", "currencies"}; !reflect.DeepEqual(db.deletedFrom, want) {
+			t.Errorf("expected deletes from %v, got %v", want, db.deletedFrom)
+		}
+		if want := map[string]int{"prices": 1, "currencies": 1}; !reflect.DeepEqual(deleted, want) {
+			t.Errorf("expected %v, got %v", want, deleted)
+		}
+	})
+
+	t.Run("it only resolves the key columns", func(t *testing.T) {
+		// The plan isn't loaded yet, so resolving plan_id would fail.
+		db := &mockDBAdapter{primaryKey: []string{"id"}, keys: [][]any{{int64(1)}}}
+		table := csvTable(t, "id,plan_id\n1,\"{{ lookup|plans,id,code=pro }}\"\n")
+		table.Strategy = domain.StrategyDelete
+
+		deleted, err := PruneTablesAction{DB: db, Tables: []domain.Table{table}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if deleted[table.Name] != 0 {
+			t.Errorf("expected nothing deleted, got %v", deleted)
+		}
+	})
+}
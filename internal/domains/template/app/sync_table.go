@@ -4,26 +4,71 @@ import (
 	"context"
 
 	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
 )
 
+// DefaultBatchSize is how many rows SyncTableAction inserts per InsertRows
+// call when BatchSize isn't set.
+const DefaultBatchSize = 1000
+
+// SyncTableAction reloads a table with the truncate strategy from its record
+// files, once TruncateTablesAction has emptied it. Rows are streamed from the
+// files and inserted BatchSize at a time, so a large table is never held in
+// memory whole.
 type SyncTableAction struct {
 	DB    DBAdapter
 	Table domain.Table
+	// BatchSize is how many rows go to each InsertRows call. Zero means
+	// DefaultBatchSize.
+	BatchSize int
+	// Progress, when set, is called after each batch with the number of rows
+	// inserted so far.
+	Progress func(inserted int)
+	// Secrets resolves {{ asm.<source>.<key> }} expressions in the rows.
+	Secrets expr.SecretResolver
 }
 
 func (a SyncTableAction) Execute(ctx context.Context) (int, error) {
-	rows, err := LoadTableDataAction{Table: a.Table}.Execute(ctx)
+	types, err := a.DB.ColumnTypes(ctx, a.Table.Name)
 	if err != nil {
 		return 0, err
 	}
 
-	if err := a.DB.TruncateTable(ctx, a.Table.Name); err != nil {
-		return 0, err
+	size := a.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
 	}
 
-	if len(rows) == 0 {
-		return 0, nil
+	inserted := 0
+	batch := make([]map[string]any, 0, size)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := a.DB.InsertRows(ctx, a.Table.Name, batch)
+		if err != nil {
+			return err
+		}
+		inserted += n
+		batch = make([]map[string]any, 0, size)
+		if a.Progress != nil {
+			a.Progress(inserted)
+		}
+		return nil
 	}
 
-	return a.DB.InsertRows(ctx, a.Table.Name, rows)
+	err = LoadTableDataAction{Table: a.Table, Types: types, Resolver: newResolver(a.DB, a.Secrets)}.Stream(ctx, func(row map[string]any) error {
+		batch = append(batch, row)
+		if len(batch) < size {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return inserted, err
+	}
+	if err := flush(); err != nil {
+		return inserted, err
+	}
+	return inserted, nil
 }
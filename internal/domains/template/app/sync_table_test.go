@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestSyncTable(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it inserts the rows in batches", func(t *testing.T) {
+		db := &mockDBAdapter{}
+		table := csvTable(t, "code,value\na,1\nb,2\nc,3\nd,4\ne,5\n")
+		table.Strategy = domain.StrategyTruncate
+
+		var progress []int
+		n, err := SyncTableAction{
+			DB:        db,
+			Table:     table,
+			BatchSize: 2,
+			Progress:  func(inserted int) { progress = append(progress, inserted) },
+		}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 5 {
+			t.Errorf("expected 5 rows inserted, got %d", n)
+		}
+		if len(db.truncated) != 0 {
+			t.Errorf("expected the table to be left to TruncateTablesAction, got %v truncated", db.truncated)
+		}
+		var sizes []int
+		for _, b := range db.batches {
+			sizes = append(sizes, len(b))
+		}
+		if !reflect.DeepEqual(sizes, []int{2, 2, 1}) {
+			t.Errorf("expected batches of 2, 2, 1, got %v", sizes)
+		}
+		if db.batches[2][0]["code"] != "e" {
+			t.Errorf("expected the last batch to hold row e, got %v", db.batches[2])
+		}
+		if !reflect.DeepEqual(progress, []int{2, 4, 5}) {
+			t.Errorf("expected progress 2, 4, 5, got %v", progress)
+		}
+	})
+
+	t.Run("it uses the default batch size when none is set", func(t *testing.T) {
+		db := &mockDBAdapter{}
+		table := csvTable(t, "code,value\na,1\nb,2\n")
+		table.Strategy = domain.StrategyTruncate
+
+		if _, err := (SyncTableAction{DB: db, Table: table}).Execute(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(db.batches) != 1 || len(db.batches[0]) != 2 {
+			t.Errorf("expected one batch of 2 rows, got %v", db.batches)
+		}
+	})
+
+	t.Run("it inserts nothing for an empty table", func(t *testing.T) {
+		db := &mockDBAdapter{}
+		table := csvTable(t, "code,value\n")
+		table.Strategy = domain.StrategyTruncate
+
+		n, err := SyncTableAction{DB: db, Table: table}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 0 || len(db.batches) != 0 {
+			t.Errorf("expected no inserts, got %d rows in %v", n, db.batches)
+		}
+	})
+
+	t.Run("it stops at an invalid row", func(t *testing.T) {
+		db := &mockDBAdapter{}
+		table := csvTable(t, "code,value\na,1\nb\n")
+		table.Strategy = domain.StrategyTruncate
+
+		_, err := SyncTableAction{DB: db, Table: table, BatchSize: 1}.Execute(ctx)
+		if !errors.Is(err, domain.ErrInvalidRecord) {
+			t.Errorf("expected ErrInvalidRecord, got %v", err)
+		}
+	})
+}
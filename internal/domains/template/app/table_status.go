@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"sort"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+// TableStatusAction compares the configured template tables with the
+// joka_templates tracking table to determine which are synced, modified,
+// new, or orphaned.
+type TableStatusAction struct {
+	DB     DBAdapter
+	Tables []domain.Table
+}
+
+// Execute returns the status of every configured or tracked table.
+func (a TableStatusAction) Execute(ctx context.Context) ([]domain.TableSyncInfo, error) {
+	synced, err := a.DB.GetAllSyncedTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var result []domain.TableSyncInfo
+
+	for _, table := range a.Tables {
+		seen[table.Name] = true
+
+		hash, err := HashTable(table)
+		if err != nil {
+			return nil, err
+		}
+
+		dbHash, tracked := synced[table.Name]
+		switch {
+		case !tracked:
+			result = append(result, domain.TableSyncInfo{Table: table.Name, Status: domain.StatusNew})
+		case dbHash != hash:
+			result = append(result, domain.TableSyncInfo{Table: table.Name, Status: domain.StatusModified})
+		default:
+			result = append(result, domain.TableSyncInfo{Table: table.Name, Status: domain.StatusSynced})
+		}
+	}
+
+	for name := range synced {
+		if !seen[name] {
+			result = append(result, domain.TableSyncInfo{Table: name, Status: domain.StatusOrphaned})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Table < result[j].Table
+	})
+
+	return result, nil
+}
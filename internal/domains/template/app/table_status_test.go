@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestTableStatus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it reports synced, modified, new and orphaned tables", func(t *testing.T) {
+		synced := csvTable(t, "id\n1\n")
+		synced.Name = "currencies"
+		modified := csvTable(t, "id\n2\n")
+		modified.Name = "plans"
+		added := csvTable(t, "id\n3\n")
+		added.Name = "regions"
+
+		hash, err := HashTable(synced)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		db := &mockDBAdapter{synced: map[string]string{
+			"currencies": hash,
+			"plans":      "stale",
+			"flags":      "whatever",
+		}}
+
+		result, err := TableStatusAction{DB: db, Tables: []domain.Table{synced, modified, added}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []domain.TableSyncInfo{
+			{Table: "currencies", Status: domain.StatusSynced},
+			{Table: "flags", Status: domain.StatusOrphaned},
+			{Table: "plans", Status: domain.StatusModified},
+			{Table: "regions", Status: domain.StatusNew},
+		}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("expected %+v, got %+v", want, result)
+		}
+	})
+}
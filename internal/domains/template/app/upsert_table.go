@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
+)
+
+// UpsertTableAction syncs a table with the update strategy: each template
+// row is inserted, or updates the row with the same key. Rows that aren't in
+// the templates are left alone.
+type UpsertTableAction struct {
+	DB    DBAdapter
+	Table domain.Table
+	// Secrets resolves {{ asm.<source>.<key> }} expressions in the rows.
+	Secrets expr.SecretResolver
+}
+
+func (a UpsertTableAction) Execute(ctx context.Context) (domain.SyncResult, error) {
+	rows, err := loadTypedRows(ctx, a.DB, a.Table, newResolver(a.DB, a.Secrets))
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+	if len(rows) == 0 {
+		return domain.SyncResult{}, nil
+	}
+
+	key, err := tableKey(ctx, a.DB, a.Table)
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+	if err := checkKeyValues(a.Table.Name, key, rows); err != nil {
+		return domain.SyncResult{}, err
+	}
+
+	return a.DB.UpsertRows(ctx, a.Table.Name, key, rows)
+}
+
+// tableKey returns the configured key of a table, or else its primary key.
+func tableKey(ctx context.Context, db DBAdapter, table domain.Table) ([]string, error) {
+	if len(table.Key) > 0 {
+		return table.Key, nil
+	}
+	key, err := db.PrimaryKey(ctx, table.Name)
+	if err != nil {
+		return nil, fmt.Errorf("reading primary key of %s: %w", table.Name, err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%s: %w", table.Name, domain.ErrNoKey)
+	}
+	return key, nil
+}
+
+// checkKeyValues makes sure every row sets every key column.
+func checkKeyValues(table string, key []string, rows []map[string]any) error {
+	for i, row := range rows {
+		for _, k := range key {
+			if v, ok := row[k]; !ok || v == nil {
+				return fmt.Errorf("%s: row %d has no value for key column %q", table, i+1, k)
+			}
+		}
+	}
+	return nil
+}
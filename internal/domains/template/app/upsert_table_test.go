@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
+)
+
+type mockDBAdapter struct {
+	primaryKey  []string
+	upsertKey   []string
+	upserted    []map[string]any
+	keys        [][]any
+	rows        []map[string]any
+	fks         []domain.ForeignKeyRef
+	fksTo       map[string][]domain.ForeignKeyRef // table -> fks referencing it, overriding fks
+	referencing map[string]int                    // fk name -> referencing rows
+	deleted     [][]any
+	deletedFrom []string          // table of each DeleteRows call
+	synced      map[string]string // table -> content hash
+	columnTypes map[string]domain.ColumnType
+	truncated   []string
+	batches     [][]map[string]any // rows of each InsertRows call
+	lookups     map[string]any     // "table.return_col.where_col=value" -> value
+}
+
+func (m *mockDBAdapter) EnsureTrackingTable(ctx context.Context) error { return nil }
+func (m *mockDBAdapter) GetAllSyncedTables(ctx context.Context) (map[string]string, error) {
+	return m.synced, nil
+}
+func (m *mockDBAdapter) RecordTableSynced(ctx context.Context, tableName, contentHash string) error {
+	return nil
+}
+
+func (m *mockDBAdapter) TruncateTables(ctx context.Context, tables []domain.Table) error {
+	for _, t := range tables {
+		m.truncated = append(m.truncated, t.Name)
+	}
+	return nil
+}
+func (m *mockDBAdapter) InsertRows(ctx context.Context, tableName string, rows []map[string]any) (int, error) {
+	m.batches = append(m.batches, rows)
+	return len(rows), nil
+}
+func (m *mockDBAdapter) UpsertRows(ctx context.Context, tableName string, key []string, rows []map[string]any) (domain.SyncResult, error) {
+	m.upsertKey = key
+	m.upserted = rows
+	return domain.SyncResult{Inserted: len(rows)}, nil
+}
+func (m *mockDBAdapter) LookupValue(ctx context.Context, table, returnCol, whereCol string, whereVal any) (any, error) {
+	v, ok := m.lookups[fmt.Sprintf("%s.%s.%s=%v", table, returnCol, whereCol, whereVal)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s.%s where %s=%v", expr.ErrLookupNotFound, table, returnCol, whereCol, whereVal)
+	}
+	return v, nil
+}
+func (m *mockDBAdapter) ColumnTypes(ctx context.Context, tableName string) (map[string]domain.ColumnType, error) {
+	return m.columnTypes, nil
+}
+func (m *mockDBAdapter) PrimaryKey(ctx context.Context, tableName string) ([]string, error) {
+	return m.primaryKey, nil
+}
+func (m *mockDBAdapter) ListKeys(ctx context.Context, tableName string, key []string) ([][]any, error) {
+	return m.keys, nil
+}
+func (m *mockDBAdapter) ReadRows(ctx context.Context, tableName string) ([]map[string]any, error) {
+	return m.rows, nil
+}
+func (m *mockDBAdapter) ReferencingForeignKeys(ctx context.Context, tableName string) ([]domain.ForeignKeyRef, error) {
+	if m.fksTo != nil {
+		return m.fksTo[tableName], nil
+	}
+	return m.fks, nil
+}
+func (m *mockDBAdapter) CountReferencingRows(ctx context.Context, tableName string, key []string, keys [][]any, fk domain.ForeignKeyRef) (int, error) {
+	return m.referencing[fk.Name], nil
+}
+func (m *mockDBAdapter) DeleteRows(ctx context.Context, tableName string, key []string, keys [][]any) (int, error) {
+	m.deleted = keys
+	m.deletedFrom = append(m.deletedFrom, tableName)
+	return len(keys), nil
+}
+func (m *mockDBAdapter) DisableForeignKeys(ctx context.Context) error { return nil }
+func (m *mockDBAdapter) EnableForeignKeys(ctx context.Context) error  { return nil }
+
+// csvTable writes content as the only record of a table.
+func csvTable(t *testing.T, content string, key ...string) domain.Table {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rows.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing csv: %v", err)
+	}
+	return domain.Table{
+		Name:     "settings",
+		Strategy: domain.StrategyUpdate,
+		Key:      key,
+		Records:  []domain.Record{{Name: "rows", Path: path, Type: domain.RecordTypeList}},
+	}
+}
+
+func TestUpsertTable(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("it upserts by the configured key", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}}
+		table := csvTable(t, "code,value\ntimeout,30\nretries,3\n", "code")
+
+		result, err := UpsertTableAction{DB: db, Table: table}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(db.upsertKey, []string{"code"}) {
+			t.Errorf("expected key [code], got %v", db.upsertKey)
+		}
+		if result.Inserted != 2 || len(db.upserted) != 2 {
+			t.Errorf("expected 2 rows upserted, got %+v", result)
+		}
+	})
+
+	t.Run("it defaults to the primary key", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}}
+		table := csvTable(t, "id,value\n1,30\n")
+
+		if _, err := (UpsertTableAction{DB: db, Table: table}).Execute(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(db.upsertKey, []string{"id"}) {
+			t.Errorf("expected key [id], got %v", db.upsertKey)
+		}
+	})
+
+	t.Run("it errors when the table has no key", func(t *testing.T) {
+		table := csvTable(t, "code,value\ntimeout,30\n")
+
+		_, err := UpsertTableAction{DB: &mockDBAdapter{}, Table: table}.Execute(ctx)
+		if !errors.Is(err, domain.ErrNoKey) {
+			t.Errorf("expected ErrNoKey, got %v", err)
+		}
+	})
+
+	t.Run("it errors when a row has no value for a key column", func(t *testing.T) {
+		db := &mockDBAdapter{}
+		table := csvTable(t, "value\n30\n", "code")
+
+		if _, err := (UpsertTableAction{DB: db, Table: table}).Execute(ctx); err == nil {
+			t.Error("expected an error")
+		}
+		if db.upserted != nil {
+			t.Error("expected nothing to be written")
+		}
+	})
+
+	t.Run("it converts cells to the live column types and loads the NULL token as NULL", func(t *testing.T) {
+		db := &mockDBAdapter{
+			primaryKey:  []string{"id"},
+			columnTypes: map[string]domain.ColumnType{"id": domain.ColumnInt, "active": domain.ColumnBool},
+		}
+		table := csvTable(t, "id,active,note\n1,yes,\\N\n")
+		table.NullToken = `\N`
+
+		if _, err := (UpsertTableAction{DB: db, Table: table}).Execute(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []map[string]any{{"id": int64(1), "active": true, "note": nil}}
+		if !reflect.DeepEqual(db.upserted, want) {
+			t.Errorf("expected %v, got %v", want, db.upserted)
+		}
+	})
+
+	t.Run("it resolves lookups and secrets in template expressions", func(t *testing.T) {
+		db := &mockDBAdapter{
+			primaryKey:  []string{"id"},
+			columnTypes: map[string]domain.ColumnType{"id": domain.ColumnInt, "plan_id": domain.ColumnInt},
+			lookups:     map[string]any{"plans.id.code=pro": int64(7)},
+		}
+		table := csvTable(t, "id,plan_id,api_key\n1,\"{{ lookup|plans,id,code=pro }}\",{{ asm.seed.api_key }}\n")
+
+		_, err := UpsertTableAction{DB: db, Table: table, Secrets: secretsMock{"seed.api_key": "s3cret"}}.Execute(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []map[string]any{{"id": int64(1), "plan_id": int64(7), "api_key": "s3cret"}}
+		if !reflect.DeepEqual(db.upserted, want) {
+			t.Errorf("expected %v, got %v", want, db.upserted)
+		}
+	})
+
+	t.Run("it fails on a lookup that matches no row", func(t *testing.T) {
+		db := &mockDBAdapter{primaryKey: []string{"id"}}
+		table := csvTable(t, "id,plan_id\n1,\"{{ lookup|plans,id,code=gone }}\"\n")
+
+		_, err := UpsertTableAction{DB: db, Table: table}.Execute(ctx)
+		if !errors.Is(err, expr.ErrLookupNotFound) {
+			t.Errorf("expected ErrLookupNotFound, got %v", err)
+		}
+	})
+}
+
+// secretsMock resolves "source.key" to a value.
+type secretsMock map[string]string
+
+func (m secretsMock) Resolve(_ context.Context, source, key string) (string, error) {
+	v, ok := m[source+"."+key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret source %q", key, source)
+	}
+	return v, nil
+}
@@ -4,4 +4,8 @@ import "errors"
 
 var (
 	ErrTableNotFound = errors.New("table does not exist")
+	ErrNoKey         = errors.New("table has no primary key; set key in its table config")
+	ErrWouldOrphan   = errors.New("deleting rows would orphan rows that reference them")
+	ErrRecordShape   = errors.New("record file does not hold rows")
+	ErrInvalidRecord = errors.New("invalid record")
 )
@@ -8,6 +8,8 @@ const (
 	StrategyTruncate StrategyType = "truncate"
 )
 
+// RecordType is the shape of a record file: a list format always holds many
+// rows, while a row document (YAML or JSON) holds one row or a list of rows.
 type RecordType string
 
 const (
@@ -15,15 +17,126 @@ const (
 	RecordTypeList RecordType = "list"
 )
 
+// RecordFormat is the file format of a record, from its extension.
+type RecordFormat string
+
+const (
+	FormatYAML   RecordFormat = "yaml"
+	FormatJSON   RecordFormat = "json"
+	FormatNDJSON RecordFormat = "ndjson"
+	FormatCSV    RecordFormat = "csv"
+	FormatTSV    RecordFormat = "tsv"
+)
+
 type Record struct {
-	Name string
-	Path string
-	Type RecordType
+	Name   string
+	Path   string
+	Type   RecordType
+	Format RecordFormat
 }
 
 type Table struct {
 	Name     string
 	Path     string
 	Strategy StrategyType
+	// Key is the columns that identify a row for the update and delete
+	// strategies. Empty means the table's primary key.
+	Key []string
+	// NullToken is the CSV/TSV cell text that loads as NULL. Empty means no
+	// cell is NULL.
+	NullToken string
+	// Columns reshape the rows as they load, in Source order.
+	Columns []ColumnMapping
+	// Truncate is how the truncate strategy empties the table.
+	Truncate TruncateOptions
 	Records  []Record
 }
+
+// TruncateOptions modify the TRUNCATE the truncate strategy runs. The zero
+// value resets the table's identity (auto-increment) columns and fails if a
+// table outside the statement references it.
+type TruncateOptions struct {
+	// ContinueIdentity keeps the identity sequences where they are
+	// (PostgreSQL only; MySQL always resets AUTO_INCREMENT).
+	ContinueIdentity bool
+	// Cascade also truncates every table that references the table
+	// (PostgreSQL only).
+	Cascade bool
+}
+
+// ColumnMapping reshapes one column of a table's rows, from the table's
+// `columns:` config. Source is the column as the record files name it (or,
+// for a computed column, the column to write).
+type ColumnMapping struct {
+	Source string
+	// Column is the database column Source is written to. Empty keeps the
+	// name.
+	Column string
+	// Default is written when a row doesn't have the column at all. Nil
+	// means none.
+	Default any
+	// Ignore drops the column from every row.
+	Ignore bool
+	// Value computes the column from the row: ${name} is replaced by the
+	// row's value of the column name, after renames and defaults.
+	Value string
+}
+
+// Target is the database column the mapping writes.
+func (m ColumnMapping) Target() string {
+	if m.Column != "" {
+		return m.Column
+	}
+	return m.Source
+}
+
+// ColumnType is the kind of value a column holds, from its live database
+// type or a CSV/TSV header hint (price:decimal). CSV and TSV cells are
+// checked and converted to it; string columns take any text.
+type ColumnType string
+
+const (
+	ColumnString   ColumnType = "string"
+	ColumnBool     ColumnType = "bool"
+	ColumnInt      ColumnType = "int"
+	ColumnDecimal  ColumnType = "decimal"
+	ColumnDate     ColumnType = "date"
+	ColumnDatetime ColumnType = "datetime"
+	ColumnJSON     ColumnType = "json"
+)
+
+// SyncResult counts what syncing one table did. Truncate only inserts; only
+// delete deletes.
+type SyncResult struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+}
+
+// ForeignKeyRef is a foreign key that references a synced table: Columns of
+// Table (possibly the synced table itself) point at RefColumns.
+type ForeignKeyRef struct {
+	Name       string
+	Table      string
+	Columns    []string
+	RefColumns []string
+}
+
+// TableStatus is the sync state of a template table, from the content hash
+// recorded in joka_templates.
+type TableStatus string
+
+const (
+	StatusSynced   TableStatus = "synced"
+	StatusModified TableStatus = "modified"
+	StatusNew      TableStatus = "new"
+	StatusOrphaned TableStatus = "orphaned"
+)
+
+// TableSyncInfo pairs a table with its sync status. Orphaned tables are
+// tracked but no longer configured.
+type TableSyncInfo struct {
+	Table  string      `json:"table"`
+	Status TableStatus `json:"status"`
+}
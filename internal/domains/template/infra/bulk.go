@@ -0,0 +1,146 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// maxPlaceholders is the most bind parameters MySQL accepts in one prepared
+// statement.
+const maxPlaceholders = 65535
+
+// rowGroup is a run of consecutive rows with the same columns.
+type rowGroup struct {
+	columns []string
+	rows    []map[string]any
+}
+
+// groupRows splits rows into runs sharing the same column set, keeping their
+// order, so each run can be inserted with one column list.
+func groupRows(rows []map[string]any) []rowGroup {
+	var groups []rowGroup
+	var last string
+	for _, row := range rows {
+		cols := make([]string, 0, len(row))
+		for c := range row {
+			cols = append(cols, c)
+		}
+		sort.Strings(cols)
+		sig := strings.Join(cols, "\x00")
+		if n := len(groups); n > 0 && sig == last {
+			groups[n-1].rows = append(groups[n-1].rows, row)
+			continue
+		}
+		groups = append(groups, rowGroup{columns: cols, rows: []map[string]any{row}})
+		last = sig
+	}
+	return groups
+}
+
+// unionColumns returns every column any of rows has, sorted.
+func unionColumns(rows []map[string]any) []string {
+	seen := make(map[string]bool)
+	var cols []string
+	for _, row := range rows {
+		for c := range row {
+			if !seen[c] {
+				seen[c] = true
+				cols = append(cols, c)
+			}
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// insertQuery renders a multi-row INSERT of rows into table and its args. A
+// row without one of the columns gets DEFAULT, the column's database default.
+func (d dialect) insertQuery(table string, columns []string, rows []map[string]any) (string, []any) {
+	args := make([]any, 0, len(columns)*len(rows))
+	tuples := make([]string, len(rows))
+	for i, row := range rows {
+		ph := make([]string, len(columns))
+		for j, c := range columns {
+			v, ok := row[c]
+			if !ok {
+				ph[j] = "DEFAULT"
+				continue
+			}
+			args = append(args, v)
+			ph[j] = d.placeholder(len(args))
+		}
+		tuples[i] = "(" + strings.Join(ph, ", ") + ")"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		d.quote(table), d.columns("", columns), strings.Join(tuples, ", ")), args
+}
+
+// insertBatched inserts rows with multi-row INSERTs over the union of their
+// columns, as few as fit under maxPlaceholders.
+func insertBatched(ctx context.Context, db DBTX, d dialect, table string, rows []map[string]any) error {
+	columns := unionColumns(rows)
+	if len(columns) == 0 {
+		return nil
+	}
+	per := maxPlaceholders / len(columns)
+	for start := 0; start < len(rows); start += per {
+		end := min(start+per, len(rows))
+		query, args := d.insertQuery(table, columns, rows[start:end])
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRows loads rows into a PostgreSQL table with COPY FROM STDIN, one COPY
+// per group of rows sharing their columns, since COPY can't write DEFAULT;
+// leaving a column out of a group's COPY gives it its default instead. COPY
+// has to run inside a transaction, so when db isn't one, copyRows opens and
+// commits its own.
+func copyRows(ctx context.Context, db DBTX, table string, rows []map[string]any) error {
+	if conn, ok := db.(*sql.DB); ok {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := copyRows(ctx, tx, table, rows); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
+	for _, g := range groupRows(rows) {
+		if err := copyGroup(ctx, db, table, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyGroup(ctx context.Context, db DBTX, table string, g rowGroup) error {
+	stmt, err := db.PrepareContext(ctx, pq.CopyIn(table, g.columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]any, len(g.columns))
+	for _, row := range g.rows {
+		for i, c := range g.columns {
+			args[i] = row[c]
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+	}
+	// An Exec without arguments flushes the buffered rows and ends the COPY.
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
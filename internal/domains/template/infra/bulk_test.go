@@ -0,0 +1,90 @@
+package infra
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestGroupRows(t *testing.T) {
+	t.Run("it groups consecutive rows with the same columns", func(t *testing.T) {
+		rows := []map[string]any{
+			{"id": 1, "name": "a"},
+			{"name": "b", "id": 2},
+			{"id": 3},
+			{"id": 4, "name": "d"},
+		}
+
+		groups := groupRows(rows)
+		if len(groups) != 3 {
+			t.Fatalf("expected 3 groups, got %d", len(groups))
+		}
+		if !reflect.DeepEqual(groups[0].columns, []string{"id", "name"}) || len(groups[0].rows) != 2 {
+			t.Errorf("unexpected first group: %+v", groups[0])
+		}
+		if !reflect.DeepEqual(groups[1].columns, []string{"id"}) || len(groups[1].rows) != 1 {
+			t.Errorf("unexpected second group: %+v", groups[1])
+		}
+		if groups[2].rows[0]["id"] != 4 {
+			t.Errorf("expected the last group to keep row order, got %+v", groups[2])
+		}
+	})
+}
+
+func TestInsertQuery(t *testing.T) {
+	rows := []map[string]any{
+		{"id": 1, "name": "a"},
+		{"id": 2, "name": nil},
+	}
+
+	t.Run("it renders a multi-row MySQL insert", func(t *testing.T) {
+		query, args := mysqlDialect.insertQuery("users", []string{"id", "name"}, rows)
+		if query != "INSERT INTO `users` (`id`, `name`) VALUES (?, ?), (?, ?)" {
+			t.Errorf("unexpected query: %s", query)
+		}
+		if !reflect.DeepEqual(args, []any{1, "a", 2, nil}) {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("it writes DEFAULT for columns a row doesn't have", func(t *testing.T) {
+		mixed := []map[string]any{{"id": 1, "name": "a"}, {"id": 2}}
+		columns := unionColumns(mixed)
+		if !reflect.DeepEqual(columns, []string{"id", "name"}) {
+			t.Fatalf("expected the union id, name, got %v", columns)
+		}
+		query, args := mysqlDialect.insertQuery("users", columns, mixed)
+		if query != "INSERT INTO `users` (`id`, `name`) VALUES (?, ?), (?, DEFAULT)" {
+			t.Errorf("unexpected query: %s", query)
+		}
+		if !reflect.DeepEqual(args, []any{1, "a", 2}) {
+			t.Errorf("unexpected args: %v", args)
+		}
+	})
+
+	t.Run("it numbers PostgreSQL placeholders across rows", func(t *testing.T) {
+		query, _ := postgresDialect.insertQuery("users", []string{"id", "name"}, rows)
+		if query != `INSERT INTO "users" ("id", "name") VALUES ($1, $2), ($3, $4)` {
+			t.Errorf("unexpected query: %s", query)
+		}
+	})
+}
+
+func TestTruncateQuery(t *testing.T) {
+	tables := []domain.Table{{Name: "posts"}, {Name: "users"}}
+
+	t.Run("it truncates the tables together and restarts their identities", func(t *testing.T) {
+		query := truncateQuery(tables, domain.TruncateOptions{})
+		if query != `TRUNCATE TABLE "posts", "users" RESTART IDENTITY` {
+			t.Errorf("unexpected query: %s", query)
+		}
+	})
+
+	t.Run("it renders the configured options", func(t *testing.T) {
+		query := truncateQuery(tables[:1], domain.TruncateOptions{ContinueIdentity: true, Cascade: true})
+		if query != `TRUNCATE TABLE "posts" CONTINUE IDENTITY CASCADE` {
+			t.Errorf("unexpected query: %s", query)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+// headerHints maps the type names a CSV/TSV header may carry after a colon
+// (price:decimal) to column types.
+var headerHints = map[string]domain.ColumnType{
+	"string":    domain.ColumnString,
+	"text":      domain.ColumnString,
+	"bool":      domain.ColumnBool,
+	"boolean":   domain.ColumnBool,
+	"int":       domain.ColumnInt,
+	"integer":   domain.ColumnInt,
+	"decimal":   domain.ColumnDecimal,
+	"numeric":   domain.ColumnDecimal,
+	"float":     domain.ColumnDecimal,
+	"date":      domain.ColumnDate,
+	"datetime":  domain.ColumnDatetime,
+	"timestamp": domain.ColumnDatetime,
+	"json":      domain.ColumnJSON,
+}
+
+// parseHeader splits a header into its column name and type hint. A suffix
+// that isn't a known type name is part of the column name.
+func parseHeader(header string) (string, domain.ColumnType) {
+	i := strings.LastIndex(header, ":")
+	if i < 0 {
+		return header, ""
+	}
+	hint, ok := headerHints[strings.ToLower(strings.TrimSpace(header[i+1:]))]
+	if !ok {
+		return header, ""
+	}
+	return strings.TrimSpace(header[:i]), hint
+}
+
+// columnTypeOf classifies a live column by its information_schema data_type
+// (and, on MySQL, column_type, where tinyint(1) is a boolean).
+func columnTypeOf(dataType, columnType string) domain.ColumnType {
+	dataType = strings.ToLower(dataType)
+	switch {
+	case dataType == "boolean" || strings.ToLower(columnType) == "tinyint(1)":
+		return domain.ColumnBool
+	case strings.HasSuffix(dataType, "int") || dataType == "integer":
+		return domain.ColumnInt
+	case dataType == "decimal" || dataType == "numeric" || dataType == "float" || dataType == "double" ||
+		dataType == "real" || dataType == "double precision":
+		return domain.ColumnDecimal
+	case dataType == "date":
+		return domain.ColumnDate
+	case dataType == "datetime" || strings.HasPrefix(dataType, "timestamp"):
+		return domain.ColumnDatetime
+	case dataType == "json" || dataType == "jsonb":
+		return domain.ColumnJSON
+	default:
+		return domain.ColumnString
+	}
+}
+
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+var datetimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+	time.DateOnly,
+}
+
+// coerce checks a CSV/TSV cell against a column type and converts it:
+// booleans and integers become bool and int64, while decimals, dates,
+// datetimes and JSON are validated and kept as text, so the database
+// parses them with full precision and in its own time zone.
+func coerce(cell string, t domain.ColumnType) (any, error) {
+	switch t {
+	case domain.ColumnBool:
+		switch strings.ToLower(cell) {
+		case "true", "t", "yes", "y", "1":
+			return true, nil
+		case "false", "f", "no", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", cell)
+	case domain.ColumnInt:
+		n, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", cell)
+		}
+		return n, nil
+	case domain.ColumnDecimal:
+		if !decimalPattern.MatchString(cell) {
+			return nil, fmt.Errorf("%q is not a number", cell)
+		}
+	case domain.ColumnDate:
+		if _, err := time.Parse(time.DateOnly, cell); err != nil {
+			return nil, fmt.Errorf("%q is not a date (YYYY-MM-DD)", cell)
+		}
+	case domain.ColumnDatetime:
+		valid := false
+		for _, layout := range datetimeLayouts {
+			if _, err := time.Parse(layout, cell); err == nil {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%q is not a datetime (YYYY-MM-DD HH:MM:SS)", cell)
+		}
+	case domain.ColumnJSON:
+		if !json.Valid([]byte(cell)) {
+			return nil, fmt.Errorf("%q is not valid JSON", cell)
+		}
+	}
+	return cell, nil
+}
+
+// columnTypes runs a catalog query returning column name, data type and
+// full column type per row, and classifies each column.
+func columnTypes(ctx context.Context, db DBTX, query string, args ...any) (map[string]domain.ColumnType, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]domain.ColumnType)
+	for rows.Next() {
+		var name, dataType, columnType string
+		if err := rows.Scan(&name, &dataType, &columnType); err != nil {
+			return nil, err
+		}
+		types[name] = columnTypeOf(dataType, columnType)
+	}
+	return types, rows.Err()
+}
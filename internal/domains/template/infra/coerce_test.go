@@ -0,0 +1,82 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+)
+
+func TestColumnTypeOf(t *testing.T) {
+	t.Run("it classifies MySQL and PostgreSQL column types", func(t *testing.T) {
+		cases := []struct {
+			dataType, columnType string
+			want                 domain.ColumnType
+		}{
+			{"tinyint", "tinyint(1)", domain.ColumnBool},
+			{"boolean", "bool", domain.ColumnBool},
+			{"tinyint", "tinyint unsigned", domain.ColumnInt},
+			{"bigint", "int8", domain.ColumnInt},
+			{"integer", "int4", domain.ColumnInt},
+			{"numeric", "numeric", domain.ColumnDecimal},
+			{"double precision", "float8", domain.ColumnDecimal},
+			{"date", "date", domain.ColumnDate},
+			{"timestamp with time zone", "timestamptz", domain.ColumnDatetime},
+			{"datetime", "datetime(6)", domain.ColumnDatetime},
+			{"jsonb", "jsonb", domain.ColumnJSON},
+			{"varchar", "varchar(255)", domain.ColumnString},
+			{"USER-DEFINED", "mood", domain.ColumnString},
+		}
+		for _, c := range cases {
+			if got := columnTypeOf(c.dataType, c.columnType); got != c.want {
+				t.Errorf("%s/%s: expected %s, got %s", c.dataType, c.columnType, c.want, got)
+			}
+		}
+	})
+}
+
+func TestCoerce(t *testing.T) {
+	t.Run("it accepts valid cells", func(t *testing.T) {
+		cases := []struct {
+			cell string
+			t    domain.ColumnType
+			want any
+		}{
+			{"Yes", domain.ColumnBool, true},
+			{"0", domain.ColumnBool, false},
+			{"-42", domain.ColumnInt, int64(-42)},
+			{"1.50", domain.ColumnDecimal, "1.50"},
+			{"1e-3", domain.ColumnDecimal, "1e-3"},
+			{"2024-02-29", domain.ColumnDate, "2024-02-29"},
+			{"2024-02-29 10:00:00", domain.ColumnDatetime, "2024-02-29 10:00:00"},
+			{"2024-02-29T10:00:00.5Z", domain.ColumnDatetime, "2024-02-29T10:00:00.5Z"},
+			{"[1, 2]", domain.ColumnJSON, "[1, 2]"},
+			{"anything", domain.ColumnString, "anything"},
+		}
+		for _, c := range cases {
+			got, err := coerce(c.cell, c.t)
+			if err != nil || got != c.want {
+				t.Errorf("%q as %s: expected %v, got %v (%v)", c.cell, c.t, c.want, got, err)
+			}
+		}
+	})
+
+	t.Run("it rejects invalid cells", func(t *testing.T) {
+		cases := []struct {
+			cell string
+			t    domain.ColumnType
+		}{
+			{"maybe", domain.ColumnBool},
+			{"1.5", domain.ColumnInt},
+			{"NaN", domain.ColumnDecimal},
+			{"", domain.ColumnDecimal},
+			{"2024-02-30", domain.ColumnDate},
+			{"yesterday", domain.ColumnDatetime},
+			{"{", domain.ColumnJSON},
+		}
+		for _, c := range cases {
+			if _, err := coerce(c.cell, c.t); err == nil {
+				t.Errorf("%q as %s: expected an error", c.cell, c.t)
+			}
+		}
+	})
+}
@@ -1,19 +1,40 @@
 package infra
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
 	"gopkg.in/yaml.v3"
 )
 
+// recordFormats maps the file extensions GetTables picks up to their format.
+// Files with other extensions are ignored.
+var recordFormats = map[string]domain.RecordFormat{
+	".yaml":   domain.FormatYAML,
+	".yml":    domain.FormatYAML,
+	".json":   domain.FormatJSON,
+	".ndjson": domain.FormatNDJSON,
+	".csv":    domain.FormatCSV,
+	".tsv":    domain.FormatTSV,
+}
+
 type TableConfig struct {
-	Name     string
-	Strategy domain.StrategyType
+	Name      string
+	Strategy  domain.StrategyType
+	Key       []string
+	NullToken string
+	Columns   []domain.ColumnMapping
+	Truncate  domain.TruncateOptions
 }
 
 func GetTables(templatesDir string, tableConfigs []TableConfig) ([]domain.Table, error) {
@@ -41,85 +62,338 @@ func GetTables(templatesDir string, tableConfigs []TableConfig) ([]domain.Table,
 				continue
 			}
 			ext := strings.ToLower(filepath.Ext(entry.Name()))
-			var recordType domain.RecordType
-			switch ext {
-			case ".csv":
-				recordType = domain.RecordTypeList
-			case ".yaml", ".yml":
-				recordType = domain.RecordTypeRow
-			default:
+			format, ok := recordFormats[ext]
+			if !ok {
 				continue
 			}
+			recordType := domain.RecordTypeList
+			if format == domain.FormatYAML || format == domain.FormatJSON {
+				recordType = domain.RecordTypeRow
+			}
 
 			stem := strings.TrimSuffix(entry.Name(), ext)
 			records = append(records, domain.Record{
-				Name: stem,
-				Path: filepath.Join(tablePath, entry.Name()),
-				Type: recordType,
+				Name:   stem,
+				Path:   filepath.Join(tablePath, entry.Name()),
+				Type:   recordType,
+				Format: format,
 			})
 		}
 
+		if err := checkColumns(tc.Name, tc.Columns); err != nil {
+			return nil, err
+		}
+
 		strategy := tc.Strategy
 		if strategy == "" {
 			strategy = domain.StrategyUpdate
 		}
+		if tc.Truncate != (domain.TruncateOptions{}) && strategy != domain.StrategyTruncate {
+			return nil, fmt.Errorf("table %s: truncate options apply only to the truncate strategy, not %s", tc.Name, strategy)
+		}
 
 		tables = append(tables, domain.Table{
-			Name:     tc.Name,
-			Path:     tablePath,
-			Strategy: strategy,
-			Records:  records,
+			Name:      tc.Name,
+			Path:      tablePath,
+			Strategy:  strategy,
+			Key:       tc.Key,
+			NullToken: tc.NullToken,
+			Columns:   tc.Columns,
+			Truncate:  tc.Truncate,
+			Records:   records,
 		})
 	}
 
 	return tables, nil
 }
 
-func LoadRecord(record domain.Record) ([]map[string]any, error) {
-	switch record.Type {
-	case domain.RecordTypeRow:
+// checkColumns rejects column mappings that contradict each other: an
+// ignored column that is also renamed, defaulted or computed, and two
+// mappings writing the same column.
+func checkColumns(table string, columns []domain.ColumnMapping) error {
+	targets := make(map[string]string, len(columns))
+	for _, m := range columns {
+		if m.Ignore {
+			if m.Column != "" || m.Default != nil || m.Value != "" {
+				return fmt.Errorf("table %s: column %q is ignored, so it can't also set column, default or value", table, m.Source)
+			}
+			continue
+		}
+		if other, ok := targets[m.Target()]; ok {
+			return fmt.Errorf("table %s: columns %q and %q both write %q", table, other, m.Source, m.Target())
+		}
+		targets[m.Target()] = m.Source
+	}
+	return nil
+}
+
+// LoadOptions controls how CSV and TSV cells are read. YAML and JSON values
+// are already typed and are loaded as they are.
+type LoadOptions struct {
+	// NullToken is the cell text that loads as NULL; empty means none.
+	NullToken string
+	// Types are the live column types cells are checked and converted to.
+	// A header hint (price:decimal) overrides them.
+	Types map[string]domain.ColumnType
+}
+
+// LoadRecord parses a record file into rows. YAML and JSON files hold one
+// row (a mapping) or a list of rows; NDJSON holds one JSON object per line;
+// CSV and TSV start with a header line. Nested values in YAML and JSON are
+// written as JSON, for JSON columns. A file whose content doesn't have the
+// shape its extension implies fails with an error wrapping ErrRecordShape;
+// a malformed CSV/TSV line or a cell that doesn't fit its column type fails
+// with an error wrapping ErrInvalidRecord, naming the file and line.
+func LoadRecord(record domain.Record, opts LoadOptions) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := StreamRecord(record, opts, func(row map[string]any) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// StreamRecord parses a record file like LoadRecord, passing each row to fn
+// as it is read, and stops at the first error fn returns. NDJSON, CSV and
+// TSV files are read a line at a time; YAML and JSON documents are parsed
+// whole.
+func StreamRecord(record domain.Record, opts LoadOptions, fn func(row map[string]any) error) error {
+	format := record.Format
+	if format == "" {
+		// A record built by type alone is YAML or CSV.
+		format = domain.FormatYAML
+		if record.Type == domain.RecordTypeList {
+			format = domain.FormatCSV
+		}
+	}
+
+	switch format {
+	case domain.FormatYAML:
 		data, err := os.ReadFile(record.Path)
 		if err != nil {
-			return nil, fmt.Errorf("reading record file %s: %w", record.Path, err)
-		}
-		var row map[string]any
-		if err := yaml.Unmarshal(data, &row); err != nil {
-			return nil, fmt.Errorf("parsing YAML record %s: %w", record.Path, err)
+			return fmt.Errorf("reading record file %s: %w", record.Path, err)
 		}
-		if row == nil {
-			return nil, nil
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parsing YAML record %s: %w", record.Path, err)
 		}
-		return []map[string]any{row}, nil
+		return emitRows(record.Path, doc, fn)
 
-	case domain.RecordTypeList:
-		f, err := os.Open(record.Path)
+	case domain.FormatJSON:
+		data, err := os.ReadFile(record.Path)
 		if err != nil {
-			return nil, fmt.Errorf("opening record file %s: %w", record.Path, err)
+			return fmt.Errorf("reading record file %s: %w", record.Path, err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("parsing JSON record %s: %w", record.Path, err)
+		}
+		if dec.More() {
+			return fmt.Errorf("%s: %w: more than one JSON value (use .ndjson for one object per line)", record.Path, domain.ErrRecordShape)
+		}
+		return emitRows(record.Path, doc, fn)
+
+	case domain.FormatNDJSON:
+		return streamNDJSON(record.Path, fn)
+
+	case domain.FormatCSV, domain.FormatTSV:
+		return streamDelimited(record.Path, format, opts, fn)
+	}
+
+	return nil
+}
+
+// streamDelimited reads a CSV or TSV file. Every line must have as many
+// fields as the header.
+func streamDelimited(path string, format domain.RecordFormat, opts LoadOptions, fn func(map[string]any) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening record file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	name := strings.ToUpper(string(format))
+	reader := csv.NewReader(f)
+	if format == domain.FormatTSV {
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+	headers, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading %s headers from %s: %w", name, path, err)
+	}
+
+	columns := make([]string, len(headers))
+	types := make([]domain.ColumnType, len(headers))
+	for i, h := range headers {
+		columns[i], types[i] = parseHeader(h)
+		if types[i] == "" {
+			types[i] = opts.Types[columns[i]]
 		}
-		defer f.Close()
+	}
 
-		reader := csv.NewReader(f)
-		headers, err := reader.Read()
+	for {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return nil, fmt.Errorf("reading CSV headers from %s: %w", record.Path, err)
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return fmt.Errorf("%s line %d: %w: %v", path, parseErr.StartLine, domain.ErrInvalidRecord, parseErr.Err)
+			}
+			return fmt.Errorf("reading %s record %s: %w", name, path, err)
 		}
+		line, _ := reader.FieldPos(0)
 
-		var rows []map[string]any
-		for {
-			record, err := reader.Read()
+		row := make(map[string]any, len(columns))
+		for i, col := range columns {
+			if opts.NullToken != "" && cells[i] == opts.NullToken {
+				row[col] = nil
+				continue
+			}
+			// Template expressions are typed once they're resolved.
+			if _, ok := expr.Template(cells[i]); ok {
+				row[col] = cells[i]
+				continue
+			}
+			value, err := coerce(cells[i], types[i])
 			if err != nil {
-				break
+				return fmt.Errorf("%s line %d, column %q: %w: %v", path, line, col, domain.ErrInvalidRecord, err)
 			}
-			row := make(map[string]any, len(headers))
-			for i, h := range headers {
-				if i < len(record) {
-					row[h] = record[i]
-				}
+			row[col] = value
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamNDJSON reads one JSON object per line, skipping blank lines.
+func streamNDJSON(path string, fn func(map[string]any) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening record file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("parsing NDJSON record %s line %d: %w", path, line, err)
+		}
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s line %d: %w: expected a JSON object, got %s", path, line, domain.ErrRecordShape, shapeOf(doc))
+		}
+		row, err := jsonColumns(obj)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading record file %s: %w", path, err)
+	}
+	return nil
+}
+
+// emitRows passes the rows of a parsed YAML or JSON document to fn.
+func emitRows(path string, doc any, fn func(map[string]any) error) error {
+	rows, err := documentRows(path, doc)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentRows turns a parsed YAML or JSON document into rows: a mapping is
+// one row and a list of mappings is many. An empty document has none.
+func documentRows(path string, doc any) ([]map[string]any, error) {
+	switch d := doc.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		row, err := jsonColumns(d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return []map[string]any{row}, nil
+	case []any:
+		rows := make([]map[string]any, 0, len(d))
+		for i, item := range d {
+			obj, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w: row %d is %s, expected a mapping of column to value", path, domain.ErrRecordShape, i+1, shapeOf(item))
+			}
+			row, err := jsonColumns(obj)
+			if err != nil {
+				return nil, fmt.Errorf("%s: row %d: %w", path, i+1, err)
 			}
 			rows = append(rows, row)
 		}
 		return rows, nil
+	default:
+		return nil, fmt.Errorf("%s: %w: expected a mapping (one row) or a list of mappings (rows), got %s", path, domain.ErrRecordShape, shapeOf(doc))
+	}
+}
+
+// jsonColumns encodes nested mappings and lists as JSON text, so they can be
+// written to JSON columns.
+func jsonColumns(row map[string]any) (map[string]any, error) {
+	for col, v := range row {
+		switch v.(type) {
+		case map[string]any, []any:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: column %q: %v", domain.ErrRecordShape, col, err)
+			}
+			row[col] = string(encoded)
+		case map[any]any:
+			return nil, fmt.Errorf("%w: column %q: a nested mapping needs string keys to be written as JSON", domain.ErrRecordShape, col)
+		}
 	}
+	return row, nil
+}
 
-	return nil, nil
+// shapeOf names the kind of a parsed value for error messages.
+func shapeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "a mapping"
+	case map[any]any:
+		return "a mapping with non-string keys"
+	case []any:
+		return "a list"
+	case string:
+		return "a string"
+	default:
+		return fmt.Sprintf("a scalar (%v)", v)
+	}
 }
@@ -1,8 +1,12 @@
 package infra
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/apsdsm/joka/internal/domains/template/domain"
@@ -18,7 +22,7 @@ func TestLoadRecord(t *testing.T) {
 			Name: "welcome",
 			Path: yamlFile,
 			Type: domain.RecordTypeRow,
-		})
+		}, LoadOptions{})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -42,7 +46,7 @@ func TestLoadRecord(t *testing.T) {
 			Name: "defaults",
 			Path: csvFile,
 			Type: domain.RecordTypeList,
-		})
+		}, LoadOptions{})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -56,6 +60,80 @@ func TestLoadRecord(t *testing.T) {
 			t.Errorf("expected value '3', got %v", rows[1]["value"])
 		}
 	})
+
+	load := func(t *testing.T, name, content string, format domain.RecordFormat) ([]map[string]any, error) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+		return LoadRecord(domain.Record{Name: name, Path: path, Format: format}, LoadOptions{})
+	}
+
+	t.Run("it loads a YAML list as multiple rows", func(t *testing.T) {
+		rows, err := load(t, "currencies.yaml", "- code: USD\n  name: US dollar\n- code: EUR\n  name: Euro\n", domain.FormatYAML)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows) != 2 || rows[1]["code"] != "EUR" {
+			t.Errorf("expected 2 rows ending with EUR, got %v", rows)
+		}
+	})
+
+	t.Run("it loads a JSON object or array and encodes nested values as JSON", func(t *testing.T) {
+		rows, err := load(t, "plan.json", `{"code": "pro", "limits": {"seats": 10}, "tags": ["a", "b"]}`, domain.FormatJSON)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["limits"] != `{"seats":10}` || rows[0]["tags"] != `["a","b"]` {
+			t.Errorf("expected nested values as JSON text, got %v", rows)
+		}
+
+		rows, err = load(t, "plans.json", `[{"code": "free", "price": 0}, {"code": "pro", "price": 12345678901}]`, domain.FormatJSON)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows) != 2 || fmt.Sprint(rows[1]["price"]) != "12345678901" {
+			t.Errorf("expected 2 rows with exact numbers, got %v", rows)
+		}
+	})
+
+	t.Run("it loads NDJSON and TSV as multiple rows", func(t *testing.T) {
+		rows, err := load(t, "events.ndjson", "{\"id\": 1}\n\n{\"id\": 2, \"meta\": {\"a\": true}}\n", domain.FormatNDJSON)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows) != 2 || rows[1]["meta"] != `{"a":true}` {
+			t.Errorf("expected 2 rows, got %v", rows)
+		}
+
+		rows, err = load(t, "settings.tsv", "key\tvalue\ngreeting\tsay \"hi\", friend\n", domain.FormatTSV)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["value"] != `say "hi", friend` {
+			t.Errorf("expected the value split on tabs only, got %v", rows)
+		}
+	})
+
+	t.Run("it rejects files whose shape does not match their extension", func(t *testing.T) {
+		cases := []struct {
+			name, content string
+			format        domain.RecordFormat
+			message       string
+		}{
+			{"scalar.yaml", "just text\n", domain.FormatYAML, "got a string"},
+			{"list.json", `[{"id": 1}, 2]`, domain.FormatJSON, "row 2 is a scalar"},
+			{"two.json", `{"id": 1} {"id": 2}`, domain.FormatJSON, "use .ndjson"},
+			{"array.ndjson", "{\"id\": 1}\n[1, 2]\n", domain.FormatNDJSON, "line 2"},
+		}
+		for _, c := range cases {
+			_, err := load(t, c.name, c.content, c.format)
+			if !errors.Is(err, domain.ErrRecordShape) || !strings.Contains(err.Error(), c.message) {
+				t.Errorf("%s: expected ErrRecordShape mentioning %q, got %v", c.name, c.message, err)
+			}
+		}
+	})
 }
 
 func TestGetTables(t *testing.T) {
@@ -98,4 +176,123 @@ func TestGetTables(t *testing.T) {
 			t.Errorf("expected record type list, got %s", tables[1].Records[0].Type)
 		}
 	})
+
+	t.Run("it discovers every supported format and ignores other files", func(t *testing.T) {
+		dir := t.TempDir()
+		tableDir := filepath.Join(dir, "plans")
+		os.Mkdir(tableDir, 0755)
+		for _, name := range []string{"a.json", "b.ndjson", "c.tsv", "d.yml", "README.md"} {
+			os.WriteFile(filepath.Join(tableDir, name), []byte(""), 0644)
+		}
+
+		tables, err := GetTables(dir, []TableConfig{{Name: "plans"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var formats []domain.RecordFormat
+		for _, r := range tables[0].Records {
+			formats = append(formats, r.Format)
+		}
+		want := []domain.RecordFormat{domain.FormatJSON, domain.FormatNDJSON, domain.FormatTSV, domain.FormatYAML}
+		if !reflect.DeepEqual(formats, want) {
+			t.Errorf("expected %v, got %v", want, formats)
+		}
+	})
+	t.Run("it rejects contradicting column mappings", func(t *testing.T) {
+		dir := t.TempDir()
+		os.Mkdir(filepath.Join(dir, "users"), 0755)
+
+		cases := [][]domain.ColumnMapping{
+			{{Source: "legacy", Ignore: true, Column: "old"}},
+			{{Source: "E-mail", Column: "email"}, {Source: "mail", Column: "email"}},
+		}
+		for _, columns := range cases {
+			if _, err := GetTables(dir, []TableConfig{{Name: "users", Columns: columns}}); err == nil {
+				t.Errorf("expected an error for %+v", columns)
+			}
+		}
+	})
+
+	t.Run("it rejects truncate options on other strategies", func(t *testing.T) {
+		dir := t.TempDir()
+		os.Mkdir(filepath.Join(dir, "users"), 0755)
+
+		_, err := GetTables(dir, []TableConfig{{Name: "users", Strategy: domain.StrategyUpdate, Truncate: domain.TruncateOptions{Cascade: true}}})
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+		tables, err := GetTables(dir, []TableConfig{{Name: "users", Strategy: domain.StrategyTruncate, Truncate: domain.TruncateOptions{Cascade: true}}})
+		if err != nil || !tables[0].Truncate.Cascade {
+			t.Errorf("expected the options to be kept, got %+v, %v", tables, err)
+		}
+	})
+}
+
+func TestLoadDelimited(t *testing.T) {
+	load := func(t *testing.T, content string, opts LoadOptions) ([]map[string]any, error) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "plans.csv")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing csv: %v", err)
+		}
+		return LoadRecord(domain.Record{Name: "plans", Path: path, Format: domain.FormatCSV}, opts)
+	}
+
+	t.Run("it reports malformed lines with their line number", func(t *testing.T) {
+		_, err := load(t, "code,price\nfree,0\npro,10,extra\n", LoadOptions{})
+		if !errors.Is(err, domain.ErrInvalidRecord) || !strings.Contains(err.Error(), "line 3") {
+			t.Fatalf("expected ErrInvalidRecord on line 3, got %v", err)
+		}
+
+		_, err = load(t, "code,name\nfree,\"unterminated\n", LoadOptions{})
+		if !errors.Is(err, domain.ErrInvalidRecord) || !strings.Contains(err.Error(), "line 2") {
+			t.Fatalf("expected ErrInvalidRecord on line 2, got %v", err)
+		}
+	})
+
+	t.Run("it loads the null token as NULL and keeps empty cells", func(t *testing.T) {
+		rows, err := load(t, "code,note,label\nfree,\\N,\n", LoadOptions{NullToken: `\N`})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rows[0]["note"] != nil || rows[0]["label"] != "" {
+			t.Errorf("expected note NULL and label empty, got %v", rows[0])
+		}
+	})
+
+	t.Run("it converts cells to the live column types", func(t *testing.T) {
+		types := map[string]domain.ColumnType{"active": domain.ColumnBool, "seats": domain.ColumnInt, "price": domain.ColumnDecimal}
+		rows, err := load(t, "code,active,seats,price\npro,true,10,12.50\n", LoadOptions{Types: types})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := map[string]any{"code": "pro", "active": true, "seats": int64(10), "price": "12.50"}
+		if !reflect.DeepEqual(rows[0], want) {
+			t.Errorf("expected %v, got %v", want, rows[0])
+		}
+	})
+
+	t.Run("it takes types from header hints over live types", func(t *testing.T) {
+		types := map[string]domain.ColumnType{"seats": domain.ColumnString}
+		rows, err := load(t, "code,seats:int,limits:json,ratio:x\npro,10,\"{\"\"a\"\":1}\",1:2\n", LoadOptions{Types: types})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := map[string]any{"code": "pro", "seats": int64(10), "limits": `{"a":1}`, "ratio:x": "1:2"}
+		if !reflect.DeepEqual(rows[0], want) {
+			t.Errorf("expected %v, got %v", want, rows[0])
+		}
+	})
+
+	t.Run("it rejects cells that don't fit their type, naming the line and column", func(t *testing.T) {
+		_, err := load(t, "code,price:decimal\nfree,0\npro,12,50\n", LoadOptions{})
+		if err == nil || !strings.Contains(err.Error(), "line 3") {
+			t.Fatalf("expected an error on line 3, got %v", err)
+		}
+
+		_, err = load(t, "code,price:decimal\npro,twelve\n", LoadOptions{})
+		if !errors.Is(err, domain.ErrInvalidRecord) || !strings.Contains(err.Error(), `line 2, column "price"`) {
+			t.Fatalf("expected ErrInvalidRecord naming line 2 and price, got %v", err)
+		}
+	})
 }
@@ -0,0 +1,285 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/apsdsm/joka/internal/domains/template/domain"
+	"github.com/apsdsm/joka/internal/expr"
+)
+
+// deleteBatchSize is how many keys one DELETE or reference count covers.
+const deleteBatchSize = 500
+
+// dialect is what differs between the MySQL and PostgreSQL statements.
+type dialect struct {
+	quote       func(name string) string
+	placeholder func(n int) string // n counts from 1
+}
+
+var (
+	mysqlDialect = dialect{
+		quote:       func(name string) string { return "`" + name + "`" },
+		placeholder: func(int) string { return "?" },
+	}
+	postgresDialect = dialect{
+		quote:       func(name string) string { return `"` + name + `"` },
+		placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	}
+)
+
+// columns renders qualified, quoted column names, e.g. p.`id`, p.`code`.
+func (d dialect) columns(alias string, names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = alias + d.quote(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// keyIn renders "(cols) IN ((?, ?), ...)" matching keys, numbering
+// placeholders after the args already collected, and returns the args
+// extended with the key values.
+func (d dialect) keyIn(alias string, key []string, keys [][]any, args []any) (string, []any) {
+	tuples := make([]string, len(keys))
+	for i, k := range keys {
+		ph := make([]string, len(k))
+		for j, v := range k {
+			args = append(args, v)
+			ph[j] = d.placeholder(len(args))
+		}
+		tuples[i] = "(" + strings.Join(ph, ", ") + ")"
+	}
+	return fmt.Sprintf("(%s) IN (%s)", d.columns(alias, key), strings.Join(tuples, ", ")), args
+}
+
+// deleteKeysQuery deletes the rows of table with the given keys.
+func (d dialect) deleteKeysQuery(table string, key []string, keys [][]any) (string, []any) {
+	cond, args := d.keyIn("", key, keys, nil)
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", d.quote(table), cond), args
+}
+
+// referencingQuery selects, from the rows of fk.Table pointing at the rows of
+// table with the given keys, what (COUNT(*), or the referencing rows' own
+// key columns).
+func (d dialect) referencingQuery(what, table string, key []string, keys [][]any, fk domain.ForeignKeyRef) (string, []any) {
+	join := make([]string, len(fk.Columns))
+	for i := range fk.Columns {
+		join[i] = fmt.Sprintf("c.%s = p.%s", d.quote(fk.Columns[i]), d.quote(fk.RefColumns[i]))
+	}
+	cond, args := d.keyIn("p.", key, keys, nil)
+	return fmt.Sprintf("SELECT %s FROM %s c JOIN %s p ON %s WHERE %s",
+		what, d.quote(fk.Table), d.quote(table), strings.Join(join, " AND "), cond), args
+}
+
+// groupForeignKeys folds one row per foreign key column, ordered by
+// constraint and position, into ForeignKeyRefs.
+func groupForeignKeys(rows [][4]string) []domain.ForeignKeyRef {
+	var refs []domain.ForeignKeyRef
+	for _, r := range rows {
+		name, table, column, refColumn := r[0], r[1], r[2], r[3]
+		if n := len(refs); n > 0 && refs[n-1].Name == name && refs[n-1].Table == table {
+			refs[n-1].Columns = append(refs[n-1].Columns, column)
+			refs[n-1].RefColumns = append(refs[n-1].RefColumns, refColumn)
+			continue
+		}
+		refs = append(refs, domain.ForeignKeyRef{Name: name, Table: table, Columns: []string{column}, RefColumns: []string{refColumn}})
+	}
+	return refs
+}
+
+// batches splits keys into runs of at most deleteBatchSize.
+func batches(keys [][]any) [][][]any {
+	var out [][][]any
+	for len(keys) > deleteBatchSize {
+		out = append(out, keys[:deleteBatchSize])
+		keys = keys[deleteBatchSize:]
+	}
+	if len(keys) > 0 {
+		out = append(out, keys)
+	}
+	return out
+}
+
+// listKeys reads the key of every row of table.
+func listKeys(ctx context.Context, db DBTX, d dialect, table string, key []string) ([][]any, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", d.columns("", key), d.quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanKeys(rows, len(key))
+}
+
+// scanKeys reads rows of n key columns, as text where the driver returns
+// bytes.
+func scanKeys(rows *sql.Rows, n int) ([][]any, error) {
+	var keys [][]any
+	for rows.Next() {
+		values := make([]any, n)
+		ptrs := make([]any, n)
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		keys = append(keys, values)
+	}
+	return keys, rows.Err()
+}
+
+// readRows reads every row of table, keyed by column name.
+func readRows(ctx context.Context, db DBTX, d dialect, table string) ([]map[string]any, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+d.quote(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, c := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[c] = values[i]
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// lookupValue reads returnCol from the first row of table whose whereCol is
+// whereVal, for {{ lookup|... }} expressions.
+func lookupValue(ctx context.Context, db DBTX, d dialect, table, returnCol, whereCol string, whereVal any) (any, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s LIMIT 1", d.quote(returnCol), d.quote(table), d.quote(whereCol), d.placeholder(1))
+
+	var result any
+	err := db.QueryRowContext(ctx, query, whereVal).Scan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s.%s where %s=%v", expr.ErrLookupNotFound, table, returnCol, whereCol, whereVal)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s.%s: %w", table, returnCol, err)
+	}
+	if b, ok := result.([]byte); ok {
+		return string(b), nil
+	}
+	return result, nil
+}
+
+// countReferencing counts the rows of fk.Table pointing at the rows of table
+// with the given keys, a batch of keys at a time. When fk is
+// self-referencing, rows that are themselves among keys don't count: the
+// referencing rows' keys are read and checked against keys here, so each
+// query carries only its batch.
+func countReferencing(ctx context.Context, db DBTX, d dialect, table string, key []string, keys [][]any, fk domain.ForeignKeyRef) (int, error) {
+	total := 0
+	if fk.Table != table {
+		for _, batch := range batches(keys) {
+			query, args := d.referencingQuery("COUNT(*)", table, key, batch, fk)
+			var n int
+			if err := db.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+				return 0, err
+			}
+			total += n
+		}
+		return total, nil
+	}
+
+	doomed := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		doomed[keyText(k)] = true
+	}
+	for _, batch := range batches(keys) {
+		query, args := d.referencingQuery(d.columns("c.", key), table, key, batch, fk)
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		children, err := scanKeys(rows, len(key))
+		rows.Close()
+		if err != nil {
+			return 0, err
+		}
+		for _, child := range children {
+			if !doomed[keyText(child)] {
+				total++
+			}
+		}
+	}
+	return total, nil
+}
+
+// keyText renders key values as text, so keys compare alike whatever types
+// they were given or scanned as.
+func keyText(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// deleteKeys deletes the rows of table with the given keys.
+func deleteKeys(ctx context.Context, db DBTX, d dialect, table string, key []string, keys [][]any) (int, error) {
+	total := 0
+	for _, batch := range batches(keys) {
+		query, args := d.deleteKeysQuery(table, key, batch)
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += int(n)
+	}
+	return total, nil
+}
+
+// queryForeignKeys runs a catalog query returning constraint name, table,
+// column and referenced column per row, and groups the result.
+func queryForeignKeys(ctx context.Context, db DBTX, query string, args ...any) ([]domain.ForeignKeyRef, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols [][4]string
+	for rows.Next() {
+		var r [4]string
+		if err := rows.Scan(&r[0], &r[1], &r[2], &r[3]); err != nil {
+			return nil, err
+		}
+		cols = append(cols, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groupForeignKeys(cols), nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 
 	jokadb "github.com/apsdsm/joka/db"
@@ -12,6 +13,9 @@ import (
 
 type DBTX interface {
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 }
 
 type MySQLDBAdapter struct {
@@ -28,17 +32,31 @@ func NewMySQLTxDBAdapter(tx *sql.Tx, conn *sql.DB) *MySQLDBAdapter {
 	return &MySQLDBAdapter{db: tx, conn: conn, driver: jokadb.MySQL}
 }
 
-func (m *MySQLDBAdapter) TruncateTable(ctx context.Context, tableName string) error {
-	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, tableName)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
-	}
+// TruncateTables runs one TRUNCATE TABLE per table. MySQL's TRUNCATE always
+// resets AUTO_INCREMENT and has no CASCADE, so either option is an error; a
+// referenced table can only be truncated with foreign key checks disabled.
+func (m *MySQLDBAdapter) TruncateTables(ctx context.Context, tables []domain.Table) error {
+	for _, t := range tables {
+		if t.Truncate.ContinueIdentity {
+			return fmt.Errorf("truncating %s: MySQL always resets AUTO_INCREMENT on TRUNCATE (remove restart_identity: false)", t.Name)
+		}
+		if t.Truncate.Cascade {
+			return fmt.Errorf("truncating %s: MySQL has no TRUNCATE ... CASCADE (use --ignore-foreign-keys instead)", t.Name)
+		}
 
-	_, err = m.db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE `%s`", tableName))
-	return err
+		exists, err := jokadb.TableExists(ctx, m.conn, m.driver, t.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", domain.ErrTableNotFound, t.Name)
+		}
+
+		if _, err := m.db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE `%s`", t.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (m *MySQLDBAdapter) DisableForeignKeys(ctx context.Context) error {
@@ -51,6 +69,8 @@ func (m *MySQLDBAdapter) EnableForeignKeys(ctx context.Context) error {
 	return err
 }
 
+// InsertRows inserts rows with multi-row INSERT statements over the union of
+// their columns.
 func (m *MySQLDBAdapter) InsertRows(ctx context.Context, tableName string, rows []map[string]any) (int, error) {
 	if len(rows) == 0 {
 		return 0, nil
@@ -64,33 +84,210 @@ func (m *MySQLDBAdapter) InsertRows(ctx context.Context, tableName string, rows
 		return 0, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
 	}
 
+	if err := insertBatched(ctx, m.db, mysqlDialect, tableName, rows); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+func (m *MySQLDBAdapter) PrimaryKey(ctx context.Context, tableName string) ([]string, error) {
+	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+
+	rows, err := m.conn.QueryContext(ctx, `
+		SELECT column_name
+		FROM information_schema.key_column_usage
+		WHERE table_schema = DATABASE() AND table_name = ? AND constraint_name = 'PRIMARY'
+		ORDER BY ordinal_position
+	`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
 	var columns []string
-	for k := range rows[0] {
-		columns = append(columns, k)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// UpsertRows inserts each row, or updates the existing row when it collides
+// with a unique key, with INSERT ... ON DUPLICATE KEY UPDATE. MySQL can't aim
+// the statement at particular columns, so key only decides which columns are
+// left alone on update. The affected row count tells the outcomes apart: 1
+// for an insert, 2 for an update and 0 when nothing changed.
+func (m *MySQLDBAdapter) UpsertRows(ctx context.Context, tableName string, key []string, rows []map[string]any) (domain.SyncResult, error) {
+	var result domain.SyncResult
+	if len(rows) == 0 {
+		return result, nil
 	}
 
-	colNames := make([]string, len(columns))
-	placeholders := make([]string, len(columns))
-	for i, c := range columns {
-		colNames[i] = fmt.Sprintf("`%s`", c)
-		placeholders[i] = "?"
+	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, tableName)
+	if err != nil {
+		return result, err
+	}
+	if !exists {
+		return result, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
 	}
 
-	query := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)",
-		tableName,
-		strings.Join(colNames, ", "),
-		strings.Join(placeholders, ", "),
-	)
+	isKey := make(map[string]bool, len(key))
+	for _, k := range key {
+		isKey[k] = true
+	}
 
 	for _, row := range rows {
+		columns := make([]string, 0, len(row))
+		for c := range row {
+			columns = append(columns, c)
+		}
+		sort.Strings(columns)
+
+		colNames := make([]string, len(columns))
+		placeholders := make([]string, len(columns))
 		args := make([]any, len(columns))
+		var updates []string
 		for i, c := range columns {
+			colNames[i] = fmt.Sprintf("`%s`", c)
+			placeholders[i] = "?"
 			args[i] = row[c]
+			if !isKey[c] {
+				updates = append(updates, fmt.Sprintf("`%s` = VALUES(`%s`)", c, c))
+			}
+		}
+		if len(updates) == 0 {
+			updates = []string{fmt.Sprintf("`%s` = `%s`", key[0], key[0])}
+		}
+
+		query := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+			tableName,
+			strings.Join(colNames, ", "),
+			strings.Join(placeholders, ", "),
+			strings.Join(updates, ", "),
+		)
+		res, err := m.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return result, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return result, err
 		}
-		if _, err := m.db.ExecContext(ctx, query, args...); err != nil {
-			return 0, err
+		switch affected {
+		case 1:
+			result.Inserted++
+		case 2:
+			result.Updated++
+		default:
+			result.Unchanged++
 		}
 	}
 
-	return len(rows), nil
+	return result, nil
+}
+
+func (m *MySQLDBAdapter) ListKeys(ctx context.Context, tableName string, key []string) ([][]any, error) {
+	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+	return listKeys(ctx, m.db, mysqlDialect, tableName, key)
+}
+
+func (m *MySQLDBAdapter) ReferencingForeignKeys(ctx context.Context, tableName string) ([]domain.ForeignKeyRef, error) {
+	return queryForeignKeys(ctx, m.db, `
+		SELECT constraint_name, table_name, column_name, referenced_column_name
+		FROM information_schema.key_column_usage
+		WHERE table_schema = DATABASE()
+		AND referenced_table_schema = DATABASE()
+		AND referenced_table_name = ?
+		ORDER BY table_name, constraint_name, ordinal_position
+	`, tableName)
+}
+
+func (m *MySQLDBAdapter) CountReferencingRows(ctx context.Context, tableName string, key []string, keys [][]any, fk domain.ForeignKeyRef) (int, error) {
+	return countReferencing(ctx, m.db, mysqlDialect, tableName, key, keys, fk)
+}
+
+func (m *MySQLDBAdapter) DeleteRows(ctx context.Context, tableName string, key []string, keys [][]any) (int, error) {
+	return deleteKeys(ctx, m.db, mysqlDialect, tableName, key, keys)
+}
+
+func (m *MySQLDBAdapter) ReadRows(ctx context.Context, tableName string) ([]map[string]any, error) {
+	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+	return readRows(ctx, m.db, mysqlDialect, tableName)
+}
+
+// LookupValue queries a single value from an existing table row, inside the
+// sync transaction when the adapter has one. Returns an error wrapping
+// expr.ErrLookupNotFound if no row matches.
+func (m *MySQLDBAdapter) LookupValue(ctx context.Context, table, returnCol, whereCol string, whereVal any) (any, error) {
+	return lookupValue(ctx, m.db, mysqlDialect, table, returnCol, whereCol, whereVal)
+}
+
+// EnsureTrackingTable creates the joka_templates table if it does not already
+// exist. The table records the content hash of each synced template table.
+func (m *MySQLDBAdapter) EnsureTrackingTable(ctx context.Context) error {
+	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, "joka_templates")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = m.conn.ExecContext(ctx, `
+		CREATE TABLE joka_templates (
+			id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+			table_name VARCHAR(255) NOT NULL UNIQUE,
+			content_hash VARCHAR(64) NOT NULL,
+			synced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+func (m *MySQLDBAdapter) GetAllSyncedTables(ctx context.Context) (map[string]string, error) {
+	return syncedTables(ctx, m.db)
+}
+
+func (m *MySQLDBAdapter) RecordTableSynced(ctx context.Context, tableName, contentHash string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO joka_templates (table_name, content_hash) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE content_hash = VALUES(content_hash), synced_at = CURRENT_TIMESTAMP
+	`, tableName, contentHash)
+	return err
+}
+
+func (m *MySQLDBAdapter) ColumnTypes(ctx context.Context, tableName string) (map[string]domain.ColumnType, error) {
+	exists, err := jokadb.TableExists(ctx, m.conn, m.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+	return columnTypes(ctx, m.db, `
+		SELECT column_name, data_type, column_type
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = ?
+	`, tableName)
 }
@@ -3,7 +3,9 @@ package infra
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	jokadb "github.com/apsdsm/joka/db"
@@ -14,6 +16,8 @@ type PostgresDBAdapter struct {
 	db     DBTX
 	conn   *sql.DB
 	driver jokadb.Driver
+	// fksDisabled is set between DisableForeignKeys and EnableForeignKeys.
+	fksDisabled bool
 }
 
 func NewPostgresDBAdapter(conn *sql.DB) *PostgresDBAdapter {
@@ -24,19 +28,115 @@ func NewPostgresTxDBAdapter(tx *sql.Tx, conn *sql.DB) *PostgresDBAdapter {
 	return &PostgresDBAdapter{db: tx, conn: conn, driver: jokadb.Postgres}
 }
 
-func (p *PostgresDBAdapter) TruncateTable(ctx context.Context, tableName string) error {
-	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, tableName)
+// TruncateTables truncates each run of consecutive tables with the same
+// options in one statement. PostgreSQL refuses to truncate a table that
+// another table references, even with session_replication_role = replica,
+// unless the referencing table is truncated in the same statement or CASCADE
+// is given. So while foreign keys are disabled, tables without Cascade are
+// emptied with DELETE instead, which replica lets through, and their
+// sequences are restarted unless ContinueIdentity is set.
+func (p *PostgresDBAdapter) TruncateTables(ctx context.Context, tables []domain.Table) error {
+	for _, t := range tables {
+		exists, err := jokadb.TableExists(ctx, p.conn, p.driver, t.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", domain.ErrTableNotFound, t.Name)
+		}
+	}
+
+	if p.fksDisabled {
+		for _, t := range tables {
+			if t.Truncate.Cascade {
+				if _, err := p.db.ExecContext(ctx, truncateQuery([]domain.Table{t}, t.Truncate)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := p.deleteAll(ctx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for start := 0; start < len(tables); {
+		opts := tables[start].Truncate
+		end := start + 1
+		for end < len(tables) && tables[end].Truncate == opts {
+			end++
+		}
+		if _, err := p.db.ExecContext(ctx, truncateQuery(tables[start:end], opts)); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// deleteAll deletes every row of t and, unless t continues its identity,
+// restarts the sequences owned by its columns, as TRUNCATE would.
+func (p *PostgresDBAdapter) deleteAll(ctx context.Context, t domain.Table) error {
+	name := postgresDialect.quote(t.Name)
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM "+name); err != nil {
+		return err
+	}
+	if t.Truncate.ContinueIdentity {
+		return nil
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT pg_get_serial_sequence($1, attname)
+		FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped
+		  AND pg_get_serial_sequence($1, attname) IS NOT NULL`, name)
 	if err != nil {
+		return fmt.Errorf("listing sequences of %s: %w", t.Name, err)
+	}
+	var sequences []string
+	for rows.Next() {
+		var seq string
+		if err := rows.Scan(&seq); err != nil {
+			rows.Close()
+			return err
+		}
+		sequences = append(sequences, seq)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+
+	for _, seq := range sequences {
+		// pg_get_serial_sequence returns the name already quoted.
+		if _, err := p.db.ExecContext(ctx, "ALTER SEQUENCE "+seq+" RESTART"); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	_, err = p.db.ExecContext(ctx, fmt.Sprintf(`TRUNCATE TABLE "%s" RESTART IDENTITY`, tableName))
-	return err
+// truncateQuery renders a PostgreSQL TRUNCATE of tables with opts.
+func truncateQuery(tables []domain.Table, opts domain.TruncateOptions) string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = postgresDialect.quote(t.Name)
+	}
+	query := "TRUNCATE TABLE " + strings.Join(names, ", ")
+	if opts.ContinueIdentity {
+		query += " CONTINUE IDENTITY"
+	} else {
+		query += " RESTART IDENTITY"
+	}
+	if opts.Cascade {
+		query += " CASCADE"
+	}
+	return query
 }
 
+// InsertRows loads rows with COPY FROM STDIN, one COPY per run of rows
+// sharing the same columns.
 func (p *PostgresDBAdapter) InsertRows(ctx context.Context, tableName string, rows []map[string]any) (int, error) {
 	if len(rows) == 0 {
 		return 0, nil
@@ -50,43 +150,248 @@ func (p *PostgresDBAdapter) InsertRows(ctx context.Context, tableName string, ro
 		return 0, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
 	}
 
+	if err := copyRows(ctx, p.db, tableName, rows); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// DisableForeignKeys sets session_replication_role to replica for the rest
+// of the transaction, so the triggers that enforce foreign keys don't fire.
+// It needs superuser (or, from PostgreSQL 15, a granted SET privilege), and
+// doesn't allow TRUNCATE on a referenced table, so TruncateTables deletes
+// instead until EnableForeignKeys.
+func (p *PostgresDBAdapter) DisableForeignKeys(ctx context.Context) error {
+	if _, err := p.db.ExecContext(ctx, "SET LOCAL session_replication_role = replica"); err != nil {
+		return err
+	}
+	p.fksDisabled = true
+	return nil
+}
+
+// EnableForeignKeys restores session_replication_role. Rows written while it
+// was disabled are not checked.
+func (p *PostgresDBAdapter) EnableForeignKeys(ctx context.Context) error {
+	if _, err := p.db.ExecContext(ctx, "SET LOCAL session_replication_role = DEFAULT"); err != nil {
+		return err
+	}
+	p.fksDisabled = false
+	return nil
+}
+
+func (p *PostgresDBAdapter) PrimaryKey(ctx context.Context, tableName string) ([]string, error) {
+	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+
+	rows, err := p.conn.QueryContext(ctx, `
+		SELECT kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_name = tc.constraint_name
+			AND kcu.table_schema = tc.table_schema
+			AND kcu.table_name = tc.table_name
+		WHERE tc.constraint_type = 'PRIMARY KEY'
+		AND tc.table_schema = current_schema()
+		AND tc.table_name = $1
+		ORDER BY kcu.ordinal_position
+	`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
 	var columns []string
-	for k := range rows[0] {
-		columns = append(columns, k)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
 	}
+	return columns, rows.Err()
+}
 
-	colNames := make([]string, len(columns))
-	placeholders := make([]string, len(columns))
-	for i, c := range columns {
-		colNames[i] = fmt.Sprintf(`"%s"`, c)
-		placeholders[i] = fmt.Sprintf("$%d", i+1)
+// UpsertRows inserts each row, or updates the row with the same key, with
+// INSERT ... ON CONFLICT (key) DO UPDATE. The key needs a unique index or
+// constraint. Rows whose values already match are not written; RETURNING
+// (xmax = 0) tells an insert from an update.
+func (p *PostgresDBAdapter) UpsertRows(ctx context.Context, tableName string, key []string, rows []map[string]any) (domain.SyncResult, error) {
+	var result domain.SyncResult
+	if len(rows) == 0 {
+		return result, nil
 	}
 
-	query := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`,
-		tableName,
-		strings.Join(colNames, ", "),
-		strings.Join(placeholders, ", "),
-	)
+	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, tableName)
+	if err != nil {
+		return result, err
+	}
+	if !exists {
+		return result, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+
+	isKey := make(map[string]bool, len(key))
+	keyNames := make([]string, len(key))
+	for i, k := range key {
+		isKey[k] = true
+		keyNames[i] = fmt.Sprintf(`"%s"`, k)
+	}
 
 	for _, row := range rows {
+		columns := make([]string, 0, len(row))
+		for c := range row {
+			columns = append(columns, c)
+		}
+		sort.Strings(columns)
+
+		colNames := make([]string, len(columns))
+		placeholders := make([]string, len(columns))
 		args := make([]any, len(columns))
+		var sets, current, incoming []string
 		for i, c := range columns {
+			colNames[i] = fmt.Sprintf(`"%s"`, c)
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
 			args[i] = row[c]
+			if !isKey[c] {
+				sets = append(sets, fmt.Sprintf(`"%s" = EXCLUDED."%s"`, c, c))
+				current = append(current, fmt.Sprintf(`"%s"."%s"`, tableName, c))
+				incoming = append(incoming, fmt.Sprintf(`EXCLUDED."%s"`, c))
+			}
 		}
-		if _, err := p.db.ExecContext(ctx, query, args...); err != nil {
-			return 0, err
+
+		conflict := "DO NOTHING"
+		if len(sets) > 0 {
+			conflict = fmt.Sprintf("DO UPDATE SET %s WHERE ROW(%s) IS DISTINCT FROM ROW(%s)",
+				strings.Join(sets, ", "), strings.Join(current, ", "), strings.Join(incoming, ", "))
+		}
+		query := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s) ON CONFLICT (%s) %s RETURNING (xmax = 0)`,
+			tableName,
+			strings.Join(colNames, ", "),
+			strings.Join(placeholders, ", "),
+			strings.Join(keyNames, ", "),
+			conflict,
+		)
+
+		var inserted bool
+		err := p.db.QueryRowContext(ctx, query, args...).Scan(&inserted)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			result.Unchanged++
+		case err != nil:
+			return result, err
+		case inserted:
+			result.Inserted++
+		default:
+			result.Updated++
 		}
 	}
 
-	return len(rows), nil
+	return result, nil
 }
 
-// DisableForeignKeys is a no-op for PostgreSQL. Use TRUNCATE ... CASCADE instead.
-func (p *PostgresDBAdapter) DisableForeignKeys(ctx context.Context) error {
-	return nil
+func (p *PostgresDBAdapter) ListKeys(ctx context.Context, tableName string, key []string) ([][]any, error) {
+	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+	return listKeys(ctx, p.db, postgresDialect, tableName, key)
 }
 
-// EnableForeignKeys is a no-op for PostgreSQL.
-func (p *PostgresDBAdapter) EnableForeignKeys(ctx context.Context) error {
-	return nil
+func (p *PostgresDBAdapter) ReferencingForeignKeys(ctx context.Context, tableName string) ([]domain.ForeignKeyRef, error) {
+	return queryForeignKeys(ctx, p.db, `
+		SELECT con.conname, child.relname, a.attname, ra.attname
+		FROM pg_constraint con
+		JOIN pg_class child ON child.oid = con.conrelid
+		JOIN pg_class parent ON parent.oid = con.confrelid
+		JOIN pg_namespace n ON n.oid = parent.relnamespace
+		CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(col, refcol, ord)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.col
+		JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refcol
+		WHERE con.contype = 'f'
+		AND n.nspname = current_schema()
+		AND parent.relname = $1
+		ORDER BY child.relname, con.conname, k.ord
+	`, tableName)
+}
+
+func (p *PostgresDBAdapter) CountReferencingRows(ctx context.Context, tableName string, key []string, keys [][]any, fk domain.ForeignKeyRef) (int, error) {
+	return countReferencing(ctx, p.db, postgresDialect, tableName, key, keys, fk)
+}
+
+func (p *PostgresDBAdapter) DeleteRows(ctx context.Context, tableName string, key []string, keys [][]any) (int, error) {
+	return deleteKeys(ctx, p.db, postgresDialect, tableName, key, keys)
+}
+
+func (p *PostgresDBAdapter) ReadRows(ctx context.Context, tableName string) ([]map[string]any, error) {
+	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+	return readRows(ctx, p.db, postgresDialect, tableName)
+}
+
+// LookupValue queries a single value from an existing table row, inside the
+// sync transaction when the adapter has one. Returns an error wrapping
+// expr.ErrLookupNotFound if no row matches.
+func (p *PostgresDBAdapter) LookupValue(ctx context.Context, table, returnCol, whereCol string, whereVal any) (any, error) {
+	return lookupValue(ctx, p.db, postgresDialect, table, returnCol, whereCol, whereVal)
+}
+
+// EnsureTrackingTable creates the joka_templates table if it does not already
+// exist. The table records the content hash of each synced template table.
+func (p *PostgresDBAdapter) EnsureTrackingTable(ctx context.Context) error {
+	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, "joka_templates")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = p.conn.ExecContext(ctx, `
+		CREATE TABLE joka_templates (
+			id BIGSERIAL PRIMARY KEY,
+			table_name VARCHAR(255) NOT NULL UNIQUE,
+			content_hash VARCHAR(64) NOT NULL,
+			synced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+func (p *PostgresDBAdapter) GetAllSyncedTables(ctx context.Context) (map[string]string, error) {
+	return syncedTables(ctx, p.db)
+}
+
+func (p *PostgresDBAdapter) RecordTableSynced(ctx context.Context, tableName, contentHash string) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO joka_templates (table_name, content_hash) VALUES ($1, $2)
+		ON CONFLICT (table_name) DO UPDATE SET content_hash = EXCLUDED.content_hash, synced_at = CURRENT_TIMESTAMP
+	`, tableName, contentHash)
+	return err
+}
+
+func (p *PostgresDBAdapter) ColumnTypes(ctx context.Context, tableName string) (map[string]domain.ColumnType, error) {
+	exists, err := jokadb.TableExists(ctx, p.conn, p.driver, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTableNotFound, tableName)
+	}
+	return columnTypes(ctx, p.db, `
+		SELECT column_name, data_type, udt_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+	`, tableName)
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/apsdsm/joka/internal/domains/template/domain"
@@ -22,7 +24,7 @@ func createPostgresTestTable(t *testing.T, db *sql.DB, name string) {
 	t.Cleanup(func() { testlib.DropTablePostgres(t, db, name) })
 }
 
-func TestPostgresTruncateTable(t *testing.T) {
+func TestPostgresTruncateTables(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
 	}
@@ -44,8 +46,8 @@ func TestPostgresTruncateTable(t *testing.T) {
 			t.Fatalf("inserting rows: %v", err)
 		}
 
-		if err := adapter.TruncateTable(ctx, tableName); err != nil {
-			t.Fatalf("TruncateTable: %v", err)
+		if err := adapter.TruncateTables(ctx, []domain.Table{{Name: tableName}}); err != nil {
+			t.Fatalf("TruncateTables: %v", err)
 		}
 
 		var count int
@@ -58,7 +60,7 @@ func TestPostgresTruncateTable(t *testing.T) {
 	})
 
 	t.Run("it returns ErrTableNotFound for a nonexistent table", func(t *testing.T) {
-		err := adapter.TruncateTable(ctx, "nonexistent_table_xyz")
+		err := adapter.TruncateTables(ctx, []domain.Table{{Name: "nonexistent_table_xyz"}})
 		if err == nil {
 			t.Fatal("expected error for nonexistent table, got nil")
 		}
@@ -66,6 +68,140 @@ func TestPostgresTruncateTable(t *testing.T) {
 			t.Fatalf("expected ErrTableNotFound, got: %v", err)
 		}
 	})
+
+	t.Run("it truncates a referenced table together with the tables referencing it", func(t *testing.T) {
+		parent, child := createPostgresParentChild(t, db, "test_pg_tmpl_trunc")
+
+		if err := adapter.TruncateTables(ctx, []domain.Table{{Name: parent}}); err == nil {
+			t.Fatal("expected truncating the parent alone to fail")
+		}
+		if err := adapter.TruncateTables(ctx, []domain.Table{{Name: child}, {Name: parent}}); err != nil {
+			t.Fatalf("TruncateTables: %v", err)
+		}
+		var count int
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+parent+`"`).Scan(&count); err != nil {
+			t.Fatalf("counting rows: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected 0 rows after truncate, got %d", count)
+		}
+	})
+
+	t.Run("it cascades to referencing tables when asked", func(t *testing.T) {
+		parent, child := createPostgresParentChild(t, db, "test_pg_tmpl_cascade")
+
+		if err := adapter.TruncateTables(ctx, []domain.Table{{Name: parent, Truncate: domain.TruncateOptions{Cascade: true}}}); err != nil {
+			t.Fatalf("TruncateTables: %v", err)
+		}
+		var count int
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+child+`"`).Scan(&count); err != nil {
+			t.Fatalf("counting rows: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected the child emptied by the cascade, got %d rows", count)
+		}
+	})
+}
+
+// createPostgresParentChild creates <prefix>_parent and <prefix>_child, whose
+// parent_id references it, with one row each.
+func createPostgresParentChild(t *testing.T, db *sql.DB, prefix string) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	parent, child := prefix+"_parent", prefix+"_child"
+	createPostgresTestTable(t, db, parent)
+	if _, err := db.ExecContext(ctx, `CREATE TABLE "`+child+`" (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES "`+parent+`" (id))`); err != nil {
+		t.Fatalf("creating child table: %v", err)
+	}
+	t.Cleanup(func() { testlib.DropTablePostgres(t, db, child) })
+	if _, err := db.ExecContext(ctx, `INSERT INTO "`+parent+`" (id, name) VALUES (1, 'alice')`); err != nil {
+		t.Fatalf("inserting parent row: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO "`+child+`" (id, parent_id) VALUES (10, 1)`); err != nil {
+		t.Fatalf("inserting child row: %v", err)
+	}
+	return parent, child
+}
+
+func TestPostgresDisableForeignKeys(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("it inserts rows whose parent is missing", func(t *testing.T) {
+		_, child := createPostgresParentChild(t, db, "test_pg_tmpl_nofk")
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("starting transaction: %v", err)
+		}
+		defer tx.Rollback()
+		adapter := infra.NewPostgresTxDBAdapter(tx, db)
+
+		if err := adapter.DisableForeignKeys(ctx); err != nil {
+			t.Fatalf("DisableForeignKeys: %v", err)
+		}
+		if _, err := adapter.InsertRows(ctx, child, []map[string]any{{"id": 11, "parent_id": 99}}); err != nil {
+			t.Fatalf("InsertRows: %v", err)
+		}
+		if err := adapter.EnableForeignKeys(ctx); err != nil {
+			t.Fatalf("EnableForeignKeys: %v", err)
+		}
+		if _, err := adapter.InsertRows(ctx, child, []map[string]any{{"id": 12, "parent_id": 98}}); err == nil {
+			t.Error("expected the foreign key to be enforced again")
+		}
+	})
+
+	t.Run("it empties a table that an unconfigured table references", func(t *testing.T) {
+		parent, child := createPostgresParentChild(t, db, "test_pg_tmpl_nofk_trunc")
+		seqTable := "test_pg_tmpl_nofk_seq"
+		if _, err := db.ExecContext(ctx, `CREATE TABLE "`+seqTable+`" (id SERIAL PRIMARY KEY, name VARCHAR(100))`); err != nil {
+			t.Fatalf("creating serial table: %v", err)
+		}
+		t.Cleanup(func() { testlib.DropTablePostgres(t, db, seqTable) })
+		if _, err := db.ExecContext(ctx, `INSERT INTO "`+seqTable+`" (name) VALUES ('a'), ('b')`); err != nil {
+			t.Fatalf("inserting serial rows: %v", err)
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("starting transaction: %v", err)
+		}
+		defer tx.Rollback()
+		adapter := infra.NewPostgresTxDBAdapter(tx, db)
+
+		if err := adapter.DisableForeignKeys(ctx); err != nil {
+			t.Fatalf("DisableForeignKeys: %v", err)
+		}
+		if err := adapter.TruncateTables(ctx, []domain.Table{{Name: parent}, {Name: seqTable}}); err != nil {
+			t.Fatalf("TruncateTables: %v", err)
+		}
+
+		var parents, children int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+parent+`"`).Scan(&parents); err != nil {
+			t.Fatalf("counting parent rows: %v", err)
+		}
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+child+`"`).Scan(&children); err != nil {
+			t.Fatalf("counting child rows: %v", err)
+		}
+		if parents != 0 || children != 1 {
+			t.Errorf("expected the parent emptied and the child kept, got %d and %d rows", parents, children)
+		}
+
+		var id int
+		if err := tx.QueryRowContext(ctx, `INSERT INTO "`+seqTable+`" (name) VALUES ('c') RETURNING id`).Scan(&id); err != nil {
+			t.Fatalf("inserting after truncate: %v", err)
+		}
+		if id != 1 {
+			t.Errorf("expected the sequence restarted, got id %d", id)
+		}
+	})
 }
 
 func TestPostgresInsertRows(t *testing.T) {
@@ -108,6 +244,53 @@ func TestPostgresInsertRows(t *testing.T) {
 		}
 	})
 
+	t.Run("it inserts rows with differing columns", func(t *testing.T) {
+		tableName := "test_pg_tmpl_insert_mixed"
+		createPostgresTestTable(t, db, tableName)
+
+		rows := []map[string]any{
+			{"id": 1, "name": "alice"},
+			{"id": 2, "name": "bob", "email": "bob@test.com"},
+			{"id": 3, "email": "carol@test.com"},
+		}
+		if _, err := adapter.InsertRows(ctx, tableName, rows); err != nil {
+			t.Fatalf("InsertRows: %v", err)
+		}
+
+		var emails int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(email) FROM "+`"`+tableName+`"`).Scan(&emails); err != nil {
+			t.Fatalf("counting emails: %v", err)
+		}
+		if emails != 2 {
+			t.Errorf("expected 2 rows with an email, got %d", emails)
+		}
+	})
+
+	t.Run("it inserts more rows than fit in one statement", func(t *testing.T) {
+		tableName := "test_pg_tmpl_insert_large"
+		createPostgresTestTable(t, db, tableName)
+
+		rows := make([]map[string]any, 5000)
+		for i := range rows {
+			rows[i] = map[string]any{"id": i + 1, "name": fmt.Sprintf("user%d", i), "email": fmt.Sprintf("user%d@test.com", i)}
+		}
+		count, err := adapter.InsertRows(ctx, tableName, rows)
+		if err != nil {
+			t.Fatalf("InsertRows: %v", err)
+		}
+		if count != len(rows) {
+			t.Fatalf("expected %d inserted, got %d", len(rows), count)
+		}
+
+		var stored int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+`"`+tableName+`"`).Scan(&stored); err != nil {
+			t.Fatalf("counting rows: %v", err)
+		}
+		if stored != len(rows) {
+			t.Errorf("expected %d rows stored, got %d", len(rows), stored)
+		}
+	})
+
 	t.Run("it returns zero for an empty slice", func(t *testing.T) {
 		tableName := "test_pg_tmpl_empty"
 		createPostgresTestTable(t, db, tableName)
@@ -121,3 +304,279 @@ func TestPostgresInsertRows(t *testing.T) {
 		}
 	})
 }
+
+// BenchmarkPostgresInsertRows compares loading 1000 rows one InsertRows call per row
+// (one statement each, as data sync used to) with a single call, which sends
+// them as one COPY. Run with: go test -run x -bench BenchmarkPostgresInsertRows
+func BenchmarkPostgresInsertRows(b *testing.B) {
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		b.Fatalf("getting test db: %v", err)
+	}
+
+	adapter := infra.NewPostgresDBAdapter(db)
+	ctx := context.Background()
+
+	tableName := "test_pg_tmpl_bench"
+	if _, err := db.ExecContext(ctx, "CREATE TABLE "+`"`+tableName+`"`+" (id INT PRIMARY KEY, name VARCHAR(100), email VARCHAR(255))"); err != nil {
+		b.Fatalf("creating bench table: %v", err)
+	}
+	b.Cleanup(func() { db.ExecContext(ctx, "DROP TABLE "+`"`+tableName+`"`) })
+
+	rows := make([]map[string]any, 1000)
+	for i := range rows {
+		rows[i] = map[string]any{"id": i + 1, "name": fmt.Sprintf("user%d", i), "email": fmt.Sprintf("user%d@test.com", i)}
+	}
+
+	run := func(b *testing.B, batch int) {
+		for b.Loop() {
+			b.StopTimer()
+			if err := adapter.TruncateTables(ctx, []domain.Table{{Name: tableName}}); err != nil {
+				b.Fatalf("TruncateTables: %v", err)
+			}
+			b.StartTimer()
+			for start := 0; start < len(rows); start += batch {
+				if _, err := adapter.InsertRows(ctx, tableName, rows[start:min(start+batch, len(rows))]); err != nil {
+					b.Fatalf("InsertRows: %v", err)
+				}
+			}
+		}
+		b.ReportMetric(float64(len(rows)*b.N)/b.Elapsed().Seconds(), "rows/s")
+	}
+
+	b.Run("row by row", func(b *testing.B) { run(b, 1) })
+	b.Run("batched", func(b *testing.B) { run(b, len(rows)) })
+}
+
+func TestPostgresUpsertRows(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+
+	adapter := infra.NewPostgresDBAdapter(db)
+	ctx := context.Background()
+
+	t.Run("it inserts new rows, updates changed ones and counts unchanged ones", func(t *testing.T) {
+		tableName := "test_pg_tmpl_upsert"
+		createPostgresTestTable(t, db, tableName)
+
+		if _, err := db.ExecContext(ctx, `INSERT INTO "`+tableName+`" (id, name, email) VALUES (1, 'alice', 'alice@test.com'), (2, 'bob', 'bob@test.com')`); err != nil {
+			t.Fatalf("inserting rows: %v", err)
+		}
+
+		rows := []map[string]any{
+			{"id": 1, "name": "alicia", "email": "alice@test.com"},
+			{"id": 2, "name": "bob", "email": "bob@test.com"},
+			{"id": 3, "name": "carol", "email": "carol@test.com"},
+		}
+		result, err := adapter.UpsertRows(ctx, tableName, []string{"id"}, rows)
+		if err != nil {
+			t.Fatalf("UpsertRows: %v", err)
+		}
+		want := domain.SyncResult{Inserted: 1, Updated: 1, Unchanged: 1}
+		if result != want {
+			t.Errorf("expected %+v, got %+v", want, result)
+		}
+
+		var name string
+		if err := db.QueryRowContext(ctx, `SELECT name FROM "`+tableName+`" WHERE id = 1`).Scan(&name); err != nil {
+			t.Fatalf("querying row: %v", err)
+		}
+		if name != "alicia" {
+			t.Errorf("expected the row to be updated, got name=%q", name)
+		}
+	})
+
+	t.Run("it reads the column types", func(t *testing.T) {
+		tableName := "test_pg_tmpl_upsert_types"
+		createPostgresTestTable(t, db, tableName)
+
+		types, err := adapter.ColumnTypes(ctx, tableName)
+		if err != nil {
+			t.Fatalf("ColumnTypes: %v", err)
+		}
+		want := map[string]domain.ColumnType{"id": domain.ColumnInt, "name": domain.ColumnString, "email": domain.ColumnString}
+		if !reflect.DeepEqual(types, want) {
+			t.Errorf("expected %v, got %v", want, types)
+		}
+	})
+
+	t.Run("it reads the primary key", func(t *testing.T) {
+		tableName := "test_pg_tmpl_upsert_pk"
+		createPostgresTestTable(t, db, tableName)
+
+		key, err := adapter.PrimaryKey(ctx, tableName)
+		if err != nil {
+			t.Fatalf("PrimaryKey: %v", err)
+		}
+		if len(key) != 1 || key[0] != "id" {
+			t.Errorf("expected [id], got %v", key)
+		}
+	})
+
+	t.Run("it returns ErrTableNotFound for a nonexistent table", func(t *testing.T) {
+		_, err := adapter.UpsertRows(ctx, "nonexistent_table_xyz", []string{"id"}, []map[string]any{{"id": 1}})
+		if !errors.Is(err, domain.ErrTableNotFound) {
+			t.Fatalf("expected ErrTableNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestPostgresDeleteRows(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+
+	adapter := infra.NewPostgresDBAdapter(db)
+	ctx := context.Background()
+
+	t.Run("it lists keys and deletes rows by key", func(t *testing.T) {
+		tableName := "test_pg_tmpl_delete"
+		createPostgresTestTable(t, db, tableName)
+
+		if _, err := db.ExecContext(ctx, `INSERT INTO "`+tableName+`" (id, name) VALUES (1, 'alice'), (2, 'bob'), (3, 'carol')`); err != nil {
+			t.Fatalf("inserting rows: %v", err)
+		}
+
+		keys, err := adapter.ListKeys(ctx, tableName, []string{"id"})
+		if err != nil {
+			t.Fatalf("ListKeys: %v", err)
+		}
+		if len(keys) != 3 {
+			t.Fatalf("expected 3 keys, got %v", keys)
+		}
+
+		deleted, err := adapter.DeleteRows(ctx, tableName, []string{"id"}, [][]any{{1}, {3}})
+		if err != nil {
+			t.Fatalf("DeleteRows: %v", err)
+		}
+		if deleted != 2 {
+			t.Errorf("expected 2 rows deleted, got %d", deleted)
+		}
+
+		var count int
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+tableName+`"`).Scan(&count); err != nil {
+			t.Fatalf("counting rows: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("expected 1 row left, got %d", count)
+		}
+	})
+
+	t.Run("it finds and counts rows referencing the table", func(t *testing.T) {
+		tableName := "test_pg_tmpl_delete_parent"
+		childName := "test_pg_tmpl_delete_child"
+		createPostgresTestTable(t, db, tableName)
+		if _, err := db.ExecContext(ctx, `CREATE TABLE "`+childName+`" (id INTEGER PRIMARY KEY, parent_id INTEGER, CONSTRAINT fk_pg_tmpl_delete_parent FOREIGN KEY (parent_id) REFERENCES "`+tableName+`" (id))`); err != nil {
+			t.Fatalf("creating child table: %v", err)
+		}
+		t.Cleanup(func() { testlib.DropTablePostgres(t, db, childName) })
+
+		if _, err := db.ExecContext(ctx, `INSERT INTO "`+tableName+`" (id, name) VALUES (1, 'alice'), (2, 'bob')`); err != nil {
+			t.Fatalf("inserting parent rows: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO "`+childName+`" (id, parent_id) VALUES (10, 1), (11, 1)`); err != nil {
+			t.Fatalf("inserting child rows: %v", err)
+		}
+
+		fks, err := adapter.ReferencingForeignKeys(ctx, tableName)
+		if err != nil {
+			t.Fatalf("ReferencingForeignKeys: %v", err)
+		}
+		want := domain.ForeignKeyRef{Name: "fk_pg_tmpl_delete_parent", Table: childName, Columns: []string{"parent_id"}, RefColumns: []string{"id"}}
+		if !reflect.DeepEqual(fks, []domain.ForeignKeyRef{want}) {
+			t.Fatalf("expected %+v, got %+v", want, fks)
+		}
+
+		n, err := adapter.CountReferencingRows(ctx, tableName, []string{"id"}, [][]any{{1}, {2}}, fks[0])
+		if err != nil {
+			t.Fatalf("CountReferencingRows: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 referencing rows, got %d", n)
+		}
+	})
+
+	t.Run("it counts self references among more doomed keys than one statement can bind", func(t *testing.T) {
+		tableName := "test_pg_tmpl_delete_tree"
+		if _, err := db.ExecContext(ctx, `CREATE TABLE "`+tableName+`" (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES "`+tableName+`" (id))`); err != nil {
+			t.Fatalf("creating table: %v", err)
+		}
+		t.Cleanup(func() { testlib.DropTablePostgres(t, db, tableName) })
+
+		// A chain of 70,000 rows, each pointing at the one before, and one
+		// more row pointing into it that is kept.
+		if _, err := db.ExecContext(ctx, `INSERT INTO "`+tableName+`" (id, parent_id) SELECT i, NULLIF(i - 1, 0) FROM generate_series(1, 70000) i`); err != nil {
+			t.Fatalf("inserting rows: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO "`+tableName+`" (id, parent_id) VALUES (70001, 5)`); err != nil {
+			t.Fatalf("inserting kept row: %v", err)
+		}
+
+		doomed := make([][]any, 70000)
+		for i := range doomed {
+			doomed[i] = []any{i + 1}
+		}
+		fk := domain.ForeignKeyRef{Table: tableName, Columns: []string{"parent_id"}, RefColumns: []string{"id"}}
+		n, err := adapter.CountReferencingRows(ctx, tableName, []string{"id"}, doomed, fk)
+		if err != nil {
+			t.Fatalf("CountReferencingRows: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("expected only the kept row to count, got %d", n)
+		}
+	})
+
+	t.Run("it returns ErrTableNotFound for a nonexistent table", func(t *testing.T) {
+		_, err := adapter.ListKeys(ctx, "nonexistent_table_xyz", []string{"id"})
+		if !errors.Is(err, domain.ErrTableNotFound) {
+			t.Fatalf("expected ErrTableNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestPostgresTrackingTable(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	db, err := testlib.GetTestPostgresDB()
+	if err != nil {
+		t.Fatalf("getting test db: %v", err)
+	}
+
+	adapter := infra.NewPostgresDBAdapter(db)
+	ctx := context.Background()
+
+	t.Run("it records and replaces table hashes", func(t *testing.T) {
+		if err := adapter.EnsureTrackingTable(ctx); err != nil {
+			t.Fatalf("EnsureTrackingTable: %v", err)
+		}
+		t.Cleanup(func() { testlib.DropTablePostgres(t, db, "joka_templates") })
+
+		if err := adapter.RecordTableSynced(ctx, "currencies", "aaa"); err != nil {
+			t.Fatalf("RecordTableSynced: %v", err)
+		}
+		if err := adapter.RecordTableSynced(ctx, "currencies", "bbb"); err != nil {
+			t.Fatalf("RecordTableSynced: %v", err)
+		}
+
+		synced, err := adapter.GetAllSyncedTables(ctx)
+		if err != nil {
+			t.Fatalf("GetAllSyncedTables: %v", err)
+		}
+		if !reflect.DeepEqual(synced, map[string]string{"currencies": "bbb"}) {
+			t.Errorf("expected the replaced hash, got %v", synced)
+		}
+	})
+}
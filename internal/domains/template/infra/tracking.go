@@ -0,0 +1,22 @@
+package infra
+
+import "context"
+
+// syncedTables reads joka_templates into a map of table name to content hash.
+func syncedTables(ctx context.Context, db DBTX) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT table_name, content_hash FROM joka_templates")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	synced := make(map[string]string)
+	for rows.Next() {
+		var name, hash string
+		if err := rows.Scan(&name, &hash); err != nil {
+			return nil, err
+		}
+		synced[name] = hash
+	}
+	return synced, rows.Err()
+}
@@ -0,0 +1,262 @@
+// Package expr evaluates the {{ ... }} template expressions that entity files
+// and data sync (template) files may use as column values:
+//   - {{ now }} — the sync's timestamp
+//   - {{ argon2id|<raw> }} — an argon2id hash of <raw>
+//   - {{ sha256|<raw> }} — the SHA-256 hex digest of <raw>
+//   - {{ lookup|table,return_col,where_col=value }} — a value queried from an existing table row
+//   - {{ asm.<source>.<key> }} — a value from a configured secret source
+//
+// An argon2id/sha256 argument starting with "asm." is resolved as a secret
+// reference before hashing; any other argument is a literal. Entity files add
+// {{ <ref>.id }} references on top of these.
+package expr
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	// ErrInvalidTemplate is returned when a {{ ... }} expression cannot be
+	// parsed or contains an unknown function.
+	ErrInvalidTemplate = errors.New("invalid template expression")
+
+	// ErrLookupNotFound is returned when a {{ lookup|... }} expression
+	// matches zero rows in the target table.
+	ErrLookupNotFound = errors.New("lookup returned no rows")
+)
+
+// secretRefPrefix marks a template argument as a secret reference of the form
+// asm.<source>.<key> rather than a literal value.
+const secretRefPrefix = "asm."
+
+// SecretResolver resolves a named secret source and key (from the `secrets:`
+// config map) to the secret's value. Implemented by internal/secrets.
+type SecretResolver interface {
+	Resolve(ctx context.Context, source, key string) (string, error)
+}
+
+// Lookup queries a single value from an existing table row, for
+// {{ lookup|... }}. It returns an error wrapping ErrLookupNotFound when no
+// row matches.
+type Lookup interface {
+	LookupValue(ctx context.Context, table, returnCol, whereCol string, whereVal any) (any, error)
+}
+
+// Resolver evaluates expressions. Now is the value of {{ now }}; DB and
+// Secrets may be nil, in which case lookups and secret references fail.
+type Resolver struct {
+	Now     string
+	DB      Lookup
+	Secrets SecretResolver
+}
+
+// Template returns the inner expression of a {{ ... }} template and true if
+// the value is a string wrapped in template delimiters. Otherwise it returns
+// false (plain value or non-string).
+func Template(v any) (string, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return "", false
+	}
+
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{{") || !strings.HasSuffix(trimmed, "}}") {
+		return "", false
+	}
+
+	return strings.TrimSpace(trimmed[2 : len(trimmed)-2]), true
+}
+
+// Builtin reports whether an inner expression calls one of the functions
+// this package resolves, as opposed to something a caller handles itself
+// (such as an entity's {{ <ref>.id }}).
+func Builtin(expr string) bool {
+	if expr == "now" {
+		return true
+	}
+	for _, fn := range []string{"argon2id|", "sha256|", "lookup|", secretRefPrefix} {
+		if strings.HasPrefix(expr, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVolatile reports whether a raw column value resolves to a different
+// result on every evaluation (argon2id uses a random salt; now is the current
+// time). A before/after diff of these would always show a spurious change, so
+// planners label them "regenerated" instead.
+//
+// Secret references (asm.*, alone or hashed) are included even though they are
+// deterministic: a planner must never print resolved secret material, and
+// short-circuiting here also avoids a Secrets Manager fetch at plan time.
+func IsVolatile(v any) bool {
+	expr, ok := Template(v)
+	if !ok {
+		return false
+	}
+	return expr == "now" ||
+		strings.HasPrefix(expr, "argon2id|") ||
+		strings.HasPrefix(expr, secretRefPrefix) ||
+		strings.HasPrefix(expr, "sha256|"+secretRefPrefix)
+}
+
+// IsExternal reports whether a raw column value resolves from state outside
+// the record files: a lookup, or a secret reference (alone or hashed). Its
+// result can change while the files stay the same.
+func IsExternal(v any) bool {
+	expr, ok := Template(v)
+	if !ok {
+		return false
+	}
+	return strings.HasPrefix(expr, "lookup|") ||
+		strings.HasPrefix(expr, secretRefPrefix) ||
+		strings.HasPrefix(expr, "sha256|"+secretRefPrefix) ||
+		strings.HasPrefix(expr, "argon2id|"+secretRefPrefix)
+}
+
+// Value resolves v if it is a template expression. Other values, strings
+// included, are returned as-is.
+func (r Resolver) Value(ctx context.Context, v any) (any, error) {
+	expr, ok := Template(v)
+	if !ok {
+		return v, nil
+	}
+	return r.Resolve(ctx, expr)
+}
+
+// Row resolves the template expressions among a row's values into a new row.
+func (r Resolver) Row(ctx context.Context, row map[string]any) (map[string]any, error) {
+	resolved := make(map[string]any, len(row))
+	for k, v := range row {
+		val, err := r.Value(ctx, v)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", k, err)
+		}
+		resolved[k] = val
+	}
+	return resolved, nil
+}
+
+// Resolve evaluates an inner expression (without its {{ }} delimiters).
+func (r Resolver) Resolve(ctx context.Context, expr string) (any, error) {
+	if expr == "now" {
+		return r.Now, nil
+	}
+
+	if strings.HasPrefix(expr, "argon2id|") {
+		raw, err := r.hashArg(ctx, expr[len("argon2id|"):])
+		if err != nil {
+			return nil, err
+		}
+		return HashArgon2id(raw)
+	}
+
+	if strings.HasPrefix(expr, "sha256|") {
+		raw, err := r.hashArg(ctx, expr[len("sha256|"):])
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.Sum256([]byte(raw))
+		return hex.EncodeToString(h[:]), nil
+	}
+
+	if strings.HasPrefix(expr, "lookup|") {
+		return r.lookup(ctx, expr[len("lookup|"):])
+	}
+
+	if strings.HasPrefix(expr, secretRefPrefix) {
+		return r.secret(ctx, expr)
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrInvalidTemplate, expr)
+}
+
+// hashArg returns an argon2id/sha256 argument as-is unless it is a secret
+// reference (asm.<source>.<key>), in which case the secret value is resolved
+// first.
+func (r Resolver) hashArg(ctx context.Context, raw string) (string, error) {
+	if !strings.HasPrefix(raw, secretRefPrefix) {
+		return raw, nil
+	}
+	return r.secret(ctx, raw)
+}
+
+// ParseSecretRef splits an "asm.<source>.<key>" reference into its source and
+// key. Both must be non-empty and dot-free (the secret_id, which may contain
+// slashes or dots, lives in config — not in the template).
+func ParseSecretRef(s string) (source, key string, ok bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || parts[0] != "asm" || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// secret resolves an asm.<source>.<key> reference via the configured secret
+// resolver.
+func (r Resolver) secret(ctx context.Context, ref string) (string, error) {
+	source, key, ok := ParseSecretRef(ref)
+	if !ok {
+		return "", fmt.Errorf("%w: %q (want asm.<source>.<key>)", ErrInvalidTemplate, ref)
+	}
+	if r.Secrets == nil {
+		return "", fmt.Errorf("resolving %q: no secret sources configured (add a `secrets:` map to .jokarc.yaml)", ref)
+	}
+	return r.Secrets.Resolve(ctx, source, key)
+}
+
+// lookup parses a lookup expression of the form "table,return_col,where_col=value"
+// and queries the database for the matching value.
+func (r Resolver) lookup(ctx context.Context, params string) (any, error) {
+	parts := strings.SplitN(params, ",", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: lookup requires 3 comma-separated params (table,return_col,where_col=value), got %d", ErrInvalidTemplate, len(parts))
+	}
+
+	table := strings.TrimSpace(parts[0])
+	returnCol := strings.TrimSpace(parts[1])
+	whereExpr := strings.TrimSpace(parts[2])
+
+	whereParts := strings.SplitN(whereExpr, "=", 2)
+	if len(whereParts) != 2 {
+		return nil, fmt.Errorf("%w: lookup where clause must be where_col=value, got %q", ErrInvalidTemplate, whereExpr)
+	}
+
+	whereCol := strings.TrimSpace(whereParts[0])
+	whereVal := strings.TrimSpace(whereParts[1])
+
+	if r.DB == nil {
+		return nil, fmt.Errorf("resolving lookup of %s.%s: no database to query", table, returnCol)
+	}
+	return r.DB.LookupValue(ctx, table, returnCol, whereCol, whereVal)
+}
+
+// HashArgon2id produces an argon2id hash string in the standard encoded format.
+// Uses the same parameters as the lgc_api default: m=65536, t=3, p=2.
+func HashArgon2id(password string) (string, error) {
+	salt := make([]byte, 16)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generating salt: %w", err)
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, 3, 64*1024, 2, 32)
+
+	encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, 64*1024, 3, 2,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	)
+
+	return encoded, nil
+}
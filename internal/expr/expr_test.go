@@ -0,0 +1,116 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type lookupMock struct {
+	data map[string]any
+}
+
+func (l lookupMock) LookupValue(_ context.Context, table, returnCol, whereCol string, whereVal any) (any, error) {
+	val, ok := l.data[fmt.Sprintf("%s.%s.%s=%v", table, returnCol, whereCol, whereVal)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s.%s where %s=%v", ErrLookupNotFound, table, returnCol, whereCol, whereVal)
+	}
+	return val, nil
+}
+
+func TestResolverRow(t *testing.T) {
+	r := Resolver{
+		Now: "2025-06-01 12:00:00",
+		DB:  lookupMock{data: map[string]any{"plans.id.code=pro": int64(3)}},
+	}
+
+	t.Run("it resolves expressions and leaves other values alone", func(t *testing.T) {
+		row := map[string]any{
+			"name":       "Alice",
+			"count":      42,
+			"plan_id":    "{{ lookup|plans,id,code=pro }}",
+			"created_at": "{{now}}",
+		}
+
+		got, err := r.Row(context.Background(), row)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got["name"] != "Alice" || got["count"] != 42 || got["plan_id"] != int64(3) || got["created_at"] != r.Now {
+			t.Errorf("unexpected row: %v", got)
+		}
+		if row["plan_id"] != "{{ lookup|plans,id,code=pro }}" {
+			t.Error("expected the input row to be left unchanged")
+		}
+	})
+
+	t.Run("it names the column of a failed expression", func(t *testing.T) {
+		_, err := r.Row(context.Background(), map[string]any{"parent_id": "{{ parent.id }}"})
+		if !errors.Is(err, ErrInvalidTemplate) {
+			t.Fatalf("expected ErrInvalidTemplate, got %v", err)
+		}
+		if !strings.HasPrefix(err.Error(), `column "parent_id"`) {
+			t.Errorf("expected the column name first, got %q", err)
+		}
+	})
+
+	t.Run("it fails a lookup without a database", func(t *testing.T) {
+		if _, err := (Resolver{}).Value(context.Background(), "{{ lookup|plans,id,code=pro }}"); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
+
+func TestPredicates(t *testing.T) {
+	t.Run("it tells builtin functions from other expressions", func(t *testing.T) {
+		for _, e := range []string{"now", "argon2id|x", "sha256|x", "lookup|a,b,c=d", "asm.seed.key"} {
+			if !Builtin(e) {
+				t.Errorf("%s: expected builtin", e)
+			}
+		}
+		if Builtin("parent.id") {
+			t.Error("parent.id: expected not builtin")
+		}
+	})
+
+	t.Run("it marks now, argon2id and secrets as volatile", func(t *testing.T) {
+		for _, v := range []any{"{{ now }}", "{{ argon2id|pw }}", "{{ asm.seed.key }}", "{{ sha256|asm.seed.key }}"} {
+			if !IsVolatile(v) {
+				t.Errorf("%v: expected volatile", v)
+			}
+		}
+		for _, v := range []any{"{{ sha256|literal }}", "{{ lookup|a,b,c=d }}", "plain", 7} {
+			if IsVolatile(v) {
+				t.Errorf("%v: expected not volatile", v)
+			}
+		}
+	})
+
+	t.Run("it marks lookups and secrets as external", func(t *testing.T) {
+		for _, v := range []any{"{{ lookup|a,b,c=d }}", "{{ asm.seed.key }}", "{{ sha256|asm.seed.key }}", "{{ argon2id|asm.seed.key }}"} {
+			if !IsExternal(v) {
+				t.Errorf("%v: expected external", v)
+			}
+		}
+		for _, v := range []any{"{{ now }}", "{{ argon2id|pw }}", "{{ sha256|literal }}", "plain", 7} {
+			if IsExternal(v) {
+				t.Errorf("%v: expected not external", v)
+			}
+		}
+	})
+}
+
+func TestParseSecretRef(t *testing.T) {
+	source, key, ok := ParseSecretRef("asm.seed.api_key")
+	if !ok || source != "seed" || key != "api_key" {
+		t.Errorf("expected (seed, api_key), got (%s, %s, %v)", source, key, ok)
+	}
+
+	for _, s := range []string{"asm.seed", "asm.seed.a.b", "asm..key", "asm.seed.", "notasm.seed.key"} {
+		if _, _, ok := ParseSecretRef(s); ok {
+			t.Errorf("%s: expected parse to fail", s)
+		}
+	}
+}
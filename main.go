@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/joho/godotenv"
@@ -11,11 +16,16 @@ import (
 	"github.com/apsdsm/joka/cmd/entity"
 	"github.com/apsdsm/joka/cmd/lock"
 	"github.com/apsdsm/joka/cmd/migration"
+	"github.com/apsdsm/joka/cmd/schema"
 	"github.com/apsdsm/joka/cmd/shared"
 	"github.com/apsdsm/joka/cmd/template"
 	"github.com/apsdsm/joka/config"
 	"github.com/apsdsm/joka/internal/connection"
 	"github.com/apsdsm/joka/internal/secrets"
+	migrationdomain "github.com/apsdsm/joka/internal/domains/migration/domain"
+	schemaapp "github.com/apsdsm/joka/internal/domains/schema/app"
+	schemadomain "github.com/apsdsm/joka/internal/domains/schema/domain"
+	templatedomain "github.com/apsdsm/joka/internal/domains/template/domain"
 	templateinfra "github.com/apsdsm/joka/internal/domains/template/infra"
 	jokadb "github.com/apsdsm/joka/db"
 	"github.com/spf13/cobra"
@@ -33,6 +43,7 @@ func main() {
 		autoConfirm   bool
 		outputFormat  string
 		dbConn        *sql.DB
+		dbDSN         string
 		dbDriver      jokadb.Driver
 		cfg           *config.Config
 	)
@@ -71,18 +82,19 @@ func main() {
 				return err
 			}
 
-			if c.Name() == "make" {
+			// make needs no database; schema diff and status open their own.
+			if c.Name() == "make" || c.Name() == "diff" || c.CommandPath() == "joka status" {
 				return nil
 			}
 
 			// Resolve the DSN from the connection config (env by default, or a
 			// secret source declared in .jokarc.yaml / the selected profile).
-			dsn, err := connection.Resolve(c.Context(), cfg.Connection, nil)
+			dbDSN, err = connection.Resolve(c.Context(), cfg.Connection, nil)
 			if err != nil {
 				return err
 			}
 
-			dbConn, dbDriver, err = jokadb.Open(dsn)
+			dbConn, dbDriver, err = jokadb.Open(dbDSN)
 			if err != nil {
 				return fmt.Errorf("error connecting to database: %w", err)
 			}
@@ -115,15 +127,53 @@ func main() {
 	makeCmd := &cobra.Command{
 		Use:   "make [name]",
 		Short: "Create a new migration file",
-		Args:  cobra.ExactArgs(1),
+		Long: `Create a new migration file named <UTC timestamp>_<name>.sql.
+
+--template picks a scaffold. Built-ins are create_table and add_column; they
+read the table (and column) from names like create_users or
+add_email_to_users, or from --table/--column, and emit MySQL or PostgreSQL
+boilerplate to match the driver. Custom scaffolds live in the directory set by
+scaffolds: in .jokarc.yaml as <name>.sql.tmpl (plus an optional
+<name>.down.sql.tmpl), rendered with Go text/template. Templates see .Name,
+.Index, .Driver, .Table and .Column.
+
+The driver comes from --driver, else connection.driver in .jokarc.yaml, else
+the DATABASE_URL scheme, else mysql.
+
+--down also writes down/<file> with SQL that reverts the migration; --meta adds
+a comment header recording how the file was generated. make refuses to write a
+file whose index is already used by another migration.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(c *cobra.Command, args []string) error {
+			tmpl, _ := c.Flags().GetString("template")
+			table, _ := c.Flags().GetString("table")
+			column, _ := c.Flags().GetString("column")
+			down, _ := c.Flags().GetBool("down")
+			meta, _ := c.Flags().GetBool("meta")
+			driver, err := makeDriver(c, cfg)
+			if err != nil {
+				return err
+			}
 			return migration.RunMakeCommand{
 				MigrationsDir: migrationsDir,
 				Name:          args[0],
 				OutputFormat:  outputFormat,
+				Template:      tmpl,
+				ScaffoldsDir:  cfg.Scaffolds,
+				Driver:        driver,
+				Table:         table,
+				Column:        column,
+				Down:          down,
+				Meta:          meta,
 			}.Execute(c.Context())
 		},
 	}
+	makeCmd.Flags().String("template", "", "Scaffold to use: create_table, add_column, or a custom template name")
+	makeCmd.Flags().String("driver", "", "Dialect for the scaffold: mysql or postgres (default: from config or DATABASE_URL)")
+	makeCmd.Flags().String("table", "", "Table name for the scaffold (default: derived from the migration name)")
+	makeCmd.Flags().String("column", "", "Column name for the scaffold (default: derived from the migration name)")
+	makeCmd.Flags().Bool("down", false, "Also write a down file under down/")
+	makeCmd.Flags().Bool("meta", false, "Prepend a metadata comment header")
 
 	migrateCmd := &cobra.Command{
 		Use:   "migrate",
@@ -134,15 +184,45 @@ func main() {
 		Use:   "up",
 		Short: "Apply pending migrations",
 		RunE: func(c *cobra.Command, _ []string) error {
+			retry, _ := c.Flags().GetInt("retry")
+			retryBackoff, _ := c.Flags().GetDuration("retry-backoff")
+			dryRun, _ := c.Flags().GetBool("dry-run")
+			if retry < 0 {
+				return fmt.Errorf("--retry must not be negative, got %d", retry)
+			}
+			if retryBackoff < 0 {
+				return fmt.Errorf("--retry-backoff must not be negative, got %s", retryBackoff)
+			}
+			snapshots, err := resolveSnapshotOptions(c, cfg)
+			if err != nil {
+				return err
+			}
+			timeouts, err := resolveTimeouts(c, cfg)
+			if err != nil {
+				return err
+			}
+
 			return migration.RunMigrateUpCommand{
-				DB:            dbConn,
-				Driver:        dbDriver,
-				MigrationsDir: migrationsDir,
-				AutoConfirm:   autoConfirm,
-				OutputFormat:  outputFormat,
+				DB:                dbConn,
+				Driver:            dbDriver,
+				MigrationsDir:     migrationsDir,
+				AutoConfirm:       autoConfirm,
+				OutputFormat:      outputFormat,
+				Timeouts:          timeouts,
+				Retry:             retry,
+				RetryBackoff:      retryBackoff,
+				Profile:           profile,
+				DryRun:            dryRun,
+				Snapshots:         snapshots,
+				SnapshotRetention: cfg.Snapshots.Retention,
 			}.Execute(c.Context())
 		},
 	}
+	addTimeoutFlags(migrateUpCmd)
+	migrateUpCmd.Flags().Int("retry", 0, "Retry the batch up to N times when it fails on a lock timeout or deadlock")
+	migrateUpCmd.Flags().Duration("retry-backoff", 10*time.Second, "Wait before the first retry; doubles on each attempt, up to 10m")
+	migrateUpCmd.Flags().String("snapshot", "", "When to capture schema snapshots: per-migration or end-of-batch (default: snapshots.mode from config)")
+	migrateUpCmd.Flags().Bool("dry-run", false, "Print the SQL each pending migration would run (conditionals evaluated) without applying")
 
 	migrateStatusCmd := &cobra.Command{
 		Use:   "status",
@@ -167,14 +247,14 @@ func main() {
 	dataSyncCmd := &cobra.Command{
 		Use:   "sync",
 		Short: "Sync template data to the database",
+		Long: `Sync template data to the database.
+
+Tables whose record files and config are unchanged since their last sync are
+skipped; --force syncs them anyway. Tables with {{ lookup|... }} or
+{{ asm.* }} values are always synced, since a looked-up row or a rotated
+secret changes their data without changing their files.`,
 		RunE: func(c *cobra.Command, _ []string) error {
-			tables := make([]templateinfra.TableConfig, len(cfg.Tables))
-			for i, t := range cfg.Tables {
-				tables[i] = templateinfra.TableConfig{
-					Name:     t.Name,
-					Strategy: t.Strategy,
-				}
-			}
+			tables := templateTables(cfg.Tables)
 
 			// CLI flag overrides config; config is the default.
 			ignoreFK := cfg.IgnoreForeignKeys
@@ -182,6 +262,19 @@ func main() {
 				ignoreFK = ignoreForeignKeys
 			}
 
+			batchSize := cfg.BatchSize
+			if c.Flags().Changed("batch-size") {
+				batchSize, _ = c.Flags().GetInt("batch-size")
+			}
+
+			dryRun, _ := c.Flags().GetBool("dry-run")
+			force, _ := c.Flags().GetBool("force")
+
+			timeouts, err := resolveTimeouts(c, cfg)
+			if err != nil {
+				return err
+			}
+
 			return template.RunDataSyncCommand{
 				DB:                dbConn,
 				Driver:            dbDriver,
@@ -189,12 +282,35 @@ func main() {
 				Tables:            tables,
 				AutoConfirm:       autoConfirm,
 				IgnoreForeignKeys: ignoreFK,
+				BatchSize:         batchSize,
 				OutputFormat:      outputFormat,
+				DryRun:            dryRun,
+				Force:             force,
+				Timeouts:          timeouts,
+				Secrets:           secrets.New(cfg.Secrets),
 			}.Execute(c.Context())
 		},
 	}
 
-	dataSyncCmd.Flags().BoolVar(&ignoreForeignKeys, "ignore-foreign-keys", false, "Disable foreign key checks during truncate (MySQL)")
+	dataSyncCmd.Flags().Bool("dry-run", false, "Preview the rows each table would insert, update or delete without applying")
+	dataSyncCmd.Flags().Bool("force", false, "Sync every table, including those unchanged since the last sync")
+	dataSyncCmd.Flags().Int("batch-size", 0, "Rows per insert batch when reloading truncated tables (default: batch_size from config, or 1000)")
+	dataSyncCmd.Flags().BoolVar(&ignoreForeignKeys, "ignore-foreign-keys", false, "Disable foreign key checks for the whole sync: truncates, stale row deletes and loads (FOREIGN_KEY_CHECKS=0 on MySQL; session_replication_role = replica on PostgreSQL, which needs superuser or a granted SET privilege)")
+	addTimeoutFlags(dataSyncCmd)
+
+	dataStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show template table sync status",
+		RunE: func(c *cobra.Command, _ []string) error {
+			return template.RunDataStatusCommand{
+				DB:           dbConn,
+				Driver:       dbDriver,
+				TemplatesDir: templatesDir,
+				Tables:       templateTables(cfg.Tables),
+				OutputFormat: outputFormat,
+			}.Execute(c.Context())
+		},
+	}
 
 	unlockCmd := &cobra.Command{
 		Use:   "unlock",
@@ -213,14 +329,41 @@ func main() {
 			if len(args) > 0 {
 				index = args[0]
 			}
+			write, _ := c.Flags().GetString("write")
 			return migration.RunSnapshotCommand{
 				DB:             dbConn,
 				Driver:         dbDriver,
 				MigrationIndex: index,
+				WritePath:      write,
 				OutputFormat:   outputFormat,
 			}.Execute(c.Context())
 		},
 	}
+	migrateSnapshotCmd.Flags().String("write", "", "Write the snapshot to this file (e.g. schema.sql) in foreign key order")
+
+	migrateSnapshotPruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old schema snapshots, keeping the newest N",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			keep, _ := c.Flags().GetInt("keep")
+			if !c.Flags().Changed("keep") {
+				keep = cfg.Snapshots.Retention
+			}
+			if keep < 1 {
+				return fmt.Errorf("--keep is required (or set snapshots.retention in config)")
+			}
+			return migration.RunSnapshotPruneCommand{
+				DB:           dbConn,
+				Driver:       dbDriver,
+				Keep:         keep,
+				AutoConfirm:  autoConfirm,
+				OutputFormat: outputFormat,
+			}.Execute(c.Context())
+		},
+	}
+	migrateSnapshotPruneCmd.Flags().Int("keep", 0, "Number of most recent snapshots to keep (default: snapshots.retention from config)")
+	migrateSnapshotCmd.AddCommand(migrateSnapshotPruneCmd)
 
 	migrateConsolidateCmd := &cobra.Command{
 		Use:   "consolidate",
@@ -230,9 +373,19 @@ func main() {
 			if upTo == "" {
 				return fmt.Errorf("--up-to flag is required")
 			}
+			validate, _ := c.Flags().GetString("validate")
+			shadowDSN := ""
+			if validate != "" {
+				var err error
+				if shadowDSN, err = resolveShadowDSN(c, validate); err != nil {
+					return err
+				}
+			}
 			return migration.RunConsolidateCommand{
 				DB:            dbConn,
 				Driver:        dbDriver,
+				TargetDSN:     dbDSN,
+				ShadowDSN:     shadowDSN,
 				MigrationsDir: migrationsDir,
 				UpToIndex:     upTo,
 				AutoConfirm:   autoConfirm,
@@ -241,18 +394,94 @@ func main() {
 		},
 	}
 	migrateConsolidateCmd.Flags().String("up-to", "", "Migration index to consolidate up to (required)")
+	migrateConsolidateCmd.Flags().String("validate", "", "Apply the consolidated file to this throwaway database (DSN or profile) before replacing the originals")
 
 	migrateVerifyCmd := &cobra.Command{
 		Use:   "verify",
 		Short: "Detect schema drift against the latest snapshot",
+		Long: `Detect schema drift against the latest snapshot.
+
+With --shadow <dsn|profile>, the named database is dropped and recreated, every
+migration file is replayed on it in order, and the replayed schema is compared
+with each snapshot in joka_snapshots and finally with the live schema. The
+first migration index where they diverge is reported. A value containing "/"
+or "@" is a DSN; anything else is a .jokarc.yaml profile whose connection is
+used. The shadow must use the same driver as the target and must not be the
+target database.
+
+With --against-file <path>, the live schema (or, with --shadow, the replay of
+the migration files) is compared against a schema file written by
+"migrate snapshot --write" instead.`,
 		RunE: func(c *cobra.Command, _ []string) error {
+			shadow, _ := c.Flags().GetString("shadow")
+			againstFile, _ := c.Flags().GetString("against-file")
+			shadowDSN := ""
+			if shadow != "" {
+				var err error
+				if shadowDSN, err = resolveShadowDSN(c, shadow); err != nil {
+					return err
+				}
+			}
 			return migration.RunVerifyCommand{
-				DB:           dbConn,
-				Driver:       dbDriver,
-				OutputFormat: outputFormat,
+				DB:            dbConn,
+				Driver:        dbDriver,
+				TargetDSN:     dbDSN,
+				ShadowDSN:     shadowDSN,
+				AgainstFile:   againstFile,
+				MigrationsDir: migrationsDir,
+				Profile:       profile,
+				AutoConfirm:   autoConfirm,
+				OutputFormat:  outputFormat,
+			}.Execute(c.Context())
+		},
+	}
+	migrateVerifyCmd.Flags().String("shadow", "", "Replay migrations on this throwaway database (DSN or profile) and compare")
+	migrateVerifyCmd.Flags().String("against-file", "", "Compare against a schema file written by migrate snapshot --write")
+
+	migrateImportCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import migration files and history from another migration tool",
+		Long: `Import migration files and history from goose, golang-migrate, flyway or dbmate.
+
+Migration files in --source are copied into the migrations directory under
+joka's YYMMDDHHMMSS_name.sql naming. Timestamped versions keep their moment in
+time; sequential versions are numbered 000000000001, 000000000002, ... in
+order. Down sections (goose/dbmate markers, golang-migrate .down.sql files,
+flyway undo migrations) are written to down/ below the migrations directory.
+
+The tool's tracking table is read to decide which migrations are applied, and
+joka_migrations is seeded with them, keeping the original applied_at where the
+tool records it. The import refuses to run if joka already has migration files
+or history. The source files and tracking table are left untouched.
+
+The plan is always printed first; use --dry-run to stop there.`,
+		RunE: func(c *cobra.Command, _ []string) error {
+			from, _ := c.Flags().GetString("from")
+			sourceDir, _ := c.Flags().GetString("source")
+			dryRun, _ := c.Flags().GetBool("dry-run")
+			source, err := migrationdomain.ParseImportSource(from)
+			if err != nil {
+				return err
+			}
+			if sourceDir == "" {
+				return fmt.Errorf("--source flag is required")
+			}
+			return migration.RunImportCommand{
+				DB:            dbConn,
+				Driver:        dbDriver,
+				MigrationsDir: migrationsDir,
+				Source:        source,
+				SourceDir:     sourceDir,
+				DryRun:        dryRun,
+				AutoConfirm:   autoConfirm,
+				OutputFormat:  outputFormat,
+				Snapshots:     migrationdomain.SnapshotOptions{Compress: cfg.Snapshots.Compress},
 			}.Execute(c.Context())
 		},
 	}
+	migrateImportCmd.Flags().String("from", "", "Tool to import from: goose, golang-migrate, flyway or dbmate (required)")
+	migrateImportCmd.Flags().String("source", "", "Directory holding the tool's migration files (required)")
+	migrateImportCmd.Flags().Bool("dry-run", false, "Print the import plan without writing files or history")
 
 	entityCmd := &cobra.Command{
 		Use:   "entity",
@@ -282,6 +511,11 @@ stored hash. This is the escape hatch when change detection is in doubt.`,
 		RunE: func(c *cobra.Command, _ []string) error {
 			dryRun, _ := c.Flags().GetBool("dry-run")
 			force, _ := c.Flags().GetBool("force")
+			timeouts, err := resolveTimeouts(c, cfg)
+			if err != nil {
+				return err
+			}
+
 			return entity.RunEntitySyncCommand{
 				DB:           dbConn,
 				Secrets:      secrets.New(cfg.Secrets),
@@ -291,11 +525,13 @@ stored hash. This is the escape hatch when change detection is in doubt.`,
 				OutputFormat: outputFormat,
 				DryRun:       dryRun,
 				Force:        force,
+				Timeouts:     timeouts,
 			}.Execute(c.Context())
 		},
 	}
 	entitySyncCmd.Flags().Bool("dry-run", false, "Preview inserts and before/after changes without applying")
 	entitySyncCmd.Flags().Bool("force", false, "Re-apply updates for every tracked file regardless of hash")
+	addTimeoutFlags(entitySyncCmd)
 
 	entityStatusCmd := &cobra.Command{
 		Use:   "status",
@@ -361,12 +597,15 @@ stored hash. This is the escape hatch when change detection is in doubt.`,
 		Use:   "reset",
 		Short: "Drop everything and re-run init, migrations, data sync, entity sync",
 		RunE: func(c *cobra.Command, _ []string) error {
-			tables := make([]templateinfra.TableConfig, len(cfg.Tables))
-			for i, t := range cfg.Tables {
-				tables[i] = templateinfra.TableConfig{
-					Name:     t.Name,
-					Strategy: t.Strategy,
-				}
+			tables := templateTables(cfg.Tables)
+			snapshots, err := resolveSnapshotOptions(c, cfg)
+			if err != nil {
+				return err
+			}
+
+			timeouts, err := resolveTimeouts(c, cfg)
+			if err != nil {
+				return err
 			}
 
 			return dbtools.RunResetCommand{
@@ -378,15 +617,236 @@ stored hash. This is the escape hatch when change detection is in doubt.`,
 				EntitiesDir:       entitiesDir,
 				Tables:            tables,
 				IgnoreForeignKeys: cfg.IgnoreForeignKeys,
+				BatchSize:         cfg.BatchSize,
 				AutoConfirm:       autoConfirm,
 				OutputFormat:      outputFormat,
+				Timeouts:          timeouts,
+				Profile:           profile,
+				Snapshots:         snapshots,
+				SnapshotRetention: cfg.Snapshots.Retention,
+			}.Execute(c.Context())
+		},
+	}
+	addTimeoutFlags(resetCmd)
+	resetCmd.Flags().String("snapshot", "", "When to capture schema snapshots: per-migration or end-of-batch (default: snapshots.mode from config)")
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Schema documentation commands",
+	}
+
+	schemaDiagramCmd := &cobra.Command{
+		Use:   "diagram",
+		Short: "Render an entity-relationship diagram of the schema",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			formatFlag, _ := c.Flags().GetString("format")
+			format, err := schemadomain.ParseDiagramFormat(formatFlag)
+			if err != nil {
+				return err
+			}
+			index, _ := c.Flags().GetString("index")
+			include, _ := c.Flags().GetStringSlice("tables")
+			exclude, _ := c.Flags().GetStringSlice("exclude")
+			focus, _ := c.Flags().GetString("focus")
+			depth, _ := c.Flags().GetInt("depth")
+			if depth < 0 {
+				return fmt.Errorf("--depth must be 0 or more")
+			}
+			write, _ := c.Flags().GetString("write")
+			return schema.RunDiagramCommand{
+				DB:     dbConn,
+				Driver: dbDriver,
+				Index:  index,
+				Format: format,
+				Filter: schemaapp.TableFilter{
+					Include: include,
+					Exclude: exclude,
+					Focus:   focus,
+					Depth:   depth,
+				},
+				WritePath:    write,
+				OutputFormat: outputFormat,
+			}.Execute(c.Context())
+		},
+	}
+	schemaDiagramCmd.Flags().String("format", "mermaid", "Diagram format: mermaid, dot or plantuml")
+	schemaDiagramCmd.Flags().String("index", "", "Draw the snapshot taken after this migration, or \"latest\" (default: the live database)")
+	schemaDiagramCmd.Flags().StringSlice("tables", nil, "Only include tables matching these patterns (e.g. \"user_*\")")
+	schemaDiagramCmd.Flags().StringSlice("exclude", nil, "Leave out tables matching these patterns")
+	schemaDiagramCmd.Flags().String("focus", "", "Only include tables within --depth foreign key hops of this table")
+	schemaDiagramCmd.Flags().Int("depth", 1, "How many foreign key hops around --focus to include")
+	schemaDiagramCmd.Flags().String("write", "", "Write the diagram to this file instead of stdout")
+
+	schemaDocsCmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate Markdown (and HTML) documentation of each table",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			out, _ := c.Flags().GetString("out")
+			index, _ := c.Flags().GetString("index")
+			html, _ := c.Flags().GetBool("html")
+			return schema.RunDocsCommand{
+				DB:           dbConn,
+				Driver:       dbDriver,
+				Index:        index,
+				OutDir:       out,
+				HTML:         html,
+				OutputFormat: outputFormat,
+			}.Execute(c.Context())
+		},
+	}
+	schemaDocsCmd.Flags().String("out", "docs/schema", "Directory to write the pages to")
+	schemaDocsCmd.Flags().String("index", "", "Document the snapshot taken after this migration, or \"latest\" (default: the live database)")
+	schemaDocsCmd.Flags().Bool("html", false, "Also write HTML pages next to the Markdown")
+
+	schemaGenCmd := &cobra.Command{
+		Use:   "gen",
+		Short: "Generate code from the schema",
+	}
+
+	schemaGenGoCmd := &cobra.Command{
+		Use:   "go",
+		Short: "Generate a Go struct per table",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			out, _ := c.Flags().GetString("out")
+			if !c.Flags().Changed("out") && cfg.Gen.Go.Out != "" {
+				out = cfg.Gen.Go.Out
+			}
+			if out == "" {
+				return fmt.Errorf("--out is required (or set gen.go.out in config)")
+			}
+			pkg, _ := c.Flags().GetString("package")
+			if !c.Flags().Changed("package") {
+				pkg = cfg.Gen.Go.Package
+			}
+			if pkg == "" {
+				pkg = filepath.Base(out)
+			}
+			nullableFlag, _ := c.Flags().GetString("nullable")
+			if !c.Flags().Changed("nullable") && cfg.Gen.Go.Nullable != "" {
+				nullableFlag = cfg.Gen.Go.Nullable
+			}
+			nullable, err := schemadomain.ParseNullableStyle(nullableFlag)
+			if err != nil {
+				return err
+			}
+			index, _ := c.Flags().GetString("index")
+			check, _ := c.Flags().GetBool("check")
+			return schema.RunGenGoCommand{
+				DB:           dbConn,
+				Driver:       dbDriver,
+				Index:        index,
+				OutDir:       out,
+				Package:      pkg,
+				Nullable:     nullable,
+				Check:        check,
+				OutputFormat: outputFormat,
+			}.Execute(c.Context())
+		},
+	}
+	schemaGenGoCmd.Flags().String("out", "", "Directory to write schema_gen.go to (default: gen.go.out from config)")
+	schemaGenGoCmd.Flags().String("package", "", "Package name (default: gen.go.package from config, or the --out directory's name)")
+	schemaGenGoCmd.Flags().String("nullable", "sql", "Type of nullable columns: sql (sql.NullString, ...), pointer or generic (sql.Null[T])")
+	schemaGenGoCmd.Flags().String("index", "", "Generate from the snapshot taken after this migration, or \"latest\" (default: the live database)")
+	schemaGenGoCmd.Flags().Bool("check", false, "Fail if the generated file is missing or out of date instead of writing it")
+
+	schemaDiffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the schemas and migration histories of two databases",
+		Long: `Compare the schemas and migration histories of two databases.
+
+Each side is a profile (--from-profile, --to-profile) or a DSN (--from, --to);
+a side given neither uses the selected connection (--profile, or the base
+config). Tables are compared the same way as migrate verify, so MySQL's
+AUTO_INCREMENT counters are ignored, and joka_migrations is read on both sides
+to show which migrations only one of them applied. Both databases must use the
+same driver. Exits non-zero when they differ.`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			from, err := resolveDiffSide(c, "from")
+			if err != nil {
+				return err
+			}
+			to, err := resolveDiffSide(c, "to")
+			if err != nil {
+				return err
+			}
+			return schema.RunDiffCommand{From: from, To: to, OutputFormat: outputFormat}.Execute(c.Context())
+		},
+	}
+	schemaDiffCmd.Flags().String("from", "", "DSN of the database to compare from")
+	schemaDiffCmd.Flags().String("to", "", "DSN of the database to compare to")
+	schemaDiffCmd.Flags().String("from-profile", "", "Profile whose connection to compare from")
+	schemaDiffCmd.Flags().String("to-profile", "", "Profile whose connection to compare to")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show migration, entity and data status across profiles",
+		Long: `Show migration, entity and data status across profiles.
+
+With --all-profiles every profile in .jokarc.yaml is checked, with --profiles
+only the named ones, and otherwise just the selected connection. Each profile's
+connection (including secret sources) is resolved and read concurrently, each
+within --timeout. The output is a matrix of migration index by profile,
+followed by each profile's entity file statuses and how many template tables
+hold as many rows as their templates. Profiles that can't be reached are
+reported without failing the command.`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			all, _ := c.Flags().GetBool("all-profiles")
+			names, _ := c.Flags().GetStringSlice("profiles")
+			timeout, _ := c.Flags().GetDuration("timeout")
+			if all && len(names) > 0 {
+				return fmt.Errorf("use either --all-profiles or --profiles, not both")
+			}
+			if timeout <= 0 {
+				return fmt.Errorf("--timeout must be positive")
+			}
+
+			switch {
+			case all:
+				base, err := config.Load("")
+				if err != nil {
+					return err
+				}
+				if len(base.Profiles) == 0 {
+					return fmt.Errorf("no profiles defined in .jokarc.yaml")
+				}
+				for name := range base.Profiles {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+			case len(names) == 0:
+				names = []string{profile}
+			}
+
+			envs := make([]dbtools.StatusEnvironment, len(names))
+			for i, name := range names {
+				env, err := statusEnvironment(c, name)
+				if err != nil {
+					return err
+				}
+				envs[i] = env
+			}
+
+			return dbtools.RunStatusCommand{
+				Environments: envs,
+				Timeout:      timeout,
+				OutputFormat: outputFormat,
 			}.Execute(c.Context())
 		},
 	}
+	statusCmd.Flags().Bool("all-profiles", false, "Check every profile in .jokarc.yaml")
+	statusCmd.Flags().StringSlice("profiles", nil, "Check these profiles (comma-separated)")
+	statusCmd.Flags().Duration("timeout", 10*time.Second, "Give up on a profile that hasn't answered after this long")
 
-	migrateCmd.AddCommand(migrateUpCmd, migrateStatusCmd, migrateSnapshotCmd, migrateConsolidateCmd, migrateVerifyCmd)
-	dataCmd.AddCommand(dataSyncCmd)
+	migrateCmd.AddCommand(migrateUpCmd, migrateStatusCmd, migrateSnapshotCmd, migrateConsolidateCmd, migrateVerifyCmd, migrateImportCmd)
+	dataCmd.AddCommand(dataSyncCmd, dataStatusCmd)
 	entityCmd.AddCommand(entitySyncCmd, entityStatusCmd, entityReimportCmd, entityUpdateCmd)
+	schemaGenCmd.AddCommand(schemaGenGoCmd)
+	schemaCmd.AddCommand(schemaDiagramCmd, schemaDocsCmd, schemaGenCmd, schemaDiffCmd)
 	versionCmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print the version number",
@@ -399,7 +859,7 @@ stored hash. This is the escape hatch when change detection is in doubt.`,
 		},
 	}
 
-	root.AddCommand(initCmd, makeCmd, migrateCmd, dataCmd, entityCmd, dropCmd, resetCmd, unlockCmd, versionCmd)
+	root.AddCommand(initCmd, makeCmd, migrateCmd, dataCmd, entityCmd, schemaCmd, statusCmd, dropCmd, resetCmd, unlockCmd, versionCmd)
 
 	if err := root.Execute(); err != nil {
 		if outputFormat == shared.OutputJSON {
@@ -411,6 +871,203 @@ stored hash. This is the escape hatch when change detection is in doubt.`,
 	}
 }
 
+// addTimeoutFlags registers the session timeout flags shared by every command
+// that opens a write transaction.
+func addTimeoutFlags(c *cobra.Command) {
+	c.Flags().Duration("lock-timeout", 0, "Postgres lock_timeout for the transaction (e.g. 15s)")
+	c.Flags().Duration("statement-timeout", 0, "Postgres statement_timeout for the transaction (e.g. 5m)")
+	c.Flags().Duration("lock-wait-timeout", 0, "MySQL lock_wait_timeout for the session (e.g. 30s)")
+	c.Flags().Duration("max-execution-time", 0, "MySQL max_execution_time for the session (e.g. 10s)")
+}
+
+// resolveTimeouts returns the session timeouts for c: the .jokarc.yaml (and
+// profile) values, overridden by any timeout flags set on the command line. A
+// flag that is set must be positive.
+func resolveTimeouts(c *cobra.Command, cfg *config.Config) (jokadb.Timeouts, error) {
+	t := jokadb.Timeouts{
+		LockTimeout:      cfg.Timeouts.LockTimeout,
+		StatementTimeout: cfg.Timeouts.StatementTimeout,
+		LockWaitTimeout:  cfg.Timeouts.LockWaitTimeout,
+		MaxExecutionTime: cfg.Timeouts.MaxExecutionTime,
+	}
+	if c.Flags().Changed("lock-timeout") {
+		t.LockTimeout, _ = c.Flags().GetDuration("lock-timeout")
+		if t.LockTimeout <= 0 {
+			return t, fmt.Errorf("--lock-timeout must be positive, got %s", t.LockTimeout)
+		}
+	}
+	if c.Flags().Changed("statement-timeout") {
+		t.StatementTimeout, _ = c.Flags().GetDuration("statement-timeout")
+		if t.StatementTimeout <= 0 {
+			return t, fmt.Errorf("--statement-timeout must be positive, got %s", t.StatementTimeout)
+		}
+	}
+	if c.Flags().Changed("lock-wait-timeout") {
+		t.LockWaitTimeout, _ = c.Flags().GetDuration("lock-wait-timeout")
+		if t.LockWaitTimeout <= 0 {
+			return t, fmt.Errorf("--lock-wait-timeout must be positive, got %s", t.LockWaitTimeout)
+		}
+	}
+	if c.Flags().Changed("max-execution-time") {
+		t.MaxExecutionTime, _ = c.Flags().GetDuration("max-execution-time")
+		if t.MaxExecutionTime <= 0 {
+			return t, fmt.Errorf("--max-execution-time must be positive, got %s", t.MaxExecutionTime)
+		}
+	}
+	return t, t.Validate()
+}
+
+// resolveSnapshotOptions returns the snapshot settings for c: the
+// .jokarc.yaml (and profile) values, with --snapshot overriding the mode.
+func resolveSnapshotOptions(c *cobra.Command, cfg *config.Config) (migrationdomain.SnapshotOptions, error) {
+	mode := cfg.Snapshots.Mode
+	if c.Flags().Changed("snapshot") {
+		mode, _ = c.Flags().GetString("snapshot")
+	}
+	parsed, err := migrationdomain.ParseSnapshotMode(mode)
+	if err != nil {
+		return migrationdomain.SnapshotOptions{}, err
+	}
+	return migrationdomain.SnapshotOptions{Compress: cfg.Snapshots.Compress, Mode: parsed}, nil
+}
+
+// makeDriver resolves the dialect for `joka make`, which runs without a
+// database connection: --driver, then the configured connection driver (or
+// literal URL), then the DATABASE_URL scheme, defaulting to MySQL.
+func makeDriver(c *cobra.Command, cfg *config.Config) (jokadb.Driver, error) {
+	name, _ := c.Flags().GetString("driver")
+	if name == "" && cfg.Connection != nil {
+		name = cfg.Connection.Driver
+	}
+	switch name {
+	case "postgres", "postgresql":
+		return jokadb.Postgres, nil
+	case "mysql":
+		return jokadb.MySQL, nil
+	case "":
+		if cfg.Connection != nil && cfg.Connection.URL != "" {
+			return jokadb.DetectDriver(cfg.Connection.URL), nil
+		}
+		return jokadb.DetectDriver(os.Getenv("DATABASE_URL")), nil
+	default:
+		return jokadb.MySQL, fmt.Errorf("unknown driver %q (expected mysql or postgres)", name)
+	}
+}
+
+// resolveShadowDSN turns a --shadow value into a DSN. Values that look like a
+// DSN ("/" or "@") are used as-is; anything else names a .jokarc.yaml profile
+// whose connection is resolved. The profile must set its own connection:
+// otherwise it would inherit the base connection or DATABASE_URL, which may
+// well be the target, and the shadow is dropped.
+func resolveShadowDSN(c *cobra.Command, value string) (string, error) {
+	if strings.ContainsAny(value, "/@") {
+		return value, nil
+	}
+	shadowCfg, err := config.Load(value)
+	if err != nil {
+		return "", fmt.Errorf("loading shadow profile %q: %w", value, err)
+	}
+	base, err := config.Load("")
+	if err != nil {
+		return "", err
+	}
+	if base.Profiles[value].Connection == nil {
+		return "", fmt.Errorf("shadow profile %q has no connection of its own", value)
+	}
+	return connection.Resolve(c.Context(), shadowCfg.Connection, nil)
+}
+
+// resolveDiffSide returns one side of schema diff: the DSN from --<side>, the
+// connection of --<side>-profile, or else the selected connection.
+func resolveDiffSide(c *cobra.Command, side string) (schema.DiffSide, error) {
+	dsn, _ := c.Flags().GetString(side)
+	profile, _ := c.Flags().GetString(side + "-profile")
+	if dsn != "" && profile != "" {
+		return schema.DiffSide{}, fmt.Errorf("use either --%s or --%s-profile, not both", side, side)
+	}
+	if dsn != "" {
+		return schema.DiffSide{DSN: dsn}, nil
+	}
+
+	if profile == "" {
+		profile, _ = c.Flags().GetString("profile")
+	}
+	sideCfg, err := config.Load(profile)
+	if err != nil {
+		return schema.DiffSide{}, fmt.Errorf("loading %s profile %q: %w", side, profile, err)
+	}
+	dsn, err = connection.Resolve(c.Context(), sideCfg.Connection, nil)
+	if err != nil {
+		return schema.DiffSide{}, err
+	}
+	return schema.DiffSide{Profile: profile, DSN: dsn}, nil
+}
+
+// statusEnvironment describes one profile for joka status ("" is the base
+// config). Directories set on the command line apply to every profile;
+// otherwise each profile's own are used.
+func statusEnvironment(c *cobra.Command, name string) (dbtools.StatusEnvironment, error) {
+	envCfg, err := config.Load(name)
+	if err != nil {
+		return dbtools.StatusEnvironment{}, err
+	}
+
+	dir := func(flag, configured string) string {
+		value, _ := c.Flags().GetString(flag)
+		if !c.Flags().Changed(flag) && configured != "" {
+			value = configured
+		}
+		return value
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	return dbtools.StatusEnvironment{
+		Name: name,
+		ResolveDSN: func(ctx context.Context) (string, error) {
+			return connection.Resolve(ctx, envCfg.Connection, nil)
+		},
+		MigrationsDir: dir("migrations", envCfg.Migrations),
+		TemplatesDir:  dir("templates", envCfg.Templates),
+		EntitiesDir:   dir("entities", envCfg.Entities),
+		Tables:        templateTables(envCfg.Tables),
+	}, nil
+}
+
+// templateTables converts the `tables:` config to what data sync reads.
+func templateTables(tables []config.TableConfig) []templateinfra.TableConfig {
+	out := make([]templateinfra.TableConfig, len(tables))
+	for i, t := range tables {
+		out[i] = templateinfra.TableConfig{
+			Name:      t.Name,
+			Strategy:  t.Strategy,
+			Key:       t.Key,
+			NullToken: t.NullToken,
+			Columns:   columnMappings(t.Columns),
+			Truncate: templatedomain.TruncateOptions{
+				ContinueIdentity: t.Truncate.RestartIdentity != nil && !*t.Truncate.RestartIdentity,
+				Cascade:          t.Truncate.Cascade,
+			},
+		}
+	}
+	return out
+}
+
+// columnMappings converts a table's `columns:` config to column mappings,
+// sorted by source column so they apply in a stable order.
+func columnMappings(columns map[string]config.ColumnConfig) []templatedomain.ColumnMapping {
+	if len(columns) == 0 {
+		return nil
+	}
+	out := make([]templatedomain.ColumnMapping, 0, len(columns))
+	for source, c := range columns {
+		out = append(out, templatedomain.ColumnMapping{Source: source, Column: c.Column, Default: c.Default, Ignore: c.Ignore, Value: c.Value})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}
+
 // loadEnv loads environment variables from the given .env file path. If the
 // path is the default ".env" and the file doesn't exist, it silently continues.
 func loadEnv(envFile string) error {